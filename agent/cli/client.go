@@ -0,0 +1,153 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Juniper/go-netconf/netconf"
+)
+
+const (
+	baseNamespace   = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	notifNamespace  = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+	xpathCapability = "urn:ietf:params:netconf:capability:xpath:1.0"
+)
+
+// session is the NETCONF session of the CLI, opened by the first command
+// that talks to the server
+var session *netconf.Session
+
+func connect() (*netconf.Session, error) {
+	if session != nil {
+		return session, nil
+	}
+
+	var s *netconf.Session
+	var err error
+	switch *transport {
+	case "ssh":
+		s, err = dialSSH()
+	case "tls":
+		s, err = dialTLS()
+	default:
+		err = usageErrorf("unknown transport '%s'", *transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	session = s
+	return s, nil
+}
+
+// dialError is a failure to connect, unless the server did not answer in
+// time
+func dialError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return errTimeout
+	}
+	return &connectError{err}
+}
+
+// withPort adds the default port of the transport to a host without one
+func withPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func timeoutDuration() time.Duration {
+	return time.Duration(*timeout) * time.Second
+}
+
+func disconnect() {
+	if session != nil {
+		session.Close()
+		session = nil
+	}
+}
+
+// withTimeout runs f for at most the timeout of the CLI
+func withTimeout(f func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeoutDuration()):
+		return errTimeout
+	}
+}
+
+// exec sends one RPC and returns its reply. A session that timed out is
+// dropped, its reply could still arrive.
+func exec(rpc string) (*netconf.RPCReply, error) {
+	s, err := connect()
+	if err != nil {
+		return nil, err
+	}
+
+	var reply *netconf.RPCReply
+	err = withTimeout(func() (err error) {
+		reply, err = s.Exec(netconf.RawMethod(rpc))
+		return err
+	})
+	if err == errTimeout {
+		disconnect()
+	}
+	return reply, err
+}
+
+// hasCapability tells whether the server announced the capability
+func hasCapability(capability string) bool {
+	for _, c := range session.ServerCapabilities {
+		if strings.TrimSpace(c) == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// moduleNamespaces maps the YANG modules announced by the server to their
+// namespaces
+func moduleNamespaces() map[string]string {
+	namespaces := make(map[string]string)
+	for _, c := range session.ServerCapabilities {
+		parts := strings.SplitN(strings.TrimSpace(c), "?", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		query, err := url.ParseQuery(parts[1])
+		if err != nil {
+			continue
+		}
+		if module := query.Get("module"); module != "" {
+			namespaces[module] = parts[0]
+		}
+	}
+	return namespaces
+}
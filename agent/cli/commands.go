@@ -0,0 +1,417 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/Juniper/go-netconf/netconf"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/bundle"
+)
+
+var okReply = regexp.MustCompile(`^<ok(\s[^>]*)?(/>|></ok>)$`)
+
+// parseFlags parses the flags of a command, which takes at most maxArgs
+// arguments, any number if maxArgs is negative
+func parseFlags(fs *flag.FlagSet, args []string, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return &usageError{err.Error()}
+	}
+	if maxArgs >= 0 && fs.NArg() > maxArgs {
+		return usageErrorf("unexpected argument '%s'", fs.Arg(maxArgs))
+	}
+	return nil
+}
+
+// printReply prints the data of a reply in the output format, "ok" if it
+// has none. The root wraps replies with more than one element.
+func printReply(reply *netconf.RPCReply, root string) error {
+	data := strings.TrimSpace(reply.Data)
+	if data == "" || okReply.MatchString(data) {
+		fmt.Fprintln(stdout, "ok")
+		return nil
+	}
+	if root != "" {
+		data = "<" + root + ">" + data + "</" + root + ">"
+	}
+	return printXML(data)
+}
+
+func execAndPrint(rpc, root string) error {
+	reply, err := exec(rpc)
+	if err != nil {
+		return err
+	}
+	return printReply(reply, root)
+}
+
+func datastoreFlag(fs *flag.FlagSet, name string) *string {
+	return fs.String(name, "running", "Datastore: running or candidate")
+}
+
+func cmdGet(args []string) error {
+	fs := newFlagSet("get")
+	var xpaths listFlag
+	fs.Var(&xpaths, "x", "Xpath of the data to get, can be given more than once")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	filter, err := filterXML(xpaths)
+	if err != nil {
+		return err
+	}
+	return execAndPrint("<get>"+filter+"</get>", "")
+}
+
+func cmdGetConfig(args []string) error {
+	fs := newFlagSet("get-config")
+	source := datastoreFlag(fs, "source")
+	var xpaths listFlag
+	fs.Var(&xpaths, "x", "Xpath of the data to get, can be given more than once")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	filter, err := filterXML(xpaths)
+	if err != nil {
+		return err
+	}
+	return execAndPrint(fmt.Sprintf("<get-config><source><%s/></source>%s</get-config>", *source, filter), "")
+}
+
+func cmdEdit(args []string) error {
+	fs := newFlagSet("edit")
+	target := datastoreFlag(fs, "target")
+	file := fs.String("file", "", "File with the XML configuration to merge")
+	dryRun := fs.Bool("dry-run", false, "Only test the edit, see the audit records for the planned SBI calls")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if (*file == "") == (fs.NArg() == 0) {
+		return usageErrorf("either -file or path=value arguments are needed")
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+
+	var config string
+	if *file != "" {
+		data, err := ioutil.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		config = string(data)
+	} else {
+		root := &xmlNode{namespace: baseNamespace}
+		namespaces := moduleNamespaces()
+		for _, arg := range fs.Args() {
+			path, value, err := splitAssignment(arg)
+			if err != nil {
+				return err
+			}
+			if err := root.add(path, namespaces, &value, ""); err != nil {
+				return err
+			}
+		}
+		config = root.xml()
+	}
+	return execAndPrint(editRPC(*target, config, *dryRun), "")
+}
+
+// editRPC returns the edit-config of the configuration, which is only
+// tested in a dry run
+func editRPC(target, config string, dryRun bool) string {
+	testOption := ""
+	if dryRun {
+		testOption = "<test-option>test-only</test-option>"
+	}
+	return fmt.Sprintf("<edit-config><target><%s/></target>%s<config>%s</config></edit-config>", target, testOption, config)
+}
+
+func cmdDelete(args []string) error {
+	fs := newFlagSet("delete")
+	target := datastoreFlag(fs, "target")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf("no path to delete")
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	root := &xmlNode{namespace: baseNamespace}
+	namespaces := moduleNamespaces()
+	for _, path := range fs.Args() {
+		if err := root.add(path, namespaces, nil, "delete"); err != nil {
+			return err
+		}
+	}
+	return execAndPrint(editRPC(*target, root.xml(), false), "")
+}
+
+func cmdLock(args []string) error {
+	return lock("lock", args)
+}
+
+func cmdUnlock(args []string) error {
+	return lock("unlock", args)
+}
+
+func lock(operation string, args []string) error {
+	fs := newFlagSet(operation)
+	target := datastoreFlag(fs, "target")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	return execAndPrint(fmt.Sprintf("<%s><target><%s/></target></%s>", operation, *target, operation), "")
+}
+
+func cmdCommit(args []string) error {
+	fs := newFlagSet("commit")
+	confirmed := fs.Bool("confirmed", false, "Roll back unless confirmed by another commit in time")
+	confirmTimeout := fs.Int("confirm-timeout", 0, "Seconds until a confirmed commit is rolled back, 600 by default")
+	persist := fs.String("persist", "", "Identifier that lets other sessions confirm the commit")
+	persistID := fs.String("persist-id", "", "Identifier of the confirmed commit to confirm")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("<commit>")
+	if *confirmed {
+		b.WriteString("<confirmed/>")
+	}
+	if *confirmTimeout > 0 {
+		fmt.Fprintf(&b, "<confirm-timeout>%d</confirm-timeout>", *confirmTimeout)
+	}
+	if *persist != "" {
+		b.WriteString("<persist>" + escape(*persist) + "</persist>")
+	}
+	if *persistID != "" {
+		b.WriteString("<persist-id>" + escape(*persistID) + "</persist-id>")
+	}
+	b.WriteString("</commit>")
+	return execAndPrint(b.String(), "")
+}
+
+func cmdRPC(args []string) error {
+	fs := newFlagSet("rpc")
+	file := fs.String("file", "", "File with the XML of the RPC")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if (*file == "") == (fs.NArg() == 0) {
+		return usageErrorf("either -file or the name of the RPC is needed")
+	}
+
+	if *file != "" {
+		data, err := ioutil.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		return execAndPrint(string(data), "output")
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	name, ns := fs.Arg(0), *namespace
+	if i := strings.Index(name, ":"); i >= 0 {
+		var ok bool
+		if ns, ok = moduleNamespaces()[name[:i]]; !ok {
+			return usageErrorf("unknown module '%s'", name[:i])
+		}
+		name = name[i+1:]
+	}
+
+	var b strings.Builder
+	b.WriteString("<" + name + ` xmlns="` + escape(ns) + `">`)
+	for _, arg := range fs.Args()[1:] {
+		leaf, value, err := splitAssignment(arg)
+		if err != nil {
+			return err
+		}
+		b.WriteString("<" + leaf + ">" + escape(value) + "</" + leaf + ">")
+	}
+	b.WriteString("</" + name + ">")
+	return execAndPrint(b.String(), "output")
+}
+
+func cmdSubscribe(args []string) error {
+	fs := newFlagSet("subscribe")
+	stream := fs.String("stream", "NETCONF", "Event stream")
+	count := fs.Int("count", 0, "Stop after this many notifications, never if 0")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	rpc := `<create-subscription xmlns="` + notifNamespace + `"><stream>` + escape(*stream) + "</stream></create-subscription>"
+	if _, err := exec(rpc); err != nil {
+		return err
+	}
+
+	// notifications come without a deadline
+	for n := 0; *count == 0 || n < *count; n++ {
+		msg, err := session.Transport.Receive()
+		if err != nil {
+			return fmt.Errorf("receiving notifications failed: %v", err)
+		}
+		if err := printXML(string(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cmdCapabilities(args []string) error {
+	fs := newFlagSet("capabilities")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	s, err := connect()
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "<capabilities><session-id>%d</session-id>", s.SessionID)
+	for _, c := range s.ServerCapabilities {
+		b.WriteString("<capability>" + escape(strings.TrimSpace(c)) + "</capability>")
+	}
+	b.WriteString("</capabilities>")
+	return printXML(b.String())
+}
+
+// cmdExport writes the configuration bundle of the RIC to the file, or to
+// stdout if there is none
+func cmdExport(args []string) error {
+	fs := newFlagSet("export")
+	format := fs.String("format", bundle.JSON, "Bundle format: json or xml")
+	modules := fs.String("modules", "", "Comma separated modules to export, all if empty")
+	file := fs.String("file", "", "File to write the bundle to")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	rpc := fmt.Sprintf(`<export-config xmlns="%s"><encoding>%s</encoding>%s</export-config>`,
+		escape(*namespace), escape(*format), listElement("modules", *modules))
+	reply, err := exec(rpc)
+	if err != nil {
+		return err
+	}
+
+	var out struct {
+		Bundle string `xml:"bundle"`
+	}
+	if err := xml.Unmarshal([]byte("<output>"+reply.Data+"</output>"), &out); err != nil {
+		return err
+	}
+	if *file == "" {
+		fmt.Fprintln(stdout, out.Bundle)
+		return nil
+	}
+	return ioutil.WriteFile(*file, []byte(out.Bundle+"\n"), 0644)
+}
+
+// cmdImport imports the configuration bundle of the file and prints the
+// changes it makes, or would make in the dry-run and diff modes
+func cmdImport(args []string) error {
+	fs := newFlagSet("import")
+	mode := fs.String("mode", "dry-run", "Import mode: dry-run, diff or apply")
+	modules := fs.String("modules", "", "Comma separated modules to import, all of the bundle if empty")
+	xapps := fs.String("xapps", "", "Comma separated xApps to import")
+	file := fs.String("file", "", "File with the bundle")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *file == "" {
+		return usageErrorf("configuration bundle missing, see -file")
+	}
+
+	data, err := ioutil.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if _, err := bundle.Decode(data); err != nil {
+		return err
+	}
+
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, data)
+	rpc := fmt.Sprintf(`<import-config xmlns="%s"><bundle>%s</bundle><mode>%s</mode>%s%s</import-config>`,
+		escape(*namespace), escaped.String(), escape(*mode), listElement("modules", *modules), listElement("xapps", *xapps))
+	reply, err := exec(rpc)
+	if err != nil {
+		return err
+	}
+
+	var out struct {
+		Diff     string `xml:"diff"`
+		Changes  int    `xml:"changes"`
+		SBICalls string `xml:"sbi-calls"`
+	}
+	if err := xml.Unmarshal([]byte("<output>"+reply.Data+"</output>"), &out); err != nil {
+		return err
+	}
+	if out.Diff != "" {
+		fmt.Fprintln(stdout, out.Diff)
+	}
+	fmt.Fprintf(stdout, "%d changes (%s)\n", out.Changes, *mode)
+	if out.SBICalls != "" {
+		fmt.Fprintln(stdout, "Planned SBI calls:")
+		fmt.Fprintln(stdout, out.SBICalls)
+	}
+	return nil
+}
+
+// listElement returns the comma separated list as an RPC input leaf with
+// space separated items, nothing if the list is empty
+func listElement(name, list string) string {
+	items := strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(items) == 0 {
+		return ""
+	}
+	return "<" + name + ">" + escape(strings.Join(items, " ")) + "</" + name + ">"
+}
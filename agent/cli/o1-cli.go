@@ -1,132 +1,270 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// o1-cli is a NETCONF client for the O1 interface of the RIC:
+//
+//	o1-cli [global flags] <command> [command flags] [arguments]
+//
+// Hosts and credentials come from the flags or from a profile of the
+// config file. The exit code tells scripts how a command ended.
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/Juniper/go-netconf/netconf"
-	xj "github.com/basgys/goxml2json"
-	"golang.org/x/crypto/ssh"
-	"io/ioutil"
-	"log"
+	"io"
 	"os"
 	"strings"
-	"time"
+
+	"github.com/Juniper/go-netconf/netconf"
+)
+
+// Exit codes
+const (
+	exitOK       = 0
+	exitRPCError = 1 // the server answered with an rpc-error
+	exitUsage    = 2
+	exitConnect  = 3 // the server could not be reached or refused the login
+	exitTimeout  = 4
+	exitFailure  = 5 // anything else, e.g. an unreadable file
 )
 
 var (
-	host      = flag.String("host", "localhost", "Hostname")
-	username  = flag.String("username", "netconf", "Username")
-	passwd    = flag.String("password", "netconf", "Password")
-	source    = flag.String("source", "running", "Source datastore")
-	target    = flag.String("target", "running", "Target datastore")
-	subtree   = flag.String("subtree", "ric", "Subtree or module to select")
-	namespace = flag.String("namespace", "urn:o-ran:ric:xapp-desc:1.0", "XML namespace")
-	file      = flag.String("file", "", "Configuration file")
-	action    = flag.String("action", "get", "Netconf command: get or edit")
-	timeout   = flag.Int("timeout", 30, "Timeout")
-
-	getStateXml   = "<get><filter type=\"subtree\"><ric xmlns=\"%s\"></ric></filter></get>"
-	getConfigXml  = "<get-config><source><%s/></source><filter type=\"subtree\"><%s/></filter></get-config>"
-	editConfigXml = "<edit-config><target><%s/></target><config>%s</config></edit-config>"
+	configFile  = flag.String("config", "", "Config file with the profiles, $O1_CLI_CONFIG or ~/.o1-cli.yaml by default")
+	profileName = flag.String("profile", "", "Profile of the config file to use, $O1_CLI_PROFILE or its default profile by default")
+	host        = flag.String("host", "localhost", "Hostname, with an optional port, 830 for SSH and 6513 for TLS by default")
+	transport   = flag.String("transport", "ssh", "Transport of NETCONF: ssh or tls")
+	username    = flag.String("username", "netconf", "SSH username, the password comes from $O1_CLI_PASSWORD or a prompt")
+	keyFiles    = flag.String("key", "", "Comma separated SSH private key files, ~/.ssh/id_* by default")
+	useAgent    = flag.Bool("agent", true, "Use the keys of the SSH agent at $SSH_AUTH_SOCK")
+	knownHosts  = flag.String("known-hosts", "", "SSH known hosts file, ~/.ssh/known_hosts by default; unknown hosts are added to it")
+	certFile    = flag.String("cert", "", "Client certificate file for TLS")
+	certKeyFile = flag.String("cert-key", "", "Private key file of the client certificate")
+	caFile      = flag.String("ca", "", "CA certificates file to verify the TLS server with, the system ones by default")
+	insecure    = flag.Bool("insecure", false, "Do not verify the SSH host key or the TLS server certificate")
+	namespace   = flag.String("namespace", "urn:o-ran:ric:xapp-desc:1.0", "XML namespace of the RPCs")
+	timeout     = flag.Int("timeout", 30, "Timeout in seconds")
+	output      = flag.String("o", "json", "Output format: json, xml, yaml or table")
+
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
 )
 
-func main() {
-	defer func() { // catch or finally
-		if err := recover(); err != nil { //catch
-			fmt.Fprintf(os.Stderr, "Something went wrong: %v\n", err)
-			os.Exit(1)
-		}
-	}()
+// command is a subcommand of the CLI. Its run function parses the command
+// flags itself.
+type command struct {
+	name string
+	args string
+	help string
+	run  func(args []string) error
+}
+
+var commands []*command
 
-	if flag.Parse(); flag.Parsed() == false {
-		log.Fatal("Syntax error!")
-		return
+func init() {
+	commands = []*command{
+		{"get", "[-x xpath]...", "Get configuration and state data", cmdGet},
+		{"get-config", "[-source running|candidate] [-x xpath]...", "Get configuration data", cmdGetConfig},
+		{"edit", "[-target running|candidate] [-file file | xpath=value...]", "Edit configuration data", cmdEdit},
+		{"delete", "[-target running|candidate] xpath...", "Delete configuration data", cmdDelete},
+		{"lock", "[-target running|candidate]", "Lock a datastore until the session ends", cmdLock},
+		{"unlock", "[-target running|candidate]", "Unlock a datastore", cmdUnlock},
+		{"commit", "[-confirmed] [-confirm-timeout seconds] [-persist id] [-persist-id id]", "Commit the candidate datastore", cmdCommit},
+		{"rpc", "[-file file | name [leaf=value]...]", "Call an RPC of the RIC", cmdRPC},
+		{"subscribe", "[-stream stream] [-count n]", "Print notifications as they arrive", cmdSubscribe},
+		{"capabilities", "", "List the capabilities of the server", cmdCapabilities},
+		{"xapp", "deploy|undeploy|upgrade|list|status|config get|config set ...", "Manage xApps without writing XML", cmdXapp},
+		{"export", "[-format json|xml] [-modules m,...] [-file file]", "Export the configuration of the RIC as a bundle", cmdExport},
+		{"import", "[-mode dry-run|diff|apply] [-modules m,...] [-xapps x,...] -file file", "Import a configuration bundle", cmdImport},
+		{"shell", "[-target running|candidate]", "Interactive shell on one session, with completion of paths and keys", cmdShell},
+		{"help", "[command]", "Describe the commands", cmdHelp},
 	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	os.Exit(run(flag.Args()))
+}
 
-	session := startSSHSession()
-	if session == nil {
-		return
+// run executes one command line and returns its exit code
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitUsage
 	}
-	defer session.Close()
 
-	switch *action {
-	case "get":
-		getStatus(session, getStateXml)
-	case "get-config":
-		getConfig(session, getConfigXml)
-	case "edit":
-		editConfig(session)
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "o1-cli: unknown command '%s'\n", args[0])
+		usage()
+		return exitUsage
+	}
+	if err := loadProfile(); err != nil {
+		fmt.Fprintf(stderr, "o1-cli: %v\n", err)
+		return exitUsage
 	}
-}
 
-func getStatus(session *netconf.Session, cmdXml string) {
-	cmd := netconf.RawMethod(fmt.Sprintf(cmdXml, *namespace))
-	reply, err := session.Exec(cmd)
+	err := cmd.run(args[1:])
+	disconnect()
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
 	if err != nil {
-		log.Fatal(err)
-		return
+		fmt.Fprintf(stderr, "o1-cli: %s: %s\n", cmd.name, errorText(err))
 	}
-	displayReply(reply.RawReply)
+	return exitCode(err)
 }
 
-func getConfig(session *netconf.Session, cmdXml string) {
-	cmd := netconf.RawMethod(fmt.Sprintf(cmdXml, *source, *subtree))
-	reply, err := session.Exec(cmd)
-	if err != nil {
-		log.Fatal(err)
-		return
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
 	}
-	displayReply(reply.RawReply)
+	return nil
 }
 
-func editConfig(session *netconf.Session) {
-	if *file == "" {
-		log.Fatal("Configuration file missing!")
-		return
+func usage() {
+	fmt.Fprintf(stderr, "Usage: o1-cli [global flags] <command> [command flags] [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(stderr, "  %-13s %s\n", c.name, c.help)
 	}
+	fmt.Fprintf(stderr, "\nGlobal flags:\n")
+	flag.CommandLine.SetOutput(stderr)
+	flag.PrintDefaults()
+}
 
-	if data, err := ioutil.ReadFile(*file); err == nil {
-		cmd := netconf.RawMethod(fmt.Sprintf(editConfigXml, *target, data))
-		reply, err := session.Exec(cmd)
-		if err != nil {
-			log.Fatal(err)
-			return
-		}
-		displayReply(reply.RawReply)
+func cmdHelp(args []string) error {
+	if len(args) == 0 {
+		usage()
+		return nil
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		return usageErrorf("unknown command '%s'", args[0])
+	}
+	if cmd.name == "help" {
+		fmt.Fprintf(stderr, "Usage: o1-cli help %s\n\n%s\n", cmd.args, cmd.help)
+		return nil
+	}
+	// the flag set of the command prints its usage, xapp passes the
+	// arguments on to its commands
+	if err := cmd.run(append(args[1:len(args):len(args)], "-h")); err != flag.ErrHelp {
+		return err
 	}
+	return nil
 }
 
-func startSSHSession() *netconf.Session {
-	sshConfig := &ssh.ClientConfig{
-		User:            *username,
-		Auth:            []ssh.AuthMethod{ssh.Password(*passwd)},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         time.Duration(*timeout) * time.Second,
-	}
+// usageError is a mistake on the command line
+type usageError struct {
+	msg string
+}
 
-	session, err := netconf.DialSSH(*host, sshConfig)
-	if err != nil {
-		log.Fatal(err)
-		return nil
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// connectError is a failure to reach or log in to the server
+type connectError struct {
+	err error
+}
+
+func (e *connectError) Error() string {
+	return fmt.Sprintf("connecting to %s failed: %v", *host, e.err)
+}
+
+func (e *connectError) Unwrap() error {
+	return e.err
+}
+
+var errTimeout = errors.New("timed out")
+
+func exitCode(err error) int {
+	var rpcErr *netconf.RPCError
+	var uErr *usageError
+	var cErr *connectError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &rpcErr):
+		return exitRPCError
+	case errors.As(err, &uErr):
+		return exitUsage
+	case errors.As(err, &cErr):
+		return exitConnect
+	case errors.Is(err, errTimeout):
+		return exitTimeout
 	}
-	return session
+	return exitFailure
 }
 
-func prettyPrint(b string) string {
-	var out bytes.Buffer
-	if err := json.Indent(&out, []byte(b), "", "  "); err == nil {
-		return string(out.Bytes())
+// errorText describes an error, with the tag and path of an rpc-error
+func errorText(err error) string {
+	var rpcErr *netconf.RPCError
+	if !errors.As(err, &rpcErr) {
+		return err.Error()
+	}
+	parts := []string{strings.TrimSpace(rpcErr.Message)}
+	if rpcErr.Tag != "" {
+		parts = append(parts, "tag "+rpcErr.Tag)
 	}
-	return ""
+	if rpcErr.Path != "" {
+		parts = append(parts, "path "+strings.TrimSpace(rpcErr.Path))
+	}
+	return strings.Join(parts, ", ")
 }
 
-func displayReply(rawReply string) {
-	xml := strings.NewReader(rawReply)
-	json, err := xj.Convert(xml)
-	if err != nil {
-		log.Fatal("Something went sore ... XML is invalid!")
+// newFlagSet returns the flags of a command, which report their errors
+// instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		cmd := findCommand(name)
+		if cmd == nil {
+			cmd = findXappCommand(name)
+		}
+		fmt.Fprintf(stderr, "Usage: o1-cli %s %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.help)
+		fs.PrintDefaults()
 	}
-	fmt.Println(prettyPrint(json.String()))
+	return fs
+}
+
+// outputFlag lets a command that prints data take the output format too
+func outputFlag(fs *flag.FlagSet) {
+	fs.StringVar(output, "o", *output, "Output format: json, xml, yaml or table")
+}
+
+// listFlag is a flag that can be given more than once
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
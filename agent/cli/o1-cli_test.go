@@ -0,0 +1,196 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Juniper/go-netconf/netconf"
+	"github.com/stretchr/testify/assert"
+)
+
+const xappDescNamespace = "urn:o-ran:ric:xapp-desc:1.0"
+
+func withSession(t *testing.T, capabilities ...string) {
+	session = &netconf.Session{ServerCapabilities: capabilities}
+	t.Cleanup(func() { session = nil })
+}
+
+func TestParseXpath(t *testing.T) {
+	steps, err := parseXpath("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='a/b]'][kind=\"x'y\"]/version")
+	assert.Nil(t, err)
+	assert.Equal(t, []step{
+		{module: "o-ran-sc-ric-xapp-desc-v1", name: "ric"},
+		{name: "xapps"},
+		{name: "xapp", keys: []keyValue{{"name", "a/b]"}, {"kind", "x'y"}}},
+		{name: "version"},
+	}, steps)
+
+	for _, path := range []string{"ric", "/ric/xapp[name='a'", "/ric/xapp[name=a]", "/ric//version"} {
+		_, err := parseXpath(path)
+		assert.Equal(t, exitUsage, exitCode(err), path)
+	}
+}
+
+func TestSplitAssignment(t *testing.T) {
+	path, value, err := splitAssignment("/ric/xapp[name='a=b']/config={\"x\": 1}")
+	assert.Nil(t, err)
+	assert.Equal(t, "/ric/xapp[name='a=b']/config", path)
+	assert.Equal(t, "{\"x\": 1}", value)
+
+	_, _, err = splitAssignment("/ric/xapp[name='a=b']")
+	assert.NotNil(t, err)
+}
+
+func TestEditXML(t *testing.T) {
+	withSession(t, xappDescNamespace+"?module=o-ran-sc-ric-xapp-desc-v1&revision=2020-09-01")
+	namespaces := moduleNamespaces()
+
+	root := &xmlNode{namespace: baseNamespace}
+	version, release := "1.0.0", "r<1>"
+	assert.Nil(t, root.add("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='kpimon']/version", namespaces, &version, ""))
+	assert.Nil(t, root.add("/ric/xapps/xapp[name='kpimon']/release-name", namespaces, &release, ""))
+	assert.Nil(t, root.add("/ric/xapps/xapp[name='old']", namespaces, nil, "delete"))
+	assert.Equal(t, `<ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps>`+
+		`<xapp><name>kpimon</name><version>1.0.0</version><release-name>r&lt;1&gt;</release-name></xapp>`+
+		`<xapp xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="delete"><name>old</name></xapp>`+
+		`</xapps></ric>`, root.xml())
+
+	assert.NotNil(t, root.add("/unknown:ric", namespaces, nil, ""))
+}
+
+func TestFilterXML(t *testing.T) {
+	withSession(t, xappDescNamespace+"?module=o-ran-sc-ric-xapp-desc-v1")
+
+	filter, err := filterXML(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", filter)
+
+	filter, err = filterXML([]string{"/ric/xapps/xapp[name='kpimon']", "/ric/generic-xapps", "/ric/generic-xapps/xapp/name"})
+	assert.Nil(t, err)
+	assert.Equal(t, `<filter type="subtree"><ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps><xapp><name>kpimon</name></xapp></xapps>`+
+		`<generic-xapps></generic-xapps></ric></filter>`, filter)
+
+	session.ServerCapabilities = append(session.ServerCapabilities, xpathCapability)
+	filter, err = filterXML([]string{"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='kpimon']"})
+	assert.Nil(t, err)
+	assert.Equal(t, `<filter type="xpath" select="/m0:ric/xapps/xapp[name=&#39;kpimon&#39;]" xmlns:m0="urn:o-ran:ric:xapp-desc:1.0"/>`, filter)
+}
+
+func TestOutputFormats(t *testing.T) {
+	data := `<data xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><ric xmlns="urn:o-ran:ric:xapp-desc:1.0">` +
+		`<xapps><xapp><name>kpimon</name><version>1.0.0</version></xapp></xapps></ric></data>`
+
+	text, err := formatXML(data, "json")
+	assert.Nil(t, err)
+	assert.Contains(t, text, `"version": "1.0.0"`)
+
+	text, err = formatXML(data, "yaml")
+	assert.Nil(t, err)
+	assert.Contains(t, text, "version: 1.0.0")
+
+	text, err = formatXML(data, "xml")
+	assert.Nil(t, err)
+	assert.Equal(t, `<data xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <ric xmlns="urn:o-ran:ric:xapp-desc:1.0">
+    <xapps>
+      <xapp>
+        <name>kpimon</name>
+        <version>1.0.0</version>
+      </xapp>
+    </xapps>
+  </ric>
+</data>`, text)
+
+	text, err = formatXML(data, "table")
+	assert.Nil(t, err)
+	assert.Equal(t, "PATH                     VALUE\n"+
+		"/ric/xapps/xapp/name     kpimon\n"+
+		"/ric/xapps/xapp/version  1.0.0", text)
+
+	_, err = formatXML(data, "csv")
+	assert.Equal(t, exitUsage, exitCode(err))
+}
+
+func TestProfile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "o1-cli.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`default: lab
+profiles:
+  lab:
+    host: ric-lab:830
+    username: admin
+    timeout: 60
+  prod:
+    host: ric-prod
+`), 0600))
+
+	saved := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) { saved[f.Name] = f.Value.String() })
+	givenFlags = nil
+	t.Cleanup(func() {
+		givenFlags = nil
+		for name, value := range saved {
+			flag.Set(name, value)
+		}
+		flag.CommandLine.Parse(nil)
+	})
+
+	assert.Nil(t, flag.CommandLine.Parse([]string{"-config", file, "-username", "ops"}))
+	assert.Nil(t, loadProfile())
+	assert.Equal(t, "ric-lab:830", *host)
+	assert.Equal(t, "ops", *username)
+	assert.Equal(t, 60, *timeout)
+
+	os.Setenv("O1_CLI_PROFILE", "prod")
+	defer os.Unsetenv("O1_CLI_PROFILE")
+	*host = "localhost"
+	assert.Nil(t, loadProfile())
+	assert.Equal(t, "ric-prod", *host)
+
+	*profileName = "staging"
+	assert.Equal(t, exitUsage, exitCode(loadProfile()))
+}
+
+func TestExitCodes(t *testing.T) {
+	assert.Equal(t, exitOK, exitCode(nil))
+	assert.Equal(t, exitRPCError, exitCode(&netconf.RPCError{Tag: "invalid-value", Message: "bad"}))
+	assert.Equal(t, exitUsage, exitCode(usageErrorf("bad flag")))
+	assert.Equal(t, exitConnect, exitCode(&connectError{errors.New("refused")}))
+	assert.Equal(t, exitTimeout, exitCode(errTimeout))
+	assert.Equal(t, exitFailure, exitCode(errors.New("no such file")))
+
+	assert.Equal(t, "bad, tag invalid-value, path /ric", errorText(&netconf.RPCError{Tag: "invalid-value", Message: " bad ", Path: "/ric"}))
+
+	var errOut bytes.Buffer
+	stderr = &errOut
+	defer func() { stderr = os.Stderr }()
+	assert.Equal(t, exitUsage, run([]string{"frobnicate"}))
+	assert.Contains(t, errOut.String(), "unknown command 'frobnicate'")
+	assert.Equal(t, exitUsage, run([]string{"delete"}))
+	assert.Equal(t, exitOK, run([]string{"help", "edit"}))
+	assert.Contains(t, errOut.String(), "Usage: o1-cli edit")
+}
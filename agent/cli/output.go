@@ -0,0 +1,170 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	xj "github.com/basgys/goxml2json"
+	"gopkg.in/yaml.v2"
+)
+
+// printXML prints an XML document with a single root in the output format
+func printXML(data string) error {
+	text, err := formatXML(data, *output)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, text)
+	return nil
+}
+
+func formatXML(data, format string) (string, error) {
+	switch format {
+	case "json":
+		return toJSON(data)
+	case "xml":
+		return indentXML(data)
+	case "yaml":
+		text, err := toJSON(data)
+		if err != nil {
+			return "", err
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(text), &v); err != nil {
+			return "", err
+		}
+		out, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(out), "\n"), err
+	case "table":
+		return toTable(data)
+	}
+	return "", usageErrorf("unknown output format '%s'", format)
+}
+
+func toJSON(data string) (string, error) {
+	j, err := xj.Convert(strings.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid XML in the reply: %v", err)
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, j.Bytes(), "", "  "); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// indentXML puts each element on a line of its own, leaves keep their
+// value on the line
+func indentXML(data string) (string, error) {
+	d := xml.NewDecoder(strings.NewReader(data))
+	var b strings.Builder
+	depth, text, leaf := 0, "", false
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid XML in the reply: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(strings.Repeat("  ", depth) + "<" + qualifiedName(t.Name))
+			for _, a := range t.Attr {
+				b.WriteString(" " + qualifiedName(a.Name) + `="` + escape(a.Value) + `"`)
+			}
+			b.WriteString(">")
+			depth, text, leaf = depth+1, "", true
+		case xml.CharData:
+			text += strings.TrimSpace(string(t))
+		case xml.EndElement:
+			depth--
+			if leaf {
+				b.WriteString(escape(text))
+			} else {
+				b.WriteString("\n" + strings.Repeat("  ", depth))
+			}
+			b.WriteString("</" + qualifiedName(t.Name) + ">")
+			leaf = false
+		}
+	}
+	return b.String(), nil
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// toTable lists the leaves of the document with their paths
+func toTable(data string) (string, error) {
+	d := xml.NewDecoder(strings.NewReader(data))
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tVALUE")
+
+	var path []string
+	text, leaf := "", false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid XML in the reply: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text, leaf = "", true
+		case xml.CharData:
+			text += strings.TrimSpace(string(t))
+		case xml.EndElement:
+			if leaf {
+				// the root is left out, it is the same for all rows
+				fmt.Fprintf(w, "/%s\t%s\n", strings.Join(path[1:], "/"), text)
+			}
+			path = path[:len(path)-1]
+			leaf = false
+		}
+	}
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
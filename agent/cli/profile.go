@@ -0,0 +1,126 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// profileKeys maps the global flags a profile can set to their keys in it.
+// A config file looks like:
+//
+//	default: lab
+//	profiles:
+//	  lab:
+//	    host: ric-lab:830
+//	    username: admin
+//	    key: /home/admin/.ssh/ric-lab
+//	    timeout: 60
+//	    output: table
+//	  prod:
+//	    host: ric-prod
+//	    transport: tls
+//	    cert: /etc/o1/client.crt
+//	    cert-key: /etc/o1/client.key
+//	    ca: /etc/o1/ca.crt
+//
+// Passwords have no place in it, they come from $O1_CLI_PASSWORD or a
+// prompt.
+var profileKeys = map[string]string{
+	"host":        "host",
+	"transport":   "transport",
+	"username":    "username",
+	"key":         "key",
+	"agent":       "agent",
+	"known-hosts": "known-hosts",
+	"cert":        "cert",
+	"cert-key":    "cert-key",
+	"ca":          "ca",
+	"insecure":    "insecure",
+	"namespace":   "namespace",
+	"timeout":     "timeout",
+	"o":           "output",
+}
+
+// givenFlags are the global flags of the command line, which no profile
+// overrides
+var givenFlags map[string]bool
+
+func defaultConfigFile() string {
+	if path := os.Getenv("O1_CLI_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".o1-cli.yaml")
+}
+
+// loadProfile sets the global flags not given on the command line from the
+// selected profile of the config file. Without a config file or a profile
+// the flags keep their defaults.
+func loadProfile() error {
+	path := *configFile
+	if path == "" {
+		path = defaultConfigFile()
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return usageErrorf("reading config file %s failed: %v", path, err)
+	}
+
+	name := *profileName
+	if name == "" {
+		name = os.Getenv("O1_CLI_PROFILE")
+	}
+	if name == "" {
+		name = v.GetString("default")
+	}
+	if name == "" {
+		return nil
+	}
+	p := v.Sub("profiles." + name)
+	if p == nil {
+		return usageErrorf("no profile '%s' in %s", name, path)
+	}
+
+	if givenFlags == nil {
+		givenFlags = make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { givenFlags[f.Name] = true })
+	}
+	for name, key := range profileKeys {
+		if !givenFlags[name] && p.IsSet(key) {
+			if err := flag.Set(name, p.GetString(key)); err != nil {
+				return usageErrorf("invalid %s in profile: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+)
+
+const monitoringNamespace = "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"
+
+// loadSchema fetches the YANG modules the server announces with
+// get-schema. The IETF modules are left out, the RIC data is not in them.
+func loadSchema() (*yang.Schema, []error) {
+	namespaces := moduleNamespaces()
+	names := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		if !strings.HasPrefix(name, "ietf-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	s := yang.NewSchema()
+	var errs []error
+	for _, name := range names {
+		m, err := fetchModule(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("no schema of module '%s': %s", name, errorText(err)))
+			continue
+		}
+		s.Add(m)
+	}
+	return s, errs
+}
+
+func fetchModule(name string) (*yang.Module, error) {
+	reply, err := exec(`<get-schema xmlns="` + monitoringNamespace + `"><identifier>` + escape(name) + "</identifier><format>yang</format></get-schema>")
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Data string `xml:"data"`
+	}
+	if err := xml.Unmarshal([]byte("<output>"+reply.Data+"</output>"), &out); err != nil {
+		return nil, err
+	}
+	return yang.Parse(out.Data)
+}
+
+// resolvePath returns the schema nodes of the steps of a path
+func resolvePath(s *yang.Schema, path string) ([]*yang.Entry, []step, error) {
+	steps, err := parseXpath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if steps[0].module == "" {
+		return nil, nil, usageErrorf("the top node of '%s' needs its module, e.g. /%s:%s", path, xappDescModule, steps[0].name)
+	}
+	m, ok := s.Modules[steps[0].module]
+	if !ok {
+		return nil, nil, usageErrorf("unknown module '%s'", steps[0].module)
+	}
+
+	entries := make([]*yang.Entry, len(steps))
+	for i, st := range steps {
+		var e *yang.Entry
+		if i == 0 {
+			e = m.Node(st.name)
+		} else {
+			e = entries[i-1].Child(st.name)
+		}
+		if e == nil {
+			parent := "/" + m.Name + ":"
+			if i > 0 {
+				parent = entries[i-1].Path()
+			}
+			return nil, nil, usageErrorf("no node '%s' in %s", st.name, parent)
+		}
+		for _, k := range st.keys {
+			if !e.IsKey(k.name) {
+				return nil, nil, usageErrorf("'%s' is not a key of %s", k.name, e.Path())
+			}
+		}
+		entries[i] = e
+	}
+	return entries, steps, nil
+}
+
+// checkEdit tells whether the path is a configuration node whose list
+// entries are given with all of their keys
+func checkEdit(entries []*yang.Entry, steps []step) error {
+	for i, e := range entries {
+		if !e.Config {
+			return usageErrorf("%s is state data, it cannot be edited", e.Path())
+		}
+		if e.Kind == yang.List && len(steps[i].keys) != len(e.Keys) && i < len(entries)-1 {
+			return usageErrorf("the entry of %s needs its keys: %s", e.Path(), strings.Join(e.Keys, ", "))
+		}
+	}
+	return nil
+}
+
+var integerSizes = map[string]int{"int8": 8, "int16": 16, "int32": 32, "int64": 64, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64}
+
+// checkValue checks a leaf value against the built-in type of the leaf
+func checkValue(e *yang.Entry, value string) error {
+	switch size, integer := integerSizes[e.Type]; {
+	case len(e.Enums) > 0:
+		if !contains(e.Enums, value) {
+			return usageErrorf("'%s' is not one of %s", value, strings.Join(e.Enums, ", "))
+		}
+	case e.Type == "boolean":
+		if value != "true" && value != "false" {
+			return usageErrorf("'%s' is not a boolean", value)
+		}
+	case integer && strings.HasPrefix(e.Type, "u"):
+		if _, err := strconv.ParseUint(value, 10, size); err != nil {
+			return usageErrorf("'%s' is not a %s", value, e.Type)
+		}
+	case integer:
+		if _, err := strconv.ParseInt(value, 10, size); err != nil {
+			return usageErrorf("'%s' is not a %s", value, e.Type)
+		}
+	}
+	return nil
+}
+
+// keyValuesFunc returns the key values of the entries of the list at the
+// path, for the completion of predicates
+type keyValuesFunc func(list *yang.Entry, path, key string) []string
+
+// completePath returns the paths that complete a partial one. Containers
+// come with a '/', lists with a '[' to go on with.
+func completePath(s *yang.Schema, partial string, keyValues keyValuesFunc) []string {
+	if partial == "" {
+		return []string{"/"}
+	}
+	if !strings.HasPrefix(partial, "/") {
+		return nil
+	}
+
+	i := lastStepStart(partial)
+	parentPath, last := partial[:i], partial[i:]
+	var candidates []string
+	if parentPath == "/" {
+		for _, name := range s.ModuleNames() {
+			for _, n := range s.Modules[name].Nodes {
+				c := "/" + name + ":" + nodeCompletion(n)
+				// the module may be left out while typing
+				if strings.HasPrefix(c, partial) || !strings.Contains(last, ":") && strings.HasPrefix(n.Name, last) {
+					candidates = append(candidates, c)
+				}
+			}
+		}
+		return candidates
+	}
+
+	entries, _, err := resolvePath(s, strings.TrimSuffix(parentPath, "/"))
+	if err != nil {
+		return nil
+	}
+	parent := entries[len(entries)-1]
+
+	if j := strings.Index(last, "["); j >= 0 {
+		list := parent.Child(last[:j])
+		if list == nil || list.Kind != yang.List {
+			return nil
+		}
+		given := last[:j]
+		preds := last[j:]
+		var keys []string
+		for len(preds) > 0 && preds[0] == '[' {
+			end := predicateEnd(preds)
+			if end < 0 {
+				break
+			}
+			kv := strings.SplitN(preds[1:end], "=", 2)
+			keys = append(keys, strings.TrimSpace(kv[0]))
+			given += preds[:end+1]
+			preds = preds[end+1:]
+		}
+		for _, key := range list.Keys {
+			if contains(keys, key) {
+				continue
+			}
+			for _, v := range keyValues(list, parentPath+last[:j], key) {
+				candidates = append(candidates, parentPath+given+predicate(key, v))
+			}
+			break
+		}
+		return withPrefix(candidates, partial)
+	}
+
+	for _, c := range parent.Children {
+		candidates = append(candidates, parentPath+nodeCompletion(c))
+	}
+	return withPrefix(candidates, partial)
+}
+
+func nodeCompletion(e *yang.Entry) string {
+	switch e.Kind {
+	case yang.Container:
+		return e.Name + "/"
+	case yang.List:
+		return e.Name + "["
+	}
+	return e.Name
+}
+
+// lastStepStart returns the index of the last step of a path, after its
+// last '/' outside of predicates
+func lastStepStart(path string) int {
+	var quote rune
+	depth, start := 0, 0
+	for i, c := range path {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			start = i + 1
+		}
+	}
+	return start
+}
+
+func withPrefix(candidates []string, prefix string) []string {
+	var result []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func commonPrefix(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
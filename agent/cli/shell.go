@@ -0,0 +1,519 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const shellPrompt = "o1> "
+
+// stdin is read by the shell, on a terminal with line editing
+var stdin io.Reader = os.Stdin
+
+// shellCommands are the commands of the shell besides the ones of the CLI
+var shellCommands = []*command{
+	{"show", "[config] [path]", "Show the data at the path, or only its configuration", nil},
+	{"set", "path value", "Set a leaf, checked against the YANG schema", nil},
+	{"delete", "path", "Delete the configuration at the path", nil},
+	{"target", "[running|candidate]", "Show or change the datastore of set and delete", nil},
+	{"history", "", "List the command lines of the session", nil},
+	{"exit", "", "Leave the shell", nil},
+}
+
+var errExit = errors.New("exit")
+
+// shell keeps one NETCONF session open for the commands typed in it
+type shell struct {
+	schema  *yang.Schema
+	target  string
+	history []string
+	// keys caches the key values of lists for completion until the next
+	// command line
+	keys map[string][]string
+	// list shows the candidates of a completion
+	list io.Writer
+}
+
+func cmdShell(args []string) error {
+	fs := newFlagSet("shell")
+	target := datastoreFlag(fs, "target")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	schema, errs := loadSchema()
+	for _, err := range errs {
+		fmt.Fprintf(stderr, "o1-cli: %v\n", err)
+	}
+	sh := &shell{schema: schema, target: *target, list: stdout}
+
+	f, ok := stdin.(*os.File)
+	if !ok || !terminal.IsTerminal(int(f.Fd())) {
+		lines := bufio.NewScanner(stdin)
+		for lines.Scan() {
+			if sh.execLine(lines.Text()) {
+				return nil
+			}
+		}
+		return lines.Err()
+	}
+
+	state, err := terminal.MakeRaw(int(f.Fd()))
+	if err != nil {
+		return err
+	}
+	defer terminal.Restore(int(f.Fd()), state)
+
+	t := terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{f, os.Stdout}, shellPrompt)
+	t.AutoCompleteCallback = sh.autoComplete
+	sh.list = t
+	defer func(out, errOut io.Writer) { stdout, stderr = out, errOut }(stdout, stderr)
+	stdout, stderr = t, t
+
+	fmt.Fprintf(t, "Connected to %s, %d modules. Tab completes, help lists the commands.\n", *host, len(schema.Modules))
+	for {
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if sh.execLine(line) {
+			return nil
+		}
+	}
+}
+
+// execLine runs a command line and tells whether the shell ends
+func (sh *shell) execLine(line string) bool {
+	words, err := splitWords(line)
+	if err != nil {
+		fmt.Fprintf(stderr, "o1-cli: %v\n", err)
+		return false
+	}
+	if len(words) == 0 {
+		return false
+	}
+	sh.history = append(sh.history, line)
+	sh.keys = nil
+
+	// commands like xapp list change the output format for themselves only
+	format := *output
+	err = sh.execute(words)
+	*output = format
+
+	if err == errExit {
+		return true
+	}
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(stderr, "o1-cli: %s: %s\n", words[0], errorText(err))
+	}
+	return false
+}
+
+func (sh *shell) execute(words []string) error {
+	args := words[1:]
+	switch words[0] {
+	case "show":
+		return sh.show(args)
+	case "set":
+		return sh.set(args)
+	case "delete":
+		return sh.delete(args)
+	case "target":
+		return sh.setTarget(args)
+	case "history":
+		for i, line := range sh.history {
+			fmt.Fprintf(stdout, "%4d  %s\n", i+1, line)
+		}
+		return nil
+	case "exit", "quit":
+		return errExit
+	case "help":
+		if len(args) == 0 {
+			shellUsage()
+			return nil
+		}
+		if c := findShellCommand(args[0]); c != nil {
+			fmt.Fprintf(stdout, "Usage: %s %s\n\n%s\n", c.name, c.args, c.help)
+			return nil
+		}
+		return cmdHelp(args)
+	case "shell":
+		return usageErrorf("already in the shell")
+	}
+
+	cmd := findCommand(words[0])
+	if cmd == nil {
+		return usageErrorf("unknown command, help lists them")
+	}
+	return cmd.run(args)
+}
+
+func findShellCommand(name string) *command {
+	for _, c := range shellCommands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func shellUsage() {
+	fmt.Fprintf(stdout, "Shell commands:\n")
+	for _, c := range shellCommands {
+		fmt.Fprintf(stdout, "  %-9s %-21s %s\n", c.name, c.args, c.help)
+	}
+	fmt.Fprintf(stdout, "\nCommands of o1-cli, without the global flags:\n")
+	for _, c := range commands {
+		if c.name != "shell" && findShellCommand(c.name) == nil {
+			fmt.Fprintf(stdout, "  %-13s %s\n", c.name, c.help)
+		}
+	}
+}
+
+// lookup resolves a path in the schema. Paths of modules whose schema
+// could not be fetched go to the server unchecked, without entries.
+func (sh *shell) lookup(path string) ([]*yang.Entry, []step, error) {
+	steps, err := parseXpath(path)
+	if err != nil {
+		return nil, nil, usageErrorf("%v", err)
+	}
+	if _, ok := sh.schema.Modules[steps[0].module]; !ok {
+		if _, ok := moduleNamespaces()[steps[0].module]; ok {
+			return nil, steps, nil
+		}
+	}
+	return resolvePath(sh.schema, path)
+}
+
+func (sh *shell) show(args []string) error {
+	config := len(args) > 0 && args[0] == "config"
+	if config {
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		return usageErrorf("unexpected argument '%s'", args[1])
+	}
+	if len(args) == 1 {
+		if _, _, err := sh.lookup(args[0]); err != nil {
+			return err
+		}
+	}
+
+	filter, err := filterXML(args)
+	if err != nil {
+		return err
+	}
+	if config {
+		return execAndPrint(fmt.Sprintf("<get-config><source><%s/></source>%s</get-config>", sh.target, filter), "")
+	}
+	return execAndPrint("<get>"+filter+"</get>", "")
+}
+
+func (sh *shell) set(args []string) error {
+	var path, value string
+	switch len(args) {
+	case 1:
+		var err error
+		if path, value, err = splitAssignment(args[0]); err != nil {
+			return err
+		}
+	case 2:
+		path, value = args[0], args[1]
+	default:
+		return usageErrorf("set takes a path and a value")
+	}
+
+	entries, steps, err := sh.lookup(path)
+	if err != nil {
+		return err
+	}
+	if entries != nil {
+		leaf := entries[len(entries)-1]
+		if !leaf.IsLeaf() {
+			return usageErrorf("%s is not a leaf", leaf.Path())
+		}
+		if err := checkEdit(entries, steps); err != nil {
+			return err
+		}
+		if err := checkValue(leaf, value); err != nil {
+			return err
+		}
+	}
+	return sh.edit(path, &value, "")
+}
+
+func (sh *shell) delete(args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("delete takes a path")
+	}
+	entries, steps, err := sh.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	if entries != nil {
+		if err := checkEdit(entries, steps); err != nil {
+			return err
+		}
+	}
+	return sh.edit(args[0], nil, "delete")
+}
+
+func (sh *shell) edit(path string, value *string, operation string) error {
+	root := &xmlNode{namespace: baseNamespace}
+	if err := root.add(path, moduleNamespaces(), value, operation); err != nil {
+		return err
+	}
+	return execAndPrint(editRPC(sh.target, root.xml(), false), "")
+}
+
+func (sh *shell) setTarget(args []string) error {
+	switch {
+	case len(args) == 0:
+		fmt.Fprintln(stdout, sh.target)
+	case len(args) == 1 && (args[0] == "running" || args[0] == "candidate"):
+		sh.target = args[0]
+	default:
+		return usageErrorf("the target is running or candidate")
+	}
+	return nil
+}
+
+// keyValues returns the key values of the entries of a list, from the
+// configuration or, for state lists, from all data
+func (sh *shell) keyValues(list *yang.Entry, path, key string) []string {
+	id := path + " " + key
+	if values, ok := sh.keys[id]; ok {
+		return values
+	}
+
+	// the server selects the entries of a list only with their keys, so
+	// the parent of the list is fetched
+	var parents []string
+	if i := lastStepStart(path); i > 1 {
+		parents = append(parents, path[:i-1])
+	}
+	filter, err := filterXML(parents)
+	if err != nil {
+		return nil
+	}
+	rpc := "<get>" + filter + "</get>"
+	if list.Config {
+		rpc = fmt.Sprintf("<get-config><source><%s/></source>%s</get-config>", sh.target, filter)
+	}
+	reply, err := exec(rpc)
+	if err != nil {
+		return nil
+	}
+	entries, err := listEntries(reply.Data, list.Name)
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	for _, e := range entries {
+		if v, ok := e[key]; ok && !contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	if sh.keys == nil {
+		sh.keys = make(map[string][]string)
+	}
+	sh.keys[id] = values
+	return values
+}
+
+// autoComplete is the completion of the terminal on the tab key. Without
+// a longer common prefix it lists the candidates.
+func (sh *shell) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	head, candidates := sh.complete(line[:pos])
+	if len(candidates) > 1 && head == line[:pos] {
+		from := lastStepStart(head[wordStart(head):])
+		names := make([]string, len(candidates))
+		for i, c := range candidates {
+			names[i] = strings.TrimSpace(c[from:])
+		}
+		fmt.Fprintln(sh.list, strings.Join(names, "  "))
+	}
+	return head + line[pos:], len(head), true
+}
+
+// complete extends the last word of the line up to the cursor and returns
+// the candidates for it
+func (sh *shell) complete(head string) (string, []string) {
+	start := wordStart(head)
+	word := head[start:]
+	words := strings.Fields(head[:start])
+
+	var candidates []string
+	switch {
+	case len(words) == 0 || len(words) == 1 && words[0] == "help":
+		for _, c := range shellCommands {
+			candidates = append(candidates, c.name+" ")
+		}
+		for _, c := range commands {
+			if c.name != "shell" && findShellCommand(c.name) == nil {
+				candidates = append(candidates, c.name+" ")
+			}
+		}
+		candidates = withPrefix(candidates, word)
+	case words[0] == "target" && len(words) == 1:
+		candidates = withPrefix([]string{"running ", "candidate "}, word)
+	case words[0] == "set" && len(words) == 2:
+		candidates = withPrefix(sh.valueCompletions(words[1]), word)
+	case takesPath(words):
+		candidates = completePath(sh.schema, word, sh.keyValues)
+		if words[0] == "show" && len(words) == 1 && strings.HasPrefix("config", word) {
+			candidates = append(candidates, "config ")
+		}
+	}
+	if len(candidates) == 0 {
+		return head, nil
+	}
+
+	completion := commonPrefix(candidates)
+	if len(candidates) > 1 && !strings.HasPrefix(completion, word) {
+		// candidates with the module left out of the word
+		completion = word
+	}
+	if len(candidates) == 1 && !strings.HasSuffix(completion, " ") && !strings.ContainsAny(completion[len(completion)-1:], "/[]") {
+		completion += " "
+	}
+	return head[:start] + completion, candidates
+}
+
+// takesPath tells whether the word after the words is a path
+func takesPath(words []string) bool {
+	last := words[len(words)-1]
+	switch words[0] {
+	case "show":
+		return len(words) == 1 || len(words) == 2 && last == "config"
+	case "set", "delete":
+		return len(words) == 1
+	case "get", "get-config":
+		return last == "-x"
+	}
+	return false
+}
+
+func (sh *shell) valueCompletions(path string) []string {
+	entries, _, err := resolvePath(sh.schema, path)
+	if err != nil {
+		return nil
+	}
+	leaf := entries[len(entries)-1]
+	switch {
+	case len(leaf.Enums) > 0:
+		return leaf.Enums
+	case leaf.Type == "boolean":
+		return []string{"true", "false"}
+	}
+	return nil
+}
+
+// wordStart returns the index of the last word of a line, which may have
+// blanks in quotes or predicates
+func wordStart(line string) int {
+	var quote rune
+	depth, start := 0, 0
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case (c == ' ' || c == '\t') && depth == 0:
+			start = i + 1
+		}
+	}
+	return start
+}
+
+// splitWords splits a command line at blanks outside of quotes and
+// predicates. A word in quotes loses them, the quotes of a predicate
+// stay.
+func splitWords(line string) ([]string, error) {
+	var words []string
+	for rest := strings.TrimSpace(line); rest != ""; rest = strings.TrimLeft(rest, " \t") {
+		if rest[0] == '\'' || rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], rest[0])
+			if end < 0 {
+				return nil, usageErrorf("unterminated quote in '%s'", rest)
+			}
+			words = append(words, rest[1:end+1])
+			rest = rest[end+2:]
+			continue
+		}
+
+		var quote rune
+		depth, end := 0, len(rest)
+	word:
+		for i, c := range rest {
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"':
+				quote = c
+			case c == '[':
+				depth++
+			case c == ']':
+				depth--
+			case (c == ' ' || c == '\t') && depth == 0:
+				end = i
+				break word
+			}
+		}
+		if quote != 0 {
+			return nil, usageErrorf("unterminated quote in '%s'", rest)
+		}
+		words = append(words, rest[:end])
+		rest = rest[end:]
+	}
+	return words, nil
+}
@@ -0,0 +1,154 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	xappDescCapability = "urn:o-ran:ric:xapp-desc:1.0?module=o-ran-sc-ric-xapp-desc-v1&revision=2026-10-19"
+	ueecCapability     = "urn:o-ran:ric:ueec-config:1.0?module=o-ran-sc-ric-ueec-config-v1&revision=2020-01-29"
+)
+
+func newTestShell(t *testing.T) *shell {
+	withSession(t, xappDescCapability, ueecCapability)
+	schema, err := yang.LoadDir("../yang")
+	assert.Nil(t, err)
+	return &shell{schema: schema, target: "running", keys: map[string][]string{
+		"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp name": {"kpimon", "ts"},
+	}}
+}
+
+func TestSplitWords(t *testing.T) {
+	words, err := splitWords(`  set /m:ric/xapps/xapp[name='a b']/version "1.0 rc"  `)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"set", "/m:ric/xapps/xapp[name='a b']/version", "1.0 rc"}, words)
+
+	words, err = splitWords("")
+	assert.Nil(t, err)
+	assert.Empty(t, words)
+
+	for _, line := range []string{`set /ric 'x`, `show /ric/xapp[name='x]`} {
+		_, err := splitWords(line)
+		assert.Equal(t, exitUsage, exitCode(err), line)
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	sh := newTestShell(t)
+	complete := func(partial string) []string {
+		return completePath(sh.schema, partial, sh.keyValues)
+	}
+
+	assert.Equal(t, []string{"/"}, complete(""))
+	assert.Equal(t, []string{"/o-ran-sc-ric-xapp-desc-v1:ric/"}, complete("/o-ran-sc-ric-xapp-d"))
+	assert.Contains(t, complete("/ri"), "/o-ran-sc-ric-ueec-config-v1:ric/")
+	assert.Equal(t, []string{"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp["}, complete("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/"))
+	assert.Equal(t, []string{
+		"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='kpimon']",
+		"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ts']",
+	}, complete("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp["))
+	assert.Equal(t, []string{"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='kpimon']"},
+		complete("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='k"))
+	assert.Equal(t, []string{"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ts']/version"},
+		complete("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ts']/ver"))
+	assert.Empty(t, complete("/o-ran-sc-ric-xapp-desc-v1:ric/nothing/"))
+	assert.Empty(t, complete("ric"))
+}
+
+func TestShellComplete(t *testing.T) {
+	sh := newTestShell(t)
+
+	line, candidates := sh.complete("hist")
+	assert.Equal(t, "history ", line)
+	assert.Equal(t, []string{"history "}, candidates)
+
+	line, candidates = sh.complete("show c")
+	assert.Equal(t, "show config ", line)
+	assert.Len(t, candidates, 1)
+
+	line, _ = sh.complete("show /o-ran-sc-ric-xapp-desc-v1:ric/dry")
+	assert.Equal(t, "show /o-ran-sc-ric-xapp-desc-v1:ric/dry-run ", line)
+
+	line, candidates = sh.complete("set /o-ran-sc-ric-xapp-desc-v1:ric/dry-run ")
+	assert.Equal(t, "set /o-ran-sc-ric-xapp-desc-v1:ric/dry-run ", line)
+	assert.Equal(t, []string{"true", "false"}, candidates)
+
+	line, candidates = sh.complete("show /ric")
+	assert.Equal(t, "show /ric", line)
+	assert.True(t, len(candidates) > 1)
+
+	line, candidates = sh.complete("lock -target ")
+	assert.Equal(t, "lock -target ", line)
+	assert.Empty(t, candidates)
+}
+
+func TestShellEdits(t *testing.T) {
+	sh := newTestShell(t)
+
+	for _, args := range [][]string{
+		{"/ric/dry-run", "true"},
+		{"/o-ran-sc-ric-xapp-desc-v1:ric/xapps", "x"},
+		{"/o-ran-sc-ric-xapp-desc-v1:ric/dry-run", "yes"},
+		{"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp/version", "1.0"},
+		{"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[version='1']/version", "1.0"},
+		{"/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name='ts']/status", "up"},
+		{"/o-ran-sc-ric-xapp-desc-v1:ric/dry-run"},
+	} {
+		assert.Equal(t, exitUsage, exitCode(sh.set(args)), args)
+	}
+	assert.Equal(t, exitUsage, exitCode(sh.delete([]string{"/o-ran-sc-ric-xapp-desc-v1:ric/jobs"})))
+	assert.Equal(t, exitUsage, exitCode(sh.show([]string{"/o-ran-sc-ric-xapp-desc-v1:ric/xapp"})))
+
+	entries, steps, err := resolvePath(sh.schema, "/o-ran-sc-ric-ueec-config-v1:ric/config/name")
+	assert.Nil(t, err)
+	assert.Nil(t, checkEdit(entries, steps))
+
+	leaf := &yang.Entry{Type: "uint8"}
+	assert.Nil(t, checkValue(leaf, "255"))
+	assert.NotNil(t, checkValue(leaf, "256"))
+	leaf.Type = "int16"
+	assert.Nil(t, checkValue(leaf, "-5"))
+	assert.NotNil(t, checkValue(leaf, "x"))
+}
+
+func TestShellLines(t *testing.T) {
+	withSession(t)
+	var out, errOut bytes.Buffer
+	stdout, stderr = &out, &errOut
+	stdin = strings.NewReader("help\n\ntarget candidate\ntarget\nbogus\nset 'x\nshell\nhistory\nexit\ntarget\n")
+	defer func() { stdout, stderr, stdin = os.Stdout, os.Stderr, os.Stdin }()
+
+	assert.Nil(t, cmdShell(nil))
+	assert.Contains(t, out.String(), "Shell commands:")
+	assert.Contains(t, out.String(), "   6  history\n")
+	assert.Contains(t, errOut.String(), "o1-cli: bogus: unknown command, help lists them")
+	assert.Contains(t, errOut.String(), "unterminated quote")
+	assert.Contains(t, errOut.String(), "o1-cli: shell: already in the shell")
+	// the line after exit is not run
+	assert.Equal(t, 1, strings.Count(out.String(), "\ncandidate\n"))
+}
@@ -0,0 +1,239 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Juniper/go-netconf/netconf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// defaultKeys are the private keys in ~/.ssh tried without -key
+var defaultKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// readPassword prompts for a password on the terminal
+var readPassword = func(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("no terminal to prompt on")
+	}
+	fmt.Fprint(stderr, prompt)
+	password, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(stderr)
+	return string(password), err
+}
+
+func dialSSH() (*netconf.Session, error) {
+	hostKeyCallback, err := hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	signers, err := sshSigners()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", withPort(*host, "830"), timeoutDuration())
+	if err != nil {
+		return nil, dialError(err)
+	}
+
+	// the deadline covers the handshake and the hello, not the time spent
+	// at the password prompt
+	setDeadline := func() { conn.SetDeadline(time.Now().Add(timeoutDuration())) }
+	clearDeadline := func() { conn.SetDeadline(time.Time{}) }
+
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	auth = append(auth, ssh.PasswordCallback(func() (string, error) {
+		return sshPassword(clearDeadline, setDeadline)
+	}))
+	config := &ssh.ClientConfig{
+		User:            *username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	}
+
+	setDeadline()
+	s, err := newSSHSession(conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, dialError(err)
+	}
+	clearDeadline()
+	return s, nil
+}
+
+// newSSHSession catches the panic of go-netconf on a server that sends no
+// hello
+func newSSHSession(conn net.Conn, config *ssh.ClientConfig) (s *netconf.Session, err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("no NETCONF hello from the server")
+		}
+	}()
+	return netconf.NewSSHSession(conn, config)
+}
+
+// sshPassword returns $O1_CLI_PASSWORD, or prompts for the password
+func sshPassword(pause, resume func()) (string, error) {
+	if password, ok := os.LookupEnv("O1_CLI_PASSWORD"); ok {
+		return password, nil
+	}
+
+	pause()
+	defer resume()
+	password, err := readPassword(fmt.Sprintf("%s@%s's password: ", *username, *host))
+	if err != nil {
+		return "", fmt.Errorf("no password, set $O1_CLI_PASSWORD: %v", err)
+	}
+	return password, nil
+}
+
+// sshSigners returns the keys of the agent and of the key files. Default
+// keys that cannot be read are left out, the ones given by -key not.
+func sshSigners() ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); *useAgent && sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if s, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, s...)
+			}
+		}
+	}
+
+	files := strings.FieldsFunc(*keyFiles, func(r rune) bool { return r == ',' })
+	explicit := len(files) > 0
+	if !explicit {
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, name := range defaultKeys {
+				files = append(files, filepath.Join(home, ".ssh", name))
+			}
+		}
+	}
+
+	for _, file := range files {
+		signer, err := readKey(file)
+		if err != nil {
+			if explicit {
+				return nil, err
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// readKey reads a private key, prompting for the passphrase of an
+// encrypted one
+func readKey(file string) (ssh.Signer, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		var passphrase string
+		if passphrase, err = readPassword(fmt.Sprintf("Passphrase for %s: ", file)); err == nil {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading key %s failed: %v", file, err)
+	}
+	return signer, nil
+}
+
+func knownHostsFile() (string, error) {
+	if *knownHosts != "" {
+		return *knownHosts, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// hostKeyCallback verifies the host key against the known hosts file.
+// The key of a host not in it is trusted on first use and added to it.
+func hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if *insecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	path, err := knownHostsFile()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	known, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s failed: %v", path, err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			want := keyErr.Want[0]
+			return fmt.Errorf("host key of %s does not match the one in %s:%d, remove that line if the key has been changed on purpose",
+				hostname, want.Filename, want.Line)
+		}
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "o1-cli: added the %s host key of %s to %s, fingerprint %s\n",
+			key.Type(), hostname, path, ssh.FingerprintSHA256(key))
+		return nil
+	}, nil
+}
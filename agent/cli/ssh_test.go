@@ -0,0 +1,134 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	assert.Nil(t, err)
+	return key
+}
+
+func TestKnownHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	*knownHosts = path
+	defer func() { *knownHosts = "" }()
+
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 830}
+	key := newHostKey(t)
+
+	var errOut bytes.Buffer
+	stderr = &errOut
+	defer func() { stderr = os.Stderr }()
+
+	// trusted on first use
+	cb, err := hostKeyCallback()
+	assert.Nil(t, err)
+	assert.Nil(t, cb("10.0.0.1:830", addr, key))
+	assert.Contains(t, errOut.String(), "added the ssh-ed25519 host key of 10.0.0.1:830")
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), "[10.0.0.1]:830 ssh-ed25519 ")
+
+	cb, err = hostKeyCallback()
+	assert.Nil(t, err)
+	assert.Nil(t, cb("10.0.0.1:830", addr, key))
+
+	err = cb("10.0.0.1:830", addr, newHostKey(t))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "does not match the one in "+path+":1")
+
+	*insecure = true
+	defer func() { *insecure = false }()
+	cb, err = hostKeyCallback()
+	assert.Nil(t, err)
+	assert.Nil(t, cb("10.0.0.1:830", addr, newHostKey(t)))
+}
+
+func TestSSHPassword(t *testing.T) {
+	paused, resumed := false, false
+	pause, resume := func() { paused = true }, func() { resumed = true }
+
+	os.Setenv("O1_CLI_PASSWORD", "secret")
+	password, err := sshPassword(pause, resume)
+	os.Unsetenv("O1_CLI_PASSWORD")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", password)
+	assert.False(t, paused)
+
+	saved := readPassword
+	defer func() { readPassword = saved }()
+	readPassword = func(prompt string) (string, error) {
+		assert.Equal(t, "netconf@localhost's password: ", prompt)
+		return "typed", nil
+	}
+	password, err = sshPassword(pause, resume)
+	assert.Nil(t, err)
+	assert.Equal(t, "typed", password)
+	assert.True(t, paused && resumed)
+
+	readPassword = func(string) (string, error) { return "", errors.New("no terminal to prompt on") }
+	_, err = sshPassword(pause, resume)
+	assert.EqualError(t, err, "no password, set $O1_CLI_PASSWORD: no terminal to prompt on")
+}
+
+func TestSSHSigners(t *testing.T) {
+	*useAgent = false
+	defer func() { *useAgent = true }()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(priv)
+	assert.Nil(t, err)
+	file := filepath.Join(t.TempDir(), "id_ecdsa")
+	assert.Nil(t, ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	*keyFiles = file
+	defer func() { *keyFiles = "" }()
+	signers, err := sshSigners()
+	assert.Nil(t, err)
+	assert.Len(t, signers, 1)
+	assert.Equal(t, "ecdsa-sha2-nistp256", signers[0].PublicKey().Type())
+
+	*keyFiles = file + ",missing"
+	_, err = sshSigners()
+	assert.NotNil(t, err)
+	assert.Equal(t, exitFailure, exitCode(err))
+}
@@ -0,0 +1,151 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/Juniper/go-netconf/netconf"
+)
+
+// endOfMessage ends the messages of NETCONF 1.0 framing, which is all the
+// client announces
+var endOfMessage = []byte("]]>]]>")
+
+// tlsTransport carries NETCONF over TLS, see RFC 7589
+type tlsTransport struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (t *tlsTransport) Send(data []byte) error {
+	var buf bytes.Buffer
+	buf.Write(data)
+	buf.Write(endOfMessage)
+	buf.WriteByte('\n')
+	_, err := t.conn.Write(buf.Bytes())
+	return err
+}
+
+func (t *tlsTransport) Receive() ([]byte, error) {
+	var msg []byte
+	for {
+		b, err := t.reader.ReadBytes('>')
+		msg = append(msg, b...)
+		if bytes.HasSuffix(msg, endOfMessage) {
+			return msg[:len(msg)-len(endOfMessage)], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *tlsTransport) Close() error {
+	return t.conn.Close()
+}
+
+func (t *tlsTransport) ReceiveHello() (*netconf.HelloMessage, error) {
+	msg, err := t.Receive()
+	if err != nil {
+		return nil, err
+	}
+	hello := &netconf.HelloMessage{}
+	if err := xml.Unmarshal(msg, hello); err != nil {
+		return nil, fmt.Errorf("invalid hello: %v", err)
+	}
+	return hello, nil
+}
+
+func (t *tlsTransport) SendHello(hello *netconf.HelloMessage) error {
+	data, err := xml.Marshal(hello)
+	if err != nil {
+		return err
+	}
+	return t.Send(append([]byte(xml.Header), data...))
+}
+
+// tlsConfig authenticates the client with its certificate, the server
+// gets the username from it
+func tlsConfig() (*tls.Config, error) {
+	if *certFile == "" || *certKeyFile == "" {
+		return nil, usageErrorf("NETCONF over TLS needs a client certificate, see -cert and -cert-key")
+	}
+	cert, err := tls.LoadX509KeyPair(*certFile, *certKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading the client certificate failed: %v", err)
+	}
+
+	config := &tls.Config{
+		Certificates:       []tls.Certificate{cert},
+		InsecureSkipVerify: *insecure,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if *caFile != "" {
+		pem, err := ioutil.ReadFile(*caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", *caFile)
+		}
+	}
+	return config, nil
+}
+
+func dialTLS() (*netconf.Session, error) {
+	config, err := tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeoutDuration()}
+	conn, err := tls.DialWithDialer(dialer, "tcp", withPort(*host, "6513"), config)
+	if err != nil {
+		return nil, dialError(err)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeoutDuration()))
+	t := &tlsTransport{conn: conn, reader: bufio.NewReader(conn)}
+	hello, err := t.ReceiveHello()
+	if err == nil {
+		err = t.SendHello(&netconf.HelloMessage{Capabilities: netconf.DefaultCapabilities})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, dialError(err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return &netconf.Session{
+		Transport:          t,
+		SessionID:          hello.SessionID,
+		ServerCapabilities: hello.Capabilities,
+	}, nil
+}
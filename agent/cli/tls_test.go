@@ -0,0 +1,143 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCert writes a self-signed certificate and its key to dir
+func writeCert(t *testing.T, dir, name string) (string, string, tls.Certificate) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	assert.Nil(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	assert.Nil(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.Nil(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.Nil(t, err)
+	return certFile, keyFile, cert
+}
+
+// serveTLS answers the hello and each RPC of a NETCONF over TLS client
+// with ok
+func serveTLS(t *testing.T, serverCert tls.Certificate, clientCA string) net.Listener {
+	pool := x509.NewCertPool()
+	data, err := ioutil.ReadFile(clientCA)
+	assert.Nil(t, err)
+	pool.AppendCertsFromPEM(data)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	assert.Nil(t, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tr := &tlsTransport{conn: conn, reader: bufio.NewReader(conn)}
+				tr.Send([]byte(`<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities>` +
+					`<capability>urn:ietf:params:netconf:base:1.0</capability></capabilities><session-id>7</session-id></hello>`))
+				if _, err := tr.ReceiveHello(); err != nil {
+					return
+				}
+				for {
+					if _, err := tr.Receive(); err != nil {
+						return
+					}
+					tr.Send([]byte(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><ok/></rpc-reply>`))
+				}
+			}()
+		}
+	}()
+	return l
+}
+
+func TestNetconfOverTLS(t *testing.T) {
+	dir := t.TempDir()
+	serverCertFile, _, serverCert := writeCert(t, dir, "ric")
+	clientCertFile, clientKeyFile, _ := writeCert(t, dir, "operator")
+	l := serveTLS(t, serverCert, clientCertFile)
+	defer l.Close()
+
+	var out, errOut bytes.Buffer
+	stdout, stderr = &out, &errOut
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+	*host, *transport = l.Addr().String(), "tls"
+	defer func() { *host, *transport = "localhost", "ssh" }()
+
+	assert.Equal(t, exitUsage, run([]string{"lock"}))
+	assert.Contains(t, errOut.String(), "needs a client certificate")
+
+	*certFile, *certKeyFile = clientCertFile, clientKeyFile
+	defer func() { *certFile, *certKeyFile, *caFile = "", "", "" }()
+	assert.Equal(t, exitConnect, run([]string{"lock"}))
+
+	*caFile = serverCertFile
+	assert.Equal(t, exitOK, run([]string{"lock"}))
+	assert.Equal(t, "ok\n", out.String())
+
+	out.Reset()
+	assert.Equal(t, exitOK, run([]string{"capabilities", "-o", "table"}))
+	assert.Contains(t, out.String(), "/session-id  7")
+}
@@ -0,0 +1,602 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	xappDescModule = "o-ran-sc-ric-xapp-desc-v1"
+	xappsPath      = "/" + xappDescModule + ":ric/xapps"
+)
+
+// descriptorLeaves are the leaves of an xApp descriptor after its name, in
+// the order of the YANG module
+var descriptorLeaves = []string{"release-name", "version", "namespace", "override-file", "config", "depends-on"}
+
+var xappCommands []*command
+
+func init() {
+	xappCommands = []*command{
+		{"xapp deploy", "[-file descriptor.json] [-version v] [-release-name r] [-namespace ns] [-override-file file] [-config file] [-depends-on x,...] [-dry-run] [name]",
+			"Deploy an xApp by creating its descriptor", xappDeploy},
+		{"xapp undeploy", "[-dry-run] name...", "Undeploy xApps by deleting their descriptors", xappUndeploy},
+		{"xapp upgrade", "[-version v] [-release-name r] [-dry-run] name", "Redeploy an xApp with another version", xappUpgrade},
+		{"xapp list", "", "List the xApps of the running configuration", xappList},
+		{"xapp status", "[name]...", "Show the health of the deployed xApps", xappStatus},
+		{"xapp config get", "name", "Show the configuration the appmgr has for an xApp", xappConfigGet},
+		{"xapp config set", "[-module m] [-namespace ns] [-dry-run] [-file control.json | path=value...] name",
+			"Change the configuration of an xApp through its config module", xappConfigSet},
+	}
+}
+
+func findXappCommand(name string) *command {
+	for _, c := range xappCommands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func cmdXapp(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprintf(stderr, "Usage: o1-cli xapp <command> [command flags] [arguments]\n\nCommands:\n")
+		for _, c := range xappCommands {
+			fmt.Fprintf(stderr, "  %-11s %s\n", strings.TrimPrefix(c.name, "xapp "), c.help)
+		}
+		if len(args) == 0 {
+			return usageErrorf("missing xapp command")
+		}
+		return flag.ErrHelp
+	}
+
+	name, rest := "xapp "+args[0], args[1:]
+	if args[0] == "config" && len(rest) > 0 {
+		name, rest = name+" "+rest[0], rest[1:]
+	}
+	cmd := findXappCommand(name)
+	if cmd == nil {
+		return usageErrorf("unknown command '%s'", name)
+	}
+	return cmd.run(rest)
+}
+
+func xappPath(name string) string {
+	return xappsPath + "/xapp" + predicate("name", name)
+}
+
+// tableOutput makes table the default output of a command that lists
+// things, unless the output is given on the command line or by a profile
+func tableOutput() {
+	given := false
+	flag.Visit(func(f *flag.Flag) { given = given || f.Name == "o" })
+	if !given {
+		*output = "table"
+	}
+}
+
+func xappDeploy(args []string) error {
+	fs := newFlagSet("xapp deploy")
+	file := fs.String("file", "", "JSON file with the xApp descriptor, the flags override its members")
+	values := map[string]*string{
+		"release-name": fs.String("release-name", "", "Name of the xApp in Kubernetes"),
+		"version":      fs.String("version", "", "Version of the helm chart"),
+		"namespace":    fs.String("namespace", "", "Kubernetes namespace, the default one of the agent if not given"),
+		"depends-on":   fs.String("depends-on", "", "Comma separated xApps to deploy before this one at startup"),
+	}
+	overrideFile := fs.String("override-file", "", "JSON file with the overrides of the helm chart")
+	config := fs.String("config", "", "JSON file with the configuration of the xApp")
+	target := datastoreFlag(fs, "target")
+	dryRun := fs.Bool("dry-run", false, "Only test the deployment, see the audit records for the planned SBI calls")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	desc := make(map[string]string)
+	if *file != "" {
+		if err := readDescriptor(*file, desc); err != nil {
+			return err
+		}
+	}
+	for leaf, value := range values {
+		if *value != "" {
+			desc[leaf] = *value
+		}
+	}
+	desc["depends-on"] = strings.Join(strings.FieldsFunc(desc["depends-on"], func(r rune) bool { return r == ',' || r == ' ' }), " ")
+	for leaf, file := range map[string]string{"override-file": *overrideFile, "config": *config} {
+		if file == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if desc[leaf], err = compactJSON(data); err != nil {
+			return fmt.Errorf("invalid JSON in %s: %v", file, err)
+		}
+	}
+	if fs.NArg() > 0 {
+		desc["name"] = fs.Arg(0)
+	}
+	if desc["name"] == "" {
+		return usageErrorf("the name of the xApp is missing")
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	return execAndPrint(editRPC(*target, descriptorXML(desc, "create"), *dryRun), "")
+}
+
+// readDescriptor reads the members of a JSON xApp descriptor. Objects,
+// like the override file and the config, become JSON strings; a list of
+// dependencies becomes space separated.
+func readDescriptor(file string, desc map[string]string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return fmt.Errorf("invalid xApp descriptor %s: %v", file, err)
+	}
+
+	for name, v := range members {
+		if name != "name" && !contains(descriptorLeaves, name) {
+			return fmt.Errorf("invalid xApp descriptor %s: unknown member '%s'", file, name)
+		}
+		if s, ok := v.(string); ok {
+			desc[name] = s
+			continue
+		}
+		if items, ok := v.([]interface{}); ok && name == "depends-on" {
+			for _, item := range items {
+				desc[name] += fmt.Sprintf(" %v", item)
+			}
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		desc[name] = string(b)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func compactJSON(data []byte) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// descriptorXML returns the xApp descriptor with the edit operation
+func descriptorXML(desc map[string]string, operation string) string {
+	root := &xmlNode{namespace: baseNamespace}
+	namespaces := map[string]string{xappDescModule: "urn:o-ran:ric:xapp-desc:1.0"}
+	entry := xappPath(desc["name"])
+	root.add(entry, namespaces, nil, operation)
+	for _, leaf := range descriptorLeaves {
+		if value, ok := desc[leaf]; ok && value != "" {
+			root.add(entry+"/"+leaf, namespaces, &value, "")
+		}
+	}
+	return root.xml()
+}
+
+func xappUndeploy(args []string) error {
+	fs := newFlagSet("xapp undeploy")
+	target := datastoreFlag(fs, "target")
+	dryRun := fs.Bool("dry-run", false, "Only test the undeployment, see the audit records for the planned SBI calls")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf("no xApp to undeploy")
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	root := &xmlNode{namespace: baseNamespace}
+	for _, name := range fs.Args() {
+		root.add(xappPath(name), moduleNamespaces(), nil, "delete")
+	}
+	return execAndPrint(editRPC(*target, root.xml(), *dryRun), "")
+}
+
+// xappUpgrade redeploys an xApp with another version or release name. The
+// redeploy-xapp RPC commits them to the descriptor once the xApp is
+// redeployed, so it is never left undeployed in between. The upgrade takes
+// effect at once, there is no -target for it.
+func xappUpgrade(args []string) error {
+	fs := newFlagSet("xapp upgrade")
+	version := fs.String("version", "", "New version of the helm chart")
+	release := fs.String("release-name", "", "New name of the xApp in Kubernetes")
+	dryRun := fs.Bool("dry-run", false, "Only test the upgrade and print the SBI call it would make")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf("the name of the xApp is missing")
+	}
+	if *version == "" && *release == "" {
+		return usageErrorf("nothing to upgrade, see -version and -release-name")
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	reply, err := exec(redeployRPC(fs.Arg(0), *release, *version, *dryRun))
+	if err != nil {
+		return err
+	}
+
+	var out struct {
+		SBICalls string `xml:"sbi-calls"`
+	}
+	if err := xml.Unmarshal([]byte("<output>"+reply.Data+"</output>"), &out); err != nil {
+		return err
+	}
+	if out.SBICalls != "" {
+		fmt.Fprintln(stdout, "Planned SBI calls:")
+		fmt.Fprintln(stdout, out.SBICalls)
+		return nil
+	}
+	fmt.Fprintln(stdout, "ok")
+	return nil
+}
+
+// redeployRPC returns the redeploy-xapp RPC, without the release name or
+// version if they are empty
+func redeployRPC(name, release, version string, dryRun bool) string {
+	var b strings.Builder
+	b.WriteString(`<redeploy-xapp xmlns="` + escape(*namespace) + `"><name>` + escape(name) + "</name>")
+	if release != "" {
+		b.WriteString("<release-name>" + escape(release) + "</release-name>")
+	}
+	if version != "" {
+		b.WriteString("<version>" + escape(version) + "</version>")
+	}
+	if dryRun {
+		b.WriteString("<dry-run>true</dry-run>")
+	}
+	b.WriteString("</redeploy-xapp>")
+	return b.String()
+}
+
+func xappFilter(path string) (string, error) {
+	if _, err := connect(); err != nil {
+		return "", err
+	}
+	return filterXML([]string{path})
+}
+
+func xappList(args []string) error {
+	tableOutput()
+	fs := newFlagSet("xapp list")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	filter, err := xappFilter(xappsPath)
+	if err != nil {
+		return err
+	}
+	reply, err := exec("<get-config><source><running/></source>" + filter + "</get-config>")
+	if err != nil {
+		return err
+	}
+	return printEntries(reply.Data, "xapp", []string{"name", "version", "release-name", "namespace", "depends-on"}, nil)
+}
+
+func xappStatus(args []string) error {
+	tableOutput()
+	fs := newFlagSet("xapp status")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+
+	filter, err := xappFilter("/" + xappDescModule + ":ric/health")
+	if err != nil {
+		return err
+	}
+	reply, err := exec("<get>" + filter + "</get>")
+	if err != nil {
+		return err
+	}
+	return printEntries(reply.Data, "status", []string{"name", "namespace", "status", "health"}, fs.Args())
+}
+
+// printEntries prints the list entries of the data as a table of the
+// leaves, only the ones of the names if any are given. Other output
+// formats print the data.
+func printEntries(data, list string, leaves, names []string) error {
+	if *output != "table" {
+		return printXML(strings.TrimSpace(data))
+	}
+	entries, err := listEntries(data, list)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(strings.Join(leaves, "\t")))
+	for _, e := range entries {
+		if len(names) > 0 && !contains(names, e["name"]) {
+			continue
+		}
+		row := make([]string, len(leaves))
+		for i, leaf := range leaves {
+			row[i] = e[leaf]
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// listEntries returns the leaves of the entries of a list in the data
+func listEntries(data, list string) ([]map[string]string, error) {
+	d := xml.NewDecoder(strings.NewReader(data))
+	var entries []map[string]string
+	var entry map[string]string
+	depth, entryDepth := 0, 0
+	text := ""
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML in the reply: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if entry == nil && t.Name.Local == list {
+				entry, entryDepth = make(map[string]string), depth
+			}
+			text = ""
+		case xml.CharData:
+			text += string(t)
+		case xml.EndElement:
+			switch {
+			case entry != nil && depth == entryDepth+1:
+				entry[t.Name.Local] = strings.TrimSpace(text)
+			case entry != nil && depth == entryDepth:
+				entries = append(entries, entry)
+				entry = nil
+			}
+			depth--
+		}
+	}
+	return entries, nil
+}
+
+func xappConfigGet(args []string) error {
+	tableOutput()
+	fs := newFlagSet("xapp config get")
+	outputFlag(fs)
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf("the name of the xApp is missing")
+	}
+
+	name := fs.Arg(0)
+	filter, err := xappFilter("/" + xappDescModule + ":ric/configuration/xapps/xapp" + predicate("name", name))
+	if err != nil {
+		return err
+	}
+	reply, err := exec("<get>" + filter + "</get>")
+	if err != nil {
+		return err
+	}
+	if *output == "xml" {
+		return printXML(strings.TrimSpace(reply.Data))
+	}
+
+	entries, err := listEntries(reply.Data, "xapp")
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 || entries[0]["config"] == "" {
+		return fmt.Errorf("no configuration of xApp '%s'", name)
+	}
+	var config interface{}
+	if err := json.Unmarshal([]byte(entries[0]["config"]), &config); err != nil {
+		return fmt.Errorf("invalid configuration of xApp '%s': %v", name, err)
+	}
+	return printJSON(config)
+}
+
+// printJSON prints a JSON value in the output format, a table lists its
+// members by path
+func printJSON(v interface{}) error {
+	switch *output {
+	case "json":
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(out))
+	case "yaml":
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(stdout, string(out))
+	case "table":
+		w := tabwriter.NewWriter(stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "PATH\tVALUE")
+		flattenJSON(w, "", v)
+		return w.Flush()
+	default:
+		return usageErrorf("unknown output format '%s'", *output)
+	}
+	return nil
+}
+
+func flattenJSON(w io.Writer, path string, v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flattenJSON(w, path+"/"+k, value[k])
+		}
+	case []interface{}:
+		for i, item := range value {
+			flattenJSON(w, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	default:
+		b, _ := json.Marshal(value)
+		fmt.Fprintf(w, "%s\t%s\n", path, strings.Trim(string(b), `"`))
+	}
+}
+
+// xappConfigSet edits the control container of the config module of the
+// xApp, from which the agent patches the configuration the appmgr has
+func xappConfigSet(args []string) error {
+	fs := newFlagSet("xapp config set")
+	module := fs.String("module", "", "Config module of the xApp, o-ran-sc-ric-<name>-config-v1 by default")
+	namespace := fs.String("namespace", "", "Kubernetes namespace of the xApp")
+	file := fs.String("file", "", "JSON file with the members of the control container to set")
+	target := datastoreFlag(fs, "target")
+	dryRun := fs.Bool("dry-run", false, "Only test the change, see the audit records for the planned SBI calls")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageErrorf("the name of the xApp is missing")
+	}
+	name, assignments := fs.Arg(0), fs.Args()[1:]
+	if (*file == "") == (len(assignments) == 0) {
+		return usageErrorf("either -file or path=value arguments are needed")
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	if *module == "" {
+		*module = "o-ran-sc-ric-" + name + "-config-v1"
+	}
+	ns, ok := moduleNamespaces()[*module]
+	if !ok {
+		return usageErrorf("the server has no config module '%s' of xApp '%s', see -module", *module, name)
+	}
+
+	var control string
+	if *file != "" {
+		data, err := ioutil.ReadFile(*file)
+		if err != nil {
+			return err
+		}
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		var members map[string]interface{}
+		if err := d.Decode(&members); err != nil {
+			return fmt.Errorf("invalid JSON in %s: %v", *file, err)
+		}
+		var b strings.Builder
+		jsonToXML(&b, "control", members)
+		control = b.String()
+	} else {
+		root := &xmlNode{namespace: ns}
+		namespaces := map[string]string{*module: ns}
+		for _, arg := range assignments {
+			path, value, err := splitAssignment(arg)
+			if err != nil {
+				return err
+			}
+			path = "/" + *module + ":control/" + strings.TrimPrefix(path, "/")
+			if err := root.add(path, namespaces, &value, ""); err != nil {
+				return err
+			}
+		}
+		control = root.xml()
+	}
+
+	var b strings.Builder
+	b.WriteString(`<ric xmlns="` + escape(ns) + `"><config><name>` + escape(name) + "</name>")
+	if *namespace != "" {
+		b.WriteString("<namespace>" + escape(*namespace) + "</namespace>")
+	}
+	b.WriteString(control + "</config></ric>")
+	return execAndPrint(editRPC(*target, b.String(), *dryRun), "")
+}
+
+// jsonToXML writes a JSON value as the element of the name. Members of
+// objects become child elements, the items of arrays repeated elements.
+func jsonToXML(b *strings.Builder, name string, v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("<" + name + ">")
+		for _, k := range keys {
+			jsonToXML(b, k, value[k])
+		}
+		b.WriteString("</" + name + ">")
+	case []interface{}:
+		for _, item := range value {
+			jsonToXML(b, name, item)
+		}
+	case nil:
+		b.WriteString("<" + name + "/>")
+	default:
+		b.WriteString("<" + name + ">" + escape(fmt.Sprint(value)) + "</" + name + ">")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescriptorXML(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kpimon.json")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"name": "kpimon", "version": "1.0.0",
+		"override-file": {"replicas": 2}, "depends-on": ["e2mgr", "ts"]}`), 0600))
+
+	desc := make(map[string]string)
+	assert.Nil(t, readDescriptor(file, desc))
+	assert.Equal(t, map[string]string{"name": "kpimon", "version": "1.0.0", "override-file": `{"replicas":2}`, "depends-on": " e2mgr ts"}, desc)
+
+	desc["depends-on"] = "e2mgr ts"
+	assert.Equal(t, `<ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps>`+
+		`<xapp xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="create"><name>kpimon</name>`+
+		`<version>1.0.0</version><override-file>{&#34;replicas&#34;:2}</override-file><depends-on>e2mgr ts</depends-on>`+
+		`</xapp></xapps></ric>`, descriptorXML(desc, "create"))
+
+	assert.Nil(t, ioutil.WriteFile(file, []byte(`{"name": "kpimon", "versoin": "1.0.0"}`), 0600))
+	assert.EqualError(t, readDescriptor(file, desc), "invalid xApp descriptor "+file+": unknown member 'versoin'")
+}
+
+func TestRedeployRPC(t *testing.T) {
+	assert.Equal(t, `<redeploy-xapp xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>kpimon</name><version>1.1.0</version></redeploy-xapp>`,
+		redeployRPC("kpimon", "", "1.1.0", false))
+	assert.Equal(t, `<redeploy-xapp xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>kpimon</name><release-name>kpi&amp;mon</release-name>`+
+		`<version>1.1.0</version><dry-run>true</dry-run></redeploy-xapp>`, redeployRPC("kpimon", "kpi&mon", "1.1.0", true))
+}
+
+func TestXappTables(t *testing.T) {
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout, *output = os.Stdout, "json" }()
+	*output = "table"
+
+	data := `<data xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><health>
+		<status><name>kpimon</name><status>deployed</status><health>healthy</health><namespace>ricxapp</namespace></status>
+		<status><name>ts</name><status>failed</status><health>unhealthy</health></status>
+		</health></ric></data>`
+	entries, err := listEntries(data, "status")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "ricxapp", entries[0]["namespace"])
+
+	assert.Nil(t, printEntries(data, "status", []string{"name", "namespace", "status", "health"}, []string{"ts"}))
+	assert.Equal(t, "NAME  NAMESPACE  STATUS  HEALTH\n"+
+		"ts               failed  unhealthy\n", out.String())
+
+	out.Reset()
+	assert.Nil(t, printJSON(map[string]interface{}{"controls": map[string]interface{}{"active": true, "ids": []interface{}{"a", 2.0}}}))
+	assert.Equal(t, "PATH              VALUE\n"+
+		"/controls/active  true\n"+
+		"/controls/ids[0]  a\n"+
+		"/controls/ids[1]  2\n", out.String())
+}
+
+func TestJSONToXML(t *testing.T) {
+	var b strings.Builder
+	jsonToXML(&b, "control", map[string]interface{}{
+		"active":      true,
+		"interfaceId": map[string]interface{}{"plmnId": "3<1"},
+		"cells":       []interface{}{map[string]interface{}{"id": 1.0}, map[string]interface{}{"id": 2.0}},
+	})
+	assert.Equal(t, "<control><active>true</active><cells><id>1</id></cells><cells><id>2</id></cells>"+
+		"<interfaceId><plmnId>3&lt;1</plmnId></interfaceId></control>", b.String())
+}
+
+func TestXappCommands(t *testing.T) {
+	var errOut bytes.Buffer
+	stderr = &errOut
+	defer func() { stderr = os.Stderr }()
+
+	assert.Equal(t, exitUsage, run([]string{"xapp"}))
+	assert.Equal(t, exitUsage, run([]string{"xapp", "redeploy"}))
+	assert.Contains(t, errOut.String(), "unknown command 'xapp redeploy'")
+	assert.Equal(t, exitUsage, run([]string{"xapp", "deploy", "-version", "1.0.0"}))
+	assert.Equal(t, exitUsage, run([]string{"xapp", "upgrade", "kpimon"}))
+	assert.Equal(t, exitUsage, run([]string{"xapp", "config", "set", "kpimon"}))
+
+	errOut.Reset()
+	assert.Equal(t, exitOK, run([]string{"help", "xapp", "config", "set"}))
+	assert.Contains(t, errOut.String(), "Usage: o1-cli xapp config set")
+}
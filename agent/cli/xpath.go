@@ -0,0 +1,323 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keyValue is one list key predicate of a path, e.g. [name='kpimon']
+type keyValue struct {
+	name  string
+	value string
+}
+
+// step is one node of a path such as
+// /o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='kpimon']/version. The
+// module is empty when the node is in the module of its parent.
+type step struct {
+	module string
+	name   string
+	keys   []keyValue
+}
+
+// parseXpath splits an absolute path into its steps
+func parseXpath(path string) ([]step, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, usageErrorf("invalid path '%s': not absolute", path)
+	}
+
+	var parts []string
+	var quote rune
+	depth, start := 0, 1
+	for i, c := range path {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0 && i > 0:
+			parts = append(parts, path[start:i])
+			start = i + 1
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, usageErrorf("invalid path '%s': unbalanced predicate", path)
+	}
+	parts = append(parts, path[start:])
+
+	steps := make([]step, 0, len(parts))
+	for _, p := range parts {
+		s, err := parseStep(p)
+		if err != nil {
+			return nil, usageErrorf("invalid path '%s': %v", path, err)
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+func parseStep(s string) (step, error) {
+	st := step{}
+	name := s
+	if i := strings.Index(s, "["); i >= 0 {
+		name = s[:i]
+		for preds := s[i:]; len(preds) > 0; {
+			end := predicateEnd(preds)
+			if preds[0] != '[' || end < 0 {
+				return st, fmt.Errorf("bad predicate '%s'", preds)
+			}
+			kv := strings.SplitN(preds[1:end], "=", 2)
+			if len(kv) != 2 {
+				return st, fmt.Errorf("bad predicate '%s'", preds[:end+1])
+			}
+			val := strings.TrimSpace(kv[1])
+			if len(val) < 2 || (val[0] != '\'' && val[0] != '"') || val[len(val)-1] != val[0] {
+				return st, fmt.Errorf("unquoted predicate value '%s'", val)
+			}
+			st.keys = append(st.keys, keyValue{strings.TrimSpace(kv[0]), val[1 : len(val)-1]})
+			preds = preds[end+1:]
+		}
+	}
+
+	if i := strings.Index(name, ":"); i >= 0 {
+		st.module, name = name[:i], name[i+1:]
+	}
+	if name == "" {
+		return st, fmt.Errorf("empty node name")
+	}
+	st.name = name
+	return st, nil
+}
+
+// predicateEnd returns the index of the ']' closing the predicate at the
+// start of s, -1 if there is none
+func predicateEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// predicate returns the key predicate [name='value'] of a path
+func predicate(name, value string) string {
+	quote := "'"
+	if strings.Contains(value, "'") {
+		quote = "\""
+	}
+	return "[" + name + "=" + quote + value + quote + "]"
+}
+
+// splitAssignment splits path=value at the first '=' outside of the
+// predicates of the path
+func splitAssignment(arg string) (string, string, error) {
+	var quote rune
+	depth := 0
+	for i, c := range arg {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '=' && depth == 0:
+			return arg[:i], arg[i+1:], nil
+		}
+	}
+	return "", "", usageErrorf("'%s' is not of the form path=value", arg)
+}
+
+// xmlNode builds the XML of a set of paths, for subtree filters and for
+// the config of edit-config
+type xmlNode struct {
+	name      string
+	namespace string
+	keys      []keyValue
+	value     *string
+	operation string
+	selected  bool
+	children  []*xmlNode
+}
+
+// add adds the node of the path, with a value for a leaf and an edit
+// operation for the node itself. The namespaces map modules to their
+// namespaces; the top node without a module is in the default namespace.
+func (n *xmlNode) add(path string, namespaces map[string]string, value *string, operation string) error {
+	steps, err := parseXpath(path)
+	if err != nil {
+		return err
+	}
+
+	node := n
+	ns := *namespace
+	for _, s := range steps {
+		if s.module != "" {
+			var ok bool
+			if ns, ok = namespaces[s.module]; !ok {
+				return usageErrorf("unknown module '%s' in '%s'", s.module, path)
+			}
+		}
+		if node.selected {
+			return nil
+		}
+		node = node.child(s.name, ns, s.keys)
+	}
+	node.value = value
+	node.operation = operation
+	if value == nil && operation == "" {
+		// a selection node of a filter selects all of its subtree
+		node.selected = true
+		node.children = nil
+	}
+	return nil
+}
+
+func (n *xmlNode) child(name, ns string, keys []keyValue) *xmlNode {
+	for _, c := range n.children {
+		if c.name == name && c.namespace == ns && sameKeys(c.keys, keys) {
+			return c
+		}
+	}
+	c := &xmlNode{name: name, namespace: ns, keys: keys}
+	n.children = append(n.children, c)
+	return c
+}
+
+func sameKeys(a, b []keyValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// xml returns the XML of the children of the node
+func (n *xmlNode) xml() string {
+	var b strings.Builder
+	for _, c := range n.children {
+		c.write(&b, n.namespace)
+	}
+	return b.String()
+}
+
+func (n *xmlNode) write(b *strings.Builder, parentNamespace string) {
+	b.WriteString("<" + n.name)
+	if n.namespace != parentNamespace {
+		b.WriteString(` xmlns="` + escape(n.namespace) + `"`)
+	}
+	if n.operation != "" {
+		b.WriteString(` xmlns:nc="` + baseNamespace + `" nc:operation="` + n.operation + `"`)
+	}
+	b.WriteString(">")
+	for _, k := range n.keys {
+		b.WriteString("<" + k.name + ">" + escape(k.value) + "</" + k.name + ">")
+	}
+	if n.value != nil {
+		b.WriteString(escape(*n.value))
+	}
+	for _, c := range n.children {
+		c.write(b, n.namespace)
+	}
+	b.WriteString("</" + n.name + ">")
+}
+
+// filterXML returns the filter of get and get-config selecting the paths,
+// nothing to select everything. A single path becomes an xpath filter if
+// the server supports them.
+func filterXML(paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+	namespaces := moduleNamespaces()
+
+	if len(paths) == 1 && hasCapability(xpathCapability) {
+		return xpathFilter(paths[0], namespaces)
+	}
+
+	root := &xmlNode{namespace: baseNamespace}
+	for _, p := range paths {
+		if err := root.add(p, namespaces, nil, ""); err != nil {
+			return "", err
+		}
+	}
+	return `<filter type="subtree">` + root.xml() + "</filter>", nil
+}
+
+// xpathFilter declares a prefix for each namespace of the path, so that
+// its top node may leave out the module too
+func xpathFilter(path string, namespaces map[string]string) (string, error) {
+	steps, err := parseXpath(path)
+	if err != nil {
+		return "", err
+	}
+
+	prefixes := make(map[string]string)
+	var decls, sel strings.Builder
+	ns := *namespace
+	for i, s := range steps {
+		if s.module != "" {
+			var ok bool
+			if ns, ok = namespaces[s.module]; !ok {
+				return "", usageErrorf("unknown module '%s' in '%s'", s.module, path)
+			}
+		}
+		prefix, ok := prefixes[ns]
+		if !ok {
+			prefix = fmt.Sprintf("m%d", len(prefixes))
+			prefixes[ns] = prefix
+			decls.WriteString(` xmlns:` + prefix + `="` + escape(ns) + `"`)
+		}
+		sel.WriteString("/")
+		if i == 0 || s.module != "" {
+			sel.WriteString(prefix + ":")
+		}
+		sel.WriteString(s.name)
+		for _, k := range s.keys {
+			sel.WriteString(predicate(k.name, k.value))
+		}
+	}
+	return `<filter type="xpath" select="` + escape(sel.String()) + `"` + decls.String() + "/>", nil
+}
@@ -26,8 +26,12 @@ import (
 	"syscall"
 
 	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/gnmi"
 	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/netconf"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/restconf"
 	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
 	"github.com/spf13/viper"
 )
 
@@ -36,9 +40,12 @@ var Hash string
 var osExit = os.Exit
 
 type O1Agent struct {
-	rmrReady  bool
-	nbiClient *nbi.Nbi
-	sigChan   chan os.Signal
+	rmrReady       bool
+	nbiClient      *nbi.Nbi
+	netconfServer  *netconf.Server
+	restconfServer *restconf.Server
+	gnmiServer     *gnmi.Server
+	sigChan        chan os.Signal
 }
 
 func (o O1Agent) Consume(rp *xapp.RMRParams) (err error) {
@@ -58,7 +65,7 @@ func (o *O1Agent) StatusCB() bool {
 }
 
 func (o *O1Agent) Run() {
-        xapp.Logger.SetFormat(0)
+	xapp.Logger.SetFormat(0)
 	xapp.Logger.SetMdc("o1agent", fmt.Sprintf("%s:%s", Version, Hash))
 	xapp.SetReadyCB(func(d interface{}) { o.rmrReady = true }, true)
 	xapp.AddConfigChangeListener(o.ConfigChangeHandler)
@@ -74,6 +81,12 @@ func (o *O1Agent) Sighandler() {
 	xapp.Logger.Info("Signal handler installed!")
 
 	<-o.sigChan
+	if o.netconfServer != nil {
+		o.netconfServer.Close()
+	}
+	if o.gnmiServer != nil {
+		o.gnmiServer.Close()
+	}
 	o.nbiClient.Stop()
 	osExit(1)
 }
@@ -85,11 +98,120 @@ func NewO1Agent() *O1Agent {
 
 	sbiClient := sbi.NewSBIClient(appmgrAddr, alertmgrAddr, timeout)
 
-	return &O1Agent{
-		rmrReady:  false,
-		nbiClient: nbi.NewNbi(sbiClient),
-		sigChan:   make(chan os.Signal, 1),
+	o := &O1Agent{
+		rmrReady: false,
+		sigChan:  make(chan os.Signal, 1),
+	}
+
+	native := viper.GetString("nbi.mode") == "native"
+	restconfEnabled := viper.GetBool("restconf.enabled")
+	gnmiEnabled := viper.GetBool("gnmi.enabled")
+
+	var schema *yang.Schema
+	if native || restconfEnabled || gnmiEnabled {
+		var err error
+		if schema, err = yang.LoadDir(viper.GetString("nbi.yangDir")); err != nil {
+			xapp.Logger.Error("Loading YANG modules failed: %v", err)
+		}
+	}
+
+	// In native mode the agent serves NETCONF itself instead of netopeer2
+	if native {
+		ds := nbi.NewMemDatastore()
+		o.nbiClient = nbi.NewNbiWithDatastore(sbiClient, ds)
+		o.netconfServer = newNetconfServer(ds, schema)
+		if o.netconfServer != nil {
+			o.netconfServer.SetCandidate(o.nbiClient.Candidate())
+			o.netconfServer.SetDryRun(o.nbiClient.DryRun)
+		}
+	} else {
+		o.nbiClient = nbi.NewNbi(sbiClient)
+	}
+
+	if store, ok := o.nbiClient.Datastore().(nbi.Store); ok && schema != nil {
+		if restconfEnabled {
+			o.restconfServer = newRestconfServer(store, schema)
+		}
+		if gnmiEnabled {
+			o.gnmiServer = newGnmiServer(store, schema)
+		}
+	}
+	return o
+}
+
+func newRestconfServer(store nbi.Store, schema *yang.Schema) *restconf.Server {
+	server, err := restconf.NewServer(restconf.ConfigFromViper(), store, schema)
+	if err != nil {
+		xapp.Logger.Error("RESTCONF server setup failed: %v", err)
+		return nil
 	}
+	return server
+}
+
+func newGnmiServer(store nbi.Store, schema *yang.Schema) *gnmi.Server {
+	server, err := gnmi.NewServer(gnmi.ConfigFromViper(), store, schema)
+	if err != nil {
+		xapp.Logger.Error("gNMI server setup failed: %v", err)
+		return nil
+	}
+	return server
+}
+
+func newNetconfServer(ds *nbi.MemDatastore, schema *yang.Schema) *netconf.Server {
+	if schema == nil {
+		return nil
+	}
+
+	server, err := netconf.NewServer(netconf.ConfigFromViper(), ds, schema)
+	if err != nil {
+		xapp.Logger.Error("NETCONF server setup failed: %v", err)
+		return nil
+	}
+	return server
+}
+
+func (o *O1Agent) StartNetconf() bool {
+	if viper.GetString("nbi.mode") != "native" {
+		return true
+	}
+	if o.netconfServer == nil {
+		return false
+	}
+
+	go func() {
+		if err := o.netconfServer.ListenAndServe(); err != nil {
+			xapp.Logger.Error("NETCONF server stopped: %v", err)
+		}
+	}()
+	return true
+}
+
+func (o *O1Agent) StartRestconf() bool {
+	if !viper.GetBool("restconf.enabled") {
+		return true
+	}
+	if o.restconfServer == nil {
+		return false
+	}
+
+	o.restconfServer.InjectRoutes()
+	return true
+}
+
+func (o *O1Agent) StartGnmi() bool {
+	if !viper.GetBool("gnmi.enabled") {
+		return true
+	}
+	if o.gnmiServer == nil {
+		return false
+	}
+
+	go func() {
+		if err := o.gnmiServer.ListenAndServe(); err != nil {
+			xapp.Logger.Error("gNMI server stopped: %v", err)
+		}
+	}()
+	return true
 }
 
 func main() {
@@ -100,5 +222,20 @@ func main() {
 		return
 	}
 
+	if ok := o1Agent.StartNetconf(); !ok {
+		xapp.Logger.Error("NETCONF server initialization failed!")
+		return
+	}
+
+	if ok := o1Agent.StartRestconf(); !ok {
+		xapp.Logger.Error("RESTCONF server initialization failed!")
+		return
+	}
+
+	if ok := o1Agent.StartGnmi(); !ok {
+		xapp.Logger.Error("gNMI server initialization failed!")
+		return
+	}
+
 	o1Agent.Run()
 }
@@ -20,6 +20,7 @@
 package main
 
 import (
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"syscall"
@@ -67,3 +68,56 @@ func TestSighandler(t *testing.T) {
 	time.Sleep(time.Duration(1) * time.Second)
 	assert.Equal(t, 1, exitCode)
 }
+
+func TestStartNetconf(t *testing.T) {
+	assert.True(t, o1Agent.StartNetconf())
+	assert.Nil(t, o1Agent.netconfServer)
+}
+
+func TestRestconfServer(t *testing.T) {
+	assert.True(t, o1Agent.StartRestconf())
+
+	viper.Set("restconf.enabled", true)
+	viper.Set("nbi.yangDir", "../yang")
+	defer func() {
+		viper.Set("restconf.enabled", false)
+		viper.Set("restconf.users", map[string]string{})
+		viper.Set("nbi.yangDir", "")
+	}()
+
+	// without users RESTCONF cannot authenticate its clients
+	o := NewO1Agent()
+	assert.Nil(t, o.restconfServer)
+	assert.False(t, o.StartRestconf())
+
+	viper.Set("restconf.users", map[string]string{"admin": "secret"})
+	o = NewO1Agent()
+	assert.NotNil(t, o.restconfServer)
+	assert.Nil(t, o.netconfServer)
+	assert.True(t, o.StartRestconf())
+}
+
+func TestGnmiServer(t *testing.T) {
+	assert.True(t, o1Agent.StartGnmi())
+
+	viper.Set("gnmi.enabled", true)
+	viper.Set("gnmi.addr", "127.0.0.1:0")
+	viper.Set("nbi.yangDir", "../yang")
+	defer func() {
+		viper.Set("gnmi.enabled", false)
+		viper.Set("gnmi.addr", "")
+		viper.Set("nbi.yangDir", "")
+	}()
+
+	o := NewO1Agent()
+	assert.NotNil(t, o.gnmiServer)
+	assert.True(t, o.StartGnmi())
+	o.gnmiServer.Close()
+
+	// users need TLS
+	viper.Set("gnmi.users", map[string]string{"gnmi": "gnmi"})
+	defer viper.Set("gnmi.users", map[string]string{})
+	o = NewO1Agent()
+	assert.Nil(t, o.gnmiServer)
+	assert.False(t, o.StartGnmi())
+}
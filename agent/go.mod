@@ -14,14 +14,18 @@ require (
 	github.com/basgys/goxml2json v1.1.0
 	github.com/go-openapi/errors v0.19.3
 	github.com/go-openapi/runtime v0.19.7
+	github.com/go-openapi/spec v0.19.4
 	github.com/go-openapi/strfmt v0.19.4
 	github.com/go-openapi/swag v0.19.7
 	github.com/go-openapi/validate v0.19.6
+	github.com/openconfig/gnmi v0.0.0-20200617225440-d2b4e6a45802
 	github.com/prometheus/alertmanager v0.20.0
 	github.com/spf13/viper v1.4.0
 	github.com/stretchr/testify v1.6.1
 	github.com/valyala/fastjson v1.4.1
 	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
+	google.golang.org/grpc v1.29.1
+	gopkg.in/yaml.v2 v2.3.0
 )
 
 require (
@@ -44,7 +48,6 @@ require (
 	github.com/go-openapi/jsonpointer v0.19.3 // indirect
 	github.com/go-openapi/jsonreference v0.19.3 // indirect
 	github.com/go-openapi/loads v0.19.4 // indirect
-	github.com/go-openapi/spec v0.19.4 // indirect
 	github.com/go-redis/redis v6.15.9+incompatible // indirect
 	github.com/go-stack/stack v1.8.0 // indirect
 	github.com/golang/protobuf v1.4.2 // indirect
@@ -71,8 +74,8 @@ require (
 	golang.org/x/net v0.0.0-20200520004742-59133d7f0dd7 // indirect
 	golang.org/x/sys v0.0.0-20200519105757-fe76b779f299 // indirect
 	golang.org/x/text v0.3.5 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
 	google.golang.org/protobuf v1.23.0 // indirect
-	gopkg.in/yaml.v2 v2.3.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 	k8s.io/utils v0.0.0-20201110183641-67b214c5f920 // indirect
 )
@@ -0,0 +1,224 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package audit records every configuration transaction of the O1 agent:
+// who made it, what changed, which SBI calls it caused and how it ended.
+package audit
+
+import (
+	"fmt"
+	"log/syslog"
+	"strconv"
+	"sync"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"github.com/spf13/viper"
+)
+
+var log = xapp.Logger
+
+// Outcomes of a transaction
+const (
+	OutcomeCommitted = "committed"
+	OutcomeRejected  = "rejected"
+	OutcomeAborted   = "aborted"
+	// OutcomeDryRun marks a transaction that was only planned: its SBI
+	// calls are the ones it would have made
+	OutcomeDryRun = "dry-run"
+)
+
+// Change is one node created, modified or deleted by a transaction
+type Change struct {
+	Operation string `json:"operation"`
+	Xpath     string `json:"xpath"`
+	Before    string `json:"before,omitempty"`
+	After     string `json:"after,omitempty"`
+}
+
+// Record describes one transaction on one module. Originator names the
+// northbound interface the edit came through, User and Session identify
+// the client on it.
+type Record struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Originator string    `json:"originator,omitempty"`
+	User       string    `json:"user,omitempty"`
+	Session    uint32    `json:"session,omitempty"`
+	Module     string    `json:"module"`
+	Changes    []Change  `json:"changes"`
+	SBICalls   []string  `json:"sbi-calls,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+
+	mu sync.Mutex
+}
+
+// AddSBICall notes a call made to the appmgr or another SBI endpoint on
+// behalf of the transaction. It does nothing on a nil record, so callers
+// don't have to care whether the transaction is audited.
+func (r *Record) AddSBICall(format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.SBICalls = append(r.SBICalls, fmt.Sprintf(format, args...))
+}
+
+// Sink receives every finished record
+type Sink interface {
+	Write(r *Record) error
+	Close() error
+}
+
+// Config selects the sinks of the audit log. File is rotated once it grows
+// beyond MaxSize bytes, keeping MaxBackups older files. History is the
+// number of records kept in memory for the operational audit list.
+type Config struct {
+	File       string
+	MaxSize    int64
+	MaxBackups int
+	Syslog     bool
+	HTTPURL    string
+	History    int
+}
+
+// DefaultConfig keeps the records in memory only
+func DefaultConfig() Config {
+	return Config{
+		MaxSize:    10 * 1024 * 1024,
+		MaxBackups: 5,
+		History:    100,
+	}
+}
+
+// ConfigFromViper reads the audit section of the configuration. maxSize is
+// given in megabytes.
+func ConfigFromViper() Config {
+	cfg := DefaultConfig()
+	cfg.File = viper.GetString("audit.file")
+	if viper.IsSet("audit.maxSize") {
+		cfg.MaxSize = viper.GetInt64("audit.maxSize") * 1024 * 1024
+	}
+	if viper.IsSet("audit.maxBackups") {
+		cfg.MaxBackups = viper.GetInt("audit.maxBackups")
+	}
+	cfg.Syslog = viper.GetBool("audit.syslog")
+	cfg.HTTPURL = viper.GetString("audit.httpURL")
+	if viper.IsSet("audit.history") {
+		cfg.History = viper.GetInt("audit.history")
+	}
+	return cfg
+}
+
+// Logger numbers the records, hands them to the sinks and keeps the latest
+// ones for reading back
+type Logger struct {
+	sinks   []Sink
+	history int
+
+	mu      sync.Mutex
+	lastID  uint64
+	records []*Record
+}
+
+// NewLogger opens the sinks of cfg
+func NewLogger(cfg Config) (*Logger, error) {
+	l := &Logger{history: cfg.History}
+
+	if cfg.File != "" {
+		f, err := NewFileSink(cfg.File, cfg.MaxSize, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		l.sinks = append(l.sinks, f)
+	}
+	if cfg.Syslog {
+		s, err := NewSyslogSink(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, "o1agent")
+		if err != nil {
+			l.Close()
+			return nil, err
+		}
+		l.sinks = append(l.sinks, s)
+	}
+	if cfg.HTTPURL != "" {
+		l.sinks = append(l.sinks, NewHTTPSink(cfg.HTTPURL, 5*time.Second, 256))
+	}
+	return l, nil
+}
+
+// NewLoggerWithSinks returns a logger writing to the given sinks
+func NewLoggerWithSinks(history int, sinks ...Sink) *Logger {
+	return &Logger{history: history, sinks: sinks}
+}
+
+// Log numbers and timestamps r and writes it to every sink. A failing sink
+// is logged, the others still get the record.
+func (l *Logger) Log(r *Record) {
+	if l == nil || r == nil {
+		return
+	}
+
+	l.mu.Lock()
+	l.lastID++
+	r.ID = strconv.FormatUint(l.lastID, 10)
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if l.history > 0 {
+		l.records = append(l.records, r)
+		if len(l.records) > l.history {
+			l.records = l.records[len(l.records)-l.history:]
+		}
+	}
+	l.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range l.sinks {
+		if err := s.Write(r); err != nil {
+			log.Error("audit: writing record %s failed: %v", r.ID, err)
+		}
+	}
+}
+
+// Records returns the records kept in memory, oldest first
+func (l *Logger) Records() []*Record {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]*Record{}, l.records...)
+}
+
+// Close flushes and closes every sink
+func (l *Logger) Close() {
+	if l == nil {
+		return
+	}
+	for _, s := range l.sinks {
+		if err := s.Close(); err != nil {
+			log.Error("audit: closing sink failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func readRecords(t *testing.T, path string) []*audit.Record {
+	f, err := os.Open(path)
+	if !assert.Nil(t, err) {
+		return nil
+	}
+	defer f.Close()
+
+	var records []*audit.Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		r := &audit.Record{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func newRecord(module string) *audit.Record {
+	return &audit.Record{
+		Time:       time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+		Originator: "netconf",
+		User:       "admin",
+		Session:    3,
+		Module:     module,
+		Changes:    []audit.Change{{Operation: "modified", Xpath: "/" + module + ":ric/x", Before: "1", After: "2"}},
+		Outcome:    audit.OutcomeCommitted,
+	}
+}
+
+func TestFileSinkWritesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	sink, err := audit.NewFileSink(path, 0, 0)
+	assert.Nil(t, err)
+
+	l := audit.NewLoggerWithSinks(10, sink)
+	rec := newRecord("mod-a")
+	rec.AddSBICall("deploy xApp '%s'", "anr")
+	l.Log(rec)
+	l.Log(newRecord("mod-b"))
+	l.Close()
+
+	records := readRecords(t, path)
+	if assert.Equal(t, 2, len(records)) {
+		assert.Equal(t, "1", records[0].ID)
+		assert.Equal(t, "mod-a", records[0].Module)
+		assert.Equal(t, "admin", records[0].User)
+		assert.Equal(t, uint32(3), records[0].Session)
+		assert.Equal(t, []string{"deploy xApp 'anr'"}, records[0].SBICalls)
+		assert.Equal(t, "2", records[0].Changes[0].After)
+		assert.Equal(t, 2026, records[0].Time.Year())
+		assert.Equal(t, "2", records[1].ID)
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	line, _ := json.Marshal(newRecord("mod-a"))
+	sink, err := audit.NewFileSink(path, int64(len(line))*2+10, 2)
+	assert.Nil(t, err)
+
+	l := audit.NewLoggerWithSinks(0, sink)
+	for i := 0; i < 7; i++ {
+		l.Log(newRecord("mod-a"))
+	}
+	l.Close()
+
+	// Two records fit into a file, the oldest ones are dropped
+	assert.Equal(t, 1, len(readRecords(t, path)))
+	assert.Equal(t, 2, len(readRecords(t, path+".1")))
+	assert.Equal(t, 2, len(readRecords(t, path+".2")))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, "7", readRecords(t, path)[0].ID)
+	assert.Equal(t, "3", readRecords(t, path+".2")[0].ID)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var received []*audit.Record
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		rec := &audit.Record{}
+		assert.Nil(t, json.Unmarshal(body, rec))
+		mu.Lock()
+		received = append(received, rec)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	l := audit.NewLoggerWithSinks(0, audit.NewHTTPSink(ts.URL, time.Second, 8))
+	l.Log(newRecord("mod-a"))
+	l.Log(newRecord("mod-b"))
+	l.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Equal(t, 2, len(received)) {
+		assert.Equal(t, "mod-a", received[0].Module)
+		assert.Equal(t, "mod-b", received[1].Module)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	l := audit.NewLoggerWithSinks(2)
+	for _, m := range []string{"mod-a", "mod-b", "mod-c"} {
+		l.Log(newRecord(m))
+	}
+
+	records := l.Records()
+	if assert.Equal(t, 2, len(records)) {
+		assert.Equal(t, "mod-b", records[0].Module)
+		assert.Equal(t, "mod-c", records[1].Module)
+	}
+
+	var nilRecord *audit.Record
+	nilRecord.AddSBICall("ignored")
+}
+
+func TestConfigFromViperDefaults(t *testing.T) {
+	cfg := audit.ConfigFromViper()
+	assert.Equal(t, "", cfg.File)
+	assert.Equal(t, 100, cfg.History)
+	assert.Equal(t, int64(10*1024*1024), cfg.MaxSize)
+}
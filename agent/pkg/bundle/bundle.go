@@ -0,0 +1,137 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package bundle is the versioned format of a complete O1 configuration of
+// a RIC, as exported by the agent and imported into another RIC: the running
+// configuration of every managed module and the deployed xApps.
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Format is the version of the bundle format written by Encode. Decode
+// accepts bundles up to this version.
+const Format = 1
+
+// Encodings of a bundle
+const (
+	JSON = "json"
+	XML  = "xml"
+)
+
+// Bundle is the configuration of a RIC at the time it was created
+type Bundle struct {
+	XMLName xml.Name  `json:"-" xml:"o1-config-bundle"`
+	Format  int       `json:"format" xml:"format"`
+	Created time.Time `json:"created" xml:"created"`
+	Modules []Module  `json:"modules" xml:"module"`
+	Xapps   []Xapp    `json:"xapps" xml:"xapp"`
+}
+
+// Module is the running configuration of one YANG module, as leaves
+// addressed by their absolute xpath
+type Module struct {
+	Name   string `json:"name" xml:"name"`
+	Leaves []Leaf `json:"leaves" xml:"leaf"`
+}
+
+// Leaf is one configured leaf
+type Leaf struct {
+	Xpath string `json:"xpath" xml:"xpath"`
+	Value string `json:"value" xml:"value"`
+}
+
+// Xapp is an xApp the appmgr had deployed
+type Xapp struct {
+	Name    string `json:"name" xml:"name"`
+	Version string `json:"version,omitempty" xml:"version,omitempty"`
+	Status  string `json:"status,omitempty" xml:"status,omitempty"`
+}
+
+// New returns an empty bundle of the current format
+func New() *Bundle {
+	return &Bundle{Format: Format, Created: time.Now().UTC()}
+}
+
+// Module returns the configuration of the named module, nil if the bundle
+// has none
+func (b *Bundle) Module(name string) *Module {
+	for i := range b.Modules {
+		if b.Modules[i].Name == name {
+			return &b.Modules[i]
+		}
+	}
+	return nil
+}
+
+// AddModule adds the configuration of a module, given as xpath to value
+func (b *Bundle) AddModule(name string, leaves map[string]string) {
+	m := Module{Name: name, Leaves: []Leaf{}}
+	for xpath, value := range leaves {
+		m.Leaves = append(m.Leaves, Leaf{Xpath: xpath, Value: value})
+	}
+	sort.Slice(m.Leaves, func(i, j int) bool { return m.Leaves[i].Xpath < m.Leaves[j].Xpath })
+	b.Modules = append(b.Modules, m)
+}
+
+// Encode writes the bundle as JSON or XML, indented for people to read
+func (b *Bundle) Encode(encoding string) ([]byte, error) {
+	switch encoding {
+	case JSON, "":
+		return json.MarshalIndent(b, "", "  ")
+	case XML:
+		data, err := xml.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), data...), nil
+	}
+	return nil, fmt.Errorf("unknown bundle encoding '%s'", encoding)
+}
+
+// Decode reads a bundle in either encoding, telling them apart by their
+// first character
+func Decode(data []byte) (*Bundle, error) {
+	b := &Bundle{}
+	var err error
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '<' {
+		err = xml.Unmarshal(trimmed, b)
+	} else {
+		err = json.Unmarshal(trimmed, b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %v", err)
+	}
+
+	if b.Format < 1 || b.Format > Format {
+		return nil, fmt.Errorf("unsupported bundle format %d", b.Format)
+	}
+	for _, m := range b.Modules {
+		if m.Name == "" {
+			return nil, fmt.Errorf("invalid bundle: module without a name")
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,67 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package bundle_test
+
+import (
+	"testing"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/bundle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	b := bundle.New()
+	b.AddModule("o-ran-sc-ric-xapp-desc-v1", map[string]string{
+		"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']/version":      "1.0.0",
+		"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']/name":         "ueec",
+		"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']/release-name": "ueec",
+	})
+	b.Xapps = []bundle.Xapp{{Name: "ueec", Version: "1.0.0", Status: "deployed"}}
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']/name", b.Module("o-ran-sc-ric-xapp-desc-v1").Leaves[0].Xpath)
+	assert.Nil(t, b.Module("o-ran-sc-ric-ueec-config-v1"))
+
+	for _, encoding := range []string{bundle.JSON, bundle.XML} {
+		data, err := b.Encode(encoding)
+		assert.Nil(t, err)
+		decoded, err := bundle.Decode(data)
+		if assert.Nil(t, err, encoding) {
+			assert.Equal(t, b.Modules, decoded.Modules, encoding)
+			assert.Equal(t, b.Xapps, decoded.Xapps, encoding)
+			assert.True(t, b.Created.Equal(decoded.Created), encoding)
+		}
+	}
+
+	_, err := b.Encode("yaml")
+	assert.NotNil(t, err)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"{",
+		`{"format": 2, "modules": []}`,
+		`{"modules": []}`,
+		`{"format": 1, "modules": [{"leaves": []}]}`,
+		"<o1-config-bundle><format>x</format></o1-config-bundle>",
+	} {
+		_, err := bundle.Decode([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+}
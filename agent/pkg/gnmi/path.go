@@ -0,0 +1,415 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package gnmi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const wildcard = "*"
+
+// pattern is a gNMI path resolved against the schema. Key values may be
+// the wildcard "*", a list step without keys selects every entry.
+type pattern struct {
+	segs    []nbi.PathSegment
+	entries []*yang.Entry
+}
+
+func (p *pattern) entry() *yang.Entry {
+	return p.entries[len(p.entries)-1]
+}
+
+// concrete tells if the pattern addresses a single data node
+func (p *pattern) concrete() bool {
+	for i, seg := range p.segs {
+		if len(seg.Keys) != len(p.entries[i].Keys) {
+			return false
+		}
+		for _, k := range seg.Keys {
+			if k.Value == wildcard {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// xpath returns the path of the pattern up to its first wildcard step
+func (p *pattern) xpath() string {
+	var b strings.Builder
+	module := ""
+	for i, seg := range p.segs {
+		if len(seg.Keys) != len(p.entries[i].Keys) {
+			break
+		}
+		wild := false
+		for _, k := range seg.Keys {
+			wild = wild || k.Value == wildcard
+		}
+		if wild {
+			break
+		}
+		b.WriteString("/" + seg.String(seg.Module != module))
+		module = seg.Module
+	}
+	return b.String()
+}
+
+// matches tells if the data node path segs lies at or below the pattern
+func (p *pattern) matches(segs []nbi.PathSegment) bool {
+	if len(segs) < len(p.segs) {
+		return false
+	}
+	for i, seg := range p.segs {
+		if segs[i].Name != seg.Name || segs[i].Module != seg.Module {
+			return false
+		}
+		for _, k := range seg.Keys {
+			if k.Value == wildcard {
+				continue
+			}
+			found := false
+			for _, dk := range segs[i].Keys {
+				found = found || (dk.Name == k.Name && dk.Value == k.Value)
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resolve joins prefix and path and maps the elements onto the schema. The
+// first element names its module with the origin or a "module:" prefix.
+func (s *Server) resolve(prefix, path *pb.Path) (*pattern, error) {
+	var elems []*pb.PathElem
+	origin := ""
+	for _, p := range []*pb.Path{prefix, path} {
+		if p == nil {
+			continue
+		}
+		if p.Origin != "" {
+			origin = p.Origin
+		}
+		elems = append(elems, p.Elem...)
+	}
+	if len(elems) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty path")
+	}
+
+	pat := &pattern{}
+	module := origin
+	var parent *yang.Entry
+	for _, elem := range elems {
+		name := elem.Name
+		if i := strings.Index(name, ":"); i >= 0 {
+			module, name = name[:i], name[i+1:]
+		}
+		if module == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "missing module of '%s', use the origin or a module prefix", name)
+		}
+
+		var entry *yang.Entry
+		if parent == nil {
+			entry = s.schema.Find(module, []string{name})
+		} else if entry = parent.Child(name); entry != nil && entry.Module.Name != module {
+			entry = nil
+		}
+		if entry == nil || (entry.Kind != yang.Container && entry.Kind != yang.List && !entry.IsLeaf()) {
+			return nil, status.Errorf(codes.NotFound, "unknown path element '%s:%s'", module, name)
+		}
+
+		seg := nbi.PathSegment{Module: module, Name: name}
+		for k := range elem.Key {
+			if !entry.IsKey(k) {
+				return nil, status.Errorf(codes.InvalidArgument, "'%s' is not a key of '%s'", k, name)
+			}
+		}
+		for _, k := range entry.Keys {
+			if v, ok := elem.Key[k]; ok {
+				seg.Keys = append(seg.Keys, nbi.KeyValue{Name: k, Value: v})
+			}
+		}
+
+		pat.segs = append(pat.segs, seg)
+		pat.entries = append(pat.entries, entry)
+		parent = entry
+	}
+	return pat, nil
+}
+
+// segments parses a data node xpath, filling in the inherited module names
+func segments(xpath string) []nbi.PathSegment {
+	segs, err := nbi.ParsePath(xpath)
+	if err != nil {
+		return nil
+	}
+	module := ""
+	for i := range segs {
+		if segs[i].Module == "" {
+			segs[i].Module = module
+		}
+		module = segs[i].Module
+	}
+	return segs
+}
+
+// toPath converts data node path segments into a gNMI path
+func toPath(segs []nbi.PathSegment) *pb.Path {
+	path := &pb.Path{}
+	module := ""
+	for _, seg := range segs {
+		elem := &pb.PathElem{Name: seg.Name}
+		if seg.Module != module {
+			elem.Name = seg.Module + ":" + seg.Name
+		}
+		if len(seg.Keys) > 0 {
+			elem.Key = make(map[string]string)
+			for _, k := range seg.Keys {
+				elem.Key[k.Name] = k.Value
+			}
+		}
+		path.Elem = append(path.Elem, elem)
+		module = seg.Module
+	}
+	return path
+}
+
+// PathString formats a gNMI path like /module:ric/xapps/xapp[name=x]
+func PathString(p *pb.Path) string {
+	if p == nil || len(p.Elem) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for _, elem := range p.Elem {
+		b.WriteString("/" + elem.Name)
+		keys := make([]string, 0, len(elem.Key))
+		for k := range elem.Key {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString("[" + k + "=" + elem.Key[k] + "]")
+		}
+	}
+	return b.String()
+}
+
+// leafEntry returns the schema node of a data node path
+func (s *Server) leafEntry(segs []nbi.PathSegment) *yang.Entry {
+	if len(segs) == 0 {
+		return nil
+	}
+	names := make([]string, len(segs))
+	for i, seg := range segs {
+		names[i] = seg.Name
+	}
+	return s.schema.Find(segs[0].Module, names)
+}
+
+// typedValue encodes a leaf value, as a scalar for PROTO and as RFC 7951
+// JSON otherwise.
+func typedValue(value string, entry *yang.Entry, encoding pb.Encoding) *pb.TypedValue {
+	leafType := ""
+	if entry != nil {
+		leafType = entry.Type
+	}
+
+	if encoding == pb.Encoding_JSON || encoding == pb.Encoding_JSON_IETF {
+		data, _ := json.Marshal(value)
+		switch leafType {
+		case "boolean":
+			if value == "true" || value == "false" {
+				data = []byte(value)
+			}
+		case "int8", "int16", "int32", "uint8", "uint16", "uint32":
+			if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+				data = []byte(value)
+			}
+		}
+		if encoding == pb.Encoding_JSON {
+			return &pb.TypedValue{Value: &pb.TypedValue_JsonVal{JsonVal: data}}
+		}
+		return &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: data}}
+	}
+
+	switch {
+	case leafType == "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return &pb.TypedValue{Value: &pb.TypedValue_BoolVal{BoolVal: b}}
+		}
+	case strings.HasPrefix(leafType, "uint"):
+		if u, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: u}}
+		}
+	case strings.HasPrefix(leafType, "int"):
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return &pb.TypedValue{Value: &pb.TypedValue_IntVal{IntVal: i}}
+		}
+	}
+	return &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: value}}
+}
+
+// scalarValue returns the string form of a scalar TypedValue
+func scalarValue(v *pb.TypedValue) (string, bool) {
+	switch val := v.Value.(type) {
+	case *pb.TypedValue_StringVal:
+		return val.StringVal, true
+	case *pb.TypedValue_BoolVal:
+		return strconv.FormatBool(val.BoolVal), true
+	case *pb.TypedValue_UintVal:
+		return strconv.FormatUint(val.UintVal, 10), true
+	case *pb.TypedValue_IntVal:
+		return strconv.FormatInt(val.IntVal, 10), true
+	case *pb.TypedValue_AsciiVal:
+		return val.AsciiVal, true
+	}
+	return "", false
+}
+
+// jsonValue returns the JSON document of a TypedValue, if it holds one
+func jsonValue(v *pb.TypedValue) ([]byte, bool) {
+	switch val := v.Value.(type) {
+	case *pb.TypedValue_JsonIetfVal:
+		return val.JsonIetfVal, true
+	case *pb.TypedValue_JsonVal:
+		return val.JsonVal, true
+	}
+	return nil, false
+}
+
+// leafItem is a leaf to be set by a Set request
+type leafItem struct {
+	xpath string
+	value string
+}
+
+// jsonItems flattens a JSON value of the schema node entry at xpath into
+// the items to set. Containers and list entries get an item with an empty
+// value so that they are created even without leaves.
+func jsonItems(xpath string, entry *yang.Entry, data []byte) ([]leafItem, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var items []leafItem
+	if err := flatten(xpath, entry, v, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func flatten(xpath string, entry *yang.Entry, v interface{}, items *[]leafItem) error {
+	if entry.IsLeaf() {
+		value, err := jsonScalar(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", xpath, err)
+		}
+		*items = append(*items, leafItem{xpath, value})
+		return nil
+	}
+
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: object expected", xpath)
+	}
+	*items = append(*items, leafItem{xpath, ""})
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, member := range names {
+		name := member
+		if i := strings.Index(member, ":"); i >= 0 {
+			name = member[i+1:]
+		}
+		child := entry.Child(name)
+		if child == nil {
+			return fmt.Errorf("%s: unknown element '%s'", xpath, member)
+		}
+		if entry.IsKey(name) {
+			continue
+		}
+		if child.Kind != yang.List {
+			if err := flatten(xpath+"/"+name, child, obj[member], items); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entries, ok := obj[member].([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: list '%s' must be an array", xpath, member)
+		}
+		for _, e := range entries {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: entry of '%s' must be an object", xpath, member)
+			}
+			seg := nbi.PathSegment{Name: name}
+			for _, k := range child.Keys {
+				value, err := jsonScalar(m[k])
+				if m[k] == nil || err != nil {
+					return fmt.Errorf("%s: missing key '%s' of list '%s'", xpath, k, name)
+				}
+				seg.Keys = append(seg.Keys, nbi.KeyValue{Name: k, Value: value})
+			}
+			if err := flatten(xpath+"/"+seg.String(false), child, m, items); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonScalar(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case []interface{}:
+		if len(val) == 1 && val[0] == nil {
+			return "", nil
+		}
+	}
+	return "", fmt.Errorf("invalid leaf value")
+}
@@ -0,0 +1,457 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package gnmi is a gNMI server (Capabilities, Get, Set and Subscribe) over
+// the agent's YANG modules. Paths start with a module qualified element, e.g.
+// /o-ran-sc-ric-xapp-desc-v1:ric/health, and data is read through an
+// nbi.Store so that the NBI operational data providers fill in the state.
+package gnmi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"time"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const gnmiVersion = "0.7.0"
+
+var log = xapp.Logger
+
+// Config holds the listen address, TLS files, user credentials and the
+// telemetry timing of the gNMI server.
+type Config struct {
+	Addr              string
+	CertFile          string
+	KeyFile           string
+	Users             map[string]string
+	MinSampleInterval time.Duration
+	ChangeInterval    time.Duration
+}
+
+// ConfigFromViper reads the "gnmi" section of the agent configuration
+func ConfigFromViper() Config {
+	c := Config{
+		Addr:              viper.GetString("gnmi.addr"),
+		CertFile:          viper.GetString("gnmi.tlsCert"),
+		KeyFile:           viper.GetString("gnmi.tlsKey"),
+		Users:             viper.GetStringMapString("gnmi.users"),
+		MinSampleInterval: time.Duration(viper.GetInt("gnmi.minSampleInterval")) * time.Millisecond,
+		ChangeInterval:    time.Duration(viper.GetInt("gnmi.changeInterval")) * time.Millisecond,
+	}
+	if c.Addr == "" {
+		c.Addr = ":9339"
+	}
+	if c.MinSampleInterval <= 0 {
+		c.MinSampleInterval = time.Second
+	}
+	if c.ChangeInterval <= 0 {
+		c.ChangeInterval = time.Second
+	}
+	return c
+}
+
+type Server struct {
+	pb.UnimplementedGNMIServer
+
+	config Config
+	store  nbi.Store
+	schema *yang.Schema
+	grpc   *grpc.Server
+}
+
+// NewServer fails if users are configured without TLS, as their passwords
+// would travel in clear text. Without users every call is denied.
+func NewServer(config Config, store nbi.Store, schema *yang.Schema) (*Server, error) {
+	s := &Server{config: config, store: store, schema: schema}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryAuth),
+		grpc.StreamInterceptor(s.streamAuth),
+	}
+	switch {
+	case config.CertFile != "":
+		creds, err := credentials.NewServerTLSFromFile(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	case len(config.Users) > 0:
+		return nil, errors.New("users configured without a TLS certificate")
+	}
+	if len(config.Users) == 0 {
+		log.Warn("gNMI: no users configured, every call is denied")
+	}
+
+	s.grpc = grpc.NewServer(opts...)
+	pb.RegisterGNMIServer(s.grpc, s)
+	return s, nil
+}
+
+// ListenAndServe accepts gNMI clients on the configured address
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	log.Info("gNMI: listening on %s", l.Addr())
+	return s.grpc.Serve(l)
+}
+
+// Close stops the server and ends every subscription
+func (s *Server) Close() {
+	s.grpc.Stop()
+}
+
+// authorize checks the username and password metadata of a call against
+// the users
+func (s *Server) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	user, password := username(ctx), ""
+	if v := md.Get("password"); len(v) > 0 {
+		password = v[0]
+	}
+
+	expected, ok := s.config.Users[user]
+	if ok && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1 {
+		return nil
+	}
+	log.Warn("gNMI: authentication failed for user '%s'", user)
+	return status.Error(codes.Unauthenticated, "authentication failed")
+}
+
+// username returns the username metadata of a call
+func username(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("username"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (s *Server) Capabilities(ctx context.Context, req *pb.CapabilityRequest) (*pb.CapabilityResponse, error) {
+	resp := &pb.CapabilityResponse{
+		SupportedEncodings: []pb.Encoding{pb.Encoding_JSON, pb.Encoding_JSON_IETF, pb.Encoding_PROTO},
+		GNMIVersion:        gnmiVersion,
+	}
+	for _, name := range s.schema.ModuleNames() {
+		m := s.schema.Modules[name]
+		resp.SupportedModels = append(resp.SupportedModels, &pb.ModelData{
+			Name:         m.Name,
+			Organization: "O-RAN Software Community",
+			Version:      m.Revision,
+		})
+	}
+	return resp, nil
+}
+
+func checkEncoding(encoding pb.Encoding) error {
+	switch encoding {
+	case pb.Encoding_JSON, pb.Encoding_JSON_IETF, pb.Encoding_PROTO:
+		return nil
+	}
+	return status.Errorf(codes.Unimplemented, "unsupported encoding %s", encoding)
+}
+
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	if err := checkEncoding(req.Encoding); err != nil {
+		return nil, err
+	}
+
+	if len(req.Path) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no path requested")
+	}
+
+	resp := &pb.GetResponse{}
+	for _, path := range req.Path {
+		pat, err := s.resolve(req.Prefix, path)
+		if err != nil {
+			return nil, err
+		}
+
+		matches, err := s.collect(pat, req.Type)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 && pat.concrete() {
+			return nil, status.Errorf(codes.NotFound, "no data at '%s'", PathString(path))
+		}
+
+		notif := &pb.Notification{Timestamp: time.Now().UnixNano()}
+		for _, m := range matches {
+			if req.Encoding == pb.Encoding_PROTO {
+				notif.Update = append(notif.Update, s.leafUpdates(m, req.Encoding)...)
+				continue
+			}
+			notif.Update = append(notif.Update, s.nodeUpdate(m, req.Encoding))
+		}
+		resp.Notification = append(resp.Notification, notif)
+	}
+	return resp, nil
+}
+
+// match is a data node selected by a pattern
+type match struct {
+	xpath string
+	segs  []nbi.PathSegment
+	node  *nbi.Node
+}
+
+// collect reads the data below a pattern and returns the matching nodes.
+// CONFIG data is read from the running datastore, everything else through
+// the operational data providers.
+func (s *Server) collect(pat *pattern, dataType pb.GetRequest_DataType) ([]match, error) {
+	var tree *nbi.Node
+	if dataType == pb.GetRequest_CONFIG {
+		tree = s.store.GetConfig(pat.xpath())
+	} else {
+		var err error
+		if tree, err = s.store.GetItems(pat.xpath()); err != nil {
+			log.Error("gNMI: reading '%s' failed: %v", pat.xpath(), err)
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	switch dataType {
+	case pb.GetRequest_CONFIG:
+		s.prune(tree, nil, func(e *yang.Entry) bool { return e.Config })
+	case pb.GetRequest_STATE, pb.GetRequest_OPERATIONAL:
+		s.prune(tree, nil, func(e *yang.Entry) bool { return !e.Config })
+	}
+
+	var matches []match
+	tree.Walk(func(xpath string, node *nbi.Node) {
+		segs := segments(xpath)
+		if len(segs) == len(pat.segs) && pat.matches(segs) {
+			matches = append(matches, match{xpath, segs, node})
+		}
+	})
+	return matches, nil
+}
+
+// prune removes the leaves rejected by keep. List keys are kept, nodes left
+// without other content are removed. It returns false when n becomes empty.
+func (s *Server) prune(n *nbi.Node, entry *yang.Entry, keep func(e *yang.Entry) bool) bool {
+	if n.Leaf {
+		return entry == nil || keep(entry)
+	}
+
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		var e *yang.Entry
+		if entry == nil {
+			e = s.schema.Find(child.Module, []string{child.Name})
+		} else {
+			e = entry.Child(child.Name)
+		}
+		if (entry != nil && entry.IsKey(child.Name)) || s.prune(child, e, keep) {
+			children = append(children, child)
+		}
+	}
+	n.Children = children
+
+	return len(n.Children) > len(n.Keys)
+}
+
+// leafUpdates returns an update for every leaf at or below a match
+func (s *Server) leafUpdates(m match, encoding pb.Encoding) []*pb.Update {
+	var updates []*pb.Update
+	add := func(segs []nbi.PathSegment, leaf *nbi.Node) {
+		updates = append(updates, &pb.Update{
+			Path: toPath(segs),
+			Val:  typedValue(leaf.Value, s.leafEntry(segs), encoding),
+		})
+	}
+
+	if m.node.Leaf {
+		add(m.segs, m.node)
+		return updates
+	}
+
+	m.node.Walk(func(xpath string, node *nbi.Node) {
+		if node.Leaf {
+			add(segments(m.xpath+xpath), node)
+		}
+	})
+	return updates
+}
+
+// nodeUpdate returns a single JSON encoded update of a match
+func (s *Server) nodeUpdate(m match, encoding pb.Encoding) *pb.Update {
+	if m.node.Leaf {
+		return &pb.Update{Path: toPath(m.segs), Val: typedValue(m.node.Value, s.leafEntry(m.segs), encoding)}
+	}
+
+	data := []byte((&nbi.Node{Module: m.node.Module, Children: m.node.Children}).JSON())
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+	val := &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: data}}
+	if encoding == pb.Encoding_JSON {
+		val = &pb.TypedValue{Value: &pb.TypedValue_JsonVal{JsonVal: data}}
+	}
+	return &pb.Update{Path: toPath(m.segs), Val: val}
+}
+
+// Set applies deletes, replaces and updates, in that order, as a single
+// transaction of the running datastore.
+func (s *Server) Set(ctx context.Context, req *pb.SetRequest) (*pb.SetResponse, error) {
+	edits := s.store.EditLock()
+	edits.Lock()
+	defer edits.Unlock()
+
+	s.store.DiscardChanges()
+	resp := &pb.SetResponse{Prefix: req.Prefix}
+
+	for _, path := range req.Delete {
+		pat, err := s.configTarget(req.Prefix, path)
+		if err != nil {
+			s.store.DiscardChanges()
+			return nil, err
+		}
+		if s.store.HasItem(pat.xpath()) {
+			s.store.DeleteItem(pat.xpath())
+		}
+		resp.Response = append(resp.Response, &pb.UpdateResult{Path: path, Op: pb.UpdateResult_DELETE})
+	}
+
+	for _, u := range req.Replace {
+		if err := s.setUpdate(req.Prefix, u, true); err != nil {
+			s.store.DiscardChanges()
+			return nil, err
+		}
+		resp.Response = append(resp.Response, &pb.UpdateResult{Path: u.Path, Op: pb.UpdateResult_REPLACE})
+	}
+
+	for _, u := range req.Update {
+		if err := s.setUpdate(req.Prefix, u, false); err != nil {
+			s.store.DiscardChanges()
+			return nil, err
+		}
+		resp.Response = append(resp.Response, &pb.UpdateResult{Path: u.Path, Op: pb.UpdateResult_UPDATE})
+	}
+
+	if err := s.store.ApplyChangesAs(nbi.Originator{Name: "gnmi", User: username(ctx)}); err != nil {
+		log.Error("gNMI: set failed: %v", err)
+		return nil, changeError(err)
+	}
+	resp.Timestamp = time.Now().UnixNano()
+	return resp, nil
+}
+
+// changeError reports a rejected commit, naming the offending node of an
+// nbi.ChangeError
+func changeError(err error) error {
+	var cerr *nbi.ChangeError
+	if !errors.As(err, &cerr) {
+		return status.Error(codes.Aborted, err.Error())
+	}
+
+	code := codes.Aborted
+	switch cerr.Tag {
+	case "invalid-value":
+		code = codes.InvalidArgument
+	case "operation-not-supported":
+		code = codes.Unimplemented
+	case "access-denied":
+		code = codes.PermissionDenied
+	}
+	if cerr.Xpath == "" {
+		return status.Error(code, cerr.Message)
+	}
+	return status.Errorf(code, "%s: %s", cerr.Xpath, cerr.Message)
+}
+
+// configTarget resolves the path of a Set operation, which must address a
+// single configuration node.
+func (s *Server) configTarget(prefix, path *pb.Path) (*pattern, error) {
+	pat, err := s.resolve(prefix, path)
+	if err != nil {
+		return nil, err
+	}
+	if !pat.concrete() {
+		return nil, status.Errorf(codes.InvalidArgument, "'%s' does not address a single node", PathString(path))
+	}
+	if !pat.entry().Config {
+		return nil, status.Errorf(codes.InvalidArgument, "'%s' is not configuration data", PathString(path))
+	}
+	return pat, nil
+}
+
+func (s *Server) setUpdate(prefix *pb.Path, u *pb.Update, replace bool) error {
+	pat, err := s.configTarget(prefix, u.Path)
+	if err != nil {
+		return err
+	}
+	if u.Val == nil {
+		return status.Errorf(codes.InvalidArgument, "missing value of '%s'", PathString(u.Path))
+	}
+
+	xpath := pat.xpath()
+	var items []leafItem
+	if data, ok := jsonValue(u.Val); ok {
+		if items, err = jsonItems(xpath, pat.entry(), data); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	} else if value, ok := scalarValue(u.Val); ok && pat.entry().IsLeaf() {
+		items = []leafItem{{xpath, value}}
+	} else {
+		return status.Errorf(codes.InvalidArgument, "unsupported value of '%s'", PathString(u.Path))
+	}
+
+	if replace && s.store.HasItem(xpath) {
+		s.store.DeleteItem(xpath)
+	}
+	for _, item := range items {
+		if err := s.store.SetItem(item.xpath, item.value); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,441 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package gnmi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const xappDesc = "o-ran-sc-ric-xapp-desc-v1"
+
+var ds *nbi.MemDatastore
+var server *Server
+var client pb.GNMIClient
+
+var healthMu sync.Mutex
+var health = map[string]string{}
+
+func TestMain(m *testing.M) {
+	schema, err := yang.LoadDir("../../yang")
+	if err != nil {
+		panic(err)
+	}
+
+	ds = nbi.NewMemDatastore()
+	ds.SubscribeModuleChange(xappDesc, func(s nbi.ChangeSession, module, xpath string, event nbi.Event, reqID int) error {
+		changes, _ := s.GetChanges("//.")
+		for _, c := range changes {
+			if event == nbi.EventChange && c.NewValue == "rejected" {
+				return errors.New("xApp rejected by appmgr")
+			}
+			if event == nbi.EventChange && c.NewValue == "invalid" {
+				return &nbi.ChangeError{Xpath: c.Xpath, Tag: "invalid-value", Message: "no such helm release"}
+			}
+			if event == nbi.EventChange && c.NewValue == "forbidden" {
+				o := s.Originator()
+				return &nbi.ChangeError{Xpath: c.Xpath, Tag: "access-denied", Message: o.Name + " user '" + o.User + "' may not deploy"}
+			}
+		}
+		return nil
+	})
+	ds.SubscribeOperData(xappDesc, "/o-ran-sc-ric-xapp-desc-v1:ric/health", func(module, xpath string, tree nbi.OperDataTree) error {
+		healthMu.Lock()
+		defer healthMu.Unlock()
+		for name, h := range health {
+			tree.CreateNewElement("/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name='"+name+"']", "health", h)
+		}
+		return nil
+	})
+	ds.SubscribeOperData("o-ran-sc-ric-gnb-status-v1", "/o-ran-sc-ric-gnb-status-v1:ric/nodes", func(module, xpath string, tree nbi.OperDataTree) error {
+		tree.CreateNewElement("/o-ran-sc-ric-gnb-status-v1:ric/nodes/node[ran-name='gnb-1']", "port", "36422")
+		tree.CreateNewElement("/o-ran-sc-ric-gnb-status-v1:ric/nodes/node[ran-name='gnb-1']", "connection-status", "connected")
+		return nil
+	})
+
+	dir, err := ioutil.TempDir("", "gnmi")
+	if err != nil {
+		panic(err)
+	}
+	certFile, keyFile, roots, err := writeCert(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	config := Config{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		Users:             map[string]string{"gnmi": "gnmi"},
+		MinSampleInterval: 10 * time.Millisecond,
+		ChangeInterval:    20 * time.Millisecond,
+	}
+	server, err = NewServer(config, ds, schema)
+	if err != nil {
+		panic(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	go server.Serve(l)
+
+	creds := credentials.NewTLS(&tls.Config{RootCAs: roots})
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		panic(err)
+	}
+	client = pb.NewGNMIClient(conn)
+
+	code := m.Run()
+	conn.Close()
+	server.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// writeCert writes a self-signed certificate of 127.0.0.1 and its key to
+// dir, and returns their files and a pool holding the certificate
+func writeCert(dir string) (string, string, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "o1agent"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return "", "", nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(certFile, certPem, 0600); err != nil {
+		return "", "", nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		return "", "", nil, err
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPem)
+	return certFile, keyFile, roots, nil
+}
+
+func setHealth(name, h string) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	if h == "" {
+		delete(health, name)
+	} else {
+		health[name] = h
+	}
+}
+
+func authContext() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "username", "gnmi", "password", "gnmi")
+}
+
+func path(module string, elems ...*pb.PathElem) *pb.Path {
+	elems[0].Name = module + ":" + elems[0].Name
+	return &pb.Path{Elem: elems}
+}
+
+func elem(name string, keys ...string) *pb.PathElem {
+	e := &pb.PathElem{Name: name}
+	if len(keys) > 0 {
+		e.Key = make(map[string]string)
+		for i := 0; i+1 < len(keys); i += 2 {
+			e.Key[keys[i]] = keys[i+1]
+		}
+	}
+	return e
+}
+
+func xappPath(name string, leaf ...*pb.PathElem) *pb.Path {
+	return path(xappDesc, append([]*pb.PathElem{elem("ric"), elem("xapps"), elem("xapp", "name", name)}, leaf...)...)
+}
+
+func TestCapabilities(t *testing.T) {
+	resp, err := client.Capabilities(authContext(), &pb.CapabilityRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, gnmiVersion, resp.GNMIVersion)
+	assert.Contains(t, resp.SupportedEncodings, pb.Encoding_JSON_IETF)
+	assert.Len(t, resp.SupportedModels, 5)
+	assert.Equal(t, "o-ran-sc-ric-alarm-v1", resp.SupportedModels[0].Name)
+
+	_, err = client.Capabilities(context.Background(), &pb.CapabilityRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestUsers(t *testing.T) {
+	_, err := NewServer(Config{Users: map[string]string{"gnmi": "gnmi"}}, ds, server.schema)
+	assert.NotNil(t, err)
+
+	// without users every call is denied
+	s, err := NewServer(Config{}, ds, server.schema)
+	assert.Nil(t, err)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("username", "gnmi", "password", "gnmi"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(s.authorize(ctx)))
+	assert.Nil(t, server.authorize(ctx))
+}
+
+func TestSetAndGet(t *testing.T) {
+	ctx := authContext()
+	val := &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"name":"gnmi-xapp","release-name":"r1","version":"1.0.0"}`)}}
+	resp, err := client.Set(ctx, &pb.SetRequest{Update: []*pb.Update{{Path: xappPath("gnmi-xapp"), Val: val}}})
+	assert.Nil(t, err)
+	assert.Equal(t, pb.UpdateResult_UPDATE, resp.Response[0].Op)
+	assert.Equal(t, "r1", ds.GetConfig("").Find("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='gnmi-xapp']/release-name").Value)
+
+	get, err := client.Get(ctx, &pb.GetRequest{Path: []*pb.Path{xappPath("gnmi-xapp")}, Type: pb.GetRequest_CONFIG, Encoding: pb.Encoding_JSON_IETF})
+	assert.Nil(t, err)
+	assert.Len(t, get.Notification[0].Update, 1)
+	assert.Contains(t, string(get.Notification[0].Update[0].Val.GetJsonIetfVal()), `"release-name": "r1"`)
+
+	str := &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "r2"}}
+	_, err = client.Set(ctx, &pb.SetRequest{Replace: []*pb.Update{{Path: xappPath("gnmi-xapp", elem("release-name")), Val: str}}})
+	assert.Nil(t, err)
+
+	get, err = client.Get(ctx, &pb.GetRequest{Path: []*pb.Path{xappPath("gnmi-xapp", elem("release-name"))}, Encoding: pb.Encoding_PROTO})
+	assert.Nil(t, err)
+	assert.Equal(t, "r2", get.Notification[0].Update[0].Val.GetStringVal())
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name=gnmi-xapp]/release-name", PathString(get.Notification[0].Update[0].Path))
+
+	_, err = client.Set(ctx, &pb.SetRequest{Delete: []*pb.Path{xappPath("gnmi-xapp")}})
+	assert.Nil(t, err)
+	_, err = client.Get(ctx, &pb.GetRequest{Path: []*pb.Path{xappPath("gnmi-xapp")}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestSetErrors(t *testing.T) {
+	ctx := authContext()
+	rejected := &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"release-name":"rejected"}`)}}
+	_, err := client.Set(ctx, &pb.SetRequest{Update: []*pb.Update{{Path: xappPath("bad-xapp"), Val: rejected}}})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.False(t, ds.HasItem("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='bad-xapp']"))
+
+	invalid := &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"release-name":"invalid"}`)}}
+	_, err = client.Set(ctx, &pb.SetRequest{Update: []*pb.Update{{Path: xappPath("bad-xapp"), Val: invalid}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='bad-xapp']/release-name: no such helm release", status.Convert(err).Message())
+
+	forbidden := &pb.TypedValue{Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{"release-name":"forbidden"}`)}}
+	_, err = client.Set(ctx, &pb.SetRequest{Update: []*pb.Update{{Path: xappPath("bad-xapp"), Val: forbidden}}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "gnmi user 'gnmi' may not deploy")
+
+	str := &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: "x"}}
+	_, err = client.Set(ctx, &pb.SetRequest{Update: []*pb.Update{{Path: path(xappDesc, elem("ric"), elem("health")), Val: str}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Set(ctx, &pb.SetRequest{Delete: []*pb.Path{path(xappDesc, elem("ric"), elem("xapps"), elem("xapp"))}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Get(ctx, &pb.GetRequest{Path: []*pb.Path{{Elem: []*pb.PathElem{elem("ric")}}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.Get(ctx, &pb.GetRequest{Path: []*pb.Path{path(xappDesc, elem("unknown"))}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Get(ctx, &pb.GetRequest{Path: []*pb.Path{xappPath("x")}, Encoding: pb.Encoding_ASCII})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestGetState(t *testing.T) {
+	setHealth("ueec", "healthy")
+	defer setHealth("ueec", "")
+
+	ctx := authContext()
+	get, err := client.Get(ctx, &pb.GetRequest{
+		Prefix:   &pb.Path{Origin: xappDesc},
+		Path:     []*pb.Path{{Elem: []*pb.PathElem{elem("ric"), elem("health"), elem("status", "name", "*"), elem("health")}}},
+		Type:     pb.GetRequest_STATE,
+		Encoding: pb.Encoding_PROTO,
+	})
+	assert.Nil(t, err)
+	updates := get.Notification[0].Update
+	assert.Len(t, updates, 1)
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name=ueec]/health", PathString(updates[0].Path))
+	assert.Equal(t, "healthy", updates[0].Val.GetStringVal())
+
+	get, err = client.Get(ctx, &pb.GetRequest{
+		Path:     []*pb.Path{path("o-ran-sc-ric-gnb-status-v1", elem("ric"), elem("nodes"), elem("node"))},
+		Encoding: pb.Encoding_PROTO,
+	})
+	assert.Nil(t, err)
+	values := map[string]*pb.TypedValue{}
+	for _, u := range get.Notification[0].Update {
+		values[u.Path.Elem[len(u.Path.Elem)-1].Name] = u.Val
+	}
+	assert.Equal(t, uint64(36422), values["port"].GetUintVal())
+	assert.Equal(t, "connected", values["connection-status"].GetStringVal())
+	assert.Equal(t, "gnb-1", values["ran-name"].GetStringVal())
+
+	get, err = client.Get(ctx, &pb.GetRequest{
+		Path:     []*pb.Path{path("o-ran-sc-ric-gnb-status-v1", elem("ric"), elem("nodes"), elem("node", "ran-name", "gnb-1"), elem("port"))},
+		Encoding: pb.Encoding_JSON_IETF,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "36422", string(get.Notification[0].Update[0].Val.GetJsonIetfVal()))
+}
+
+func subscribe(t *testing.T, list *pb.SubscriptionList) (pb.GNMI_SubscribeClient, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(authContext())
+	stream, err := client.Subscribe(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, stream.Send(&pb.SubscribeRequest{Request: &pb.SubscribeRequest_Subscribe{Subscribe: list}}))
+	return stream, cancel
+}
+
+func healthSubscription(mode pb.SubscriptionMode, interval uint64) *pb.Subscription {
+	return &pb.Subscription{
+		Path:           path(xappDesc, elem("ric"), elem("health")),
+		Mode:           mode,
+		SampleInterval: interval,
+	}
+}
+
+func recv(t *testing.T, stream pb.GNMI_SubscribeClient) *pb.SubscribeResponse {
+	resp, err := stream.Recv()
+	assert.Nil(t, err)
+	return resp
+}
+
+func TestSubscribeOnce(t *testing.T) {
+	setHealth("ueec", "healthy")
+	defer setHealth("ueec", "")
+
+	stream, cancel := subscribe(t, &pb.SubscriptionList{
+		Mode:         pb.SubscriptionList_ONCE,
+		Encoding:     pb.Encoding_PROTO,
+		Subscription: []*pb.Subscription{healthSubscription(pb.SubscriptionMode_TARGET_DEFINED, 0)},
+	})
+	defer cancel()
+
+	update := recv(t, stream).GetUpdate()
+	assert.Len(t, update.Update, 2)
+	assert.Equal(t, "healthy", update.Update[0].Val.GetStringVal())
+	assert.True(t, recv(t, stream).GetSyncResponse())
+
+	_, err := stream.Recv()
+	assert.NotNil(t, err)
+}
+
+func TestSubscribePoll(t *testing.T) {
+	setHealth("ueec", "healthy")
+	defer setHealth("ueec", "")
+
+	stream, cancel := subscribe(t, &pb.SubscriptionList{
+		Mode:         pb.SubscriptionList_POLL,
+		UpdatesOnly:  true,
+		Subscription: []*pb.Subscription{healthSubscription(pb.SubscriptionMode_TARGET_DEFINED, 0)},
+	})
+	defer cancel()
+
+	assert.True(t, recv(t, stream).GetSyncResponse())
+
+	setHealth("ueec", "unhealthy")
+	assert.Nil(t, stream.Send(&pb.SubscribeRequest{Request: &pb.SubscribeRequest_Poll{Poll: &pb.Poll{}}}))
+	update := recv(t, stream).GetUpdate()
+	assert.Equal(t, `"unhealthy"`, string(update.Update[0].Val.GetJsonVal()))
+	assert.True(t, recv(t, stream).GetSyncResponse())
+}
+
+func TestSubscribeOnChange(t *testing.T) {
+	setHealth("ueec", "healthy")
+	defer setHealth("ueec", "")
+
+	stream, cancel := subscribe(t, &pb.SubscriptionList{
+		Mode:         pb.SubscriptionList_STREAM,
+		Encoding:     pb.Encoding_PROTO,
+		Subscription: []*pb.Subscription{healthSubscription(pb.SubscriptionMode_ON_CHANGE, 0)},
+	})
+	defer cancel()
+
+	assert.Len(t, recv(t, stream).GetUpdate().Update, 2)
+	assert.True(t, recv(t, stream).GetSyncResponse())
+
+	setHealth("ueec", "unhealthy")
+	update := recv(t, stream).GetUpdate()
+	assert.Len(t, update.Update, 1)
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name=ueec]/health", PathString(update.Update[0].Path))
+	assert.Equal(t, "unhealthy", update.Update[0].Val.GetStringVal())
+
+	setHealth("ueec", "")
+	update = recv(t, stream).GetUpdate()
+	assert.Len(t, update.Update, 0)
+	assert.Len(t, update.Delete, 2)
+}
+
+func TestSubscribeSample(t *testing.T) {
+	setHealth("ueec", "healthy")
+	defer setHealth("ueec", "")
+
+	stream, cancel := subscribe(t, &pb.SubscriptionList{
+		Mode:         pb.SubscriptionList_STREAM,
+		Encoding:     pb.Encoding_PROTO,
+		Subscription: []*pb.Subscription{healthSubscription(pb.SubscriptionMode_SAMPLE, uint64(20*time.Millisecond))},
+	})
+	defer cancel()
+
+	assert.Len(t, recv(t, stream).GetUpdate().Update, 2)
+	assert.True(t, recv(t, stream).GetSyncResponse())
+
+	for i := 0; i < 2; i++ {
+		update := recv(t, stream).GetUpdate()
+		assert.Len(t, update.Update, 2)
+		assert.Equal(t, "healthy", update.Update[0].Val.GetStringVal())
+	}
+}
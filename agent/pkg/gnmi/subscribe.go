@@ -0,0 +1,261 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package gnmi
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// leafState is the last value reported for a leaf of a subscription
+type leafState struct {
+	segs  []nbi.PathSegment
+	value string
+}
+
+// subscription is one path of a subscription list. The providers are pull
+// based, so ON_CHANGE is detected by comparing snapshots taken every
+// ChangeInterval.
+type subscription struct {
+	pat      *pattern
+	mode     pb.SubscriptionMode
+	interval time.Duration
+	suppress bool
+	last     map[string]leafState
+}
+
+// subscriber serializes the responses sent on one Subscribe stream
+type subscriber struct {
+	server   *Server
+	stream   pb.GNMI_SubscribeServer
+	encoding pb.Encoding
+	mu       sync.Mutex
+}
+
+func (s *Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	list := req.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument, "the first request must be a subscription list")
+	}
+	if err := checkEncoding(list.Encoding); err != nil {
+		return err
+	}
+	if len(list.Subscription) == 0 {
+		return status.Error(codes.InvalidArgument, "empty subscription list")
+	}
+
+	var subs []*subscription
+	for _, sub := range list.Subscription {
+		pat, err := s.resolve(list.Prefix, sub.Path)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, s.newSubscription(pat, sub))
+	}
+
+	c := &subscriber{server: s, stream: stream, encoding: list.Encoding}
+	if err := c.sync(subs, list.UpdatesOnly); err != nil {
+		return err
+	}
+
+	switch list.Mode {
+	case pb.SubscriptionList_ONCE:
+		return nil
+	case pb.SubscriptionList_POLL:
+		for {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if req.GetPoll() == nil {
+				return status.Error(codes.InvalidArgument, "only poll requests are accepted on a POLL subscription")
+			}
+			if err := c.sync(subs, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	errs := make(chan error, len(subs))
+	done := make(chan struct{})
+	defer close(done)
+	for _, sub := range subs {
+		go c.run(sub, done, errs)
+	}
+
+	select {
+	case <-stream.Context().Done():
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+func (s *Server) newSubscription(pat *pattern, sub *pb.Subscription) *subscription {
+	result := &subscription{pat: pat, mode: sub.Mode, interval: s.config.ChangeInterval, suppress: sub.SuppressRedundant}
+	if sub.Mode == pb.SubscriptionMode_SAMPLE {
+		result.interval = time.Duration(sub.SampleInterval)
+		if result.interval < s.config.MinSampleInterval {
+			result.interval = s.config.MinSampleInterval
+		}
+	}
+	return result
+}
+
+// snapshot returns the current leaves of a subscription keyed by xpath
+func (s *Server) snapshot(sub *subscription) (map[string]leafState, error) {
+	matches, err := s.collect(sub.pat, pb.GetRequest_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make(map[string]leafState)
+	for _, m := range matches {
+		if m.node.Leaf {
+			leaves[m.xpath] = leafState{m.segs, m.node.Value}
+			continue
+		}
+		m.node.Walk(func(xpath string, node *nbi.Node) {
+			if node.Leaf {
+				leaves[m.xpath+xpath] = leafState{segments(m.xpath + xpath), node.Value}
+			}
+		})
+	}
+	return leaves, nil
+}
+
+// sync sends the current state of every subscription followed by a
+// sync_response.
+func (c *subscriber) sync(subs []*subscription, updatesOnly bool) error {
+	for _, sub := range subs {
+		leaves, err := c.server.snapshot(sub)
+		if err != nil {
+			return err
+		}
+		sub.last = leaves
+		if updatesOnly {
+			continue
+		}
+		if err := c.send(c.notification(leaves, nil)); err != nil {
+			return err
+		}
+	}
+	return c.sendResponse(&pb.SubscribeResponse{Response: &pb.SubscribeResponse_SyncResponse{SyncResponse: true}})
+}
+
+// run reports a subscription every interval: all leaves for SAMPLE,
+// changed leaves for ON_CHANGE and SAMPLE with suppress_redundant.
+func (c *subscriber) run(sub *subscription, done chan struct{}, errs chan error) {
+	ticker := time.NewTicker(sub.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		leaves, err := c.server.snapshot(sub)
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		updates := leaves
+		if sub.mode != pb.SubscriptionMode_SAMPLE || sub.suppress {
+			updates = make(map[string]leafState)
+			for xpath, leaf := range leaves {
+				if old, ok := sub.last[xpath]; !ok || old.value != leaf.value {
+					updates[xpath] = leaf
+				}
+			}
+		}
+		var deletes []leafState
+		for xpath, leaf := range sub.last {
+			if _, ok := leaves[xpath]; !ok {
+				deletes = append(deletes, leaf)
+			}
+		}
+		sub.last = leaves
+
+		if len(updates) == 0 && len(deletes) == 0 {
+			continue
+		}
+		if err := c.send(c.notification(updates, deletes)); err != nil {
+			errs <- err
+			return
+		}
+	}
+}
+
+func (c *subscriber) notification(updates map[string]leafState, deletes []leafState) *pb.Notification {
+	notif := &pb.Notification{Timestamp: time.Now().UnixNano()}
+
+	xpaths := make([]string, 0, len(updates))
+	for xpath := range updates {
+		xpaths = append(xpaths, xpath)
+	}
+	sort.Strings(xpaths)
+
+	for _, xpath := range xpaths {
+		leaf := updates[xpath]
+		notif.Update = append(notif.Update, &pb.Update{
+			Path: toPath(leaf.segs),
+			Val:  typedValue(leaf.value, c.server.leafEntry(leaf.segs), c.encoding),
+		})
+	}
+	for _, leaf := range deletes {
+		notif.Delete = append(notif.Delete, toPath(leaf.segs))
+	}
+	return notif
+}
+
+func (c *subscriber) send(notif *pb.Notification) error {
+	if len(notif.Update) == 0 && len(notif.Delete) == 0 {
+		return nil
+	}
+	return c.sendResponse(&pb.SubscribeResponse{Response: &pb.SubscribeResponse_Update{Update: notif}})
+}
+
+func (c *subscriber) sendResponse(resp *pb.SubscribeResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stream.Send(resp)
+}
@@ -0,0 +1,355 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/valyala/fastjson"
+)
+
+const (
+	accessModule = "o-ran-sc-ric-xapp-access-v1"
+	accessXpath  = "/o-ran-sc-ric-xapp-access-v1:access"
+)
+
+// Operations of the access rules, see o-ran-sc-ric-xapp-access-v1
+const (
+	AccessAny       = "any"
+	AccessDeploy    = "deploy"
+	AccessUndeploy  = "undeploy"
+	AccessConfigure = "configure"
+	AccessLifecycle = "lifecycle"
+	AccessManage    = "manage-access"
+)
+
+// AccessRule permits or denies an operation to the users of a group on the
+// xApps and namespaces matching its shell patterns
+type AccessRule struct {
+	Name      string
+	Group     string
+	Operation string
+	Namespace string
+	Xapp      string
+	Permit    bool
+}
+
+// AccessPolicy is the content of the access container. Without one, or
+// while it is not enabled, everything is permitted.
+type AccessPolicy struct {
+	Enabled       bool
+	DefaultPermit bool
+	Groups        map[string][]string
+	Rules         []AccessRule
+}
+
+func defaultAccessPolicy() *AccessPolicy {
+	return &AccessPolicy{DefaultPermit: true}
+}
+
+// ParseAccessPolicy reads the access container from its JSON encoding, as
+// returned by ChangeSession.GetData
+func ParseAccessPolicy(data string) (*AccessPolicy, error) {
+	p := defaultAccessPolicy()
+	if data == "" {
+		return p, nil
+	}
+
+	value, err := fastjson.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	access := value.Get(accessModule + ":access")
+	if access == nil {
+		return p, nil
+	}
+
+	p.Enabled = access.GetBool("enabled")
+	if action := string(access.GetStringBytes("default-action")); action != "" {
+		p.DefaultPermit = action == "permit"
+	}
+
+	p.Groups = make(map[string][]string)
+	for _, g := range access.GetArray("group") {
+		name := string(g.GetStringBytes("name"))
+		for _, u := range g.GetArray("user") {
+			p.Groups[name] = append(p.Groups[name], string(u.GetStringBytes("name")))
+		}
+	}
+
+	for _, r := range access.GetArray("rule") {
+		rule := AccessRule{
+			Name:      string(r.GetStringBytes("name")),
+			Group:     string(r.GetStringBytes("group")),
+			Operation: string(r.GetStringBytes("operation")),
+			Namespace: string(r.GetStringBytes("namespace")),
+			Xapp:      string(r.GetStringBytes("xapp")),
+		}
+		switch action := string(r.GetStringBytes("action")); action {
+		case "permit":
+			rule.Permit = true
+		case "deny":
+		default:
+			return nil, fmt.Errorf("rule '%s': invalid action '%s'", rule.Name, action)
+		}
+		for _, pattern := range []string{rule.Namespace, rule.Xapp} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("rule '%s': invalid pattern '%s'", rule.Name, pattern)
+			}
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	return p, nil
+}
+
+// Allowed tells if user may carry out operation on xapp in namespace. The
+// first rule matching decides, the default action applies if none does.
+func (p *AccessPolicy) Allowed(user, operation, namespace, xapp string) bool {
+	if !p.Enabled {
+		return true
+	}
+	for _, r := range p.Rules {
+		if r.matches(p.member(user, r.Group), operation, namespace, xapp) {
+			return r.Permit
+		}
+	}
+	return p.DefaultPermit
+}
+
+func (p *AccessPolicy) member(user, group string) bool {
+	if group == "" || group == "*" {
+		return true
+	}
+	for _, u := range p.Groups[group] {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *AccessRule) matches(member bool, operation, namespace, xapp string) bool {
+	if !member {
+		return false
+	}
+	if r.Operation != "" && r.Operation != AccessAny && r.Operation != operation {
+		return false
+	}
+	return matchPattern(r.Namespace, namespace) && matchPattern(r.Xapp, xapp)
+}
+
+func matchPattern(pattern, value string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+// trusted tells if o bypasses the access rules: commits of the agent itself,
+// like the rollback of an unconfirmed commit, the replay at startup or the
+// version of an xApp redeployed by an authorized user, and of local sysrepo
+// sessions, which have full access to the datastore anyway
+func trusted(o Originator) bool {
+	switch o.Name {
+	case "", reconcilerOriginator, confirmedCommitOriginator, startupOriginator, redeployOriginator:
+		return true
+	}
+	return false
+}
+
+func (n *Nbi) accessPolicy() *AccessPolicy {
+	n.accessMu.Lock()
+	defer n.accessMu.Unlock()
+
+	if n.access == nil {
+		return defaultAccessPolicy()
+	}
+	return n.access
+}
+
+// loadAccessPolicy reads the access rules of the running configuration
+func (n *Nbi) loadAccessPolicy() {
+	store, ok := n.ds.(Store)
+	if !ok {
+		return
+	}
+	p, err := ParseAccessPolicy(store.GetConfig(accessXpath).JSON())
+	if err != nil {
+		log.Error("NBI: invalid access rules, everything is permitted: %v", err)
+		return
+	}
+
+	n.accessMu.Lock()
+	defer n.accessMu.Unlock()
+	n.access = p
+}
+
+// stageAccessPolicy reads the access rules a transaction commits. They are
+// enforced once the transaction is done.
+func (n *Nbi) stageAccessPolicy(session ChangeSession, reqID int) error {
+	data, err := session.GetData(accessXpath)
+	if err != nil {
+		return err
+	}
+	p, err := ParseAccessPolicy(data)
+	if err != nil {
+		return &ChangeError{Xpath: accessXpath, Tag: "invalid-value", Message: fmt.Sprintf("invalid access rules: %v", err), Err: err}
+	}
+
+	n.accessMu.Lock()
+	defer n.accessMu.Unlock()
+	n.accessTx[reqID] = p
+	return nil
+}
+
+// finishAccessPolicy enforces the rules staged by a transaction once it is
+// committed, or drops them
+func (n *Nbi) finishAccessPolicy(reqID int, committed bool) {
+	n.accessMu.Lock()
+	defer n.accessMu.Unlock()
+
+	if p, ok := n.accessTx[reqID]; ok && committed {
+		n.access = p
+		log.Info("NBI: access rules updated, enabled=%v", p.Enabled)
+	}
+	delete(n.accessTx, reqID)
+}
+
+// authorize rejects operation on xapp in namespace unless the access rules
+// permit it to the originator. xpath is the node reported to the client.
+func (n *Nbi) authorize(o Originator, operation, namespace, xapp, xpath string) error {
+	if trusted(o) || n.accessPolicy().Allowed(o.User, operation, namespace, xapp) {
+		return nil
+	}
+
+	var msg string
+	switch operation {
+	case AccessManage:
+		msg = fmt.Sprintf("user '%s' may not change the access rules", o.User)
+	case AccessLifecycle:
+		msg = fmt.Sprintf("user '%s' may not restart, scale or redeploy xApp '%s' in namespace '%s'", o.User, xapp, namespace)
+	default:
+		msg = fmt.Sprintf("user '%s' may not %s xApp '%s' in namespace '%s'", o.User, operation, xapp, namespace)
+	}
+	log.Warn("NBI: access denied to %s session %d: %s", o.Name, o.Session, msg)
+	return &ChangeError{Xpath: xpath, Tag: "access-denied", Message: msg}
+}
+
+// xappAccess is what a transaction does to one xApp
+type xappAccess struct {
+	xpath     string
+	name      string
+	namespace string
+	operation string
+}
+
+// authorizeChange checks every xApp a transaction deploys, undeploys or
+// configures against the access rules
+func (n *Nbi) authorizeChange(session ChangeSession, module string) error {
+	o := session.Originator()
+	if trusted(o) {
+		return nil
+	}
+
+	switch module {
+	case accessModule:
+		return n.authorize(o, AccessManage, "", "", accessXpath)
+	case xappDescModule:
+		changes, err := session.GetChanges("//.")
+		if err != nil {
+			return err
+		}
+		for _, x := range xappChanges(session, changes, n.namespaces.defaultNamespace()) {
+			if err := n.authorize(o, x.operation, x.namespace, x.name, x.xpath); err != nil {
+				return err
+			}
+		}
+	case "o-ran-sc-ric-ueec-config-v1":
+		root := module + ":ric"
+		data, err := session.GetData("/" + root)
+		if err != nil {
+			return err
+		}
+		var name, namespace string
+		if value, err := fastjson.Parse(data); err == nil {
+			name = string(value.GetStringBytes(root, "config", "name"))
+			namespace = string(value.GetStringBytes(root, "config", "namespace"))
+		}
+		if namespace == "" {
+			namespace = n.namespaces.defaultNamespace()
+		}
+		return n.authorize(o, AccessConfigure, namespace, name, "/"+root+"/config")
+	}
+	return nil
+}
+
+// xappChanges sorts the changes of the xApp descriptors by xApp. Creating a
+// descriptor deploys the xApp, deleting it undeploys, anything else
+// configures it. Descriptors without a namespace deploy to defaultNamespace.
+func xappChanges(session ChangeSession, changes []Change, defaultNamespace string) []*xappAccess {
+	var result []*xappAccess
+	byPath := make(map[string]*xappAccess)
+	namespaces := make(map[string]bool)
+
+	for _, c := range changes {
+		segs, err := ParsePath(c.Xpath)
+		if err != nil || len(segs) < 3 || segs[1].Name != "xapps" || segs[2].Name != "xapp" || len(segs[2].Keys) == 0 {
+			continue
+		}
+		xpath := fmt.Sprintf("/%s:ric/xapps/%s", xappDescModule, segs[2].String(false))
+		x, ok := byPath[xpath]
+		if !ok {
+			x = &xappAccess{xpath: xpath, name: segs[2].Keys[0].Value, operation: AccessConfigure}
+			byPath[xpath] = x
+			result = append(result, x)
+		}
+
+		switch {
+		case len(segs) == 3 && c.Oper == OpCreated:
+			x.operation = AccessDeploy
+		case len(segs) == 3 && c.Oper == OpDeleted:
+			x.operation = AccessUndeploy
+		case len(segs) == 4 && segs[3].Name == "namespace":
+			x.namespace = c.NewValue
+			if c.Oper == OpDeleted {
+				x.namespace = c.OldValue
+			}
+			namespaces[xpath] = true
+		}
+	}
+
+	for _, x := range result {
+		if namespaces[x.xpath] {
+			continue
+		}
+		// The namespace of a reconfigured xApp is not part of the changes
+		if data, err := session.GetData(x.xpath); err == nil && data != "" {
+			if value, err := fastjson.Parse(data); err == nil {
+				x.namespace = string(value.GetStringBytes(xappDescModule+":ric", "xapps", "xapp", "0", "namespace"))
+			}
+		}
+		if x.namespace == "" {
+			x.namespace = defaultNamespace
+		}
+	}
+	return result
+}
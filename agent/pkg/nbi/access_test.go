@@ -0,0 +1,163 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
+	"github.com/stretchr/testify/assert"
+)
+
+const accessPolicyJSON = `{"o-ran-sc-ric-xapp-access-v1:access": {
+	"enabled": true,
+	"default-action": "deny",
+	"group": [
+		{"name": "admin", "user": [{"name": "alice"}]},
+		{"name": "noc", "user": [{"name": "bob"}, {"name": "carol"}]}
+	],
+	"rule": [
+		{"name": "no-prod", "group": "noc", "operation": "any", "namespace": "prod-*", "action": "deny"},
+		{"name": "admin", "group": "admin", "action": "permit"},
+		{"name": "noc-config", "group": "noc", "operation": "configure", "xapp": "ueec*", "action": "permit"}
+	]
+}}`
+
+func TestAccessPolicy(t *testing.T) {
+	p, err := ParseAccessPolicy(accessPolicyJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(p.Rules))
+
+	assert.True(t, p.Allowed("alice", AccessUndeploy, "prod-ran", "ueec"))
+	assert.True(t, p.Allowed("bob", AccessConfigure, "ricxapp", "ueec-2"))
+	assert.False(t, p.Allowed("bob", AccessConfigure, "prod-ran", "ueec"))
+	assert.False(t, p.Allowed("bob", AccessUndeploy, "ricxapp", "ueec"))
+	assert.False(t, p.Allowed("bob", AccessConfigure, "ricxapp", "anr"))
+	assert.False(t, p.Allowed("mallory", AccessConfigure, "ricxapp", "ueec"))
+
+	// Nothing is enforced until enabled
+	p.Enabled = false
+	assert.True(t, p.Allowed("mallory", AccessUndeploy, "prod-ran", "ueec"))
+
+	p, err = ParseAccessPolicy("")
+	assert.Nil(t, err)
+	assert.True(t, p.Allowed("mallory", AccessManage, "", ""))
+
+	_, err = ParseAccessPolicy(`{"o-ran-sc-ric-xapp-access-v1:access": {"rule": [{"name": "r", "namespace": "[", "action": "deny"}]}}`)
+	assert.NotNil(t, err)
+}
+
+// setAccessRules commits the rules as the agent itself, which is always
+// allowed to, and removes them when the test ends
+func setAccessRules(t *testing.T) {
+	rule := func(name, group, operation, namespace, action string) {
+		path := accessXpath + "/rule[name='" + name + "']"
+		assert.Nil(t, ds.SetItem(path+"/group", group))
+		assert.Nil(t, ds.SetItem(path+"/operation", operation))
+		assert.Nil(t, ds.SetItem(path+"/namespace", namespace))
+		assert.Nil(t, ds.SetItem(path+"/action", action))
+	}
+	assert.Nil(t, ds.SetItem(accessXpath+"/enabled", "true"))
+	assert.Nil(t, ds.SetItem(accessXpath+"/default-action", "deny"))
+	assert.Nil(t, ds.SetItem(accessXpath+"/group[name='admin']/user[name='alice']", ""))
+	assert.Nil(t, ds.SetItem(accessXpath+"/group[name='noc']/user[name='bob']", ""))
+	rule("admin", "admin", "any", "*", "permit")
+	rule("noc-config", "noc", "configure", "ricxapp", "permit")
+	assert.Nil(t, ds.ApplyChanges())
+	assert.True(t, n.accessPolicy().Enabled)
+
+	t.Cleanup(func() {
+		ds.DiscardChanges()
+		assert.Nil(t, ds.DeleteItem(accessXpath))
+		assert.Nil(t, ds.ApplyChanges())
+		assert.False(t, n.accessPolicy().Enabled)
+	})
+}
+
+func assertAccessDenied(t *testing.T, err error, xpath string) {
+	var cerr *ChangeError
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, "access-denied", cerr.Tag)
+		assert.Equal(t, xpath, cerr.Xpath)
+	}
+}
+
+func TestAccessRulesOfEdits(t *testing.T) {
+	setAccessRules(t)
+	bob := Originator{Name: "netconf", User: "bob", Session: 4}
+	alice := Originator{Name: "netconf", User: "alice", Session: 5}
+
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='guarded']"
+	assert.Nil(t, ds.SetItem(path+"/release-name", "guarded-xapp"))
+	assert.Nil(t, ds.SetItem(path+"/namespace", "ricxapp"))
+	err := ds.ApplyChangesAs(bob)
+	assertAccessDenied(t, err, path)
+	assert.Equal(t, "user 'bob' may not deploy xApp 'guarded' in namespace 'ricxapp'", err.Error())
+	assert.False(t, ds.HasItem(path))
+	assert.Equal(t, "rejected", lastAuditRecord(t).Outcome)
+
+	ts := CreateHTTPServer(t, "POST", "/ric/v1/xapps", 8080, http.StatusCreated, apimodel.Xapp{})
+	assert.Nil(t, ds.SetItem(path+"/release-name", "guarded-xapp"))
+	assert.Nil(t, ds.SetItem(path+"/namespace", "ricxapp"))
+	assert.Nil(t, ds.ApplyChangesAs(alice))
+	ts.Close()
+
+	// Configuring an xApp in ricxapp is fine for the NOC, undeploying not
+	cs := newConfigServer(t, map[string]interface{}{})
+	config := "/o-ran-sc-ric-ueec-config-v1:ric/config"
+	assert.Nil(t, ds.SetItem(config+"/name", "ueec"))
+	assert.Nil(t, ds.SetItem(config+"/namespace", "ricxapp"))
+	assert.Nil(t, ds.SetItem(config+"/control/active", "true"))
+	assert.Nil(t, ds.ApplyChangesAs(bob))
+	cs.Close()
+
+	assert.Nil(t, ds.SetItem(config+"/namespace", "prod"))
+	assertAccessDenied(t, ds.ApplyChangesAs(bob), config)
+
+	assert.Nil(t, ds.DeleteItem(path))
+	assertAccessDenied(t, ds.ApplyChangesAs(bob), path)
+	assert.True(t, ds.HasItem(path))
+
+	ts = CreateHTTPServer(t, "DELETE", "/ric/v1/xapps/guarded-xapp", 8080, http.StatusNoContent, apimodel.Xapp{})
+	assert.Nil(t, ds.DeleteItem(path))
+	assert.Nil(t, ds.ApplyChangesAs(alice))
+	ts.Close()
+
+	// Only admins may change the rules
+	assert.Nil(t, ds.SetItem(accessXpath+"/default-action", "permit"))
+	assertAccessDenied(t, ds.ApplyChangesAs(bob), accessXpath)
+	assert.False(t, n.accessPolicy().DefaultPermit)
+}
+
+func TestAccessRulesOfLifecycleRPCs(t *testing.T) {
+	setAccessRules(t)
+	commands := mockCommands(t, "")
+
+	restart := "/o-ran-sc-ric-xapp-desc-v1:restart-xapp"
+	_, err := ds.CallRPCAs(Originator{Name: "restconf", User: "bob"}, restart, map[string]string{"name": "ueec"})
+	assertAccessDenied(t, err, restart)
+	assert.Empty(t, *commands)
+
+	_, err = ds.CallRPCAs(Originator{Name: "restconf", User: "alice"}, restart, map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(*commands))
+}
@@ -0,0 +1,153 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+)
+
+const auditXpath = "/o-ran-sc-ric-xapp-desc-v1:ric/audit"
+
+// newAuditLogger opens the configured audit sinks. If they cannot be opened
+// the records are still kept in memory.
+func newAuditLogger() *audit.Logger {
+	cfg := audit.ConfigFromViper()
+	logger, err := audit.NewLogger(cfg)
+	if err != nil {
+		log.Error("NBI: opening audit log failed: %v", err)
+		return audit.NewLoggerWithSinks(cfg.History)
+	}
+	return logger
+}
+
+// Audit returns the audit log of the configuration transactions
+func (n *Nbi) Audit() *audit.Logger {
+	return n.audit
+}
+
+func auditKey(module string, reqID int) string {
+	return fmt.Sprintf("%s/%d", module, reqID)
+}
+
+// startAudit records the originator and the changes of a transaction in its
+// CHANGE event. The record is logged once the transaction ends.
+func (n *Nbi) startAudit(session ChangeSession, module string, reqID int) *audit.Record {
+	rec := newAuditRecord(session, module)
+
+	n.auditMu.Lock()
+	defer n.auditMu.Unlock()
+
+	n.auditTx[auditKey(module, reqID)] = rec
+	return rec
+}
+
+func newAuditRecord(session ChangeSession, module string) *audit.Record {
+	o := session.Originator()
+	rec := &audit.Record{
+		Time:       time.Now(),
+		Originator: o.Name,
+		User:       o.User,
+		Session:    o.Session,
+		Module:     module,
+	}
+
+	changes, err := session.GetChanges("//.")
+	if err != nil {
+		log.Error("NBI: reading changes for the audit log failed: %v", err)
+	}
+	for _, c := range changes {
+		rec.Changes = append(rec.Changes, audit.Change{
+			Operation: c.Oper.String(),
+			Xpath:     c.Xpath,
+			Before:    c.OldValue,
+			After:     c.NewValue,
+		})
+	}
+	return rec
+}
+
+// finishAudit logs the record of a transaction with its outcome
+func (n *Nbi) finishAudit(module string, reqID int, outcome string, err error) {
+	key := auditKey(module, reqID)
+
+	n.auditMu.Lock()
+	rec, ok := n.auditTx[key]
+	delete(n.auditTx, key)
+	n.auditMu.Unlock()
+
+	if !ok {
+		return
+	}
+	rec.Outcome = outcome
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	n.audit.Log(rec)
+}
+
+// auditProvider reports the latest audit records as operational data
+type auditProvider struct {
+	n *Nbi
+}
+
+func (p *auditProvider) GetOperData(xpath string, tree OperDataTree) error {
+	for _, rec := range p.n.audit.Records() {
+		path := fmt.Sprintf("%s/record[id='%s']", auditXpath, rec.ID)
+		tree.CreateNewElement(path, "id", rec.ID)
+		tree.CreateNewElement(path, "time", rec.Time.UTC().Format(time.RFC3339))
+		tree.CreateNewElement(path, "module", rec.Module)
+		tree.CreateNewElement(path, "outcome", rec.Outcome)
+		if rec.Originator != "" {
+			tree.CreateNewElement(path, "originator", rec.Originator)
+		}
+		if rec.User != "" {
+			tree.CreateNewElement(path, "user", rec.User)
+		}
+		if rec.Session != 0 {
+			tree.CreateNewElement(path, "session-id", strconv.FormatUint(uint64(rec.Session), 10))
+		}
+		if rec.Error != "" {
+			tree.CreateNewElement(path, "error", rec.Error)
+		}
+
+		for i, c := range rec.Changes {
+			cpath := fmt.Sprintf("%s/change[index='%d']", path, i)
+			tree.CreateNewElement(cpath, "index", strconv.Itoa(i))
+			tree.CreateNewElement(cpath, "operation", c.Operation)
+			tree.CreateNewElement(cpath, "xpath", c.Xpath)
+			if c.Before != "" {
+				tree.CreateNewElement(cpath, "before", c.Before)
+			}
+			if c.After != "" {
+				tree.CreateNewElement(cpath, "after", c.After)
+			}
+		}
+		for i, call := range rec.SBICalls {
+			spath := fmt.Sprintf("%s/sbi-call[index='%d']", path, i)
+			tree.CreateNewElement(spath, "index", strconv.Itoa(i))
+			tree.CreateNewElement(spath, "call", call)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"net/http"
+	"testing"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func lastAuditRecord(t *testing.T) *audit.Record {
+	records := n.Audit().Records()
+	if !assert.NotEmpty(t, records) {
+		t.FailNow()
+	}
+	return records[len(records)-1]
+}
+
+func TestAuditOfCommittedChange(t *testing.T) {
+	ts := newConfigServer(t, map[string]interface{}{"active": true})
+	defer ts.Close()
+
+	path := "/o-ran-sc-ric-ueec-config-v1:ric/config"
+	assert.Nil(t, ds.SetItem(path+"/name", "ueec"))
+	assert.Nil(t, ds.SetItem(path+"/namespace", "ricxapp"))
+	assert.Nil(t, ds.SetItem(path+"/control/active", "false"))
+	assert.Nil(t, ds.ApplyChangesAs(Originator{Name: "netconf", User: "admin", Session: 7}))
+
+	rec := lastAuditRecord(t)
+	assert.Equal(t, audit.OutcomeCommitted, rec.Outcome)
+	assert.Equal(t, "netconf", rec.Originator)
+	assert.Equal(t, "admin", rec.User)
+	assert.Equal(t, uint32(7), rec.Session)
+	assert.Equal(t, "o-ran-sc-ric-ueec-config-v1", rec.Module)
+	assert.Contains(t, rec.SBICalls, "modify config of xApp 'ueec'")
+
+	var active *audit.Change
+	for i, c := range rec.Changes {
+		if c.Xpath == path+"/control/active" {
+			active = &rec.Changes[i]
+		}
+	}
+	if assert.NotNil(t, active) {
+		assert.Equal(t, "false", active.After)
+	}
+
+	tree := mapTree{}
+	assert.Nil(t, n.GetOperData("o-ran-sc-ric-xapp-desc-v1", auditXpath, tree))
+	recPath := auditXpath + "/record[id='" + rec.ID + "']"
+	assert.Equal(t, "committed", tree[recPath+"/outcome"])
+	assert.Equal(t, "admin", tree[recPath+"/user"])
+	assert.Equal(t, "7", tree[recPath+"/session-id"])
+	assert.Equal(t, "modify config of xApp 'ueec'", tree[recPath+"/sbi-call[index='0']/call"])
+}
+
+func TestAuditOfRejectedChange(t *testing.T) {
+	ts := CreateHTTPServer(t, "POST", "/ric/v1/xapps", 8080, http.StatusInternalServerError, nil)
+	defer ts.Close()
+
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='audited']"
+	assert.Nil(t, ds.SetItem(path+"/release-name", "audited-xapp"))
+	assert.NotNil(t, ds.ApplyChangesAs(Originator{Name: "restconf", User: "operator"}))
+
+	rec := lastAuditRecord(t)
+	assert.Equal(t, audit.OutcomeRejected, rec.Outcome)
+	assert.Equal(t, "restconf", rec.Originator)
+	assert.Equal(t, "operator", rec.User)
+	assert.Equal(t, []string{"deploy xApp 'audited'"}, rec.SBICalls)
+	assert.Contains(t, rec.Error, "deploying xApp 'audited' failed")
+	assert.NotEmpty(t, rec.Changes)
+	assert.Equal(t, "created", rec.Changes[0].Operation)
+}
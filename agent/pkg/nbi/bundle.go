@@ -0,0 +1,291 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/bundle"
+)
+
+// Modes of an import
+const (
+	ImportDryRun = "dry-run"
+	ImportDiff   = "diff"
+	ImportApply  = "apply"
+)
+
+// ImportOptions tell what an import does: Mode is one of the import modes,
+// Modules and Xapps select what it touches. Without Modules every module of
+// the bundle is imported; Xapps alone select xApps of the xApp descriptor
+// module.
+type ImportOptions struct {
+	Mode    string
+	Modules []string
+	Xapps   []string
+}
+
+func (n *Nbi) managed(module string) bool {
+	for _, m := range n.schemas {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportBundle returns the running configuration of the managed modules,
+// or of the ones listed, and the xApps the appmgr has deployed
+func (n *Nbi) ExportBundle(modules []string) (*bundle.Bundle, error) {
+	store, ok := n.ds.(Store)
+	if !ok {
+		return nil, fmt.Errorf("the datastore cannot be exported")
+	}
+	if len(modules) == 0 {
+		modules = n.schemas
+	}
+
+	running := store.GetConfig("/")
+	b := bundle.New()
+	for _, module := range modules {
+		if !n.managed(module) {
+			return nil, fmt.Errorf("module '%s' is not managed", module)
+		}
+		leaves := make(map[string]string)
+		moduleTree(running, module).Walk(func(xpath string, node *Node) {
+			if node.Leaf {
+				leaves[xpath] = node.Value
+			}
+		})
+		b.AddModule(module, leaves)
+	}
+
+	deployed, err := sbiClient.ListDeployedXapps()
+	if err != nil {
+		return nil, fmt.Errorf("listing the deployed xApps failed: %v", err)
+	}
+	b.Xapps = []bundle.Xapp{}
+	for _, x := range deployed {
+		if x != nil && x.Name != nil {
+			b.Xapps = append(b.Xapps, bundle.Xapp{Name: *x.Name, Version: x.Version, Status: x.Status})
+		}
+	}
+	return b, nil
+}
+
+// ImportResult is what an import changes, or would change, and the SBI
+// calls a dry run plans for it
+type ImportResult struct {
+	Changes  []Change
+	SBICalls []string
+}
+
+// ImportBundle makes the configuration of b the running one, as far as
+// opts select it. A dry run validates the changes and plans their SBI calls
+// without applying them, a diff only computes them.
+func (n *Nbi) ImportBundle(o Originator, b *bundle.Bundle, opts ImportOptions) (*ImportResult, error) {
+	store, ok := n.ds.(Store)
+	if !ok {
+		return nil, fmt.Errorf("the datastore cannot be imported into")
+	}
+
+	running := store.GetConfig("/")
+	target, err := n.importTarget(running, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{Changes: Diff(running, target)}
+
+	switch opts.Mode {
+	case ImportDiff:
+		return result, nil
+	case ImportDryRun, "":
+		result.SBICalls, err = n.planChanges(o, result.Changes, target)
+		return result, err
+	case ImportApply:
+		defer lockEdits(store)()
+		return result, applyTree(store, o, target)
+	}
+	return nil, fmt.Errorf("unknown import mode '%s'", opts.Mode)
+}
+
+// importTarget returns running with the selected parts of the bundle
+// replacing their running configuration. The xApps the bundle lists as
+// deployed without configuring them are added to the xApp descriptors.
+func (n *Nbi) importTarget(running *Node, b *bundle.Bundle, opts ImportOptions) (*Node, error) {
+	modules := opts.Modules
+	switch {
+	case len(modules) > 0:
+	case len(opts.Xapps) > 0:
+		modules = []string{xappDescModule}
+	default:
+		for _, m := range b.Modules {
+			modules = append(modules, m.Name)
+		}
+	}
+
+	target := running.Clone()
+	for _, module := range modules {
+		if !n.managed(module) {
+			return nil, fmt.Errorf("module '%s' is not managed", module)
+		}
+		m := b.Module(module)
+		if m == nil {
+			return nil, fmt.Errorf("the bundle has no configuration of module '%s'", module)
+		}
+		tree := NewTree()
+		for _, leaf := range m.Leaves {
+			if err := tree.Set(leaf.Xpath, leaf.Value); err != nil {
+				return nil, fmt.Errorf("invalid bundle: %v", err)
+			}
+		}
+		if len(moduleTree(tree, module).Children) != len(tree.Children) {
+			return nil, fmt.Errorf("invalid bundle: configuration of module '%s' outside of it", module)
+		}
+		if module == xappDescModule {
+			addDeployedXapps(tree, b.Xapps)
+		}
+
+		if module == xappDescModule && len(opts.Xapps) > 0 {
+			for _, name := range opts.Xapps {
+				xpath := fmt.Sprintf("/%s:ric/xapps/xapp[name='%s']", xappDescModule, name)
+				sub := tree.Subtree(xpath)
+				if sub == nil {
+					return nil, fmt.Errorf("the bundle has no xApp '%s'", name)
+				}
+				target.Delete(xpath)
+				target.Merge(sub)
+			}
+			continue
+		}
+
+		target.Children = removeModule(target.Children, module)
+		target.Merge(tree)
+	}
+	return target, nil
+}
+
+// moduleTree returns a tree holding the top-level nodes of module in tree
+func moduleTree(tree *Node, module string) *Node {
+	result := NewTree()
+	for _, c := range tree.Children {
+		if c.Module == module {
+			result.Children = append(result.Children, c)
+		}
+	}
+	return result
+}
+
+func removeModule(nodes []*Node, module string) []*Node {
+	var kept []*Node
+	for _, c := range nodes {
+		if c.Module != module {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// addDeployedXapps adds a descriptor for every deployed xApp that no
+// descriptor of tree names or releases
+func addDeployedXapps(tree *Node, xapps []bundle.Xapp) {
+	known := make(map[string]bool)
+	if list := tree.Find(fmt.Sprintf("/%s:ric/xapps", xappDescModule)); list != nil {
+		for _, x := range list.Children {
+			known[leafValue(x, "name")] = true
+			known[leafValue(x, "release-name")] = true
+		}
+	}
+
+	for _, x := range xapps {
+		if known[x.Name] {
+			continue
+		}
+		xpath := fmt.Sprintf("/%s:ric/xapps/xapp[name='%s']", xappDescModule, x.Name)
+		tree.Create(xpath)
+		if x.Version != "" {
+			tree.Set(xpath+"/version", x.Version)
+		}
+	}
+}
+
+// changeLines describes changes one per line, in the notation of diffConfig
+func changeLines(changes []Change) []string {
+	var lines []string
+	for _, ch := range changes {
+		switch {
+		case ch.Oper == OpModified:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", ch.Xpath, ch.OldValue, ch.NewValue))
+		case !ch.Leaf:
+			lines = append(lines, fmt.Sprintf("%s %s", changeSign(ch.Oper), ch.Xpath))
+		case ch.Oper == OpDeleted:
+			lines = append(lines, fmt.Sprintf("- %s: %s", ch.Xpath, ch.OldValue))
+		default:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", ch.Xpath, ch.NewValue))
+		}
+	}
+	return lines
+}
+
+func changeSign(oper Operation) string {
+	if oper == OpDeleted {
+		return "-"
+	}
+	return "+"
+}
+
+func (n *Nbi) exportConfig(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	b, err := n.ExportBundle(strings.Fields(input["modules"]))
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.Encode(input["encoding"])
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"bundle": string(data)}, nil
+}
+
+func (n *Nbi) importConfig(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	b, err := bundle.Decode([]byte(input["bundle"]))
+	if err != nil {
+		return nil, err
+	}
+	opts := ImportOptions{
+		Mode:    input["mode"],
+		Modules: strings.Fields(input["modules"]),
+		Xapps:   strings.Fields(input["xapps"]),
+	}
+
+	result, err := n.ImportBundle(o, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	output := map[string]string{
+		"diff":    strings.Join(changeLines(result.Changes), "\n"),
+		"changes": strconv.Itoa(len(result.Changes)),
+	}
+	if len(result.SBICalls) > 0 {
+		output["sbi-calls"] = strings.Join(result.SBICalls, "\n")
+	}
+	return output, nil
+}
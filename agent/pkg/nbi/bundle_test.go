@@ -0,0 +1,136 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/bundle"
+	"github.com/stretchr/testify/assert"
+)
+
+func bundleXapp(name string) string {
+	return "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='" + name + "']"
+}
+
+func TestExportImportBundle(t *testing.T) {
+	a := newAppmgr(t, map[string]string{"kpimon": "1.0.0", "ts": "2.0.0"})
+
+	src, _ := newCandidateNbi(t)
+	store := src.Datastore().(Store)
+	store.SetItem(bundleXapp("kpimon")+"/version", "1.0.0")
+	store.SetItem(bundleXapp("anr")+"/release-name", "anr")
+	assert.Nil(t, store.ApplyChangesAs(Originator{Name: reconcilerOriginator}))
+
+	output, err := src.exportConfig(Originator{}, "", map[string]string{"encoding": "xml"})
+	assert.Nil(t, err)
+	b, err := bundle.Decode([]byte(output["bundle"]))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 2, len(b.Modules))
+	assert.Equal(t, 4, len(b.Module(xappDescModule).Leaves))
+	assert.Equal(t, 2, len(b.Xapps))
+	_, err = src.ExportBundle([]string{"ietf-interfaces"})
+	assert.NotNil(t, err)
+
+	dst, _ := newCandidateNbi(t)
+	store = dst.Datastore().(Store)
+	store.SetItem(bundleXapp("old")+"/release-name", "old")
+	assert.Nil(t, store.ApplyChangesAs(Originator{Name: reconcilerOriginator}))
+
+	input := map[string]string{"bundle": output["bundle"], "mode": ImportDiff}
+	output, err = dst.importConfig(Originator{}, "", input)
+	assert.Nil(t, err)
+	assert.Equal(t, "12", output["changes"])
+	diff := strings.Split(output["diff"], "\n")
+	assert.Contains(t, diff, "+ "+bundleXapp("ts"))
+	assert.Contains(t, diff, "+ "+bundleXapp("ts")+"/version: 2.0.0")
+	assert.Contains(t, diff, "- "+bundleXapp("old"))
+
+	result, err := dst.ImportBundle(Originator{}, b, ImportOptions{Mode: ImportDryRun})
+	assert.Nil(t, err)
+	assert.Equal(t, 12, len(result.Changes))
+	assert.Equal(t, []string{"undeploy xApp 'old'", "deploy xApp 'anr'", "deploy xApp 'kpimon'", "deploy xApp 'ts'"}, result.SBICalls)
+	assert.True(t, store.HasItem(bundleXapp("old")))
+	assert.Empty(t, a.changes())
+
+	dst.SetNamespaces(NamespacePolicy{Default: "ricxapp", Allowed: []string{"ricxapp"}})
+	b.Module(xappDescModule).Leaves = append(b.Module(xappDescModule).Leaves, bundle.Leaf{Xpath: bundleXapp("anr") + "/namespace", Value: "kube-system"})
+	_, err = dst.ImportBundle(Originator{}, b, ImportOptions{Mode: ImportDryRun})
+	assert.NotNil(t, err)
+
+	result, err = dst.ImportBundle(Originator{}, b, ImportOptions{Mode: ImportApply, Xapps: []string{"kpimon"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(result.Changes))
+	assert.True(t, store.HasItem(bundleXapp("kpimon")))
+	assert.True(t, store.HasItem(bundleXapp("old")))
+	assert.False(t, store.HasItem(bundleXapp("anr")))
+	assert.Equal(t, []string{"POST /ric/v1/xapps"}, a.changes())
+
+	_, err = dst.ImportBundle(Originator{}, b, ImportOptions{Mode: ImportApply, Xapps: []string{"unknown"}})
+	assert.NotNil(t, err)
+	_, err = dst.ImportBundle(Originator{}, b, ImportOptions{Mode: ImportApply, Modules: []string{"o-ran-sc-ric-xapp-access-v1"}})
+	assert.NotNil(t, err)
+	_, err = dst.ImportBundle(Originator{}, b, ImportOptions{Mode: "merge"})
+	assert.NotNil(t, err)
+}
+
+func TestImportConfigRPC(t *testing.T) {
+	a := newAppmgr(t, nil)
+	p, _ := newCandidateNbi(t)
+	assert.True(t, p.SubscribeRPCs())
+	store := p.Datastore().(Store)
+
+	b := bundle.New()
+	b.AddModule(xappDescModule, map[string]string{bundleXapp("kpimon") + "/release-name": "kpimon"})
+	data, err := b.Encode("json")
+	assert.Nil(t, err)
+	input := map[string]string{"bundle": string(data), "mode": ImportApply}
+
+	// the import waits for another northbound staging its edits
+	edits := store.EditLock()
+	edits.Lock()
+	done := make(chan map[string]string)
+	go func() {
+		output, err := store.CallRPCAs(Originator{Name: "netopeer2", User: "admin"}, "/o-ran-sc-ric-xapp-desc-v1:import-config", input)
+		assert.Nil(t, err)
+		done <- output
+	}()
+	select {
+	case <-done:
+		t.Error("the import did not wait for the edit lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	edits.Unlock()
+
+	output := <-done
+	assert.Equal(t, "3", output["changes"])
+	assert.True(t, store.HasItem(bundleXapp("kpimon")))
+	assert.Equal(t, []string{"POST /ric/v1/xapps"}, a.changes())
+	if records := p.Audit().Records(); assert.NotEmpty(t, records) {
+		rec := records[len(records)-1]
+		assert.Equal(t, audit.OutcomeCommitted, rec.Outcome)
+		assert.Equal(t, "admin", rec.User)
+	}
+}
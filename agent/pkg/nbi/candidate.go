@@ -0,0 +1,434 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConfirmTimeout is how long a confirmed commit waits for its
+// confirmation, as in RFC 6241
+const DefaultConfirmTimeout = 600 * time.Second
+
+// confirmedCommitOriginator makes the rollback of an unconfirmed commit,
+// which undoes what an authorized client did and must not be refused
+const confirmedCommitOriginator = "confirmed-commit"
+
+// CandidateValidator checks the changes a commit would make to the running
+// configuration, data being the configuration after the commit
+type CandidateValidator func(o Originator, changes []Change, data *Node) error
+
+// CommitOptions are the parameters of a NETCONF <commit>. A confirmed
+// commit is rolled back after Timeout unless a commit confirms it; with
+// Persist set it survives the session and is confirmed by giving the same
+// value as PersistID.
+type CommitOptions struct {
+	Confirmed bool
+	Timeout   time.Duration
+	Persist   string
+	PersistID string
+}
+
+// Candidate is a candidate configuration datastore on top of the running
+// configuration of a Store. Edits stay in the candidate until Commit applies
+// all of them in a single transaction of the Store, so the module change
+// handlers see the changes of every module at once.
+type Candidate struct {
+	store    Store
+	validate CandidateValidator
+	mu       sync.Mutex
+	tree     *Node
+	pending  *confirmedCommit
+}
+
+// confirmedCommit is a commit waiting for its confirmation. backup is the
+// running configuration before it.
+type confirmedCommit struct {
+	owner   Originator
+	persist string
+	backup  *Node
+	timer   *time.Timer
+}
+
+func NewCandidate(store Store, validate CandidateValidator) *Candidate {
+	return &Candidate{store: store, validate: validate}
+}
+
+// Copy returns a candidate holding the same edits, checked by validate, to
+// try an edit on without changing c
+func (c *Candidate) Copy(validate CandidateValidator) *Candidate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cp := NewCandidate(c.store, validate)
+	if c.tree != nil {
+		cp.tree = c.tree.Clone()
+	}
+	return cp
+}
+
+// working returns the candidate tree, a copy of running until the first edit
+func (c *Candidate) working() *Node {
+	if c.tree == nil {
+		c.tree = c.store.GetConfig("/")
+	}
+	return c.tree
+}
+
+// GetConfig returns a copy of the candidate configuration below xpath
+func (c *Candidate) GetConfig(xpath string) *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tree == nil {
+		return c.store.GetConfig(xpath)
+	}
+	if xpath == "" || xpath == "/" {
+		return c.tree.Clone()
+	}
+	if tree := c.tree.Subtree(xpath); tree != nil {
+		return tree
+	}
+	return NewTree()
+}
+
+func (c *Candidate) HasItem(xpath string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tree == nil {
+		return c.store.HasItem(xpath)
+	}
+	return c.tree.Find(xpath) != nil
+}
+
+// SetItem sets a leaf value, or creates a list entry or container when
+// value is empty and xpath does not address a leaf
+func (c *Candidate) SetItem(xpath, value string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tree := c.working()
+	node, err := tree.Create(xpath)
+	if err != nil {
+		return err
+	}
+	if value != "" || node.Leaf {
+		return tree.Set(xpath, value)
+	}
+	return nil
+}
+
+// DeleteItem removes xpath and everything below it
+func (c *Candidate) DeleteItem(xpath string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.working().Delete(xpath) {
+		return fmt.Errorf("data node '%s' not found", xpath)
+	}
+	return nil
+}
+
+// Discard resets the candidate to the running configuration
+func (c *Candidate) Discard() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tree = nil
+}
+
+// Changes returns what a commit would change in the running configuration
+func (c *Candidate) Changes() []Change {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tree == nil {
+		return nil
+	}
+	return Diff(c.store.GetConfig("/"), c.tree)
+}
+
+// Validate checks the candidate without committing it
+func (c *Candidate) Validate(o Originator) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.check(o)
+}
+
+func (c *Candidate) check(o Originator) error {
+	if c.tree == nil || c.validate == nil {
+		return nil
+	}
+	changes := Diff(c.store.GetConfig("/"), c.tree)
+	if len(changes) == 0 {
+		return nil
+	}
+	return c.validate(o, changes, c.tree)
+}
+
+// Commit validates the candidate and applies it to the running
+// configuration. A commit while a confirmed commit is pending confirms it,
+// unless it is confirmed again, which only extends the timeout.
+func (c *Candidate) Commit(o Originator, opts CommitOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending != nil {
+		if err := c.pending.check(o, opts.PersistID); err != nil {
+			return err
+		}
+	} else if opts.PersistID != "" {
+		return &ChangeError{Tag: "invalid-value", Message: fmt.Sprintf("no confirmed commit with persist-id '%s'", opts.PersistID)}
+	}
+
+	if err := c.check(o); err != nil {
+		return err
+	}
+
+	var backup *Node
+	if opts.Confirmed && c.pending == nil {
+		backup = c.store.GetConfig("/")
+	}
+	if c.tree != nil {
+		if err := applyTree(c.store, o, c.tree); err != nil {
+			return err
+		}
+		c.tree = nil
+	}
+
+	switch {
+	case opts.Confirmed:
+		timeout := opts.Timeout
+		if timeout <= 0 {
+			timeout = DefaultConfirmTimeout
+		}
+		if c.pending == nil {
+			c.pending = &confirmedCommit{owner: o, backup: backup}
+		} else {
+			c.pending.timer.Stop()
+		}
+		c.pending.persist = opts.Persist
+		pending := c.pending
+		pending.timer = time.AfterFunc(timeout, func() { c.expire(pending) })
+		log.Info("NBI: confirmed commit by '%s' rolls back in %v unless confirmed", o.User, timeout)
+	case c.pending != nil:
+		c.pending.timer.Stop()
+		c.pending = nil
+		log.Info("NBI: confirmed commit confirmed by '%s'", o.User)
+	}
+	return nil
+}
+
+// CancelCommit rolls back the pending confirmed commit right away
+func (c *Candidate) CancelCommit(o Originator, persistID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil {
+		return &ChangeError{Tag: "operation-failed", Message: "no confirmed commit is pending"}
+	}
+	if err := c.pending.check(o, persistID); err != nil {
+		return err
+	}
+	return c.rollback("cancelled")
+}
+
+// SessionClosed rolls back the confirmed commit the NETCONF session of o
+// made without persist, as the confirmation can only come from that session
+func (c *Candidate) SessionClosed(o Originator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending != nil && c.pending.persist == "" && c.pending.owner == o {
+		c.rollback("session closed")
+	}
+}
+
+// ConfirmPending tells if a confirmed commit waits for its confirmation
+func (c *Candidate) ConfirmPending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pending != nil
+}
+
+// lockEdits takes the edit lock of store, so the edits of the agent do not
+// interleave with the ones of the northbounds. It returns the unlock.
+func lockEdits(store Store) func() {
+	l := store.EditLock()
+	l.Lock()
+	return l.Unlock
+}
+
+func (c *Candidate) expire(pending *confirmedCommit) {
+	defer lockEdits(c.store)()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == pending {
+		c.rollback("timed out")
+	}
+}
+
+// rollback restores the running configuration of before the pending
+// confirmed commit, undoing its deploys and config changes through the
+// module change handlers. The candidate edits made since are dropped.
+func (c *Candidate) rollback(reason string) error {
+	pending := c.pending
+	c.pending = nil
+	c.tree = nil
+	pending.timer.Stop()
+
+	log.Warn("NBI: confirmed commit by '%s' %s, rolling back", pending.owner.User, reason)
+	if err := applyTree(c.store, Originator{Name: confirmedCommitOriginator}, pending.backup); err != nil {
+		log.Error("NBI: rollback of confirmed commit failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// check tells if o may confirm or cancel the commit: the session that made
+// it or, for a persistent one, whoever gives the persist-id
+func (p *confirmedCommit) check(o Originator, persistID string) error {
+	if p.persist != "" {
+		if persistID != p.persist {
+			return &ChangeError{Tag: "invalid-value", Message: fmt.Sprintf("persist-id '%s' does not match the confirmed commit", persistID)}
+		}
+		return nil
+	}
+	if persistID != "" || o != p.owner {
+		return &ChangeError{Tag: "in-use", Message: "a confirmed commit of another session is pending"}
+	}
+	return nil
+}
+
+// applyTree makes target the running configuration of store in a single
+// transaction on behalf of o
+func applyTree(store Store, o Originator, target *Node) error {
+	changes := Diff(store.GetConfig("/"), target)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	store.DiscardChanges()
+	var deleted []string
+	for _, ch := range changes {
+		var err error
+		switch {
+		case ch.Oper == OpDeleted:
+			if below(ch.Xpath, deleted) {
+				continue
+			}
+			deleted = append(deleted, ch.Xpath)
+			err = store.DeleteItem(ch.Xpath)
+		case ch.Leaf:
+			err = store.SetItem(ch.Xpath, ch.NewValue)
+		default:
+			err = store.SetItem(ch.Xpath, "")
+		}
+		if err != nil {
+			store.DiscardChanges()
+			return err
+		}
+	}
+	return store.ApplyChangesAs(o)
+}
+
+// below tells if xpath is one of paths or a descendant of one
+func below(xpath string, paths []string) bool {
+	for _, p := range paths {
+		if xpath == p || strings.HasPrefix(xpath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidate returns the candidate datastore of the NBI, validated by
+// validateChanges. It is nil if the datastore cannot be edited directly.
+func (n *Nbi) Candidate() *Candidate {
+	n.candidateMu.Lock()
+	defer n.candidateMu.Unlock()
+
+	if n.candidate == nil {
+		if store, ok := n.ds.(Store); ok {
+			n.candidate = NewCandidate(store, n.validateChanges)
+		}
+	}
+	return n.candidate
+}
+
+// validateChanges runs the checks of a commit that need no SBI change for
+// every module at once: the access rules, the namespace policy and the
+// config schemas of the xApps. The config of an xApp the same changes
+// undeploy is refused.
+func (n *Nbi) validateChanges(o Originator, changes []Change, data *Node) error {
+	undeployed := make(map[string]bool)
+	for _, module := range n.schemas {
+		modChanges := changesOfModule(changes, module)
+		if len(modChanges) == 0 {
+			continue
+		}
+
+		session := &memSession{changes: modChanges, data: data, originator: o}
+		if err := n.authorizeChange(session, module); err != nil {
+			return err
+		}
+
+		if module == xappDescModule {
+			for _, x := range xappChanges(session, modChanges, n.namespaces.defaultNamespace()) {
+				switch x.operation {
+				case AccessDeploy:
+					if err := n.namespaces.Check(x.namespace); err != nil {
+						return &ChangeError{Xpath: x.xpath + "/namespace", Tag: "invalid-value", Message: err.Error(), Err: err}
+					}
+				case AccessUndeploy:
+					undeployed[x.namespace+"/"+x.name] = true
+				}
+			}
+		}
+	}
+
+	for _, module := range n.schemas {
+		modChanges := changesOfModule(changes, module)
+		if module != "o-ran-sc-ric-ueec-config-v1" || len(modChanges) == 0 {
+			continue
+		}
+
+		cc, err := n.xappConfigPatch(&memSession{changes: modChanges, data: data, originator: o}, module)
+		if err != nil {
+			return err
+		}
+		if cc != nil && undeployed[cc.namespace+"/"+cc.name] {
+			return &ChangeError{
+				Xpath:   fmt.Sprintf("/%s:ric/config", module),
+				Tag:     "invalid-value",
+				Message: fmt.Sprintf("xApp '%s' is configured and undeployed at once", cc.name),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const candidateXapp = "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='kpimon']"
+
+// newCandidateNbi returns an NBI on its own datastore, subscribed to the
+// xApp descriptor and ueec config modules, and its candidate
+func newCandidateNbi(t *testing.T) (*Nbi, *Candidate) {
+	oldNbi, oldClient := nbiClient, sbiClient
+	t.Cleanup(func() { nbiClient, sbiClient = oldNbi, oldClient })
+
+	p := NewNbiWithDatastore(newSBIClient(), NewMemDatastore())
+	p.schemas = []string{xappDescModule, "o-ran-sc-ric-ueec-config-v1"}
+	for _, module := range p.schemas {
+		assert.True(t, p.SubscribeModule(module))
+	}
+	return p, p.Candidate()
+}
+
+func TestCandidateCommit(t *testing.T) {
+	a := newAppmgr(t, nil)
+	p, c := newCandidateNbi(t)
+	store := p.Datastore().(Store)
+
+	assert.Nil(t, c.SetItem(candidateXapp+"/release-name", "kpimon"))
+	assert.True(t, c.HasItem(candidateXapp))
+	assert.False(t, store.HasItem(candidateXapp))
+	assert.Equal(t, 3, len(c.Changes()))
+	assert.Empty(t, a.changes())
+
+	assert.Nil(t, c.Commit(Originator{}, CommitOptions{}))
+	assert.True(t, store.HasItem(candidateXapp))
+	assert.Equal(t, []string{"POST /ric/v1/xapps"}, a.changes())
+	assert.Empty(t, c.Changes())
+
+	assert.Nil(t, c.DeleteItem(candidateXapp))
+	c.Discard()
+	assert.True(t, c.HasItem(candidateXapp))
+}
+
+func TestCandidateValidation(t *testing.T) {
+	p, c := newCandidateNbi(t)
+	p.SetNamespaces(NamespacePolicy{Default: "ricxapp", Allowed: []string{"ricxapp"}})
+
+	c.SetItem(candidateXapp+"/namespace", "kube-system")
+	err := c.Validate(Originator{})
+	var cerr *ChangeError
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, candidateXapp+"/namespace", cerr.Xpath)
+	}
+	assert.NotNil(t, c.Commit(Originator{}, CommitOptions{}))
+	assert.False(t, p.Datastore().(Store).HasItem(candidateXapp))
+	c.Discard()
+
+	// the config of an xApp cannot change while it is undeployed
+	c.SetItem(candidateXapp, "")
+	assert.Nil(t, c.Commit(Originator{Name: reconcilerOriginator}, CommitOptions{}))
+
+	mockCommands(t, "")
+	cs := newConfigServer(t, map[string]interface{}{})
+	defer cs.Close()
+	c.DeleteItem(candidateXapp)
+	c.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/name", "kpimon")
+	c.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/control/active", "true")
+	err = c.Validate(Originator{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "configured and undeployed at once")
+	assert.Empty(t, cs.puts)
+}
+
+func TestConfirmedCommitRollback(t *testing.T) {
+	a := newAppmgr(t, nil)
+	p, c := newCandidateNbi(t)
+	o := Originator{Name: "netconf", User: "admin", Session: 1}
+
+	c.SetItem(candidateXapp, "")
+	assert.Nil(t, c.Commit(o, CommitOptions{Confirmed: true, Timeout: 100 * time.Millisecond}))
+	assert.True(t, c.ConfirmPending())
+	assert.True(t, p.Datastore().(Store).HasItem(candidateXapp))
+
+	// only the session of the commit may confirm it
+	assert.NotNil(t, c.Commit(Originator{Name: "netconf", Session: 2}, CommitOptions{}))
+
+	assert.Eventually(t, func() bool { return !c.ConfirmPending() }, time.Second, 10*time.Millisecond)
+	assert.False(t, p.Datastore().(Store).HasItem(candidateXapp))
+	assert.Equal(t, []string{"POST /ric/v1/xapps", "DELETE /ric/v1/xapps/kpimon"}, a.changes())
+
+	// confirmed in time, nothing is undone
+	c.SetItem(candidateXapp, "")
+	assert.Nil(t, c.Commit(o, CommitOptions{Confirmed: true, Timeout: 100 * time.Millisecond}))
+	assert.Nil(t, c.Commit(o, CommitOptions{}))
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, p.Datastore().(Store).HasItem(candidateXapp))
+
+	assert.NotNil(t, c.CancelCommit(o, ""))
+}
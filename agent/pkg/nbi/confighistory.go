@@ -0,0 +1,463 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const configHistoryPrefix = "o1-config-history"
+
+// ConfigVersion is a configuration of an xApp the agent has applied
+type ConfigVersion struct {
+	Xapp       string      `json:"xapp"`
+	Namespace  string      `json:"namespace"`
+	Version    int         `json:"version"`
+	Time       time.Time   `json:"time"`
+	Originator string      `json:"originator,omitempty"`
+	Author     string      `json:"author,omitempty"`
+	Comment    string      `json:"comment,omitempty"`
+	Config     interface{} `json:"config"`
+}
+
+// ConfigHistory keeps the last versions of the configuration of every xApp
+type ConfigHistory struct {
+	kv  kvStore
+	max int
+	mu  sync.Mutex
+}
+
+// newConfigHistory keeps the versions in the store selected by the
+// configHistory section
+func newConfigHistory() *ConfigHistory {
+	max := 20
+	if viper.IsSet("configHistory.maxVersions") {
+		max = viper.GetInt("configHistory.maxVersions")
+	}
+
+	return &ConfigHistory{kv: newKVStore("configHistory"), max: max}
+}
+
+func configHistoryKey(xappName, namespace string, version int) string {
+	return fmt.Sprintf("%s/%s/%s/%08d", configHistoryPrefix, namespace, xappName, version)
+}
+
+// keys returns the keys of the versions of an xApp, oldest first
+func (h *ConfigHistory) keys(xappName, namespace string) ([]string, error) {
+	all, err := h.kv.ReadAllKeys(configHistoryPrefix)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("%s/%s/%s/", configHistoryPrefix, namespace, xappName)
+	var keys []string
+	for _, key := range all {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (h *ConfigHistory) read(key string) (*ConfigVersion, error) {
+	values, err := h.kv.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	switch value := values[key].(type) {
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return nil, nil
+	}
+
+	v := &ConfigVersion{}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("invalid config version '%s': %v", key, err)
+	}
+	return v, nil
+}
+
+// Record stores config as the next version of an xApp, dropping the oldest
+// versions beyond the maximum
+func (h *ConfigHistory) Record(xappName, namespace string, o Originator, comment string, config interface{}) (*ConfigVersion, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys, err := h.keys(xappName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	v := &ConfigVersion{
+		Xapp:       xappName,
+		Namespace:  namespace,
+		Version:    1,
+		Time:       time.Now().UTC(),
+		Originator: o.Name,
+		Author:     o.User,
+		Comment:    comment,
+		Config:     config,
+	}
+	if len(keys) > 0 {
+		last := keys[len(keys)-1]
+		n, _ := strconv.Atoi(last[strings.LastIndex(last, "/")+1:])
+		v.Version = n + 1
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.kv.Store(configHistoryKey(xappName, namespace, v.Version), string(data)); err != nil {
+		return nil, err
+	}
+
+	if keys = append(keys, configHistoryKey(xappName, namespace, v.Version)); h.max > 0 && len(keys) > h.max {
+		if err := h.kv.Delete(keys[:len(keys)-h.max]); err != nil {
+			log.Error("NBI: dropping old config versions of xApp '%s' failed: %v", xappName, err)
+		}
+	}
+	return v, nil
+}
+
+// Versions returns the versions of the configuration of an xApp, oldest
+// first
+func (h *ConfigHistory) Versions(xappName, namespace string) ([]*ConfigVersion, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys, err := h.keys(xappName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*ConfigVersion
+	for _, key := range keys {
+		v, err := h.read(key)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// Version returns one version of the configuration of an xApp
+func (h *ConfigHistory) Version(xappName, namespace string, version int) (*ConfigVersion, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, err := h.read(configHistoryKey(xappName, namespace, version))
+	if err == nil && v == nil {
+		err = fmt.Errorf("no version %d of the config of xApp '%s' in namespace '%s'", version, xappName, namespace)
+	}
+	return v, err
+}
+
+// recordConfig notes a configuration applied to an xApp. The configuration
+// the xApp had before is recorded first if the xApp has no history yet, so
+// the changes made over O1 can all be rolled back.
+func (n *Nbi) recordConfig(xappName, namespace string, o Originator, comment string, before, after interface{}) *ConfigVersion {
+	if n.history == nil {
+		return nil
+	}
+	if before != nil {
+		if versions, err := n.history.Versions(xappName, namespace); err == nil && len(versions) == 0 {
+			n.history.Record(xappName, namespace, Originator{Name: "appmgr"}, "configuration before the first change over O1", before)
+		}
+	}
+
+	v, err := n.history.Record(xappName, namespace, o, comment, after)
+	if err != nil {
+		log.Error("NBI: recording the config of xApp '%s' failed: %v", xappName, err)
+		return nil
+	}
+	return v
+}
+
+func (n *Nbi) configVersions(xappName, namespace string, versions ...int) ([]*ConfigVersion, error) {
+	if n.history == nil {
+		return nil, fmt.Errorf("config history is not enabled")
+	}
+	if len(versions) == 0 {
+		return n.history.Versions(xappName, namespace)
+	}
+
+	var result []*ConfigVersion
+	for _, version := range versions {
+		v, err := n.history.Version(xappName, namespace, version)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func parseVersion(input map[string]string, name string) (int, error) {
+	version, err := strconv.ParseUint(input[name], 10, 31)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("invalid %s '%s'", name, input[name])
+	}
+	return int(version), nil
+}
+
+// listConfigVersions returns one line per version: number, time, client
+// and comment
+func (n *Nbi) listConfigVersions(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := n.configVersions(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, v := range versions {
+		author := v.Originator
+		if v.Author != "" {
+			author += "/" + v.Author
+		}
+		line := fmt.Sprintf("%d %s %s", v.Version, v.Time.Format(time.RFC3339), author)
+		if v.Comment != "" {
+			line += " " + v.Comment
+		}
+		lines = append(lines, line)
+	}
+	return map[string]string{"versions": strings.Join(lines, "\n")}, nil
+}
+
+func (n *Nbi) diffConfigVersions(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+	from, err := parseVersion(input, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseVersion(input, "to")
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := n.configVersions(name, namespace, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"diff": strings.Join(diffConfig(versions[0].Config, versions[1].Config), "\n")}, nil
+}
+
+// rollbackXappConfig writes a stored version of the config of an xApp into
+// the running config module, so the commit validates it against the config
+// schema of the xApp, sends it to the appmgr, saves it as intent and logs it
+// like any other edit. The rollback is recorded as a new version.
+func (n *Nbi) rollbackXappConfig(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+	version, err := parseVersion(input, "version")
+	if err != nil {
+		return nil, err
+	}
+	if err := n.authorize(o, AccessConfigure, namespace, name, xpath); err != nil {
+		return nil, err
+	}
+	store, ok := n.ds.(Store)
+	if !ok {
+		return nil, fmt.Errorf("the datastore cannot be edited by the agent")
+	}
+
+	versions, err := n.configVersions(name, namespace, version)
+	if err != nil {
+		return nil, err
+	}
+
+	defer lockEdits(store)()
+	target, err := rollbackTarget(store.GetConfig("/"), name, namespace, versions[0].Config)
+	if err != nil {
+		return nil, err
+	}
+	before, _ := n.history.Versions(name, namespace)
+
+	n.stageRollback(name, namespace, version)
+	defer n.stageRollback(name, namespace, 0)
+	if err := applyTree(store, o, target); err != nil {
+		return nil, err
+	}
+
+	after, _ := n.history.Versions(name, namespace)
+	if len(after) == 0 || len(before) > 0 && after[len(after)-1].Version == before[len(before)-1].Version {
+		return nil, nil
+	}
+	return map[string]string{"version": strconv.Itoa(after[len(after)-1].Version)}, nil
+}
+
+// rollbackTarget returns running with config as the control values of the
+// xApp in the config module. The module holds the config of a single xApp,
+// so a rollback of another one is rejected.
+func rollbackTarget(running *Node, name, namespace string, config interface{}) (*Node, error) {
+	module := "o-ran-sc-ric-ueec-config-v1"
+	root := fmt.Sprintf("/%s:ric/config", module)
+
+	target := running.Clone()
+	if current := target.Find(root); current != nil {
+		configured, ns := leafValue(current, "name"), leafValue(current, "namespace")
+		if configured != "" && (configured != name || ns != "" && ns != namespace) {
+			return nil, fmt.Errorf("module %s holds the config of xApp '%s', not of '%s'", module, configured, name)
+		}
+	}
+	target.Delete(root + "/control")
+
+	leaves := make(map[string]string)
+	if err := controlLeaves(root+"/control", config, leaves); err != nil {
+		return nil, err
+	}
+	leaves[root+"/name"] = name
+	leaves[root+"/namespace"] = namespace
+	for xpath, value := range leaves {
+		if err := target.Set(xpath, value); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+// controlLeaves adds the members of config as the leaves below xpath. The
+// arrays of a config come without the keys of the YANG list they belong
+// to, so they cannot be rolled back.
+func controlLeaves(xpath string, config interface{}, leaves map[string]string) error {
+	switch value := config.(type) {
+	case nil:
+	case map[string]interface{}:
+		for name, member := range value {
+			if err := controlLeaves(xpath+"/"+name, member, leaves); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		return fmt.Errorf("the array at %s cannot be rolled back", xpath)
+	case string:
+		leaves[xpath] = value
+	case float64:
+		leaves[xpath] = strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		leaves[xpath] = fmt.Sprint(value)
+	}
+	return nil
+}
+
+// stageRollback notes the version the commit in progress rolls the config
+// of an xApp back to, version 0 clearing it
+func (n *Nbi) stageRollback(name, namespace string, version int) {
+	n.rollbackMu.Lock()
+	defer n.rollbackMu.Unlock()
+
+	key := namespace + "/" + name
+	if version == 0 {
+		delete(n.rollbacks, key)
+		return
+	}
+	n.rollbacks[key] = version
+}
+
+// configComment is the comment recorded with a new config of an xApp
+func (n *Nbi) configComment(name, namespace string) string {
+	n.rollbackMu.Lock()
+	defer n.rollbackMu.Unlock()
+
+	if version, ok := n.rollbacks[namespace+"/"+name]; ok {
+		return fmt.Sprintf("rollback to version %d", version)
+	}
+	return ""
+}
+
+// diffConfig lists the members that differ between two configurations, one
+// line each: "+" for added, "-" for removed and "~" for changed members,
+// followed by their path and values
+func diffConfig(from, to interface{}) []string {
+	var lines []string
+	diffValue("", from, to, &lines)
+	return lines
+}
+
+func diffValue(path string, from, to interface{}, lines *[]string) {
+	fromObj, fromOk := from.(map[string]interface{})
+	toObj, toOk := to.(map[string]interface{})
+	if fromOk && toOk {
+		names := make(map[string]bool)
+		for name := range fromObj {
+			names[name] = true
+		}
+		for name := range toObj {
+			names[name] = true
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+
+		for _, name := range sorted {
+			f, inFrom := fromObj[name]
+			t, inTo := toObj[name]
+			switch {
+			case !inFrom:
+				*lines = append(*lines, fmt.Sprintf("+ %s/%s: %s", path, name, jsonText(t)))
+			case !inTo:
+				*lines = append(*lines, fmt.Sprintf("- %s/%s: %s", path, name, jsonText(f)))
+			default:
+				diffValue(path+"/"+name, f, t, lines)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		if path == "" {
+			path = "/"
+		}
+		*lines = append(*lines, fmt.Sprintf("~ %s: %s -> %s", path, jsonText(from), jsonText(to)))
+	}
+}
+
+func jsonText(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
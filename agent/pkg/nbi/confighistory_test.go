@@ -0,0 +1,167 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"strings"
+	"testing"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigHistory(t *testing.T) {
+	for name, kv := range map[string]kvStore{"memory": newMemKV(), "file": &fileKV{dir: t.TempDir()}} {
+		h := &ConfigHistory{kv: kv, max: 2}
+		for i := 1; i <= 3; i++ {
+			v, err := h.Record("ueec", "ricxapp", Originator{Name: "netconf", User: "admin"}, "", map[string]interface{}{"step": float64(i)})
+			assert.Nil(t, err, name)
+			assert.Equal(t, i, v.Version, name)
+		}
+		h.Record("ueec", "other", Originator{}, "", nil)
+
+		versions, err := h.Versions("ueec", "ricxapp")
+		assert.Nil(t, err, name)
+		if assert.Equal(t, 2, len(versions), name) {
+			assert.Equal(t, 2, versions[0].Version, name)
+			assert.Equal(t, "admin", versions[1].Author, name)
+			assert.Equal(t, map[string]interface{}{"step": float64(3)}, versions[1].Config, name)
+		}
+
+		_, err = h.Version("ueec", "ricxapp", 1)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	from := map[string]interface{}{
+		"active":      true,
+		"interfaceId": map[string]interface{}{"plmnId": "310150", "eNBId": "55"},
+		"cells":       []interface{}{"a"},
+	}
+	to := map[string]interface{}{
+		"active":      false,
+		"interfaceId": map[string]interface{}{"plmnId": "310150"},
+		"timeout":     float64(10),
+		"cells":       []interface{}{"a", "b"},
+	}
+	assert.Equal(t, []string{
+		"~ /active: true -> false",
+		`~ /cells: ["a"] -> ["a","b"]`,
+		`- /interfaceId/eNBId: "55"`,
+		"+ /timeout: 10",
+	}, diffConfig(from, to))
+	assert.Empty(t, diffConfig(from, from))
+}
+
+func TestConfigVersionRPCs(t *testing.T) {
+	mockCommands(t, "")
+	oldHistory := n.history
+	defer func() { n.history = oldHistory }()
+	n.history = &ConfigHistory{kv: newMemKV(), max: 20}
+
+	cs := newConfigServer(t, nil)
+	defer cs.Close()
+	defer func() {
+		ds.DeleteItem("/o-ran-sc-ric-ueec-config-v1:ric")
+		ds.ApplyChangesAs(Originator{Name: reconcilerOriginator})
+	}()
+
+	assert.Nil(t, n.ManageConfigmaps("o-ran-sc-ric-ueec-config-v1", XappConfig, 1))
+	assert.Nil(t, n.ManageConfigmaps("o-ran-sc-ric-ueec-config-v1", strings.Replace(XappConfig, `"55"`, `"56"`, 1), 1))
+
+	output, err := callRPC("list-config-versions", map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(strings.Split(output["versions"], "\n")))
+	assert.True(t, strings.HasPrefix(output["versions"], "1 "))
+
+	output, err = callRPC("diff-config-versions", map[string]string{"name": "ueec", "from": "1", "to": "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"diff": `~ /interfaceId/globalENBId/eNBId: "55" -> "56"`}, output)
+
+	output, err = callRPC("rollback-xapp-config", map[string]string{"name": "ueec", "version": "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"version": "3"}, output)
+	if assert.Equal(t, 3, len(cs.puts)) {
+		assert.Equal(t, cs.puts[0], cs.puts[2])
+	}
+
+	output, err = callRPC("list-config-versions", map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Contains(t, output["versions"], "rollback to version 1")
+	if records := n.Audit().Records(); assert.NotEmpty(t, records) {
+		rec := records[len(records)-1]
+		assert.Equal(t, audit.OutcomeCommitted, rec.Outcome)
+		assert.Equal(t, "o-ran-sc-ric-ueec-config-v1", rec.Module)
+		assert.Equal(t, []string{"modify config of xApp 'ueec'"}, rec.SBICalls)
+	}
+
+	// a version the current config schema rejects is not applied
+	mockCommands(t, `{"type": "object", "required": ["timeout"]}`)
+	_, err = callRPC("rollback-xapp-config", map[string]string{"name": "ueec", "version": "2"})
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, len(cs.puts))
+	if records := n.Audit().Records(); assert.NotEmpty(t, records) {
+		assert.Equal(t, audit.OutcomeRejected, records[len(records)-1].Outcome)
+	}
+
+	_, err = callRPC("rollback-xapp-config", map[string]string{"name": "ueec", "version": "9"})
+	assert.NotNil(t, err)
+	_, err = callRPC("diff-config-versions", map[string]string{"name": "ueec", "from": "0", "to": "1"})
+	assert.NotNil(t, err)
+}
+
+func TestConfigRollbackSurvivesReplay(t *testing.T) {
+	mockCommands(t, "")
+	cs := newConfigServer(t, map[string]interface{}{"timeout": float64(5)})
+	defer cs.Close()
+
+	p, store := newIntentNbi(t, newMemKV(), ReplayAppmgr)
+	p.history = &ConfigHistory{kv: newMemKV(), max: 20}
+	control := "/o-ran-sc-ric-ueec-config-v1:ric/config/control"
+	store.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/name", "ueec")
+	store.SetItem(control+"/active", "true")
+	assert.Nil(t, store.ApplyChanges())
+	store.SetItem(control+"/active", "false")
+	assert.Nil(t, store.ApplyChanges())
+
+	o := Originator{Name: "netconf", User: "admin"}
+	output, err := p.rollbackXappConfig(o, "", map[string]string{"name": "ueec", "version": "2"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"version": "4"}, output)
+	assert.Equal(t, "true", store.GetConfig(control).Find(control+"/active").Value)
+	if records := p.Audit().Records(); assert.NotEmpty(t, records) {
+		assert.Equal(t, "admin", records[len(records)-1].User)
+	}
+	if v, err := p.history.Version("ueec", "ricxapp", 4); assert.Nil(t, err) {
+		assert.Equal(t, "rollback to version 2", v.Comment)
+	}
+
+	// a restart with an appmgr that lost the config applies the rollback
+	cs.config = map[string]interface{}{"timeout": float64(5)}
+	report := &RestoreReport{}
+	p.replayXappConfig(store, report)
+	assert.Equal(t, []RestoreItem{{"config", "ueec", "applied"}}, report.Items)
+	assert.Equal(t, map[string]interface{}{"active": true, "timeout": float64(5)}, cs.config)
+
+	// the config module holds the config of ueec only
+	_, err = p.rollbackXappConfig(o, "", map[string]string{"name": "anr", "version": "1"})
+	assert.NotNil(t, err)
+}
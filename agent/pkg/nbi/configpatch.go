@@ -0,0 +1,262 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+)
+
+// patchXappConfig turns the leaves a transaction changes below the control
+// container of an xApp config module into a JSON Merge Patch (RFC 7386) of
+// the configuration the appmgr has for the xApp. The patched configuration
+// is validated and replaces the old one in a single call. Deleted leaves get
+// their schema default back, or are removed if they have none. A dry run
+// notes the members the call would change instead of making it.
+func (n *Nbi) patchXappConfig(session ChangeSession, module string, rec *audit.Record, dryRun bool) error {
+	cc, err := n.xappConfigPatch(session, module)
+	if err != nil || cc == nil {
+		return err
+	}
+
+	if dryRun {
+		rec.AddSBICall("modify config of xApp '%s': %s", cc.name, strings.Join(diffConfig(cc.current, cc.config), "; "))
+		return nil
+	}
+	rec.AddSBICall("modify config of xApp '%s'", cc.name)
+	if err := sbiClient.ModifyXappConfig(sbiClient.BuildXappConfig(cc.name, cc.namespace, cc.config)); err != nil {
+		return &ChangeError{Xpath: fmt.Sprintf("/%s:ric/config", module), Message: fmt.Sprintf("configuring xApp '%s' failed: %v", cc.name, err), Err: err}
+	}
+	n.recordConfig(cc.name, cc.namespace, session.Originator(), n.configComment(cc.name, cc.namespace), cc.current, cc.config)
+	return nil
+}
+
+// xappConfigChange is the configuration a transaction gives an xApp, next
+// to the one the appmgr has
+type xappConfigChange struct {
+	name      string
+	namespace string
+	current   interface{}
+	config    interface{}
+}
+
+// xappConfigPatch builds and validates the configuration patchXappConfig
+// sends, nil if the transaction changes no control leaf
+func (n *Nbi) xappConfigPatch(session ChangeSession, module string) (*xappConfigChange, error) {
+	changes, err := session.GetChanges("//.")
+	if err != nil {
+		return nil, err
+	}
+
+	root := fmt.Sprintf("/%s:ric/config", module)
+	control := root + "/control"
+	var name, namespace string
+	var leaves []Change
+	for _, c := range changes {
+		value := c.NewValue
+		if c.Oper == OpDeleted {
+			value = c.OldValue
+		}
+		switch {
+		case c.Xpath == root+"/name":
+			name = value
+		case c.Xpath == root+"/namespace":
+			namespace = value
+		case c.Leaf && strings.HasPrefix(c.Xpath, control+"/"):
+			leaves = append(leaves, c)
+		}
+	}
+	if len(leaves) == 0 {
+		return nil, nil
+	}
+
+	data, err := session.GetData("/" + module + ":ric")
+	if err != nil {
+		return nil, err
+	}
+	var after interface{}
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &after); err != nil {
+			return nil, &ChangeError{Xpath: root, Tag: "invalid-value", Message: fmt.Sprintf("invalid xApp configuration: %v", err), Err: err}
+		}
+	}
+	if name == "" {
+		name, _ = lookup(after, module+":ric", "config", "name").(string)
+	}
+	if namespace == "" {
+		namespace, _ = lookup(after, module+":ric", "config", "namespace").(string)
+	}
+	if namespace == "" {
+		namespace = n.namespaces.defaultNamespace()
+	}
+
+	schema, err := n.xappConfigSchema(control, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	current, err := sbiClient.GetXappConfig(name, namespace)
+	if err != nil {
+		return nil, &ChangeError{Xpath: root, Message: fmt.Sprintf("reading config of xApp '%s' failed: %v", name, err), Err: err}
+	}
+
+	patch := make(map[string]interface{})
+	newControl := lookup(after, module+":ric", "config", "control")
+	for _, c := range leaves {
+		path, list, err := controlPath(c.Xpath)
+		if err != nil {
+			return nil, &ChangeError{Xpath: c.Xpath, Tag: "invalid-value", Message: err.Error(), Err: err}
+		}
+
+		var value interface{}
+		switch {
+		case list:
+			// A merge patch replaces arrays, so the whole list is sent
+			value = lookup(newControl, path...)
+		case c.Oper == OpDeleted:
+			if prop := schemaProperty(schema, path); prop != nil {
+				value = prop.Default
+			}
+		default:
+			value = leafJSON(c.NewValue, lookup(current, path...), schemaProperty(schema, path))
+		}
+		setPatch(patch, path, value)
+	}
+
+	config := mergePatch(current, patch)
+	if schema != nil {
+		if err := validateXappConfig(control, name, schema, config, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return &xappConfigChange{name: name, namespace: namespace, current: current, config: config}, nil
+}
+
+// controlPath returns the member names of a leaf below the control
+// container. If the leaf is in a list, the path ends at the list.
+func controlPath(xpath string) ([]string, bool, error) {
+	segs, err := ParsePath(xpath)
+	if err != nil {
+		return nil, false, err
+	}
+	var path []string
+	for _, s := range segs[3:] {
+		path = append(path, s.Name)
+		if len(s.Keys) > 0 {
+			return path, true, nil
+		}
+	}
+	return path, false, nil
+}
+
+// leafJSON gives the value of a leaf the JSON type the schema, or else the
+// current configuration, has for it. Other values are strings, except for
+// the boolean literals.
+func leafJSON(value string, current interface{}, schema *spec.Schema) interface{} {
+	kind := ""
+	switch {
+	case schema != nil && len(schema.Type) == 1:
+		kind = schema.Type[0]
+	case current != nil:
+		switch current.(type) {
+		case bool:
+			kind = "boolean"
+		case float64, json.Number:
+			kind = "number"
+		}
+	}
+
+	switch kind {
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "":
+		if value == "true" || value == "false" {
+			return value == "true"
+		}
+	}
+	return value
+}
+
+// lookup returns the member at path of a decoded JSON document, nil if it
+// does not exist
+func lookup(doc interface{}, path ...string) interface{} {
+	for _, name := range path {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = obj[name]
+	}
+	return doc
+}
+
+func setPatch(patch map[string]interface{}, path []string, value interface{}) {
+	for _, name := range path[:len(path)-1] {
+		child, ok := patch[name].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			patch[name] = child
+		}
+		patch = child
+	}
+	patch[path[len(path)-1]] = value
+}
+
+// mergePatch applies a JSON Merge Patch to target, leaving target as is.
+// Objects the patch leaves empty are removed, like non-presence containers
+// without children.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	result := make(map[string]interface{})
+	if t, ok := target.(map[string]interface{}); ok {
+		for k, v := range t {
+			result[k] = v
+		}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		merged := mergePatch(result[k], v)
+		if obj, ok := merged.(map[string]interface{}); ok && len(obj) == 0 {
+			delete(result, k)
+			continue
+		}
+		result[k] = merged
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
+	"github.com/stretchr/testify/assert"
+)
+
+// configServer is an appmgr serving the configuration of the ueec xApp and
+// keeping what is put
+type configServer struct {
+	*httptest.Server
+	config interface{}
+	puts   []interface{}
+}
+
+func newConfigServer(t *testing.T, config interface{}) *configServer {
+	l, err := net.Listen("tcp", "localhost:8080")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	s := &configServer{config: config}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ric/v1/config", r.URL.String())
+		w.Header().Add("Content-Type", "application/json")
+
+		switch r.Method {
+		case "GET":
+			name, namespace := "ueec", "ricxapp"
+			json.NewEncoder(w).Encode(apimodel.AllXappConfig{&apimodel.XAppConfig{
+				Metadata: &apimodel.ConfigMetadata{XappName: &name, Namespace: &namespace},
+				Config:   s.config,
+			}})
+		case "PUT":
+			var cfg apimodel.XAppConfig
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&cfg))
+			s.puts = append(s.puts, cfg.Config)
+			s.config = cfg.Config
+			json.NewEncoder(w).Encode(apimodel.ConfigValidationErrors{})
+		default:
+			t.Errorf("unexpected %s", r.Method)
+		}
+	}))
+	s.Listener.Close()
+	s.Listener = l
+	s.Start()
+	return s
+}
+
+func TestMergePatch(t *testing.T) {
+	target := map[string]interface{}{
+		"a": "b",
+		"c": map[string]interface{}{"d": "e", "f": "g"},
+	}
+	patch := map[string]interface{}{
+		"a": "z",
+		"c": map[string]interface{}{"f": nil},
+		"h": []interface{}{"i"},
+		"j": map[string]interface{}{"k": nil},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"a": "z",
+		"c": map[string]interface{}{"d": "e"},
+		"h": []interface{}{"i"},
+	}, mergePatch(target, patch))
+	assert.Equal(t, "b", target["a"])
+}
+
+func TestLeafJSON(t *testing.T) {
+	assert.Equal(t, true, leafJSON("true", nil, nil))
+	assert.Equal(t, "55", leafJSON("55", nil, nil))
+	assert.Equal(t, float64(55), leafJSON("55", float64(1), nil))
+	assert.Equal(t, float64(55), leafJSON("55", json.Number("1"), nil))
+	assert.Equal(t, "55", leafJSON("55", "1", nil))
+}
+
+func TestConfigChangesArePatched(t *testing.T) {
+	ts := newConfigServer(t, map[string]interface{}{
+		"active":      true,
+		"logLevel":    "debug",
+		"interfaceId": map[string]interface{}{"globalENBId": map[string]interface{}{"plmnId": "12345", "eNBId": float64(55)}},
+	})
+	defer ts.Close()
+	mockCommands(t, `{
+		"type": "object",
+		"properties": {
+			"active": {"type": "boolean", "default": true},
+			"interfaceId": {
+				"type": "object",
+				"properties": {
+					"globalENBId": {
+						"type": "object",
+						"properties": {
+							"plmnId": {"type": "string"},
+							"eNBId": {"type": "integer", "maximum": 1000}
+						}
+					}
+				}
+			}
+		}
+	}`)
+
+	session := func(changes ...Change) *memSession {
+		data := NewTree()
+		data.Set("/o-ran-sc-ric-ueec-config-v1:ric/config/name", "ueec")
+		return &memSession{data: data, changes: changes}
+	}
+	control := "/o-ran-sc-ric-ueec-config-v1:ric/config/control"
+
+	err := n.patchXappConfig(session(
+		Change{Oper: OpModified, Xpath: control + "/active", OldValue: "true", NewValue: "false", Leaf: true},
+		Change{Oper: OpModified, Xpath: control + "/interfaceId/globalENBId/eNBId", OldValue: "55", NewValue: "56", Leaf: true},
+	), "o-ran-sc-ric-ueec-config-v1", nil, false)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"active":      false,
+		"logLevel":    "debug",
+		"interfaceId": map[string]interface{}{"globalENBId": map[string]interface{}{"plmnId": "12345", "eNBId": float64(56)}},
+	}, ts.config)
+
+	err = n.patchXappConfig(session(
+		Change{Oper: OpDeleted, Xpath: control + "/active", OldValue: "false", Leaf: true},
+		Change{Oper: OpDeleted, Xpath: control + "/interfaceId/globalENBId/plmnId", OldValue: "12345", Leaf: true},
+	), "o-ran-sc-ric-ueec-config-v1", nil, false)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"active":      true,
+		"logLevel":    "debug",
+		"interfaceId": map[string]interface{}{"globalENBId": map[string]interface{}{"eNBId": float64(56)}},
+	}, ts.config)
+
+	puts := len(ts.puts)
+	var cerr *ChangeError
+	err = n.patchXappConfig(session(
+		Change{Oper: OpModified, Xpath: control + "/interfaceId/globalENBId/eNBId", OldValue: "56", NewValue: "5600", Leaf: true},
+	), "o-ran-sc-ric-ueec-config-v1", nil, false)
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, control+"/interfaceId/globalENBId/eNBId", cerr.Xpath)
+	}
+	assert.Equal(t, puts, len(ts.puts))
+}
@@ -0,0 +1,154 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Event is the phase of a datastore transaction, numbered as sr_event_t
+type Event int
+
+const (
+	EventUpdate Event = iota
+	EventChange
+	EventDone
+	EventAbort
+)
+
+// Operation is the kind of a single data change, numbered as sr_change_oper_t
+type Operation int
+
+const (
+	OpCreated Operation = iota
+	OpModified
+	OpDeleted
+	OpMoved
+)
+
+func (o Operation) String() string {
+	switch o {
+	case OpCreated:
+		return "created"
+	case OpModified:
+		return "modified"
+	case OpDeleted:
+		return "deleted"
+	case OpMoved:
+		return "moved"
+	}
+	return fmt.Sprintf("operation(%d)", int(o))
+}
+
+// Change describes one created, modified or deleted node of a transaction
+type Change struct {
+	Oper     Operation
+	Xpath    string
+	OldValue string
+	NewValue string
+	Leaf     bool
+}
+
+// ChangeSession gives a module change callback access to the transaction
+type ChangeSession interface {
+	// GetChanges returns the changes below xpath, "//." selects all of them
+	GetChanges(xpath string) ([]Change, error)
+	// GetData returns the data below xpath, as it will be after the commit, in JSON
+	GetData(xpath string) (string, error)
+	// Originator tells who made the transaction
+	Originator() Originator
+}
+
+// Originator identifies the client of a transaction: Name is the northbound
+// interface it came through, User and Session the client on it. Session is
+// the NETCONF session-id where there is one.
+type Originator struct {
+	Name    string
+	User    string
+	Session uint32
+}
+
+// ChangeError rejects a transaction with a reason for the client and the
+// xpath of the node that caused it. Tag is the NETCONF error-tag, empty
+// meaning operation-failed.
+type ChangeError struct {
+	Xpath   string
+	Tag     string
+	Message string
+	Err     error
+}
+
+func (e *ChangeError) Error() string {
+	return e.Message
+}
+
+func (e *ChangeError) Unwrap() error {
+	return e.Err
+}
+
+// ModuleChangeHandler is called for every phase of a transaction touching a
+// subscribed module. Returning an error in EventChange rejects the transaction.
+type ModuleChangeHandler func(session ChangeSession, module, xpath string, event Event, reqID int) error
+
+// OperDataHandler fills the operational data of a subscribed subtree
+type OperDataHandler func(module, xpath string, tree OperDataTree) error
+
+// RPCHandler serves a YANG RPC or action invoked by o. Input and output are
+// the leaf values keyed by their path relative to the operation node.
+type RPCHandler func(o Originator, xpath string, input map[string]string) (map[string]string, error)
+
+// Datastore is what the NBI needs from a YANG datastore. SysrepoDatastore
+// is used in production, MemDatastore for tests and sysrepo-less builds.
+type Datastore interface {
+	Connect() error
+	Disconnect()
+	SubscribeModuleChange(module string, handler ModuleChangeHandler) error
+	SubscribeOperData(module, xpath string, handler OperDataHandler) error
+	// SubscribeRPC serves the RPC or action at xpath
+	SubscribeRPC(xpath string, handler RPCHandler) error
+	// Notify sends the YANG notification at xpath with the given leaf values
+	Notify(xpath string, leaves map[string]string) error
+}
+
+// Store is a Datastore that northbound servers read and edit directly.
+// Edits are staged with SetItem/DeleteItem and committed by ApplyChanges,
+// which runs the subscribed module change handlers. ApplyChangesAs tells
+// the handlers who made the edits. Every writer holds EditLock from staging
+// its edits until it has applied or discarded them, so the edits of
+// different northbounds never end up in the same commit.
+type Store interface {
+	Datastore
+	// GetItems returns the configuration and operational data below xpath
+	GetItems(xpath string) (*Node, error)
+	// GetConfig returns the running configuration below xpath
+	GetConfig(xpath string) *Node
+	HasItem(xpath string) bool
+	SetItem(xpath, value string) error
+	DeleteItem(xpath string) error
+	DiscardChanges()
+	ApplyChanges() error
+	ApplyChangesAs(o Originator) error
+	// CallRPC invokes the RPC or action at xpath and returns its output
+	CallRPC(xpath string, input map[string]string) (map[string]string, error)
+	CallRPCAs(o Originator, xpath string, input map[string]string) (map[string]string, error)
+	// EditLock returns the lock of the staged edits
+	EditLock() sync.Locker
+}
@@ -0,0 +1,148 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+)
+
+const dryRunXpath = "/o-ran-sc-ric-xapp-desc-v1:ric/dry-run"
+
+// ErrDryRun is wrapped by the error rejecting a transaction that was only
+// planned because the agent is in dry-run mode
+var ErrDryRun = errors.New("dry run")
+
+// dryRunApplies tells if the CHANGE event of a transaction is only planned:
+// the agent is in dry-run mode and the transaction changes xApps or their
+// configurations, not just the mode. The commits of the agent itself are
+// never planned.
+func (n *Nbi) dryRunApplies(session ChangeSession, module string) bool {
+	switch session.Originator().Name {
+	case reconcilerOriginator, confirmedCommitOriginator, startupOriginator:
+		return false
+	}
+	if !isIntentModule(module) {
+		return false
+	}
+
+	n.dryRunMu.Lock()
+	on := n.dryRun
+	n.dryRunMu.Unlock()
+	if !on {
+		return false
+	}
+
+	changes, err := session.GetChanges("//.")
+	if err != nil {
+		return true
+	}
+	for _, c := range changes {
+		if c.Xpath != dryRunXpath {
+			return true
+		}
+	}
+	return false
+}
+
+// stageDryRun reads the dry-run mode a transaction sets. It is used once
+// the transaction is done.
+func (n *Nbi) stageDryRun(session ChangeSession, reqID int) error {
+	changes, err := session.GetChanges("//.")
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if c.Xpath == dryRunXpath {
+			n.dryRunMu.Lock()
+			n.dryRunTx[reqID] = c.Oper != OpDeleted && c.NewValue == "true"
+			n.dryRunMu.Unlock()
+		}
+	}
+	return nil
+}
+
+// finishDryRun takes the dry-run mode staged by a transaction once it is
+// committed, or drops it
+func (n *Nbi) finishDryRun(reqID int, committed bool) {
+	n.dryRunMu.Lock()
+	defer n.dryRunMu.Unlock()
+
+	if on, ok := n.dryRunTx[reqID]; ok && committed {
+		log.Info("NBI: dry-run mode %v", on)
+		n.dryRun = on
+	}
+	delete(n.dryRunTx, reqID)
+}
+
+// dryRunError rejects a planned transaction, listing the SBI calls it would
+// have made
+func dryRunError(module string, rec *audit.Record) error {
+	calls := "none"
+	if len(rec.SBICalls) > 0 {
+		calls = strings.Join(rec.SBICalls, ", ")
+	}
+	return &ChangeError{
+		Xpath:   dryRunXpath,
+		Message: fmt.Sprintf("dry run, nothing applied to module '%s'; planned SBI calls: %s", module, calls),
+		Err:     ErrDryRun,
+	}
+}
+
+// DryRun runs every check of a commit of changes and plans its SBI calls
+// without making them. The plan of each module is logged as an audit record
+// with the dry-run outcome.
+func (n *Nbi) DryRun(o Originator, changes []Change, data *Node) error {
+	_, err := n.planChanges(o, changes, data)
+	return err
+}
+
+// planChanges returns the SBI calls a commit of changes would make
+func (n *Nbi) planChanges(o Originator, changes []Change, data *Node) ([]string, error) {
+	if err := n.validateChanges(o, changes, data); err != nil {
+		return nil, err
+	}
+
+	var calls []string
+	for _, module := range n.schemas {
+		modChanges := changesOfModule(changes, module)
+		if len(modChanges) == 0 {
+			continue
+		}
+
+		session := &memSession{changes: modChanges, data: data, originator: o}
+		rec := newAuditRecord(session, module)
+		err := n.applyChange(session, module, 0, rec, true)
+		rec.Outcome = audit.OutcomeDryRun
+		if err != nil {
+			rec.Outcome, rec.Error = audit.OutcomeRejected, err.Error()
+		}
+		n.audit.Log(rec)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, rec.SBICalls...)
+	}
+	return calls, nil
+}
@@ -0,0 +1,100 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"testing"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func setDryRun(t *testing.T, store Store, on string) {
+	assert.Nil(t, store.SetItem(dryRunXpath, on))
+	assert.Nil(t, store.ApplyChanges())
+}
+
+func TestDryRunMode(t *testing.T) {
+	a := newAppmgr(t, nil)
+	p, _ := newCandidateNbi(t)
+	store := p.Datastore().(Store)
+	setDryRun(t, store, "true")
+
+	store.SetItem(candidateXapp+"/release-name", "kpimon")
+	err := store.ApplyChanges()
+	assert.True(t, errors.Is(err, ErrDryRun))
+	assert.Contains(t, err.Error(), "planned SBI calls: deploy xApp 'kpimon'")
+	assert.False(t, store.HasItem(candidateXapp))
+	assert.Empty(t, a.changes())
+	if records := p.Audit().Records(); assert.NotEmpty(t, records) {
+		rec := records[len(records)-1]
+		assert.Equal(t, audit.OutcomeDryRun, rec.Outcome)
+		assert.Equal(t, []string{"deploy xApp 'kpimon'"}, rec.SBICalls)
+	}
+
+	// the commits of the agent itself are made
+	store.SetItem(candidateXapp+"/release-name", "kpimon")
+	assert.Nil(t, store.ApplyChangesAs(Originator{Name: confirmedCommitOriginator}))
+	assert.Equal(t, []string{"POST /ric/v1/xapps"}, a.changes())
+
+	// turning the mode off along with an edit is planned too
+	store.SetItem(dryRunXpath, "false")
+	store.DeleteItem(candidateXapp)
+	assert.True(t, errors.Is(store.ApplyChanges(), ErrDryRun))
+
+	setDryRun(t, store, "false")
+	store.DeleteItem(candidateXapp)
+	assert.Nil(t, store.ApplyChanges())
+	assert.Equal(t, []string{"POST /ric/v1/xapps", "DELETE /ric/v1/xapps/kpimon"}, a.changes())
+}
+
+func TestDryRunConfig(t *testing.T) {
+	mockCommands(t, "")
+	cs := newConfigServer(t, map[string]interface{}{"active": false, "timeout": float64(5)})
+	defer cs.Close()
+
+	p, _ := newCandidateNbi(t)
+	store := p.Datastore().(Store)
+	setDryRun(t, store, "true")
+
+	store.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/name", "ueec")
+	store.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/control/active", "true")
+	err := store.ApplyChanges()
+	assert.True(t, errors.Is(err, ErrDryRun))
+	assert.Contains(t, err.Error(), "modify config of xApp 'ueec': ~ /active: false -> true")
+	assert.Empty(t, cs.puts)
+
+	// the dry run of the NBI plans without any mode
+	setDryRun(t, store, "false")
+	changes := []Change{
+		{Oper: OpCreated, Xpath: "/o-ran-sc-ric-ueec-config-v1:ric/config/name", NewValue: "ueec", Leaf: true},
+		{Oper: OpCreated, Xpath: "/o-ran-sc-ric-ueec-config-v1:ric/config/control/active", NewValue: "true", Leaf: true},
+	}
+	data := NewTree()
+	for _, c := range changes {
+		data.Set(c.Xpath, c.NewValue)
+	}
+	calls, err := p.planChanges(Originator{}, changes, data)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"modify config of xApp 'ueec': ~ /active: false -> true"}, calls)
+	assert.Nil(t, p.DryRun(Originator{}, changes, data))
+	assert.Empty(t, cs.puts)
+}
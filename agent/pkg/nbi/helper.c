@@ -1,3 +1,5 @@
+//go:build cgo && !nosysrepo
+
 /*
 ==================================================================================
   Copyright (c) 2019 AT&T Intellectual Property.
@@ -21,6 +23,7 @@
 #include "helper.h"
 #include "_cgo_export.h"
 #include <sysrepo.h>
+#include <sysrepo/values.h>
 #include <libyang/libyang.h>
 
 
@@ -28,55 +31,10 @@ int module_change_cb(sr_session_ctx_t *session, const char *module_name, const c
     return nbiModuleChangeCB(session, (char *)module_name, (char *)xpath, event, (int)req_id);
 }
 
-char * yang_data_sr2json(sr_session_ctx_t *session, const char *module_name, sr_event_t event, sr_change_oper_t *operation) {
-    sr_change_iter_t *it = NULL;
-    int rc = SR_ERR_OK;
-    sr_change_oper_t oper;
-    sr_val_t *old_value = NULL;
-    sr_val_t *new_value = NULL;
-    sr_val_t *val = NULL;
-    char *json_str = NULL;
-    struct lyd_node *root = NULL;
-
-    rc = sr_get_changes_iter(session, "//." , &it);
-    if (rc != SR_ERR_OK) {
-        goto cleanup;
-    }
-
-    while ((rc = sr_get_change_next(session, it, &oper, &old_value, &new_value)) == SR_ERR_OK) {
-        *operation = oper;
-        val = oper == SR_OP_CREATED ? new_value : old_value;
-        char *leaf = sr_val_to_str(val);
-        if (root) {
-            lyd_new_path(root, NULL, val->xpath, (void *) leaf, 0, 1);
-        } else {
-            root = lyd_new_path(NULL, sr_get_context(sr_session_get_connection(session)), val->xpath, (void *) leaf, 0, 1);
-        }
-
-        sr_free_val(old_value);
-        sr_free_val(new_value);
-        if (leaf) {
-            free(leaf);
-        }
-    }
-
-    if (root) {
-        lyd_print_mem(&json_str, root, LYD_JSON, LYP_WITHSIBLINGS | LYP_FORMAT);
-        printf("\n%s\n", json_str);
-    }
-
-cleanup:
-    sr_free_change_iter(it);
-    return json_str;
-}
-
-char * get_data_json(sr_session_ctx_t *session, const char *module_name) {
-    struct lyd_node *data;
+char * get_data_json(sr_session_ctx_t *session, const char *xpath) {
+    struct lyd_node *data = NULL;
     char *json_str = NULL;
     int rc;
-    char xpath[100];
-
-    sprintf(xpath, "/%s:ric", module_name);
 
     rc = sr_get_data(session, xpath, 0, 0, 0, &data);
     if (rc != SR_ERR_OK) {
@@ -86,6 +44,7 @@ char * get_data_json(sr_session_ctx_t *session, const char *module_name) {
 
     if (data) {
         lyd_print_mem(&json_str, data, LYD_JSON, LYP_WITHSIBLINGS | LYP_FORMAT);
+        lyd_free_withsiblings(data);
     }
     return json_str;
 }
@@ -107,3 +66,31 @@ void create_new_path(sr_session_ctx_t *session, char **parent, char *key, char *
         lyd_new_path(*p, sr_get_context(sr_session_get_connection(session)), key, value, 0, 0);
     }
 }
+
+int rpc_cb(sr_session_ctx_t *session, const char *op_path, const sr_val_t *input, const size_t input_cnt, sr_event_t event, uint32_t request_id, sr_val_t **output, size_t *output_cnt, void *private_data) {
+    return nbiRPCCB(session, (char *)op_path, (sr_val_t *)input, input_cnt, output, output_cnt);
+}
+
+int set_str_val(sr_val_t *val, size_t i, const char *xpath, const char *value) {
+    int rc;
+
+    rc = sr_val_set_xpath(&val[i], xpath);
+    if (rc != SR_ERR_OK) {
+        return rc;
+    }
+    return sr_val_set_str_data(&val[i], SR_STRING_T, value);
+}
+
+int set_error(sr_session_ctx_t *session, const char *path, const char *message) {
+    return sr_set_error(session, path, "%s", message);
+}
+
+int send_notification(sr_session_ctx_t *session, char **notif) {
+    struct lyd_node **n = (struct lyd_node **)notif;
+    int rc;
+
+    rc = sr_event_notif_send_tree(session, *n);
+    lyd_free_withsiblings(*n);
+    *n = NULL;
+    return rc;
+}
@@ -26,12 +26,18 @@ sr_val_t *get_val(sr_val_t *val, size_t i);
 
 int module_change_cb(sr_session_ctx_t *session, const char *module_name, const char *xpath, sr_event_t event, uint32_t request_id, void *private_data);
 
-char * yang_data_sr2json(sr_session_ctx_t *session, const char *module_name, sr_event_t event, sr_change_oper_t *operation);
-
-char * get_data_json(sr_session_ctx_t *session, const char *module_name);
+char * get_data_json(sr_session_ctx_t *session, const char *xpath);
 
 int gnb_status_cb(sr_session_ctx_t *session, const char *module_name, const char *xpath, const char *req_xpath, uint32_t req_id, struct lyd_node **parent, void *private_data);
 
 void create_new_path(sr_session_ctx_t *session, char **parent, char *key, char *value);
 
+int send_notification(sr_session_ctx_t *session, char **notif);
+
+int rpc_cb(sr_session_ctx_t *session, const char *op_path, const sr_val_t *input, const size_t input_cnt, sr_event_t event, uint32_t request_id, sr_val_t **output, size_t *output_cnt, void *private_data);
+
+int set_str_val(sr_val_t *val, size_t i, const char *xpath, const char *value);
+
+int set_error(sr_session_ctx_t *session, const char *path, const char *message);
+
 #endif
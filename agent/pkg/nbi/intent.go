@@ -0,0 +1,449 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Replay modes of the persisted intent at startup
+const (
+	ReplayOff       = "off"
+	ReplayDatastore = "datastore"
+	ReplayAppmgr    = "appmgr"
+)
+
+const (
+	intentPrefix      = "o1-intent"
+	restoreXpath      = "/o-ran-sc-ric-xapp-desc-v1:ric/restore"
+	startupOriginator = "startup"
+)
+
+// intentModules hold the intended xApps and their configurations
+var intentModules = []string{xappDescModule, "o-ran-sc-ric-ueec-config-v1"}
+
+// savedIntent is the configuration of a module as it is persisted
+type savedIntent struct {
+	Module string            `json:"module"`
+	Saved  time.Time         `json:"saved"`
+	Leaves map[string]string `json:"leaves"`
+}
+
+// IntentStore persists the committed configuration of the intent modules,
+// so the xApps and their configurations survive a redeployment of the RIC
+// and of the agent with it
+type IntentStore struct {
+	kv     kvStore
+	replay string
+	mu     sync.Mutex
+	trees  map[string]*Node
+	tx     map[string][]Change
+	report *RestoreReport
+}
+
+// RestoreItem is what the replay did about an xApp or its configuration
+type RestoreItem struct {
+	Kind   string
+	Name   string
+	Action string
+}
+
+// RestoreReport tells what the replay at startup restored
+type RestoreReport struct {
+	Time  time.Time
+	Mode  string
+	Items []RestoreItem
+}
+
+func (r *RestoreReport) add(kind, name, format string, args ...interface{}) {
+	item := RestoreItem{Kind: kind, Name: name, Action: fmt.Sprintf(format, args...)}
+	log.Info("NBI: restore of %s '%s': %s", kind, name, item.Action)
+	r.Items = append(r.Items, item)
+}
+
+func newIntentStore() *IntentStore {
+	return NewIntentStore(newKVStore("startup"), viper.GetString("startup.replay"))
+}
+
+// NewIntentStore keeps the intent in kv and replays it at startup as mode
+// says
+func NewIntentStore(kv kvStore, mode string) *IntentStore {
+	switch mode {
+	case ReplayOff, ReplayDatastore, ReplayAppmgr:
+	case "":
+		mode = ReplayOff
+	default:
+		log.Error("NBI: unknown replay mode '%s', using '%s'", mode, ReplayOff)
+		mode = ReplayOff
+	}
+	return &IntentStore{kv: kv, replay: mode, trees: make(map[string]*Node), tx: make(map[string][]Change)}
+}
+
+func intentKey(module string) string {
+	return intentPrefix + "/" + module
+}
+
+// load returns the persisted configuration of module, nil if there is none
+func (s *IntentStore) load(module string) (*Node, error) {
+	values, err := s.kv.Read(intentKey(module))
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	switch value := values[intentKey(module)].(type) {
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return nil, nil
+	}
+
+	var saved savedIntent
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid intent of module '%s': %v", module, err)
+	}
+	tree := NewTree()
+	for xpath, value := range saved.Leaves {
+		if err := tree.Set(xpath, value); err != nil {
+			return nil, fmt.Errorf("invalid intent of module '%s': %v", module, err)
+		}
+	}
+	return tree, nil
+}
+
+func (s *IntentStore) save(module string, tree *Node) error {
+	saved := savedIntent{Module: module, Saved: time.Now().UTC(), Leaves: make(map[string]string)}
+	tree.Walk(func(xpath string, node *Node) {
+		if node.Leaf {
+			saved.Leaves[xpath] = node.Value
+		}
+	})
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return s.kv.Store(intentKey(module), string(data))
+}
+
+// Report returns the result of the replay at startup, nil before it ran
+func (s *IntentStore) Report() *RestoreReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.report
+}
+
+func isIntentModule(module string) bool {
+	for _, m := range intentModules {
+		if m == module {
+			return true
+		}
+	}
+	return false
+}
+
+// stageIntent keeps the changes of a transaction to an intent module until
+// the transaction is done
+func (n *Nbi) stageIntent(session ChangeSession, module string, reqID int) {
+	if n.intent == nil || !isIntentModule(module) {
+		return
+	}
+	changes, err := session.GetChanges("//.")
+	if err != nil {
+		log.Error("NBI: reading changes for the startup intent failed: %v", err)
+		return
+	}
+
+	n.intent.mu.Lock()
+	defer n.intent.mu.Unlock()
+
+	n.intent.tx[auditKey(module, reqID)] = changes
+}
+
+// finishIntent persists the intent of a module once a transaction changing
+// it is committed
+func (n *Nbi) finishIntent(module string, reqID int, committed bool) {
+	if n.intent == nil {
+		return
+	}
+	key := auditKey(module, reqID)
+
+	n.intent.mu.Lock()
+	defer n.intent.mu.Unlock()
+
+	changes, ok := n.intent.tx[key]
+	delete(n.intent.tx, key)
+	if !ok || !committed {
+		return
+	}
+
+	tree := n.intent.trees[module]
+	if tree == nil {
+		tree = NewTree()
+		n.intent.trees[module] = tree
+	}
+	for _, c := range changes {
+		switch {
+		case c.Oper == OpDeleted:
+			tree.Delete(c.Xpath)
+		case c.Leaf:
+			tree.Set(c.Xpath, c.NewValue)
+		default:
+			tree.Create(c.Xpath)
+		}
+	}
+	if err := n.intent.save(module, tree); err != nil {
+		log.Error("NBI: persisting the intent of module '%s' failed: %v", module, err)
+	}
+}
+
+// replayIntent restores the persisted intent into every intent module whose
+// running configuration is empty, as after a redeployment. That commit
+// makes no SBI calls; in the appmgr mode the xApps missing in the
+// appmgr are deployed afterwards in dependency order and their
+// configurations applied.
+func (n *Nbi) replayIntent() *RestoreReport {
+	store, ok := n.ds.(Store)
+	if n.intent == nil || !ok {
+		return nil
+	}
+	report := &RestoreReport{Time: time.Now(), Mode: n.intent.replay}
+
+	for _, module := range intentModules {
+		xpath := "/" + module + ":ric"
+		if n.intent.replay != ReplayOff && len(store.GetConfig(xpath).Leaves()) == 0 {
+			n.restoreModule(store, module, report)
+		}
+
+		tree := store.GetConfig(xpath)
+		n.intent.mu.Lock()
+		n.intent.trees[module] = tree
+		if err := n.intent.save(module, tree); err != nil {
+			log.Error("NBI: persisting the intent of module '%s' failed: %v", module, err)
+		}
+		n.intent.mu.Unlock()
+	}
+
+	if n.intent.replay == ReplayAppmgr {
+		n.replayXapps(store, report)
+		n.replayXappConfig(store, report)
+	}
+
+	n.intent.mu.Lock()
+	defer n.intent.mu.Unlock()
+	n.intent.report = report
+	return report
+}
+
+// restoreModule commits the persisted configuration of module
+func (n *Nbi) restoreModule(store Store, module string, report *RestoreReport) {
+	saved, err := n.intent.load(module)
+	if err != nil {
+		report.add("datastore", module, "failed: %v", err)
+		return
+	}
+	if saved == nil {
+		return
+	}
+
+	defer lockEdits(store)()
+	store.DiscardChanges()
+	leaves := 0
+	saved.Walk(func(xpath string, node *Node) {
+		if node.Leaf && err == nil {
+			err = store.SetItem(xpath, node.Value)
+			leaves++
+		}
+	})
+	if err != nil {
+		store.DiscardChanges()
+		report.add("datastore", module, "failed: %v", err)
+		return
+	}
+	if leaves == 0 {
+		return
+	}
+
+	if err := store.ApplyChangesAs(Originator{Name: startupOriginator}); err != nil {
+		report.add("datastore", module, "failed: %v", err)
+		return
+	}
+	report.add("datastore", module, "restored %d leaves", leaves)
+}
+
+// replayXapps deploys the configured xApps the appmgr does not have, an xApp
+// after the ones it depends on
+func (n *Nbi) replayXapps(store Store, report *RestoreReport) {
+	deployed, err := sbiClient.ListDeployedXapps()
+	if err != nil {
+		report.add("appmgr", "xapps", "failed: %v", err)
+		return
+	}
+	present := make(map[string]bool)
+	for _, x := range deployed {
+		if x != nil && x.Name != nil {
+			present[*x.Name] = true
+		}
+	}
+
+	xpath := fmt.Sprintf("/%s:ric/xapps", xappDescModule)
+	xapps := store.GetConfig(xpath).Find(xpath)
+	if xapps == nil {
+		return
+	}
+	ordered, err := dependencyOrder(xapps.Children)
+	if err != nil {
+		report.add("appmgr", "xapps", "%v, deploying in name order", err)
+	}
+
+	failed := make(map[string]bool)
+	for _, x := range ordered {
+		name := leafValue(x, "name")
+		release := leafValue(x, "release-name")
+		if release == "" {
+			release = name
+		}
+
+		var missing []string
+		for _, dep := range strings.Fields(leafValue(x, "depends-on")) {
+			if failed[dep] {
+				missing = append(missing, dep)
+			}
+		}
+		switch {
+		case len(missing) > 0:
+			failed[name] = true
+			report.add("xapp", name, "skipped: dependencies %v failed", missing)
+		case present[release]:
+			report.add("xapp", name, "present")
+		default:
+			namespace := leafValue(x, "namespace")
+			if namespace == "" {
+				namespace = n.namespaces.defaultNamespace()
+			}
+			desc := sbiClient.BuildXappDescriptor(name, namespace, leafValue(x, "release-name"), leafValue(x, "version"))
+			if err := sbiClient.DeployXapp(desc); err != nil {
+				failed[name] = true
+				report.add("xapp", name, "failed: %v", err)
+				continue
+			}
+			report.add("xapp", name, "deployed")
+		}
+	}
+}
+
+// replayXappConfig applies the configured control values of the xApp config
+// module on top of the configuration the appmgr has
+func (n *Nbi) replayXappConfig(store Store, report *RestoreReport) {
+	module := "o-ran-sc-ric-ueec-config-v1"
+	tree := store.GetConfig("/" + module + ":ric")
+	session := &memSession{changes: Diff(NewTree(), tree), data: tree, originator: Originator{Name: startupOriginator}}
+
+	cc, err := n.xappConfigPatch(session, module)
+	switch {
+	case err != nil:
+		report.add("config", module, "failed: %v", err)
+	case cc == nil:
+	case reflect.DeepEqual(cc.current, cc.config):
+		report.add("config", cc.name, "present")
+	default:
+		if err := sbiClient.ModifyXappConfig(sbiClient.BuildXappConfig(cc.name, cc.namespace, cc.config)); err != nil {
+			report.add("config", cc.name, "failed: %v", err)
+			return
+		}
+		n.recordConfig(cc.name, cc.namespace, session.originator, "replayed at startup", cc.current, cc.config)
+		report.add("config", cc.name, "applied")
+	}
+}
+
+// dependencyOrder sorts xApp list entries so that every xApp comes after
+// the ones named in its depends-on leaf. Unknown dependencies are ignored; a
+// cycle is reported and its xApps are sorted by name.
+func dependencyOrder(xapps []*Node) ([]*Node, error) {
+	byName := make(map[string]*Node)
+	var names []string
+	for _, x := range xapps {
+		name := leafValue(x, "name")
+		byName[name] = x
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var ordered []*Node
+	var cycle error
+
+	var visit func(name string)
+	visit = func(name string) {
+		switch state[name] {
+		case done:
+			return
+		case visiting:
+			if cycle == nil {
+				cycle = fmt.Errorf("dependency cycle through xApp '%s'", name)
+			}
+			return
+		}
+		state[name] = visiting
+		for _, dep := range strings.Fields(leafValue(byName[name], "depends-on")) {
+			if _, ok := byName[dep]; ok {
+				visit(dep)
+			}
+		}
+		state[name] = done
+		ordered = append(ordered, byName[name])
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered, cycle
+}
+
+func (s *IntentStore) GetOperData(xpath string, tree OperDataTree) error {
+	report := s.Report()
+	if report == nil {
+		return nil
+	}
+
+	tree.CreateNewElement(restoreXpath, "mode", report.Mode)
+	tree.CreateNewElement(restoreXpath, "time", report.Time.UTC().Format(time.RFC3339))
+	for _, item := range report.Items {
+		path := fmt.Sprintf("%s/item[kind='%s'][name='%s']", restoreXpath, item.Kind, item.Name)
+		tree.CreateNewElement(path, "kind", item.Kind)
+		tree.CreateNewElement(path, "name", item.Name)
+		tree.CreateNewElement(path, "action", item.Action)
+	}
+	return nil
+}
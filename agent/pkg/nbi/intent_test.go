@@ -0,0 +1,122 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newIntentNbi returns an NBI on its own datastore keeping its intent in kv
+func newIntentNbi(t *testing.T, kv kvStore, mode string) (*Nbi, Store) {
+	oldNbi, oldClient := nbiClient, sbiClient
+	t.Cleanup(func() { nbiClient, sbiClient = oldNbi, oldClient })
+
+	p := NewNbiWithDatastore(newSBIClient(), NewMemDatastore())
+	p.intent = NewIntentStore(kv, mode)
+	p.RegisterProvider(xappDescModule, restoreXpath, p.intent)
+	for _, module := range intentModules {
+		assert.True(t, p.SubscribeModule(module))
+	}
+	return p, p.Datastore().(Store)
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tree := NewTree()
+	xpath := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps"
+	tree.Set(xpath+"/xapp[name='a']/depends-on", "c b")
+	tree.Set(xpath+"/xapp[name='b']/depends-on", "c unknown")
+	tree.Set(xpath+"/xapp[name='c']/name", "c")
+	tree.Set(xpath+"/xapp[name='d']/name", "d")
+
+	ordered, err := dependencyOrder(tree.Find(xpath).Children)
+	assert.Nil(t, err)
+	var names []string
+	for _, x := range ordered {
+		names = append(names, leafValue(x, "name"))
+	}
+	assert.Equal(t, []string{"c", "b", "a", "d"}, names)
+
+	tree.Set(xpath+"/xapp[name='c']/depends-on", "a")
+	ordered, err = dependencyOrder(tree.Find(xpath).Children)
+	assert.NotNil(t, err)
+	assert.Equal(t, 4, len(ordered))
+}
+
+func TestIntentReplay(t *testing.T) {
+	kv := newMemKV()
+	xpath := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp"
+
+	// the intent is persisted as it is committed
+	p, store := newIntentNbi(t, kv, ReplayAppmgr)
+	p.replayIntent()
+	store.SetItem(xpath+"[name='a']/depends-on", "c")
+	store.SetItem(xpath+"[name='b']/release-name", "b-xapp")
+	store.SetItem(xpath+"[name='c']/version", "1.0.0")
+	assert.Nil(t, store.ApplyChangesAs(Originator{Name: reconcilerOriginator}))
+	store.DeleteItem(xpath + "[name='d']")
+	store.SetItem(xpath+"[name='d']", "")
+	store.DiscardChanges()
+
+	// an agent starting on an empty datastore gets it back
+	a := newAppmgr(t, map[string]string{"b-xapp": "1.0.0"})
+	p, store = newIntentNbi(t, kv, ReplayAppmgr)
+	report := p.replayIntent()
+	assert.Equal(t, "c", leafValue(store.GetConfig(xpath+"[name='a']").Find(xpath+"[name='a']"), "depends-on"))
+	assert.Equal(t, []RestoreItem{
+		{"datastore", xappDescModule, "restored 6 leaves"},
+		{"xapp", "c", "deployed"},
+		{"xapp", "a", "deployed"},
+		{"xapp", "b", "present"},
+	}, report.Items)
+	assert.Equal(t, []string{"POST /ric/v1/xapps", "POST /ric/v1/xapps"}, a.changes())
+
+	tree := NewTree()
+	assert.Nil(t, p.GetOperData(xappDescModule, restoreXpath, &nodeOperDataTree{tree}))
+	assert.Equal(t, "deployed", tree.Find(restoreXpath+"/item[kind='xapp'][name='a']/action").Value)
+
+	// a datastore with a configuration keeps it
+	q, store := newIntentNbi(t, kv, ReplayDatastore)
+	store.SetItem(xpath+"[name='e']", "")
+	store.ApplyChanges()
+	report = q.replayIntent()
+	assert.Empty(t, report.Items)
+}
+
+func TestIntentConfigReplay(t *testing.T) {
+	mockCommands(t, "")
+	cs := newConfigServer(t, map[string]interface{}{"active": false, "timeout": float64(5)})
+	defer cs.Close()
+
+	p, store := newIntentNbi(t, newMemKV(), ReplayAppmgr)
+	store.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/name", "ueec")
+	store.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/control/active", "true")
+	assert.Nil(t, store.ApplyChangesAs(Originator{Name: reconcilerOriginator}))
+
+	report := &RestoreReport{}
+	p.replayXappConfig(store, report)
+	assert.Equal(t, []RestoreItem{{"config", "ueec", "applied"}}, report.Items)
+	assert.Equal(t, []interface{}{map[string]interface{}{"active": true, "timeout": float64(5)}}, cs.puts)
+
+	report = &RestoreReport{}
+	p.replayXappConfig(store, report)
+	assert.Equal(t, []RestoreItem{{"config", "ueec", "present"}}, report.Items)
+}
@@ -0,0 +1,340 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
+	"github.com/spf13/viper"
+)
+
+// Job operations and states, see the jobs container of o-ran-sc-ric-xapp-desc-v1
+const (
+	JobDeploy   = "deploy"
+	JobUndeploy = "undeploy"
+
+	JobPending      = "pending"
+	JobInstalling   = "installing"
+	JobRunning      = "running"
+	JobUninstalling = "uninstalling"
+	JobRemoved      = "removed"
+	JobFailed       = "failed"
+)
+
+const (
+	jobsXpath        = "/o-ran-sc-ric-xapp-desc-v1:ric/jobs"
+	jobCompletedPath = "/o-ran-sc-ric-xapp-desc-v1:xapp-job-completed"
+)
+
+// Job is a deployment or undeployment of one xApp
+type Job struct {
+	ID        string
+	Xapp      string
+	Operation string
+	State     string
+	Message   string
+	Created   time.Time
+	Updated   time.Time
+	desc      *apimodel.XappDescriptor
+}
+
+// JobManager runs deployments in the background, one at a time and in
+// submission order, so configuration commits don't wait for helm. The jobs
+// of a transaction are staged by its request ID and only queued once it is
+// done.
+type JobManager struct {
+	n            *Nbi
+	mu           sync.Mutex
+	jobs         map[string]*Job
+	staged       map[int][]*Job
+	lastID       int
+	queue        []*Job
+	wake         chan struct{}
+	readyTimeout time.Duration
+	pollInterval time.Duration
+	history      int
+}
+
+// newJobManager returns nil, i.e. synchronous deployments, unless
+// jobs.async is set
+func newJobManager(n *Nbi) *JobManager {
+	if !viper.GetBool("jobs.async") {
+		return nil
+	}
+
+	readyTimeout := 300 * time.Second
+	if viper.IsSet("jobs.readyTimeout") {
+		readyTimeout = time.Duration(viper.GetInt("jobs.readyTimeout")) * time.Second
+	}
+	history := 100
+	if viper.IsSet("jobs.history") {
+		history = viper.GetInt("jobs.history")
+	}
+	return NewJobManager(n, readyTimeout, 5*time.Second, history)
+}
+
+// NewJobManager creates a job manager. A deployment is running once the
+// xApp is healthy, checked every pollInterval for up to readyTimeout; with
+// a zero readyTimeout it is running as soon as the appmgr accepts it. At
+// most history finished jobs are kept.
+func NewJobManager(n *Nbi, readyTimeout, pollInterval time.Duration, history int) *JobManager {
+	m := &JobManager{
+		n:            n,
+		jobs:         make(map[string]*Job),
+		staged:       make(map[int][]*Job),
+		wake:         make(chan struct{}, 1),
+		readyTimeout: readyTimeout,
+		pollInterval: pollInterval,
+		history:      history,
+	}
+	go m.run()
+	return m
+}
+
+// Jobs returns the job manager of the NBI, nil if deployments are synchronous
+func (n *Nbi) Jobs() *JobManager {
+	return n.jobs
+}
+
+// finishJobs queues the jobs of a transaction once it is committed, or drops
+// them
+func (n *Nbi) finishJobs(reqID int, committed bool) {
+	if n.jobs != nil {
+		n.jobs.finish(reqID, committed)
+	}
+}
+
+// Submit queues a deployment job and returns its ID. It never blocks.
+func (m *JobManager) Submit(operation string, desc *apimodel.XappDescriptor) string {
+	m.mu.Lock()
+	job := m.newJob(operation, desc)
+	m.mu.Unlock()
+
+	m.enqueue([]*Job{job})
+	return job.ID
+}
+
+// stage creates a deployment job of the transaction reqID and returns its
+// ID. The job is queued by finish.
+func (m *JobManager) stage(reqID int, operation string, desc *apimodel.XappDescriptor) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.newJob(operation, desc)
+	m.staged[reqID] = append(m.staged[reqID], job)
+	return job.ID
+}
+
+// finish queues the jobs staged by the transaction reqID once it is
+// committed, or drops them
+func (m *JobManager) finish(reqID int, committed bool) {
+	m.mu.Lock()
+	jobs := m.staged[reqID]
+	delete(m.staged, reqID)
+	m.mu.Unlock()
+
+	if !committed {
+		for _, job := range jobs {
+			log.Info("NBI: job %s dropped: %s %s", job.ID, job.Operation, job.Xapp)
+		}
+		return
+	}
+	m.enqueue(jobs)
+}
+
+func (m *JobManager) newJob(operation string, desc *apimodel.XappDescriptor) *Job {
+	m.lastID++
+	now := time.Now()
+	return &Job{
+		ID:        strconv.Itoa(m.lastID),
+		Xapp:      *desc.XappName,
+		Operation: operation,
+		State:     JobPending,
+		Created:   now,
+		Updated:   now,
+		desc:      desc,
+	}
+}
+
+// enqueue adds jobs to the queue and wakes up run, without waiting for it
+func (m *JobManager) enqueue(jobs []*Job) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	for _, job := range jobs {
+		m.jobs[job.ID] = job
+		m.queue = append(m.queue, job)
+		log.Info("NBI: job %s queued: %s %s", job.ID, job.Operation, job.Xapp)
+	}
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Job returns a copy of the job with the given ID
+func (m *JobManager) Job(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+func (m *JobManager) run() {
+	for range m.wake {
+		for job := m.next(); job != nil; job = m.next() {
+			m.execute(job)
+		}
+	}
+}
+
+func (m *JobManager) next() *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.queue) == 0 {
+		return nil
+	}
+	job := m.queue[0]
+	m.queue = m.queue[1:]
+	return job
+}
+
+func (m *JobManager) execute(job *Job) {
+	var err error
+	switch job.Operation {
+	case JobDeploy:
+		m.update(job, JobInstalling, "deploying through appmgr")
+		if err = sbiClient.DeployXapp(job.desc); err == nil {
+			err = m.waitReady(job)
+		}
+		if err == nil {
+			m.update(job, JobRunning, "xApp is running")
+		}
+	case JobUndeploy:
+		m.update(job, JobUninstalling, "undeploying through appmgr")
+		if err = sbiClient.UndeployXapp(job.desc); err == nil {
+			m.update(job, JobRemoved, "xApp is removed")
+		}
+	default:
+		err = fmt.Errorf("operation '%s' not supported", job.Operation)
+	}
+	if err != nil {
+		m.update(job, JobFailed, err.Error())
+	}
+
+	done, _ := m.Job(job.ID)
+	log.Info("NBI: job %s %s: %s", done.ID, done.State, done.Message)
+	leaves := map[string]string{
+		"id":        done.ID,
+		"xapp":      done.Xapp,
+		"operation": done.Operation,
+		"state":     done.State,
+		"message":   done.Message,
+	}
+	if err := m.n.ds.Notify(jobCompletedPath, leaves); err != nil {
+		log.Error("NBI: job notification failed: %v", err)
+	}
+	m.prune()
+}
+
+// waitReady polls the xApp health until its pods are up
+func (m *JobManager) waitReady(job *Job) error {
+	if m.readyTimeout <= 0 {
+		return nil
+	}
+
+	namespace := job.desc.Namespace
+	if namespace == "" {
+		namespace = m.n.namespaces.defaultNamespace()
+	}
+	deadline := time.Now().Add(m.readyTimeout)
+	for {
+		pod, err := sbiClient.CheckXappHealth(job.Xapp, namespace)
+		if err == nil && pod.Health == "healthy" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("xApp not ready after %v", m.readyTimeout)
+		}
+		if err == nil {
+			m.update(job, JobInstalling, fmt.Sprintf("waiting for pods: %s", pod.Status))
+		}
+		time.Sleep(m.pollInterval)
+	}
+}
+
+func (m *JobManager) update(job *Job, state, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.State, job.Message, job.Updated = state, message, time.Now()
+}
+
+// prune forgets the oldest finished jobs beyond the history size
+func (m *JobManager) prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var finished []*Job
+	for _, job := range m.jobs {
+		switch job.State {
+		case JobRunning, JobRemoved, JobFailed:
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= m.history {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Updated.Before(finished[j].Updated) })
+	for _, job := range finished[:len(finished)-m.history] {
+		delete(m.jobs, job.ID)
+	}
+}
+
+func (m *JobManager) GetOperData(xpath string, tree OperDataTree) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, job := range m.jobs {
+		path := fmt.Sprintf("%s/job[id='%s']", jobsXpath, job.ID)
+		tree.CreateNewElement(path, "id", job.ID)
+		tree.CreateNewElement(path, "xapp", job.Xapp)
+		tree.CreateNewElement(path, "operation", job.Operation)
+		tree.CreateNewElement(path, "state", job.State)
+		tree.CreateNewElement(path, "message", job.Message)
+		tree.CreateNewElement(path, "created", job.Created.UTC().Format(time.RFC3339))
+		tree.CreateNewElement(path, "updated", job.Updated.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
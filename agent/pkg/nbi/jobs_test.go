@@ -0,0 +1,162 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
+	"github.com/stretchr/testify/assert"
+)
+
+// newJobsNbi returns an NBI deploying in the background and a channel
+// receiving its job notifications
+func newJobsNbi(t *testing.T, readyTimeout time.Duration, history int) (*Nbi, chan Notification) {
+	oldNbi, oldClient := nbiClient, sbiClient
+	t.Cleanup(func() { nbiClient, sbiClient = oldNbi, oldClient })
+
+	m := NewMemDatastore()
+	p := NewNbiWithDatastore(newSBIClient(), m)
+	p.jobs = NewJobManager(p, readyTimeout, 10*time.Millisecond, history)
+	p.RegisterProvider(xappDescModule, jobsXpath, p.jobs)
+
+	notifs := make(chan Notification, 10)
+	m.SubscribeNotifications(func(n Notification) { notifs <- n })
+	return p, notifs
+}
+
+func waitJob(t *testing.T, notifs chan Notification) map[string]string {
+	select {
+	case n := <-notifs:
+		assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:xapp-job-completed", n.Xpath)
+		return n.Leaves
+	case <-time.After(2 * time.Second):
+		t.Error("no job notification")
+		return nil
+	}
+}
+
+func TestDeployJob(t *testing.T) {
+	a := newAppmgr(t, nil)
+	p, notifs := newJobsNbi(t, 0, 10)
+
+	assert.Nil(t, p.ManageXapps(xappDescModule, XappDescriptor, OpCreated))
+	leaves := waitJob(t, notifs)
+	assert.Equal(t, map[string]string{"id": "1", "xapp": "ueec", "operation": "deploy", "state": "running", "message": "xApp is running"}, leaves)
+	assert.Equal(t, []string{"POST /ric/v1/xapps"}, a.changes())
+
+	assert.Nil(t, p.ManageXapps(xappDescModule, XappDescriptor, OpDeleted))
+	leaves = waitJob(t, notifs)
+	assert.Equal(t, "2", leaves["id"])
+	assert.Equal(t, "removed", leaves["state"])
+	assert.Equal(t, []string{"POST /ric/v1/xapps", "DELETE /ric/v1/xapps/ueec-xapp"}, a.changes())
+
+	tree := mapTree{}
+	assert.Nil(t, p.GetOperData(xappDescModule, jobsXpath, tree))
+	assert.Equal(t, "running", tree[jobsXpath+"/job[id='1']/state"])
+	assert.Equal(t, "undeploy", tree[jobsXpath+"/job[id='2']/operation"])
+	assert.NotEmpty(t, tree[jobsXpath+"/job[id='2']/updated"])
+}
+
+func TestJobsOfTransaction(t *testing.T) {
+	a := newAppmgr(t, nil)
+	p, notifs := newJobsNbi(t, 0, 10)
+	m := p.Datastore().(*MemDatastore)
+	m.SubscribeModuleChange(xappDescModule, p.ModuleChangeCB)
+	m.SubscribeModuleChange("o-ran-sc-ric-ueec-config-v1", func(session ChangeSession, module, xpath string, event Event, reqID int) error {
+		if event == EventChange {
+			return errors.New("config rejected")
+		}
+		return nil
+	})
+
+	// the jobs of an aborted transaction never run
+	xapp := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']"
+	m.SetItem(xapp+"/release-name", "ueec-xapp")
+	m.SetItem("/o-ran-sc-ric-ueec-config-v1:ric/config/name", "ueec")
+	assert.NotNil(t, m.ApplyChanges())
+	tree := mapTree{}
+	assert.Nil(t, p.GetOperData(xappDescModule, jobsXpath, tree))
+	assert.Empty(t, tree)
+
+	// the ones of a committed transaction are queued once it is done
+	m.SetItem(xapp+"/release-name", "ueec-xapp")
+	assert.Nil(t, m.ApplyChanges())
+	leaves := waitJob(t, notifs)
+	assert.Equal(t, "deploy", leaves["operation"])
+	assert.Equal(t, "running", leaves["state"])
+	assert.Equal(t, []string{"POST /ric/v1/xapps"}, a.changes())
+}
+
+func TestDeployJobWaitsForPods(t *testing.T) {
+	newAppmgr(t, nil)
+	p, notifs := newJobsNbi(t, time.Second, 10)
+
+	polls := 0
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (string, error) {
+		if polls++; polls < 3 {
+			return "ricxapp-ueec-7bfdd587db-2jl9j   0/1   ContainerCreating   0   1s\n", nil
+		}
+		return "ricxapp-ueec-7bfdd587db-2jl9j   1/1   Running   0   1s\n", nil
+	}
+
+	id := p.Jobs().Submit(JobDeploy, sbiClient.BuildXappDescriptor("ueec", "ricxapp", "ueec-xapp", "0.0.1"))
+	assert.Equal(t, "running", waitJob(t, notifs)["state"])
+	assert.Equal(t, 3, polls)
+
+	job, ok := p.Jobs().Job(id)
+	assert.True(t, ok)
+	assert.Equal(t, JobRunning, job.State)
+}
+
+func TestDeployJobFailures(t *testing.T) {
+	p, notifs := newJobsNbi(t, 30*time.Millisecond, 10)
+
+	// appmgr unreachable
+	p.Jobs().Submit(JobDeploy, sbiClient.BuildXappDescriptor("ueec", "ricxapp", "ueec-xapp", "0.0.1"))
+	assert.Equal(t, "failed", waitJob(t, notifs)["state"])
+
+	// pods never get ready
+	newAppmgr(t, nil)
+	mockCommands(t, "ricxapp-ueec-7bfdd587db-2jl9j   0/1   CrashLoopBackOff   3   1m\n")
+	p.Jobs().Submit(JobDeploy, sbiClient.BuildXappDescriptor("ueec", "ricxapp", "ueec-xapp", "0.0.1"))
+	leaves := waitJob(t, notifs)
+	assert.Equal(t, "failed", leaves["state"])
+	assert.Contains(t, leaves["message"], "not ready")
+}
+
+func TestJobHistory(t *testing.T) {
+	newAppmgr(t, nil)
+	p, notifs := newJobsNbi(t, 0, 1)
+
+	first := p.Jobs().Submit(JobUndeploy, sbiClient.BuildXappDescriptor("ueec", "ricxapp", "", ""))
+	waitJob(t, notifs)
+	second := p.Jobs().Submit(JobUndeploy, sbiClient.BuildXappDescriptor("anr", "ricxapp", "", ""))
+	waitJob(t, notifs)
+
+	_, ok := p.Jobs().Job(first)
+	assert.False(t, ok)
+	_, ok = p.Jobs().Job(second)
+	assert.True(t, ok)
+}
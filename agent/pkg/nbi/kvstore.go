@@ -0,0 +1,165 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"github.com/spf13/viper"
+)
+
+// kvStore is where the agent keeps state across restarts. xapp.SDLClient
+// is one.
+type kvStore interface {
+	Store(key string, value interface{}) error
+	Read(key string) (map[string]interface{}, error)
+	ReadAllKeys(key string) ([]string, error)
+	Delete(keys []string) error
+}
+
+// newKVStore opens the store selected by <section>.store: SDL for "sdl",
+// the directory <section>.dir for "file", or memory
+func newKVStore(section string) kvStore {
+	switch store := viper.GetString(section + ".store"); store {
+	case "sdl":
+		namespace := viper.GetString(section + ".sdlNamespace")
+		if namespace == "" {
+			namespace = "sdl"
+		}
+		return xapp.NewSDLClient(namespace)
+	case "file":
+		dir := viper.GetString(section + ".dir")
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			log.Error("NBI: %s directory '%s' unusable, keeping it in memory: %v", section, dir, err)
+			return newMemKV()
+		}
+		return &fileKV{dir: dir}
+	case "", "memory":
+	default:
+		log.Error("NBI: unknown %s store '%s', keeping it in memory", section, store)
+	}
+	return newMemKV()
+}
+
+// memKV keeps the values in memory, they are lost on restart
+type memKV struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+}
+
+func newMemKV() *memKV {
+	return &memKV{values: make(map[string]interface{})}
+}
+
+func (m *memKV) Store(key string, value interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.values[key] = value
+	return nil
+}
+
+func (m *memKV) Read(key string) (map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]interface{})
+	if value, ok := m.values[key]; ok {
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (m *memKV) ReadAllKeys(key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (m *memKV) Delete(keys []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.values, key)
+	}
+	return nil
+}
+
+// fileKV keeps every value in a file of dir named after its escaped key
+type fileKV struct {
+	dir string
+}
+
+func (f *fileKV) path(key string) string {
+	return filepath.Join(f.dir, url.PathEscape(key))
+}
+
+func (f *fileKV) Store(key string, value interface{}) error {
+	return ioutil.WriteFile(f.path(key), []byte(fmt.Sprint(value)), 0600)
+}
+
+func (f *fileKV) Read(key string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	data, err := ioutil.ReadFile(f.path(key))
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result[key] = string(data)
+	return result, nil
+}
+
+func (f *fileKV) ReadAllKeys(key string) ([]string, error) {
+	files, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, file := range files {
+		if k, err := url.PathUnescape(file.Name()); err == nil && strings.HasPrefix(k, key) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fileKV) Delete(keys []string) error {
+	for _, key := range keys {
+		if err := os.Remove(f.path(key)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
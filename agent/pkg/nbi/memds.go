@@ -0,0 +1,384 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification is a YANG notification sent through a MemDatastore
+type Notification struct {
+	Xpath  string
+	Leaves map[string]string
+	Time   time.Time
+}
+
+type memChangeSub struct {
+	module  string
+	handler ModuleChangeHandler
+}
+
+type memOperSub struct {
+	module  string
+	xpath   string
+	handler OperDataHandler
+}
+
+// MemDatastore is an in-memory running datastore with sysrepo-like
+// transaction semantics: edits are staged with SetItem/DeleteItem and
+// ApplyChanges runs the CHANGE phase of every subscribed module, followed
+// by DONE on success or ABORT on failure.
+type MemDatastore struct {
+	mu         sync.Mutex
+	txMu       sync.Mutex
+	editMu     sync.Mutex
+	running    *Node
+	pending    *Node
+	reqID      int
+	changeSubs []memChangeSub
+	operSubs   []memOperSub
+	rpcSubs    map[string]RPCHandler
+	notifSubs  []func(Notification)
+}
+
+var _ Store = (*MemDatastore)(nil)
+
+func NewMemDatastore() *MemDatastore {
+	return &MemDatastore{running: NewTree(), rpcSubs: make(map[string]RPCHandler)}
+}
+
+func (m *MemDatastore) Connect() error {
+	return nil
+}
+
+func (m *MemDatastore) Disconnect() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.changeSubs = nil
+	m.operSubs = nil
+	m.rpcSubs = make(map[string]RPCHandler)
+	m.notifSubs = nil
+}
+
+func (m *MemDatastore) SubscribeModuleChange(module string, handler ModuleChangeHandler) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.changeSubs = append(m.changeSubs, memChangeSub{module, handler})
+	return nil
+}
+
+func (m *MemDatastore) SubscribeOperData(module, xpath string, handler OperDataHandler) error {
+	if _, err := ParsePath(xpath); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.operSubs = append(m.operSubs, memOperSub{module, xpath, handler})
+	return nil
+}
+
+func (m *MemDatastore) SubscribeRPC(xpath string, handler RPCHandler) error {
+	if _, err := ParsePath(xpath); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rpcSubs[xpath] = handler
+	return nil
+}
+
+// CallRPC runs the handler subscribed to the RPC at xpath
+func (m *MemDatastore) CallRPC(xpath string, input map[string]string) (map[string]string, error) {
+	return m.CallRPCAs(Originator{}, xpath, input)
+}
+
+// CallRPCAs runs the handler subscribed to the RPC at xpath on behalf of o
+func (m *MemDatastore) CallRPCAs(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	m.mu.Lock()
+	handler, ok := m.rpcSubs[xpath]
+	m.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no subscriber for RPC '%s'", xpath)
+	}
+	return handler(o, xpath, input)
+}
+
+// SubscribeNotifications registers a receiver for every notification sent
+func (m *MemDatastore) SubscribeNotifications(handler func(Notification)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.notifSubs = append(m.notifSubs, handler)
+}
+
+func (m *MemDatastore) Notify(xpath string, leaves map[string]string) error {
+	if _, err := ParsePath(xpath); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	subs := append([]func(Notification){}, m.notifSubs...)
+	m.mu.Unlock()
+
+	notif := Notification{Xpath: xpath, Leaves: leaves, Time: time.Now()}
+	for _, h := range subs {
+		h(notif)
+	}
+	return nil
+}
+
+// SetItem stages a leaf value, or creates a list entry or container when
+// value is empty and xpath does not address a leaf.
+func (m *MemDatastore) SetItem(xpath, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pending == nil {
+		m.pending = m.running.Clone()
+	}
+
+	node, err := m.pending.Create(xpath)
+	if err != nil {
+		return err
+	}
+	if value != "" || node.Leaf {
+		return m.pending.Set(xpath, value)
+	}
+	return nil
+}
+
+// DeleteItem stages the removal of xpath and everything below it
+func (m *MemDatastore) DeleteItem(xpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pending == nil {
+		m.pending = m.running.Clone()
+	}
+	if !m.pending.Delete(xpath) {
+		return fmt.Errorf("data node '%s' not found", xpath)
+	}
+	return nil
+}
+
+// HasItem tells if xpath exists, taking the staged edits into account
+func (m *MemDatastore) HasItem(xpath string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tree := m.running
+	if m.pending != nil {
+		tree = m.pending
+	}
+	return tree.Find(xpath) != nil
+}
+
+// DiscardChanges drops every staged edit
+func (m *MemDatastore) DiscardChanges() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending = nil
+}
+
+func (m *MemDatastore) EditLock() sync.Locker {
+	return &m.editMu
+}
+
+// ApplyChanges commits the staged edits. The first subscriber rejecting
+// the CHANGE event aborts the whole transaction.
+func (m *MemDatastore) ApplyChanges() error {
+	return m.ApplyChangesAs(Originator{})
+}
+
+// ApplyChangesAs commits the staged edits on behalf of o
+func (m *MemDatastore) ApplyChangesAs(o Originator) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = nil
+	if pending == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	changes := Diff(m.running, pending)
+	subs := append([]memChangeSub{}, m.changeSubs...)
+	m.reqID++
+	reqID := m.reqID
+	m.mu.Unlock()
+
+	type notified struct {
+		sub     memChangeSub
+		session *memSession
+	}
+	var called []notified
+
+	for _, sub := range subs {
+		modChanges := changesOfModule(changes, sub.module)
+		if len(modChanges) == 0 {
+			continue
+		}
+
+		session := &memSession{changes: modChanges, data: pending, originator: o}
+		if err := sub.handler(session, sub.module, "", EventChange, reqID); err != nil {
+			for _, c := range called {
+				c.sub.handler(c.session, c.sub.module, "", EventAbort, reqID)
+			}
+			return err
+		}
+		called = append(called, notified{sub, session})
+	}
+
+	m.mu.Lock()
+	m.running = pending
+	m.mu.Unlock()
+
+	for _, c := range called {
+		c.sub.handler(c.session, c.sub.module, "", EventDone, reqID)
+	}
+	return nil
+}
+
+// GetItems returns the configuration below xpath merged with the operational
+// data of every provider subscribed to an overlapping subtree.
+func (m *MemDatastore) GetItems(xpath string) (*Node, error) {
+	segs, err := ParsePath(xpath)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	result := m.running.Subtree(xpath)
+	subs := append([]memOperSub{}, m.operSubs...)
+	m.mu.Unlock()
+
+	if result == nil {
+		result = NewTree()
+	}
+
+	oper := NewTree()
+	for _, sub := range subs {
+		subSegs, _ := ParsePath(sub.xpath)
+		if !segmentsOverlap(segs, subSegs) {
+			continue
+		}
+		if err := sub.handler(sub.module, sub.xpath, &nodeOperDataTree{oper}); err != nil {
+			return nil, err
+		}
+	}
+
+	if oper = oper.Subtree(xpath); oper != nil {
+		result.Merge(oper)
+	}
+	return result, nil
+}
+
+// GetConfig returns a copy of the running configuration below xpath
+func (m *MemDatastore) GetConfig(xpath string) *Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if xpath == "" || xpath == "/" {
+		return m.running.Clone()
+	}
+	if tree := m.running.Subtree(xpath); tree != nil {
+		return tree
+	}
+	return NewTree()
+}
+
+func changesOfModule(changes []Change, module string) []Change {
+	prefix := "/" + module + ":"
+	var result []Change
+	for _, c := range changes {
+		if strings.HasPrefix(c.Xpath, prefix) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func segmentsOverlap(a, b []PathSegment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+		if a[i].Module != "" && b[i].Module != "" && a[i].Module != b[i].Module {
+			return false
+		}
+	}
+	return true
+}
+
+// memSession is the transaction view handed to MemDatastore subscribers
+type memSession struct {
+	changes    []Change
+	data       *Node
+	originator Originator
+}
+
+func (s *memSession) GetChanges(xpath string) ([]Change, error) {
+	if xpath == "" || xpath == "//." {
+		return s.changes, nil
+	}
+
+	var result []Change
+	for _, c := range s.changes {
+		if c.Xpath == xpath || strings.HasPrefix(c.Xpath, xpath+"/") || strings.HasPrefix(c.Xpath, xpath+"[") {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (s *memSession) GetData(xpath string) (string, error) {
+	tree := s.data.Subtree(xpath)
+	if tree == nil {
+		return "", nil
+	}
+	return tree.JSON(), nil
+}
+
+func (s *memSession) Originator() Originator {
+	return s.originator
+}
+
+// nodeOperDataTree collects provider leaves into a Node tree
+type nodeOperDataTree struct {
+	root *Node
+}
+
+func (t *nodeOperDataTree) CreateNewElement(key, name, value string) {
+	if err := t.root.Set(fmt.Sprintf("%s/%s", key, name), value); err != nil {
+		log.Error("CreateNewElement failed: %v", err)
+	}
+}
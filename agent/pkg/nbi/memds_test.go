@@ -0,0 +1,123 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemDatastoreTransaction(t *testing.T) {
+	m := NewMemDatastore()
+	var events []Event
+	var seen []Change
+	m.SubscribeModuleChange("m", func(s ChangeSession, module, xpath string, event Event, reqID int) error {
+		events = append(events, event)
+		if event == EventChange {
+			seen, _ = s.GetChanges("//.")
+			data, _ := s.GetData("/m:ric")
+			assert.Contains(t, data, `"version": "1"`)
+		}
+		return nil
+	})
+	m.SubscribeModuleChange("other", func(s ChangeSession, module, xpath string, event Event, reqID int) error {
+		t.Error("unrelated module notified")
+		return nil
+	})
+
+	assert.Nil(t, m.SetItem("/m:ric/xapps/xapp[name='a']/version", "1"))
+	assert.Nil(t, m.ApplyChanges())
+	assert.Equal(t, []Event{EventChange, EventDone}, events)
+	assert.Equal(t, 3, len(seen))
+	assert.Equal(t, "1", m.GetConfig("/m:ric").Find("/m:ric/xapps/xapp[name='a']/version").Value)
+
+	// Nothing staged, nothing to notify
+	assert.Nil(t, m.ApplyChanges())
+	assert.Equal(t, 2, len(events))
+}
+
+func TestMemDatastoreAbort(t *testing.T) {
+	m := NewMemDatastore()
+	var events []Event
+	m.SubscribeModuleChange("a", func(s ChangeSession, module, xpath string, event Event, reqID int) error {
+		events = append(events, event)
+		return nil
+	})
+	m.SubscribeModuleChange("b", func(s ChangeSession, module, xpath string, event Event, reqID int) error {
+		return errors.New("rejected")
+	})
+
+	m.SetItem("/a:ric/x", "1")
+	m.SetItem("/b:ric/y", "2")
+	assert.NotNil(t, m.ApplyChanges())
+	assert.Equal(t, []Event{EventChange, EventAbort}, events)
+	assert.Equal(t, 0, len(m.GetConfig("").Children))
+
+	assert.False(t, m.HasItem("/a:ric/x"))
+	m.SetItem("/a:ric/x", "1")
+	assert.True(t, m.HasItem("/a:ric/x"))
+	m.DiscardChanges()
+	assert.False(t, m.HasItem("/a:ric/x"))
+
+	assert.NotNil(t, m.DeleteItem("/a:ric/x"))
+	m.DiscardChanges()
+	assert.Nil(t, m.ApplyChanges())
+}
+
+func TestMemDatastoreGetItems(t *testing.T) {
+	m := NewMemDatastore()
+	m.SetItem("/m:ric/xapps/xapp[name='a']/version", "1")
+	m.ApplyChanges()
+
+	m.SubscribeOperData("m", "/m:ric/health", func(module, xpath string, tree OperDataTree) error {
+		tree.CreateNewElement("/m:ric/health/status[name='a']", "health", "healthy")
+		return nil
+	})
+	m.SubscribeOperData("m", "/m:ric/broken", func(module, xpath string, tree OperDataTree) error {
+		return errors.New("provider failed")
+	})
+
+	tree, err := m.GetItems("/m:ric/health")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/m:ric/health/status[name='a']/health=healthy", "/m:ric/health/status[name='a']/name=a"}, tree.Leaves())
+
+	tree, err = m.GetItems("/m:ric/xapps")
+	assert.Nil(t, err)
+	assert.Equal(t, "1", tree.Find("/m:ric/xapps/xapp[name='a']/version").Value)
+	assert.Nil(t, tree.Find("/m:ric/health"))
+
+	_, err = m.GetItems("/m:ric")
+	assert.NotNil(t, err)
+}
+
+func TestMemDatastoreNotify(t *testing.T) {
+	m := NewMemDatastore()
+	var got []Notification
+	m.SubscribeNotifications(func(n Notification) {
+		got = append(got, n)
+	})
+
+	assert.Nil(t, m.Notify("/m:xapp-event", map[string]string{"name": "a"}))
+	assert.NotNil(t, m.Notify("m:xapp-event", nil))
+	assert.Equal(t, 1, len(got))
+	assert.Equal(t, "a", got[0].Leaves["name"])
+}
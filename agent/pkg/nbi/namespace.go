@@ -0,0 +1,200 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/viper"
+	"github.com/valyala/fastjson"
+)
+
+// xappNamespace is the namespace xApps are deployed to unless configured or
+// told otherwise
+func xappNamespace() string {
+	if namespace := os.Getenv("XAPP_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return "ricxapp"
+}
+
+// NamespacePolicy tells where xApps may be deployed. Default applies to
+// descriptors and RPCs without a namespace. Without Allowed namespaces any
+// namespace is accepted.
+type NamespacePolicy struct {
+	Default string
+	Allowed []string
+}
+
+// namespacePolicyFromViper reads xapps.defaultNamespace and xapps.namespaces
+// of the configuration
+func namespacePolicyFromViper() NamespacePolicy {
+	p := NamespacePolicy{
+		Default: viper.GetString("xapps.defaultNamespace"),
+		Allowed: viper.GetStringSlice("xapps.namespaces"),
+	}
+	if p.Default == "" {
+		p.Default = xappNamespace()
+	}
+	return p
+}
+
+func (p NamespacePolicy) defaultNamespace() string {
+	if p.Default == "" {
+		return xappNamespace()
+	}
+	return p.Default
+}
+
+// Allows tells if xApps may be deployed to namespace. The empty namespace
+// stands for the default one.
+func (p NamespacePolicy) Allows(namespace string) bool {
+	if len(p.Allowed) == 0 || namespace == "" || namespace == p.defaultNamespace() {
+		return true
+	}
+	for _, ns := range p.Allowed {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Check rejects namespaces xApps may not be deployed to
+func (p NamespacePolicy) Check(namespace string) error {
+	if !p.Allows(namespace) {
+		return fmt.Errorf("namespace '%s' is not allowed, use one of %v", namespace, p.allowed())
+	}
+	return nil
+}
+
+// Resolve returns the namespace to use for namespace, the default if empty
+func (p NamespacePolicy) Resolve(namespace string) (string, error) {
+	if namespace == "" {
+		return p.defaultNamespace(), nil
+	}
+	if err := p.Check(namespace); err != nil {
+		return "", err
+	}
+	return namespace, nil
+}
+
+func (p NamespacePolicy) allowed() []string {
+	allowed := []string{p.defaultNamespace()}
+	for _, ns := range p.Allowed {
+		if ns != allowed[0] {
+			allowed = append(allowed, ns)
+		}
+	}
+	return allowed
+}
+
+// Namespaces returns the namespace policy of the xApps
+func (n *Nbi) Namespaces() NamespacePolicy {
+	return n.namespaces
+}
+
+// SetNamespaces replaces the namespace policy of the xApps
+func (n *Nbi) SetNamespaces(p NamespacePolicy) {
+	n.namespaces = p
+}
+
+// xappNamespaces returns the namespaces of the deployed xApps, sorted, and
+// always the default one
+func (n *Nbi) xappNamespaces() []string {
+	n.nsMu.Lock()
+	defer n.nsMu.Unlock()
+
+	seen := map[string]bool{n.namespaces.defaultNamespace(): true}
+	for _, ns := range n.deployedNs {
+		seen[ns] = true
+	}
+	var result []string
+	for ns := range seen {
+		result = append(result, ns)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// parseXappNamespaces maps every xApp of the xApp descriptors in data to its
+// namespace
+func (n *Nbi) parseXappNamespaces(data string) map[string]string {
+	result := make(map[string]string)
+	if data == "" {
+		return result
+	}
+	value, err := fastjson.Parse(data)
+	if err != nil {
+		return result
+	}
+	for _, x := range value.GetArray(xappDescModule+":ric", "xapps", "xapp") {
+		namespace := string(x.GetStringBytes("namespace"))
+		if namespace == "" {
+			namespace = n.namespaces.defaultNamespace()
+		}
+		result[string(x.GetStringBytes("name"))] = namespace
+	}
+	return result
+}
+
+// loadXappNamespaces reads the namespaces of the xApps in the running
+// configuration
+func (n *Nbi) loadXappNamespaces() {
+	store, ok := n.ds.(Store)
+	if !ok {
+		return
+	}
+	xpath := fmt.Sprintf("/%s:ric/xapps", xappDescModule)
+	namespaces := n.parseXappNamespaces(store.GetConfig(xpath).JSON())
+
+	n.nsMu.Lock()
+	defer n.nsMu.Unlock()
+	n.deployedNs = namespaces
+}
+
+// stageXappNamespaces reads the namespaces of the xApps a transaction
+// commits. They are used once the transaction is done.
+func (n *Nbi) stageXappNamespaces(session ChangeSession, reqID int) error {
+	data, err := session.GetData(fmt.Sprintf("/%s:ric/xapps", xappDescModule))
+	if err != nil {
+		return err
+	}
+	namespaces := n.parseXappNamespaces(data)
+
+	n.nsMu.Lock()
+	defer n.nsMu.Unlock()
+	n.deployedNsTx[reqID] = namespaces
+	return nil
+}
+
+// finishXappNamespaces takes the namespaces staged by a transaction once it
+// is committed, or drops them
+func (n *Nbi) finishXappNamespaces(reqID int, committed bool) {
+	n.nsMu.Lock()
+	defer n.nsMu.Unlock()
+
+	if namespaces, ok := n.deployedNsTx[reqID]; ok && committed {
+		n.deployedNs = namespaces
+	}
+	delete(n.deployedNsTx, reqID)
+}
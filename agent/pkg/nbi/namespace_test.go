@@ -0,0 +1,99 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
+	"github.com/stretchr/testify/assert"
+)
+
+// setNamespaces restricts the namespaces of the test NBI for one test
+func setNamespaces(t *testing.T, p NamespacePolicy) {
+	old := n.Namespaces()
+	n.SetNamespaces(p)
+	t.Cleanup(func() { n.SetNamespaces(old) })
+}
+
+func TestNamespacePolicy(t *testing.T) {
+	p := NamespacePolicy{Default: "ricxapp", Allowed: []string{"ricxapp", "ricxapp-test"}}
+	assert.True(t, p.Allows("ricxapp-test"))
+	assert.True(t, p.Allows(""))
+	assert.False(t, p.Allows("kube-system"))
+
+	ns, err := p.Resolve("")
+	assert.Nil(t, err)
+	assert.Equal(t, "ricxapp", ns)
+	_, err = p.Resolve("kube-system")
+	assert.Equal(t, "namespace 'kube-system' is not allowed, use one of [ricxapp ricxapp-test]", err.Error())
+
+	assert.True(t, NamespacePolicy{Default: "ricxapp"}.Allows("anything"))
+	ns, _ = NamespacePolicy{}.Resolve("")
+	assert.Equal(t, "ricxapp", ns)
+}
+
+func TestDeployToUnknownNamespaceRejected(t *testing.T) {
+	setNamespaces(t, NamespacePolicy{Default: "ricxapp", Allowed: []string{"ricxapp"}})
+
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='intruder']"
+	assert.Nil(t, ds.SetItem(path+"/release-name", "intruder-xapp"))
+	assert.Nil(t, ds.SetItem(path+"/namespace", "kube-system"))
+	err := ds.ApplyChanges()
+
+	var cerr *ChangeError
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, path+"/namespace", cerr.Xpath)
+		assert.Equal(t, "invalid-value", cerr.Tag)
+		assert.Equal(t, "namespace 'kube-system' is not allowed, use one of [ricxapp]", cerr.Message)
+	}
+	assert.Nil(t, ds.GetConfig("/o-ran-sc-ric-xapp-desc-v1:ric").Find(path))
+
+	_, err = callRPC("restart-xapp", map[string]string{"name": "ueec", "namespace": "kube-system"})
+	assert.NotNil(t, err)
+}
+
+func TestHealthCoversEveryNamespace(t *testing.T) {
+	setNamespaces(t, NamespacePolicy{Default: "ricxapp", Allowed: []string{"ricxapp", "ricxapp-test"}})
+
+	ts := CreateHTTPServer(t, "POST", "/ric/v1/xapps", 8080, http.StatusCreated, apimodel.Xapp{})
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='kpimon']"
+	assert.Nil(t, ds.SetItem(path+"/release-name", "kpimon"))
+	assert.Nil(t, ds.SetItem(path+"/namespace", "ricxapp-test"))
+	assert.Nil(t, ds.ApplyChanges())
+	ts.Close()
+	defer func() {
+		ts := CreateHTTPServer(t, "DELETE", "/ric/v1/xapps/kpimon", 8080, http.StatusNoContent, apimodel.Xapp{})
+		defer ts.Close()
+		assert.Nil(t, ds.DeleteItem(path))
+		assert.Nil(t, ds.ApplyChanges())
+	}()
+	assert.Equal(t, []string{"ricxapp", "ricxapp-test"}, n.xappNamespaces())
+
+	commands := mockCommands(t, "")
+	tree := mapTree{}
+	assert.Nil(t, n.GetOperData("o-ran-sc-ric-xapp-desc-v1", "/o-ran-sc-ric-xapp-desc-v1:ric/health", tree))
+	assert.Equal(t, []string{
+		"/usr/local/bin/kubectl get pod -n ricxapp",
+		"/usr/local/bin/kubectl get pod -n ricxapp-test",
+	}, *commands)
+}
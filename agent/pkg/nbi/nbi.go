@@ -25,41 +25,51 @@ import (
 	"fmt"
 	"github.com/spf13/viper"
 	"github.com/valyala/fastjson"
-	"os"
-	"strings"
 	"time"
-	"unsafe"
 
 	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
 	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
 )
 
-/*
-#cgo LDFLAGS: -lsysrepo -lyang
-
-#include <stdio.h>
-#include <limits.h>
-#include <sysrepo.h>
-#include <sysrepo/values.h>
-#include "helper.h"
-*/
-import "C"
-
 var sbiClient sbi.SBIClientInterface
 var nbiClient *Nbi
 var log = xapp.Logger
 var rnib iRnib = xapp.Rnib
 
 func NewNbi(s sbi.SBIClientInterface) *Nbi {
+	return NewNbiWithDatastore(s, newDefaultDatastore())
+}
+
+func NewNbiWithDatastore(s sbi.SBIClientInterface, ds Datastore) *Nbi {
 	sbiClient = s
 
 	nbiClient = &Nbi{
-		schemas:     viper.GetStringSlice("nbi.schemas"),
-		cleanupChan: make(chan bool),
-	}
+		schemas:      viper.GetStringSlice("nbi.schemas"),
+		ds:           ds,
+		cleanupChan:  make(chan bool),
+		audit:        newAuditLogger(),
+		auditTx:      make(map[string]*audit.Record),
+		accessTx:     make(map[int]*AccessPolicy),
+		namespaces:   namespacePolicyFromViper(),
+		deployedNsTx: make(map[int]map[string]string),
+		history:      newConfigHistory(),
+		rollbacks:    make(map[string]int),
+		intent:       newIntentStore(),
+		dryRunTx:     make(map[int]bool),
+	}
+	nbiClient.reconciler = newReconciler(nbiClient)
+	nbiClient.jobs = newJobManager(nbiClient)
+	nbiClient.registerDefaultProviders()
+	nbiClient.registerDefaultRPCs()
 	return nbiClient
 }
 
+// Datastore returns the datastore the NBI subscribes to
+func (n *Nbi) Datastore() Datastore {
+	return n.ds
+}
+
 func (n *Nbi) Start() bool {
 	if ok := n.Setup(n.schemas); !ok {
 		log.Error("NBI: SYSREPO initialization failed, bailing out!")
@@ -67,29 +77,26 @@ func (n *Nbi) Start() bool {
 	}
 	log.Info("NBI: SYSREPO initialization done ... processing O1 requests!")
 
+	n.replayIntent()
+	go n.reconciler.Run()
 	return true
 }
 
 func (n *Nbi) Stop() {
-	C.sr_unsubscribe(n.subscription)
-	C.sr_session_stop(n.session)
-	C.sr_disconnect(n.connection)
+	n.reconciler.Stop()
+	n.ds.Disconnect()
+	n.audit.Close()
 
 	log.Info("NBI: SYSREPO cleanup done gracefully!")
 }
 
 func (n *Nbi) Setup(schemas []string) bool {
-	rc := C.sr_connect(0, &n.connection)
-	if C.SR_ERR_OK != rc {
-		log.Error("NBI: sr_connect failed: %s", C.GoString(C.sr_strerror(rc)))
-		return false
-	}
-
-	rc = C.sr_session_start(n.connection, C.SR_DS_RUNNING, &n.session)
-	if C.SR_ERR_OK != rc {
-		log.Error("NBI: sr_session_start failed: %s", C.GoString(C.sr_strerror(rc)))
+	if err := n.ds.Connect(); err != nil {
+		log.Error("NBI: %v", err)
 		return false
 	}
+	n.loadAccessPolicy()
+	n.loadXappNamespaces()
 
 	for {
 		if ok := n.DoSubscription(schemas); ok == true {
@@ -104,89 +111,181 @@ func (n *Nbi) DoSubscription(schemas []string) bool {
 	log.Info("Subscribing YANG modules ... %v", schemas)
 
 	for _, module := range schemas {
-		modName := C.CString(module)
-		defer C.free(unsafe.Pointer(modName))
-
-		if done := n.SubscribeModule(modName); !done {
+		if done := n.SubscribeModule(module); !done {
 			return false
 		}
 	}
-	return n.SubscribeStatusData()
+	return n.SubscribeStatusData() && n.SubscribeRPCs()
 }
 
-func (n *Nbi) SubscribeModule(module *C.char) bool {
-	rc := C.sr_module_change_subscribe(n.session, module, nil, C.sr_module_change_cb(C.module_change_cb), nil, 0, 0, &n.subscription)
-	if C.SR_ERR_OK != rc {
-		log.Info("NBI: sr_module_change_subscribe failed: %s", C.GoString(C.sr_strerror(rc)))
+func (n *Nbi) SubscribeModule(module string) bool {
+	if err := n.ds.SubscribeModuleChange(module, n.ModuleChangeCB); err != nil {
+		log.Info("NBI: %v", err)
 		return false
 	}
 	return true
 }
 
 func (n *Nbi) SubscribeStatusData() bool {
-	if ok := n.SubscribeStatus("o-ran-sc-ric-gnb-status-v1", "/o-ran-sc-ric-gnb-status-v1:ric/nodes"); !ok {
-		return ok
-	}
-
-	if ok := n.SubscribeStatus("o-ran-sc-ric-xapp-desc-v1", "/o-ran-sc-ric-xapp-desc-v1:ric/health"); !ok {
-		return ok
-	}
-
-	if ok := n.SubscribeStatus("o-ran-sc-ric-alarm-v1", "/o-ran-sc-ric-alarm-v1:ric/alarms"); !ok {
-		return ok
-	}
-	if ok := n.SubscribeStatus("o-ran-sc-ric-xapp-desc-v1", "/o-ran-sc-ric-xapp-desc-v1:ric/configuration"); !ok {
-		return ok
+	for _, e := range n.providers {
+		if ok := n.SubscribeStatus(e.module, e.xpath); !ok {
+			return ok
+		}
 	}
-
 	return true
 }
 
 func (n *Nbi) SubscribeStatus(module, xpath string) bool {
-	mod := C.CString(module)
-	path := C.CString(xpath)
-	defer C.free(unsafe.Pointer(mod))
-	defer C.free(unsafe.Pointer(path))
-
-	rc := C.sr_oper_get_items_subscribe(n.session, mod, path, C.sr_oper_get_items_cb(C.gnb_status_cb), nil, 0, &n.subscription)
-	if C.SR_ERR_OK != rc {
-		log.Error("NBI: sr_oper_get_items_subscribe failed: %s", C.GoString(C.sr_strerror(rc)))
+	if err := n.ds.SubscribeOperData(module, xpath, n.GetOperData); err != nil {
+		log.Error("NBI: %v", err)
 		return false
 	}
 	return true
 }
 
-//export nbiModuleChangeCB
-func nbiModuleChangeCB(session *C.sr_session_ctx_t, module *C.char, xpath *C.char, event C.sr_event_t, reqId C.int) C.int {
-	changedModule := C.GoString(module)
-	changedXpath := C.GoString(xpath)
-
-	log.Info("NBI: change event='%d' module=%s xpath=%s reqId=%d", event, changedModule, changedXpath, reqId)
-	if C.SR_EV_CHANGE != event {
+func (n *Nbi) ModuleChangeCB(session ChangeSession, module, xpath string, event Event, reqId int) error {
+	log.Info("NBI: change event='%d' module=%s xpath=%s reqId=%d", event, module, xpath, reqId)
+	if EventChange != event {
+		switch event {
+		case EventDone:
+			n.finishAudit(module, reqId, audit.OutcomeCommitted, nil)
+		case EventAbort:
+			n.finishAudit(module, reqId, audit.OutcomeAborted, nil)
+		}
+		switch module {
+		case accessModule:
+			n.finishAccessPolicy(reqId, event == EventDone)
+		case xappDescModule:
+			n.finishXappNamespaces(reqId, event == EventDone)
+			n.finishDryRun(reqId, event == EventDone)
+			n.finishJobs(reqId, event == EventDone)
+		}
+		n.finishIntent(module, reqId, event == EventDone)
 		log.Info("NBI: Changes finalized!")
-		return C.SR_ERR_OK
+		return nil
 	}
 
-	if changedModule == "o-ran-sc-ric-xapp-desc-v1" {
-		configJson := C.yang_data_sr2json(session, module, event, &nbiClient.oper)
-		err := nbiClient.ManageXapps(changedModule, C.GoString(configJson), int(nbiClient.oper))
-		if err != nil {
-			return C.SR_ERR_OPERATION_FAILED
+	rec := n.startAudit(session, module, reqId)
+	err := n.authorizeChange(session, module)
+	if err == nil && module == accessModule {
+		err = n.stageAccessPolicy(session, reqId)
+	}
+	if err == nil && module == xappDescModule {
+		err = n.stageXappNamespaces(session, reqId)
+	}
+	dryRun := n.dryRunApplies(session, module)
+	if err == nil && module == xappDescModule {
+		err = n.stageDryRun(session, reqId)
+	}
+	if err == nil {
+		err = n.applyChange(session, module, reqId, rec, dryRun)
+	}
+	if err == nil && dryRun {
+		n.finishAudit(module, reqId, audit.OutcomeDryRun, nil)
+		return dryRunError(module, rec)
+	}
+	if err != nil {
+		n.finishAudit(module, reqId, audit.OutcomeRejected, err)
+		if module == xappDescModule {
+			n.finishJobs(reqId, false)
 		}
+		return err
+	}
+	n.stageIntent(session, module, reqId)
+	return nil
+}
+
+// applyChange carries the CHANGE event of the transaction reqID out towards
+// the SBI, noting the calls made in rec. A dry run only notes the calls it
+// would make.
+func (n *Nbi) applyChange(session ChangeSession, module string, reqID int, rec *audit.Record, dryRun bool) error {
+	if skipsSBI(session) {
+		return nil
 	}
 
-	if changedModule == "o-ran-sc-ric-ueec-config-v1" {
-		configJson := C.get_data_json(session, module)
-		err := nbiClient.ManageConfigmaps(changedModule, C.GoString(configJson), int(C.SR_OP_MODIFIED))
+	if module == "o-ran-sc-ric-xapp-desc-v1" {
+		changes, err := session.GetChanges("//.")
 		if err != nil {
-			return C.SR_ERR_OPERATION_FAILED
+			return err
+		}
+		for _, group := range xappChangeGroups(changes) {
+			configJson, oper := BuildTree(group)
+			if err := n.manageXapps(module, configJson, oper, reqID, rec, dryRun); err != nil {
+				return err
+			}
+		}
+	}
+
+	if module == "o-ran-sc-ric-ueec-config-v1" {
+		if err := n.patchXappConfig(session, module, rec, dryRun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// xappChangeGroups splits the changes of the xApp descriptors by xApp, so a
+// transaction deploying some xApps and undeploying others makes a call for
+// each. The undeployed xApps come first.
+func xappChangeGroups(changes []Change) [][]Change {
+	var groups [][]Change
+	var deleted []bool
+	index := make(map[string]int)
+	for _, c := range changes {
+		segs, err := ParsePath(c.Xpath)
+		if err != nil || len(segs) < 3 || segs[1].Name != "xapps" || segs[2].Name != "xapp" {
+			continue
+		}
+		key := segs[2].String(false)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+			deleted = append(deleted, false)
 		}
+		groups[i] = append(groups[i], c)
+		if len(segs) == 3 && c.Oper == OpDeleted {
+			deleted[i] = true
+		}
+	}
+
+	sorted := make([][]Change, 0, len(groups))
+	for _, undeploy := range []bool{true, false} {
+		for i, group := range groups {
+			if deleted[i] == undeploy {
+				sorted = append(sorted, group)
+			}
+		}
+	}
+	return sorted
+}
+
+// skipsSBI tells if the changes of session only record what is already
+// deployed, as the commits of the reconciler, of the intent replay and of
+// redeploy-xapp do. They never reach the appmgr.
+func skipsSBI(session ChangeSession) bool {
+	switch session.Originator().Name {
+	case reconcilerOriginator, startupOriginator, redeployOriginator:
+		return true
 	}
+	return false
+}
+
+// directReqID is the request ID of the changes made outside of a datastore
+// transaction
+const directReqID = -1
 
-	return C.SR_ERR_OK
+func (n *Nbi) ManageXapps(module, configJson string, oper Operation) error {
+	err := n.manageXapps(module, configJson, oper, directReqID, nil, false)
+	n.finishJobs(directReqID, err == nil)
+	return err
 }
 
-func (n *Nbi) ManageXapps(module, configJson string, oper int) error {
+// manageXapps deploys or undeploys xApps for the transaction reqID. Jobs
+// are staged and only queued once the transaction is done.
+func (n *Nbi) manageXapps(module, configJson string, oper Operation, reqID int, rec *audit.Record, dryRun bool) error {
 	log.Info("ManageXapps: module=%s configJson=%s", module, configJson)
 
 	if configJson == "" {
@@ -196,7 +295,7 @@ func (n *Nbi) ManageXapps(module, configJson string, oper int) error {
 	root := fmt.Sprintf("%s:ric", module)
 	jsonList, err := n.ParseJsonArray(configJson, root, "xapps", "xapp")
 	if err != nil {
-		return err
+		return &ChangeError{Xpath: "/" + root + "/xapps", Tag: "invalid-value", Message: fmt.Sprintf("invalid xApp descriptor: %v", err), Err: err}
 	}
 
 	for _, m := range jsonList {
@@ -204,55 +303,113 @@ func (n *Nbi) ManageXapps(module, configJson string, oper int) error {
 		namespace := string(m.GetStringBytes("namespace"))
 		relName := string(m.GetStringBytes("release-name"))
 		version := string(m.GetStringBytes("version"))
+		xpath := fmt.Sprintf("/%s/xapps/xapp[name='%s']", root, xappName)
+
+		if err := n.namespaces.Check(namespace); err != nil && oper == OpCreated {
+			return &ChangeError{Xpath: xpath + "/namespace", Tag: "invalid-value", Message: err.Error(), Err: err}
+		}
+		if namespace == "" {
+			namespace = n.namespaces.defaultNamespace()
+		}
 
 		desc := sbiClient.BuildXappDescriptor(xappName, namespace, relName, version)
-		switch oper {
-		case C.SR_OP_CREATED:
-			return sbiClient.DeployXapp(desc)
-		case C.SR_OP_DELETED:
-			return sbiClient.UndeployXapp(desc)
+		switch {
+		case oper == OpCreated && n.jobs != nil && dryRun:
+			rec.AddSBICall("deploy xApp '%s' as a job", xappName)
+		case oper == OpDeleted && n.jobs != nil && dryRun:
+			rec.AddSBICall("undeploy xApp '%s' as a job", xappName)
+		case oper == OpCreated && n.jobs != nil:
+			rec.AddSBICall("deploy xApp '%s' as job %s", xappName, n.jobs.stage(reqID, JobDeploy, desc))
+		case oper == OpDeleted && n.jobs != nil:
+			rec.AddSBICall("undeploy xApp '%s' as job %s", xappName, n.jobs.stage(reqID, JobUndeploy, desc))
+		case oper == OpCreated:
+			rec.AddSBICall("deploy xApp '%s'", xappName)
+			if dryRun {
+				return nil
+			}
+			if err := sbiClient.DeployXapp(desc); err != nil {
+				return &ChangeError{Xpath: xpath, Message: fmt.Sprintf("deploying xApp '%s' failed: %v", xappName, err), Err: err}
+			}
+			return nil
+		case oper == OpDeleted:
+			rec.AddSBICall("undeploy xApp '%s'", xappName)
+			if dryRun {
+				return nil
+			}
+			if err := sbiClient.UndeployXapp(desc); err != nil {
+				return &ChangeError{Xpath: xpath, Message: fmt.Sprintf("undeploying xApp '%s' failed: %v", xappName, err), Err: err}
+			}
+			return nil
 		default:
-			return errors.New(fmt.Sprintf("Operation '%d' not supported!", oper))
+			err := errors.New(fmt.Sprintf("Operation '%d' not supported!", oper))
+			return &ChangeError{Xpath: xpath, Tag: "operation-not-supported", Message: err.Error(), Err: err}
 		}
 	}
 	return nil
 }
 
-func (n *Nbi) ManageConfigmaps(module, configJson string, oper int) error {
+func (n *Nbi) ManageConfigmaps(module, configJson string, oper Operation) error {
+	return n.manageConfigmaps(module, configJson, oper, nil)
+}
+
+func (n *Nbi) manageConfigmaps(module, configJson string, oper Operation, rec *audit.Record) error {
 	log.Info("ManageConfig: module=%s configJson=%s", module, configJson)
 
 	if configJson == "" {
 		return nil
 	}
 
-	if oper != C.SR_OP_MODIFIED {
+	if oper != OpModified {
 		return errors.New(fmt.Sprintf("Operation '%d' not supported!", oper))
 	}
 
+	root := fmt.Sprintf("%s:ric", module)
+	xpath := "/" + root + "/config"
+
 	value, err := n.ParseJson(configJson)
 	if err != nil {
 		log.Info("ParseJson failed with error: %v", oper)
-		return err
+		return &ChangeError{Xpath: xpath, Tag: "invalid-value", Message: fmt.Sprintf("invalid xApp configuration: %v", err), Err: err}
 	}
 
-	root := fmt.Sprintf("%s:ric", module)
 	appName := string(value.GetStringBytes(root, "config", "name"))
 	namespace := string(value.GetStringBytes(root, "config", "namespace"))
+	if namespace == "" {
+		namespace = n.namespaces.defaultNamespace()
+	}
 	controlVal := value.Get(root, "config", "control")
 	if controlVal == nil {
 		return nil
 	}
-	control := controlVal.String()
+	control := []byte(controlVal.String())
+	nodes := controlVal.Type() != fastjson.TypeString
+	if !nodes {
+		// The control object given as an escaped JSON string
+		control = controlVal.GetStringBytes()
+	}
 
 	var f interface{}
-	err = json.Unmarshal([]byte(strings.ReplaceAll(control, "\\", "")), &f)
+	err = json.Unmarshal(control, &f)
 	if err != nil {
 		log.Info("json.Unmarshal failed: %v", err)
+		return &ChangeError{Xpath: xpath + "/control", Tag: "invalid-value", Message: fmt.Sprintf("control is not valid JSON: %v", err), Err: err}
+	}
+
+	if err := n.checkXappConfig(xpath+"/control", appName, namespace, f, nodes); err != nil {
 		return err
 	}
 
 	xappConfig := sbiClient.BuildXappConfig(appName, namespace, f)
-	return sbiClient.ModifyXappConfig(xappConfig)
+	rec.AddSBICall("modify config of xApp '%s'", appName)
+	if err := sbiClient.ModifyXappConfig(xappConfig); err != nil {
+		return &ChangeError{Xpath: xpath, Message: fmt.Sprintf("configuring xApp '%s' failed: %v", appName, err), Err: err}
+	}
+	var o Originator
+	if rec != nil {
+		o = Originator{Name: rec.Originator, User: rec.User, Session: rec.Session}
+	}
+	n.recordConfig(appName, namespace, o, "", nil, f)
+	return nil
 }
 
 func (n *Nbi) ParseJson(dsContent string) (*fastjson.Value, error) {
@@ -272,123 +429,6 @@ func (n *Nbi) ParseJsonArray(dsContent, model, top, elem string) ([]*fastjson.Va
 	return v.GetArray(model, top, elem), nil
 }
 
-//export nbiGnbStateCB
-func nbiGnbStateCB(session *C.sr_session_ctx_t, module *C.char, xpath *C.char, rpath *C.char, reqid C.uint32_t, parent **C.char) C.int {
-	mod := C.GoString(module)
-	log.Info("nbiGnbStateCB: module='%s' xpath='%s' rpath='%s' [id=%d]", mod, C.GoString(xpath), C.GoString(rpath), reqid)
-
-	if mod == "o-ran-sc-ric-xapp-desc-v1" {
-
-		if C.GoString(xpath) == "/o-ran-sc-ric-xapp-desc-v1:ric/configuration" {
-			nbGetAllXappsDefCfg(session, parent)
-			return C.SR_ERR_OK
-		}
-
-		xappnamespace := os.Getenv("XAPP_NAMESPACE")
-		if xappnamespace == "" {
-			xappnamespace = "ricxapp"
-		}
-		podList, _ := sbiClient.GetAllPodStatus(xappnamespace)
-
-		for _, pod := range podList {
-			path := fmt.Sprintf("/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name='%s']", pod.Name)
-			nbiClient.CreateNewElement(session, parent, path, "name", path)
-			nbiClient.CreateNewElement(session, parent, path, "health", pod.Health)
-			nbiClient.CreateNewElement(session, parent, path, "status", pod.Status)
-		}
-		return C.SR_ERR_OK
-	}
-
-	if mod == "o-ran-sc-ric-alarm-v1" {
-		if alerts, _ := sbiClient.GetAlerts(); alerts != nil {
-			for _, alert := range alerts.Payload {
-				id := alert.Annotations["alarm_id"]
-				path := fmt.Sprintf("/o-ran-sc-ric-alarm-v1:ric/alarms/alarm[alarm-id='%s']", id)
-				nbiClient.CreateNewElement(session, parent, path, "alarm-id", id)
-				nbiClient.CreateNewElement(session, parent, path, "fault-text", alert.Alert.Labels["alertname"])
-				nbiClient.CreateNewElement(session, parent, path, "severity", alert.Alert.Labels["severity"])
-				nbiClient.CreateNewElement(session, parent, path, "status", alert.Alert.Labels["status"])
-				nbiClient.CreateNewElement(session, parent, path, "additional-info", alert.Annotations["additional_info"])
-			}
-		}
-		return C.SR_ERR_OK
-	}
-
-	gnbs, err := rnib.GetListGnbIds()
-	log.Info("Rnib.GetListGnbIds() returned elementCount=%d err:%v", len(gnbs), err)
-	if err == nil && len(gnbs) > 0 {
-		for _, gnb := range gnbs {
-			ranName := gnb.GetInventoryName()
-			info, err := rnib.GetNodeb(ranName)
-			if err != nil {
-				log.Error("GetNodeb() failed for ranName=%s: %v", ranName, err)
-				continue
-			}
-
-			prot := nbiClient.E2APProt2Str(int(info.E2ApplicationProtocol))
-			connStat := nbiClient.ConnStatus2Str(int(info.ConnectionStatus))
-			ntype := nbiClient.NodeType2Str(int(info.NodeType))
-
-			log.Info("gNB info: %s -> %s %s %s -> %s %s", ranName, prot, connStat, ntype, gnb.GetGlobalNbId().GetPlmnId(), gnb.GetGlobalNbId().GetNbId())
-
-			path := fmt.Sprintf("/o-ran-sc-ric-gnb-status-v1:ric/nodes/node[ran-name='%s']", ranName)
-			nbiClient.CreateNewElement(session, parent, path, "ran-name", ranName)
-			nbiClient.CreateNewElement(session, parent, path, "ip", info.Ip)
-			nbiClient.CreateNewElement(session, parent, path, "port", fmt.Sprintf("%d", info.Port))
-			nbiClient.CreateNewElement(session, parent, path, "plmn-id", gnb.GetGlobalNbId().GetPlmnId())
-			nbiClient.CreateNewElement(session, parent, path, "nb-id", gnb.GetGlobalNbId().GetNbId())
-			nbiClient.CreateNewElement(session, parent, path, "e2ap-protocol", prot)
-			nbiClient.CreateNewElement(session, parent, path, "connection-status", connStat)
-			nbiClient.CreateNewElement(session, parent, path, "node", ntype)
-		}
-	}
-
-	//Check if any Enbs are connected to RIC
-	enbs, err2 := rnib.GetListEnbIds()
-	log.Info("Rnib.GetListEnbIds() returned elementCount=%d err:%v", len(gnbs), err)
-	if err2 == nil || len(enbs) > 0 {
-		log.Info("Getting Enb details from list of Enbs")
-		for _, enb := range enbs {
-			ranName := enb.GetInventoryName()
-			info, err := rnib.GetNodeb(ranName)
-			if err != nil {
-				log.Error("GetNodeb() failed for ranName=%s: %v", ranName, err)
-				continue
-			}
-
-			prot := nbiClient.E2APProt2Str(int(info.E2ApplicationProtocol))
-			connStat := nbiClient.ConnStatus2Str(int(info.ConnectionStatus))
-			ntype := nbiClient.NodeType2Str(int(info.NodeType))
-
-			log.Info("eNB info: %s -> %s %s %s -> %s %s", ranName, prot, connStat, ntype, enb.GetGlobalNbId().GetPlmnId(), enb.GetGlobalNbId().GetNbId())
-
-			path := fmt.Sprintf("/o-ran-sc-ric-gnb-status-v1:ric/nodes/node[ran-name='%s']", ranName)
-			nbiClient.CreateNewElement(session, parent, path, "ran-name", ranName)
-			nbiClient.CreateNewElement(session, parent, path, "ip", info.Ip)
-			nbiClient.CreateNewElement(session, parent, path, "port", fmt.Sprintf("%d", info.Port))
-			nbiClient.CreateNewElement(session, parent, path, "plmn-id", enb.GetGlobalNbId().GetPlmnId())
-			nbiClient.CreateNewElement(session, parent, path, "nb-id", enb.GetGlobalNbId().GetNbId())
-			nbiClient.CreateNewElement(session, parent, path, "e2ap-protocol", prot)
-			nbiClient.CreateNewElement(session, parent, path, "connection-status", connStat)
-			nbiClient.CreateNewElement(session, parent, path, "node", ntype)
-		}
-	}
-
-	return C.SR_ERR_OK
-}
-
-func (n *Nbi) CreateNewElement(session *C.sr_session_ctx_t, parent **C.char, key, name, value string) {
-	basePath := fmt.Sprintf("%s/%s", key, name)
-	log.Info("%s -> %s", basePath, value)
-
-	cPath := C.CString(basePath)
-	defer C.free(unsafe.Pointer(cPath))
-	cValue := C.CString(value)
-	defer C.free(unsafe.Pointer(cValue))
-
-	C.create_new_path(session, parent, cPath, cValue)
-}
-
 func (n *Nbi) ConnStatus2Str(connStatus int) string {
 	switch connStatus {
 	case 0:
@@ -433,62 +473,6 @@ func (n *Nbi) NodeType2Str(ntype int) string {
 	return "not-specified"
 }
 
-func (n *Nbi) testModuleChangeCB(module string) bool {
-	var event C.sr_event_t = C.SR_EV_CHANGE
-	reqID := C.int(100)
-	modName := C.CString(module)
-	defer C.free(unsafe.Pointer(modName))
-
-	if ret := nbiModuleChangeCB(n.session, modName, nil, event, reqID); ret != C.SR_ERR_OK {
-		return false
-	}
-	return true
-}
-
-func (n *Nbi) testModuleChangeCBDone(module string) bool {
-	var event C.sr_event_t = C.SR_EV_DONE
-	reqID := C.int(100)
-	modName := C.CString(module)
-	defer C.free(unsafe.Pointer(modName))
-
-	if ret := nbiModuleChangeCB(n.session, modName, nil, event, reqID); ret != C.SR_ERR_OK {
-		return false
-	}
-	return true
-}
-
-func (n *Nbi) testGnbStateCB(module string) bool {
-	modName := C.CString(module)
-	defer C.free(unsafe.Pointer(modName))
-	reqID := C.uint32_t(100)
-	parent := make([]*C.char, 1)
-
-	if ret := nbiGnbStateCB(n.session, modName, nil, nil, reqID, &parent[0]); ret != C.SR_ERR_OK {
-		return false
-	}
-	return true
-}
-
-func nbGetAllXappsDefCfg(session *C.sr_session_ctx_t, parent **C.char) {
-	var xappNameList []string
-	var xappCfgList []string
-
-	//Get the default config of all deployed xapps from appgmr using rest api
-	xappNameList, xappCfgList = sbiClient.GetAllDeployedXappsConfig()
-	if xappCfgList == nil || len(xappCfgList) == 0 {
-		log.Error("GetAllDeployedXappsConfig() Failure")
-		return
-	}
-	log.Info("GetAllDeployedXappsConfig Success, recvd xapp config")
-
-	//Loop thru the list of recvd xapps for config
-	for i, xappCfg := range xappCfgList {
-		path := fmt.Sprintf("/o-ran-sc-ric-xapp-desc-v1:ric/configuration/xapps/xapp[name='%s']", xappNameList[i])
-		nbiClient.CreateNewElement(session, parent, path, "name", xappNameList[i])
-		nbiClient.CreateNewElement(session, parent, path, "config", xappCfg)
-	}
-}
-
 type iRnib interface {
 	GetListGnbIds() ([]*xapp.RNIBNbIdentity, xapp.RNIBIRNibError)
 	GetListEnbIds() ([]*xapp.RNIBNbIdentity, xapp.RNIBIRNibError)
@@ -34,6 +34,7 @@ import (
 	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
 	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
 	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
+	"github.com/prometheus/alertmanager/api/v2/models"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -75,19 +76,29 @@ var XappDescriptor = `{
   }`
 
 var n *Nbi
+var ds *MemDatastore
 var rnibM *rnibMock
 
 // Test cases
 func TestMain(M *testing.M) {
 	rnibM = new(rnibMock)
 	rnib = rnibM
-	n = NewNbi(sbi.NewSBIClient("localhost:8080", "localhost:9093", 5))
+	ds = NewMemDatastore()
+	n = NewNbiWithDatastore(newSBIClient(), ds)
+	n.schemas = []string{"o-ran-sc-ric-xapp-desc-v1", "o-ran-sc-ric-ueec-config-v1", "o-ran-sc-ric-xapp-access-v1"}
 	go n.Start()
 	time.Sleep(time.Duration(1) * time.Second)
 
 	os.Exit(M.Run())
 }
 
+// newSBIClient returns a client for the test servers. They come and go on
+// the same port, so neither keep connections to them nor retry or let the
+// breaker open in between.
+func newSBIClient() *sbi.SBIClient {
+	return sbi.NewSBIClientWithOptions("localhost:8080", "localhost:9093", 5, sbi.HTTPOptions{MaxIdleConns: -1})
+}
+
 func TestModifyConfigmap(t *testing.T) {
 	ts := CreateHTTPServer(t, "PUT", "/ric/v1/config", 8080, http.StatusOK, apimodel.ConfigValidationErrors{})
 	defer ts.Close()
@@ -116,12 +127,12 @@ func TestUeecConfigDoneModuleChangeCB(t *testing.T) {
 }
 
 func TestXappDescGnbStateCB(t *testing.T) {
-	ok := n.testGnbStateCB("o-ran-sc-ric-xapp-desc-v1")
+	ok := n.testGnbStateCB("/o-ran-sc-ric-xapp-desc-v1:ric/health")
 	assert.True(t, ok)
 }
 
 func TestAlarmGnbStateCB(t *testing.T) {
-	ok := n.testGnbStateCB("o-ran-sc-ric-alarm-v1")
+	ok := n.testGnbStateCB("/o-ran-sc-ric-alarm-v1:ric/alarms")
 	assert.True(t, ok)
 }
 
@@ -136,7 +147,7 @@ func TestGnbStateCB(t *testing.T) {
 
 	rnibM.On("GetListGnbIds").Return(gNbIDs, rnibOk).Once()
 	rnibM.On("GetNodeb", mock.Anything).Return(&nodeInfo, rnibOk).Once()
-	ok := n.testGnbStateCB("")
+	ok := n.testGnbStateCB("/o-ran-sc-ric-gnb-status-v1:ric/nodes")
 	assert.True(t, ok)
 }
 
@@ -144,7 +155,7 @@ func TestGnbStateCBWhenRnibGetListGnbIdsFails(t *testing.T) {
 	var rnibErr xapp.RNIBIRNibError = errors.New("Some RNIB Error")
 
 	rnibM.On("GetListGnbIds").Return(nil, rnibErr).Once()
-	ok := n.testGnbStateCB("")
+	ok := n.testGnbStateCB("/o-ran-sc-ric-gnb-status-v1:ric/nodes")
 	assert.True(t, ok)
 }
 
@@ -159,7 +170,7 @@ func TestGnbStateCBWhenRnibGetNodebFails(t *testing.T) {
 
 	rnibM.On("GetListGnbIds").Return(gNbIDs, rnibOk).Once()
 	rnibM.On("GetNodeb", mock.Anything).Return(nil, rnibErr).Once()
-	ok := n.testGnbStateCB("")
+	ok := n.testGnbStateCB("/o-ran-sc-ric-gnb-status-v1:ric/nodes")
 	assert.True(t, ok)
 }
 
@@ -233,6 +244,92 @@ func TestErrorCases(t *testing.T) {
         assert.Equal(t, true, err != nil)
 }
 
+func TestDeployAndUndeployXappThroughDatastore(t *testing.T) {
+	ts := CreateHTTPServer(t, "POST", "/ric/v1/xapps", 8080, http.StatusCreated, apimodel.Xapp{})
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']"
+	assert.Nil(t, ds.SetItem(path+"/release-name", "ueec-xapp"))
+	assert.Nil(t, ds.SetItem(path+"/version", "0.0.1"))
+	assert.Nil(t, ds.SetItem(path+"/namespace", "ricxapp"))
+	assert.Nil(t, ds.ApplyChanges())
+	ts.Close()
+	assert.NotNil(t, ds.GetConfig(path).Find(path))
+
+	ts = CreateHTTPServer(t, "DELETE", "/ric/v1/xapps/ueec-xapp", 8080, http.StatusNoContent, apimodel.Xapp{})
+	assert.Nil(t, ds.DeleteItem(path))
+	assert.Nil(t, ds.ApplyChanges())
+	ts.Close()
+	assert.Nil(t, ds.GetConfig("/o-ran-sc-ric-xapp-desc-v1:ric").Find(path))
+}
+
+func TestDeployXappThroughDatastoreRejected(t *testing.T) {
+	ts := CreateHTTPServer(t, "POST", "/ric/v1/xapps", 8080, http.StatusInternalServerError, nil)
+	defer ts.Close()
+
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='anr']"
+	assert.Nil(t, ds.SetItem(path+"/release-name", "anr-xapp"))
+	err := ds.ApplyChanges()
+	var cerr *ChangeError
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, path, cerr.Xpath)
+	assert.True(t, sbi.IsRejected(err))
+	assert.Contains(t, err.Error(), "deploying xApp 'anr' failed: appmgr rejected the request (status 500)")
+	assert.Nil(t, ds.GetConfig("/o-ran-sc-ric-xapp-desc-v1:ric").Find(path))
+}
+
+func TestChangeErrorsNameTheOffendingNode(t *testing.T) {
+	var cerr *ChangeError
+	err := n.ManageConfigmaps("o-ran-sc-ric-ueec-config-v1", "{", OpModified)
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "/o-ran-sc-ric-ueec-config-v1:ric/config", cerr.Xpath)
+	assert.Equal(t, "invalid-value", cerr.Tag)
+
+	err = n.ManageXapps("o-ran-sc-ric-xapp-desc-v1", "{", OpCreated)
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/xapps", cerr.Xpath)
+	assert.Equal(t, "invalid-value", cerr.Tag)
+
+	err = n.ManageXapps("o-ran-sc-ric-xapp-desc-v1", XappDescriptor, OpModified)
+	assert.True(t, errors.As(err, &cerr))
+	assert.Equal(t, "operation-not-supported", cerr.Tag)
+}
+
+func TestModifyConfigThroughDatastore(t *testing.T) {
+	ts := newConfigServer(t, map[string]interface{}{})
+	defer ts.Close()
+
+	path := "/o-ran-sc-ric-ueec-config-v1:ric/config"
+	assert.Nil(t, ds.SetItem(path+"/name", "ueec"))
+	assert.Nil(t, ds.SetItem(path+"/namespace", "ricxapp"))
+	assert.Nil(t, ds.SetItem(path+"/control/active", "true"))
+	assert.Nil(t, ds.SetItem(path+"/control/interfaceId/globalENBId/plmnId", "1234"))
+	assert.Nil(t, ds.ApplyChanges())
+	assert.Equal(t, "1234", ds.GetConfig(path).Find(path+"/control/interfaceId/globalENBId/plmnId").Value)
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"active":      true,
+		"interfaceId": map[string]interface{}{"globalENBId": map[string]interface{}{"plmnId": "1234"}},
+	}}, ts.puts)
+
+	assert.Nil(t, ds.DeleteItem(path))
+	assert.Nil(t, ds.ApplyChanges())
+	assert.Equal(t, map[string]interface{}{}, ts.config)
+}
+
+func TestGetAlarmsThroughDatastore(t *testing.T) {
+	url := "/api/v2/alerts?active=true&inhibited=true&silenced=true&unprocessed=true"
+	alerts := []models.GettableAlert{
+		models.GettableAlert{
+			Alert:       models.Alert{Labels: models.LabelSet{"alertname": "E2 CONNECTIVITY LOST", "severity": "MAJOR"}},
+			Annotations: models.LabelSet{"alarm_id": "8006"},
+		},
+	}
+	ts := CreateHTTPServer(t, "GET", url, 9093, http.StatusOK, alerts)
+	defer ts.Close()
+
+	tree, err := ds.GetItems("/o-ran-sc-ric-alarm-v1:ric/alarms")
+	assert.Nil(t, err)
+	assert.Equal(t, "MAJOR", tree.Find("/o-ran-sc-ric-alarm-v1:ric/alarms/alarm[alarm-id='8006']/severity").Value)
+}
+
 func TestConnStatus2Str(t *testing.T) {
 	assert.Equal(t, n.ConnStatus2Str(0), "not-specified")
 	assert.Equal(t, n.ConnStatus2Str(1), "connected")
@@ -283,6 +380,19 @@ func CreateHTTPServer(t *testing.T, method, url string, port, status int, respDa
 	return ts
 }
 
+func (n *Nbi) testModuleChangeCB(module string) bool {
+	return n.ModuleChangeCB(&memSession{data: NewTree()}, module, "", EventChange, 100) == nil
+}
+
+func (n *Nbi) testModuleChangeCBDone(module string) bool {
+	return n.ModuleChangeCB(&memSession{data: NewTree()}, module, "", EventDone, 100) == nil
+}
+
+func (n *Nbi) testGnbStateCB(xpath string) bool {
+	_, err := ds.GetItems(xpath)
+	return err == nil
+}
+
 func DescMatcher(result, expected *apimodel.XappDescriptor) bool {
 	if *result.XappName == *expected.XappName && result.HelmVersion == expected.HelmVersion &&
 		result.Namespace == expected.Namespace && result.ReleaseName == expected.ReleaseName {
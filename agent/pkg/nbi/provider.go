@@ -0,0 +1,104 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+)
+
+// OperDataTree receives the leaves an operational data provider reports.
+// The sysrepo callback backs it with a libyang tree, tests with a map.
+type OperDataTree interface {
+	CreateNewElement(key, name, value string)
+}
+
+// OperDataProvider fills the operational (config false) data of one subtree.
+type OperDataProvider interface {
+	GetOperData(xpath string, tree OperDataTree) error
+}
+
+// OperDataProviderFunc adapts an ordinary function to OperDataProvider.
+type OperDataProviderFunc func(xpath string, tree OperDataTree) error
+
+func (f OperDataProviderFunc) GetOperData(xpath string, tree OperDataTree) error {
+	return f(xpath, tree)
+}
+
+type providerEntry struct {
+	module   string
+	xpath    string
+	provider OperDataProvider
+}
+
+// RegisterProvider binds a provider to a module and the subtree xpath it serves.
+// Providers registered before Start are subscribed to sysrepo automatically.
+func (n *Nbi) RegisterProvider(module, xpath string, p OperDataProvider) error {
+	if module == "" || xpath == "" || p == nil {
+		return fmt.Errorf("invalid provider registration: module='%s' xpath='%s'", module, xpath)
+	}
+
+	for i, e := range n.providers {
+		if e.module == module && e.xpath == xpath {
+			n.providers[i].provider = p
+			return nil
+		}
+	}
+	n.providers = append(n.providers, providerEntry{module, xpath, p})
+	return nil
+}
+
+// LookupProvider returns the provider registered for module and xpath. If the
+// xpath is unknown, the first provider registered for the module is returned.
+func (n *Nbi) LookupProvider(module, xpath string) OperDataProvider {
+	var fallback OperDataProvider
+	for _, e := range n.providers {
+		if e.module != module {
+			continue
+		}
+		if e.xpath == xpath {
+			return e.provider
+		}
+		if fallback == nil {
+			fallback = e.provider
+		}
+	}
+	return fallback
+}
+
+func (n *Nbi) GetOperData(module, xpath string, tree OperDataTree) error {
+	p := n.LookupProvider(module, xpath)
+	if p == nil {
+		return fmt.Errorf("no operational data provider for module='%s' xpath='%s'", module, xpath)
+	}
+	return p.GetOperData(xpath, tree)
+}
+
+func (n *Nbi) registerDefaultProviders() {
+	n.RegisterProvider("o-ran-sc-ric-gnb-status-v1", "/o-ran-sc-ric-gnb-status-v1:ric/nodes", &nodeStatusProvider{n})
+	n.RegisterProvider("o-ran-sc-ric-xapp-desc-v1", "/o-ran-sc-ric-xapp-desc-v1:ric/health", &xappHealthProvider{n})
+	n.RegisterProvider("o-ran-sc-ric-alarm-v1", "/o-ran-sc-ric-alarm-v1:ric/alarms", &alarmProvider{n})
+	n.RegisterProvider("o-ran-sc-ric-xapp-desc-v1", "/o-ran-sc-ric-xapp-desc-v1:ric/configuration", &xappConfigProvider{})
+	n.RegisterProvider("o-ran-sc-ric-xapp-desc-v1", driftXpath, n.reconciler)
+	n.RegisterProvider("o-ran-sc-ric-xapp-desc-v1", auditXpath, &auditProvider{n})
+	n.RegisterProvider("o-ran-sc-ric-xapp-desc-v1", restoreXpath, n.intent)
+	if n.jobs != nil {
+		n.RegisterProvider("o-ran-sc-ric-xapp-desc-v1", jobsXpath, n.jobs)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
+	"github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mapTree map[string]string
+
+func (t mapTree) CreateNewElement(key, name, value string) {
+	t[key+"/"+name] = value
+}
+
+func TestRegisterProvider(t *testing.T) {
+	p := &Nbi{}
+	called := ""
+	err := p.RegisterProvider("mod-a", "/mod-a:ric/x", OperDataProviderFunc(func(xpath string, tree OperDataTree) error {
+		called = xpath
+		return nil
+	}))
+	assert.Nil(t, err)
+
+	assert.Nil(t, p.GetOperData("mod-a", "/mod-a:ric/x", mapTree{}))
+	assert.Equal(t, "/mod-a:ric/x", called)
+
+	// Unknown xpath falls back to the module's first provider
+	assert.Nil(t, p.GetOperData("mod-a", "/mod-a:ric/y", mapTree{}))
+	assert.Equal(t, "/mod-a:ric/y", called)
+
+	assert.NotNil(t, p.GetOperData("mod-b", "/mod-b:ric/x", mapTree{}))
+	assert.NotNil(t, p.RegisterProvider("", "/mod-a:ric/x", nil))
+}
+
+func TestRegisterProviderReplacesExisting(t *testing.T) {
+	p := &Nbi{}
+	p.RegisterProvider("mod-a", "/mod-a:ric/x", OperDataProviderFunc(func(xpath string, tree OperDataTree) error {
+		return errors.New("old provider")
+	}))
+	p.RegisterProvider("mod-a", "/mod-a:ric/x", OperDataProviderFunc(func(xpath string, tree OperDataTree) error {
+		return nil
+	}))
+	assert.Equal(t, 1, len(p.providers))
+	assert.Nil(t, p.GetOperData("mod-a", "/mod-a:ric/x", mapTree{}))
+}
+
+func TestDefaultProvidersRegistered(t *testing.T) {
+	assert.NotNil(t, n.LookupProvider("o-ran-sc-ric-gnb-status-v1", "/o-ran-sc-ric-gnb-status-v1:ric/nodes"))
+	assert.NotNil(t, n.LookupProvider("o-ran-sc-ric-xapp-desc-v1", "/o-ran-sc-ric-xapp-desc-v1:ric/health"))
+	assert.NotNil(t, n.LookupProvider("o-ran-sc-ric-xapp-desc-v1", "/o-ran-sc-ric-xapp-desc-v1:ric/configuration"))
+	assert.NotNil(t, n.LookupProvider("o-ran-sc-ric-alarm-v1", "/o-ran-sc-ric-alarm-v1:ric/alarms"))
+}
+
+func TestNodeStatusProvider(t *testing.T) {
+	var rnibOk xapp.RNIBIRNibError
+	gNbIDs := []*xapp.RNIBNbIdentity{&xapp.RNIBNbIdentity{InventoryName: "test-gnb"}}
+	nodeInfo := xapp.RNIBNodebInfo{Ip: "10.0.0.1", Port: 36421, ConnectionStatus: 1, NodeType: 2}
+
+	rnibM.On("GetListGnbIds").Return(gNbIDs, rnibOk).Once()
+	rnibM.On("GetNodeb", mock.Anything).Return(&nodeInfo, rnibOk).Once()
+
+	tree := mapTree{}
+	err := (&nodeStatusProvider{n}).GetOperData("/o-ran-sc-ric-gnb-status-v1:ric/nodes", tree)
+	assert.Nil(t, err)
+
+	path := "/o-ran-sc-ric-gnb-status-v1:ric/nodes/node[ran-name='test-gnb']"
+	assert.Equal(t, "test-gnb", tree[path+"/ran-name"])
+	assert.Equal(t, "10.0.0.1", tree[path+"/ip"])
+	assert.Equal(t, "36421", tree[path+"/port"])
+	assert.Equal(t, "connected", tree[path+"/connection-status"])
+	assert.Equal(t, "gnb", tree[path+"/node"])
+}
+
+func TestXappHealthProvider(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		return "ricxapp-ueec-7bfdd587db-2jl9j      1/1     Running   53         29d\n", nil
+	}
+
+	tree := mapTree{}
+	err := (&xappHealthProvider{}).GetOperData("/o-ran-sc-ric-xapp-desc-v1:ric/health", tree)
+	assert.Nil(t, err)
+
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name='ueec']"
+	assert.Equal(t, "healthy", tree[path+"/health"])
+	assert.Equal(t, "Running", tree[path+"/status"])
+}
+
+func TestAlarmProvider(t *testing.T) {
+	alerts := []models.GettableAlert{
+		models.GettableAlert{
+			Alert: models.Alert{
+				Labels: models.LabelSet{"alertname": "E2 CONNECTIVITY LOST", "severity": "MAJOR", "status": "active"},
+			},
+			Annotations: models.LabelSet{"alarm_id": "8006", "additional_info": "ethernet"},
+		},
+	}
+	url := "/api/v2/alerts?active=true&inhibited=true&silenced=true&unprocessed=true"
+	ts := CreateHTTPServer(t, "GET", url, 9093, http.StatusOK, alerts)
+	defer ts.Close()
+
+	tree := mapTree{}
+	err := (&alarmProvider{}).GetOperData("/o-ran-sc-ric-alarm-v1:ric/alarms", tree)
+	assert.Nil(t, err)
+
+	path := "/o-ran-sc-ric-alarm-v1:ric/alarms/alarm[alarm-id='8006']"
+	assert.Equal(t, "E2 CONNECTIVITY LOST", tree[path+"/fault-text"])
+	assert.Equal(t, "MAJOR", tree[path+"/severity"])
+	assert.Equal(t, "ethernet", tree[path+"/additional-info"])
+}
+
+func TestXappConfigProvider(t *testing.T) {
+	name, namespace := "ueec", "ricxapp"
+	cfg := apimodel.AllXappConfig{
+		&apimodel.XAppConfig{
+			Metadata: &apimodel.ConfigMetadata{XappName: &name, Namespace: &namespace},
+			Config:   map[string]interface{}{"active": true},
+		},
+	}
+	ts := CreateHTTPServer(t, "GET", "/ric/v1/config", 8080, http.StatusOK, cfg)
+	defer ts.Close()
+
+	tree := mapTree{}
+	err := (&xappConfigProvider{}).GetOperData("/o-ran-sc-ric-xapp-desc-v1:ric/configuration", tree)
+	assert.Nil(t, err)
+
+	path := "/o-ran-sc-ric-xapp-desc-v1:ric/configuration/xapps/xapp[name='ueec']"
+	assert.Equal(t, "ueec", tree[path+"/name"])
+	assert.Equal(t, `{"active":true}`, tree[path+"/config"])
+}
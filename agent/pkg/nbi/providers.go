@@ -0,0 +1,149 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+)
+
+// xappHealthProvider reports the pod status of deployed xApps in every
+// namespace xApps are deployed to
+type xappHealthProvider struct {
+	n *Nbi
+}
+
+func (p *xappHealthProvider) GetOperData(xpath string, tree OperDataTree) error {
+	namespaces := []string{xappNamespace()}
+	if p.n != nil {
+		namespaces = p.n.xappNamespaces()
+	}
+
+	for _, namespace := range namespaces {
+		podList, err := sbiClient.GetAllPodStatus(namespace)
+		if err != nil {
+			log.Error("NBI: getting pod status in namespace '%s' failed: %v", namespace, err)
+		}
+
+		for _, pod := range podList {
+			path := fmt.Sprintf("/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name='%s']", pod.Name)
+			tree.CreateNewElement(path, "name", path)
+			tree.CreateNewElement(path, "namespace", namespace)
+			tree.CreateNewElement(path, "health", pod.Health)
+			tree.CreateNewElement(path, "status", pod.Status)
+		}
+	}
+	return nil
+}
+
+// xappConfigProvider reports the current config of all deployed xApps
+type xappConfigProvider struct{}
+
+func (p *xappConfigProvider) GetOperData(xpath string, tree OperDataTree) error {
+	//Get the default config of all deployed xapps from appgmr using rest api
+	xappNameList, xappCfgList := sbiClient.GetAllDeployedXappsConfig()
+	if xappCfgList == nil || len(xappCfgList) == 0 {
+		log.Error("GetAllDeployedXappsConfig() Failure")
+		return nil
+	}
+	log.Info("GetAllDeployedXappsConfig Success, recvd xapp config")
+
+	//Loop thru the list of recvd xapps for config
+	for i, xappCfg := range xappCfgList {
+		path := fmt.Sprintf("/o-ran-sc-ric-xapp-desc-v1:ric/configuration/xapps/xapp[name='%s']", xappNameList[i])
+		tree.CreateNewElement(path, "name", xappNameList[i])
+		tree.CreateNewElement(path, "config", xappCfg)
+	}
+	return nil
+}
+
+// alarmProvider reports the active alarms fetched from the Alertmanager and
+// the xApp drift found by the reconciler
+type alarmProvider struct {
+	n *Nbi
+}
+
+func (p *alarmProvider) GetOperData(xpath string, tree OperDataTree) error {
+	if alerts, _ := sbiClient.GetAlerts(); alerts != nil {
+		for _, alert := range alerts.Payload {
+			id := alert.Annotations["alarm_id"]
+			path := fmt.Sprintf("/o-ran-sc-ric-alarm-v1:ric/alarms/alarm[alarm-id='%s']", id)
+			tree.CreateNewElement(path, "alarm-id", id)
+			tree.CreateNewElement(path, "fault-text", alert.Alert.Labels["alertname"])
+			tree.CreateNewElement(path, "severity", alert.Alert.Labels["severity"])
+			tree.CreateNewElement(path, "status", alert.Alert.Labels["status"])
+			tree.CreateNewElement(path, "additional-info", alert.Annotations["additional_info"])
+		}
+	}
+	if p.n != nil {
+		p.n.reconciler.addAlarms(tree)
+	}
+	return nil
+}
+
+// nodeStatusProvider reports the E2 nodes (gNBs and eNBs) stored in R-NIB
+type nodeStatusProvider struct {
+	n *Nbi
+}
+
+func (p *nodeStatusProvider) GetOperData(xpath string, tree OperDataTree) error {
+	gnbs, err := rnib.GetListGnbIds()
+	log.Info("Rnib.GetListGnbIds() returned elementCount=%d err:%v", len(gnbs), err)
+	if err == nil && len(gnbs) > 0 {
+		p.addNodes("gNB", gnbs, tree)
+	}
+
+	//Check if any Enbs are connected to RIC
+	enbs, err2 := rnib.GetListEnbIds()
+	log.Info("Rnib.GetListEnbIds() returned elementCount=%d err:%v", len(enbs), err2)
+	if err2 == nil || len(enbs) > 0 {
+		log.Info("Getting Enb details from list of Enbs")
+		p.addNodes("eNB", enbs, tree)
+	}
+	return nil
+}
+
+func (p *nodeStatusProvider) addNodes(kind string, nodes []*xapp.RNIBNbIdentity, tree OperDataTree) {
+	for _, node := range nodes {
+		ranName := node.GetInventoryName()
+		info, err := rnib.GetNodeb(ranName)
+		if err != nil {
+			log.Error("GetNodeb() failed for ranName=%s: %v", ranName, err)
+			continue
+		}
+
+		prot := p.n.E2APProt2Str(int(info.E2ApplicationProtocol))
+		connStat := p.n.ConnStatus2Str(int(info.ConnectionStatus))
+		ntype := p.n.NodeType2Str(int(info.NodeType))
+
+		log.Info("%s info: %s -> %s %s %s -> %s %s", kind, ranName, prot, connStat, ntype, node.GetGlobalNbId().GetPlmnId(), node.GetGlobalNbId().GetNbId())
+
+		path := fmt.Sprintf("/o-ran-sc-ric-gnb-status-v1:ric/nodes/node[ran-name='%s']", ranName)
+		tree.CreateNewElement(path, "ran-name", ranName)
+		tree.CreateNewElement(path, "ip", info.Ip)
+		tree.CreateNewElement(path, "port", fmt.Sprintf("%d", info.Port))
+		tree.CreateNewElement(path, "plmn-id", node.GetGlobalNbId().GetPlmnId())
+		tree.CreateNewElement(path, "nb-id", node.GetGlobalNbId().GetNbId())
+		tree.CreateNewElement(path, "e2ap-protocol", prot)
+		tree.CreateNewElement(path, "connection-status", connStat)
+		tree.CreateNewElement(path, "node", ntype)
+	}
+}
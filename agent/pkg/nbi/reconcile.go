@@ -0,0 +1,342 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Reconcile policies, see the drift container of o-ran-sc-ric-xapp-desc-v1
+const (
+	PolicyReport  = "report"
+	PolicyReapply = "reapply"
+	PolicyImport  = "import"
+)
+
+// Drift states of an xApp
+const (
+	DriftNotDeployed     = "not-deployed"
+	DriftNotConfigured   = "not-configured"
+	DriftVersionMismatch = "version-mismatch"
+)
+
+const driftXpath = "/o-ran-sc-ric-xapp-desc-v1:ric/drift"
+
+// reconcilerOriginator makes the commits importing the drifts
+const reconcilerOriginator = "reconciler"
+
+// Drift is an xApp whose running configuration and appmgr deployment differ
+type Drift struct {
+	Name              string
+	State             string
+	Namespace         string
+	Release           string
+	ConfiguredVersion string
+	DeployedVersion   string
+	Action            string
+	resolved          bool
+}
+
+// Reconciler compares the configured xApps with the ones the appmgr has
+// deployed, at startup and every interval, and handles the drift as the
+// policy says.
+type Reconciler struct {
+	n         *Nbi
+	policy    string
+	interval  time.Duration
+	mu        sync.Mutex
+	lastCheck time.Time
+	lastError error
+	drifts    []Drift
+	stop      chan struct{}
+}
+
+func newReconciler(n *Nbi) *Reconciler {
+	policy := viper.GetString("reconcile.policy")
+	if policy == "" {
+		policy = PolicyReport
+	}
+	interval := 300 * time.Second
+	if viper.IsSet("reconcile.interval") {
+		interval = time.Duration(viper.GetInt("reconcile.interval")) * time.Second
+	}
+	return NewReconciler(n, policy, interval)
+}
+
+// NewReconciler creates a reconciler running every interval, or only at
+// startup if the interval is 0.
+func NewReconciler(n *Nbi, policy string, interval time.Duration) *Reconciler {
+	switch policy {
+	case PolicyReport, PolicyReapply, PolicyImport:
+	default:
+		log.Error("NBI: unknown reconcile policy '%s', using '%s'", policy, PolicyReport)
+		policy = PolicyReport
+	}
+	return &Reconciler{n: n, policy: policy, interval: interval, stop: make(chan struct{})}
+}
+
+// Reconciler returns the reconciler of the NBI
+func (n *Nbi) Reconciler() *Reconciler {
+	return n.reconciler
+}
+
+// Run reconciles once and then every interval until Stop is called
+func (r *Reconciler) Run() {
+	r.Reconcile()
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.Reconcile()
+		}
+	}
+}
+
+func (r *Reconciler) Stop() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+}
+
+// Reconcile compares the running configuration with the appmgr once
+func (r *Reconciler) Reconcile() ([]Drift, error) {
+	drifts, err := r.compare()
+	if err == nil {
+		for i := range drifts {
+			r.handle(&drifts[i])
+		}
+		if r.policy == PolicyImport {
+			err = r.importDrifts(drifts)
+		}
+	}
+	if err != nil {
+		log.Error("NBI: reconcile failed: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastCheck = time.Now()
+	r.lastError = err
+	if err == nil {
+		r.drifts = drifts
+	}
+	return drifts, err
+}
+
+// compare lists the xApps that differ, sorted by name. A configured xApp is
+// deployed under its release name if it has one.
+func (r *Reconciler) compare() ([]Drift, error) {
+	store, ok := r.n.ds.(Store)
+	if !ok {
+		return nil, fmt.Errorf("datastore does not support reading the configuration")
+	}
+
+	deployed, err := sbiClient.ListDeployedXapps()
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string)
+	for _, x := range deployed {
+		if x != nil && x.Name != nil {
+			versions[*x.Name] = x.Version
+		}
+	}
+
+	var drifts []Drift
+	configured := make(map[string]bool)
+	xpath := fmt.Sprintf("/%s:ric/xapps", xappDescModule)
+	if xapps := store.GetConfig(xpath).Find(xpath); xapps != nil {
+		for _, x := range xapps.Children {
+			d := Drift{Name: leafValue(x, "name"), Namespace: leafValue(x, "namespace"),
+				Release: leafValue(x, "release-name"), ConfiguredVersion: leafValue(x, "version")}
+			release := d.Release
+			if release == "" {
+				release = d.Name
+			}
+			configured[release] = true
+
+			version, ok := versions[release]
+			switch {
+			case !ok:
+				d.State = DriftNotDeployed
+			case d.ConfiguredVersion != "" && version != "" && d.ConfiguredVersion != version:
+				d.State, d.DeployedVersion = DriftVersionMismatch, version
+			default:
+				continue
+			}
+			drifts = append(drifts, d)
+		}
+	}
+
+	for name, version := range versions {
+		if !configured[name] {
+			drifts = append(drifts, Drift{Name: name, State: DriftNotConfigured, DeployedVersion: version})
+		}
+	}
+
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Name < drifts[j].Name })
+	return drifts, nil
+}
+
+// handle makes the appmgr follow the configuration if the policy is reapply
+func (r *Reconciler) handle(d *Drift) {
+	d.Action = "none"
+	if r.policy != PolicyReapply {
+		return
+	}
+
+	desc := sbiClient.BuildXappDescriptor(d.Name, d.Namespace, d.Release, d.ConfiguredVersion)
+	var err error
+	switch d.State {
+	case DriftNotDeployed:
+		d.Action, err = "deployed", sbiClient.DeployXapp(desc)
+	case DriftNotConfigured:
+		d.Action, err = "undeployed", sbiClient.UndeployXapp(desc)
+	case DriftVersionMismatch:
+		d.Action, err = "redeployed", sbiClient.RedeployXapp(desc)
+	}
+	if err != nil {
+		d.Action = fmt.Sprintf("failed: %v", err)
+		return
+	}
+	d.resolved = true
+}
+
+// importDrifts makes the running configuration follow the appmgr. The
+// commit must not trigger any deployment, so it is made as the reconciler,
+// whose commits make no SBI calls.
+func (r *Reconciler) importDrifts(drifts []Drift) error {
+	if len(drifts) == 0 {
+		return nil
+	}
+	store := r.n.ds.(Store)
+	defer lockEdits(store)()
+	store.DiscardChanges()
+
+	for i := range drifts {
+		d := &drifts[i]
+		xpath := fmt.Sprintf("/%s:ric/xapps/xapp[name='%s']", xappDescModule, d.Name)
+
+		var err error
+		switch d.State {
+		case DriftNotDeployed:
+			d.Action, err = "removed", store.DeleteItem(xpath)
+		case DriftNotConfigured:
+			d.Action, err = "imported", store.SetItem(xpath, "")
+			if err == nil && d.DeployedVersion != "" {
+				err = store.SetItem(xpath+"/version", d.DeployedVersion)
+			}
+		case DriftVersionMismatch:
+			d.Action, err = "updated", store.SetItem(xpath+"/version", d.DeployedVersion)
+		}
+		if err != nil {
+			store.DiscardChanges()
+			return err
+		}
+	}
+
+	if err := store.ApplyChangesAs(Originator{Name: reconcilerOriginator}); err != nil {
+		return err
+	}
+	for i := range drifts {
+		drifts[i].resolved = true
+	}
+	return nil
+}
+
+// Drifts returns the result of the last successful reconciliation
+func (r *Reconciler) Drifts() []Drift {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Drift{}, r.drifts...)
+}
+
+func (r *Reconciler) GetOperData(xpath string, tree OperDataTree) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tree.CreateNewElement(driftXpath, "policy", r.policy)
+	if !r.lastCheck.IsZero() {
+		tree.CreateNewElement(driftXpath, "last-check", r.lastCheck.UTC().Format(time.RFC3339))
+	}
+	if r.lastError != nil {
+		tree.CreateNewElement(driftXpath, "last-error", r.lastError.Error())
+	}
+
+	for _, d := range r.drifts {
+		path := fmt.Sprintf("%s/xapp[name='%s']", driftXpath, d.Name)
+		tree.CreateNewElement(path, "name", d.Name)
+		tree.CreateNewElement(path, "state", d.State)
+		if d.ConfiguredVersion != "" {
+			tree.CreateNewElement(path, "configured-version", d.ConfiguredVersion)
+		}
+		if d.DeployedVersion != "" {
+			tree.CreateNewElement(path, "deployed-version", d.DeployedVersion)
+		}
+		tree.CreateNewElement(path, "action", d.Action)
+	}
+	return nil
+}
+
+// addAlarms reports an alarm for every drift left unresolved
+func (r *Reconciler) addAlarms(tree OperDataTree) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range r.drifts {
+		if d.resolved {
+			continue
+		}
+		id := "xapp-drift-" + d.Name
+		path := fmt.Sprintf("/o-ran-sc-ric-alarm-v1:ric/alarms/alarm[alarm-id='%s']", id)
+		tree.CreateNewElement(path, "alarm-id", id)
+		tree.CreateNewElement(path, "alarm-text", fmt.Sprintf("xApp '%s' drifted: %s", d.Name, d.State))
+		tree.CreateNewElement(path, "severity", "WARNING")
+		tree.CreateNewElement(path, "status", "active")
+		tree.CreateNewElement(path, "additional-info", fmt.Sprintf("policy=%s action=%s", r.policy, d.Action))
+	}
+}
+
+func leafValue(n *Node, name string) string {
+	if leaf := n.Child(name); leaf != nil {
+		return leaf.Value
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
+	"github.com/stretchr/testify/assert"
+)
+
+// appmgr is a fake appmgr recording the requests it gets
+type appmgr struct {
+	mu       sync.Mutex
+	deployed map[string]string
+	requests []string
+}
+
+func newAppmgr(t *testing.T, deployed map[string]string) *appmgr {
+	a := &appmgr{deployed: deployed}
+	l, err := net.Listen("tcp", "localhost:8080")
+	assert.Nil(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		w.Header().Add("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			var xapps apimodel.AllDeployedXapps
+			for name, version := range a.deployed {
+				name := name
+				xapps = append(xapps, &apimodel.Xapp{Name: &name, Version: version})
+			}
+			json.NewEncoder(w).Encode(xapps)
+			return
+		case "POST":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+		a.requests = append(a.requests, r.Method+" "+r.URL.Path)
+	}))
+	ts.Listener.Close()
+	ts.Listener = l
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return a
+}
+
+func (a *appmgr) changes() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string{}, a.requests...)
+}
+
+// newReconcileNbi returns an NBI whose running configuration holds ueec,
+// anr 1.0.0 and kpimon
+func newReconcileNbi(t *testing.T, policy string) (*Nbi, *MemDatastore) {
+	oldNbi, oldClient := nbiClient, sbiClient
+	t.Cleanup(func() { nbiClient, sbiClient = oldNbi, oldClient })
+
+	m := NewMemDatastore()
+	p := NewNbiWithDatastore(newSBIClient(), m)
+	p.reconciler = NewReconciler(p, policy, 0)
+	p.RegisterProvider(xappDescModule, driftXpath, p.reconciler)
+
+	xpath := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp"
+	m.SetItem(xpath+"[name='ueec']/release-name", "ueec-xapp")
+	m.SetItem(xpath+"[name='ueec']/version", "0.0.1")
+	m.SetItem(xpath+"[name='anr']/version", "1.0.0")
+	m.SetItem(xpath+"[name='kpimon']", "")
+	assert.Nil(t, m.ApplyChanges())
+
+	assert.True(t, p.SubscribeModule(xappDescModule))
+	return p, m
+}
+
+var deployedXapps = map[string]string{"ueec-xapp": "0.0.1", "anr": "2.0.0", "hw": "1.0.0"}
+
+func TestReconcileReport(t *testing.T) {
+	a := newAppmgr(t, deployedXapps)
+	p, _ := newReconcileNbi(t, PolicyReport)
+
+	drifts, err := p.Reconciler().Reconcile()
+	assert.Nil(t, err)
+	assert.Equal(t, []Drift{
+		{Name: "anr", State: DriftVersionMismatch, ConfiguredVersion: "1.0.0", DeployedVersion: "2.0.0", Action: "none"},
+		{Name: "hw", State: DriftNotConfigured, DeployedVersion: "1.0.0", Action: "none"},
+		{Name: "kpimon", State: DriftNotDeployed, Action: "none"},
+	}, drifts)
+	assert.Empty(t, a.changes())
+
+	tree := mapTree{}
+	assert.Nil(t, p.GetOperData(xappDescModule, driftXpath, tree))
+	assert.Equal(t, "report", tree[driftXpath+"/policy"])
+	assert.Equal(t, "version-mismatch", tree[driftXpath+"/xapp[name='anr']/state"])
+	assert.Equal(t, "2.0.0", tree[driftXpath+"/xapp[name='anr']/deployed-version"])
+	assert.Equal(t, "not-deployed", tree[driftXpath+"/xapp[name='kpimon']/state"])
+	assert.NotEmpty(t, tree[driftXpath+"/last-check"])
+
+	alarms := mapTree{}
+	p.reconciler.addAlarms(alarms)
+	assert.Equal(t, "xApp 'hw' drifted: not-configured", alarms["/o-ran-sc-ric-alarm-v1:ric/alarms/alarm[alarm-id='xapp-drift-hw']/alarm-text"])
+	assert.Equal(t, 15, len(alarms))
+}
+
+func TestReconcileReapply(t *testing.T) {
+	a := newAppmgr(t, deployedXapps)
+	p, _ := newReconcileNbi(t, PolicyReapply)
+
+	drifts, err := p.Reconciler().Reconcile()
+	assert.Nil(t, err)
+	assert.Equal(t, "redeployed", drifts[0].Action)
+	assert.Equal(t, "undeployed", drifts[1].Action)
+	assert.Equal(t, "deployed", drifts[2].Action)
+	assert.Equal(t, []string{"DELETE /ric/v1/xapps/anr", "POST /ric/v1/xapps", "DELETE /ric/v1/xapps/hw", "POST /ric/v1/xapps"}, a.changes())
+
+	alarms := mapTree{}
+	p.reconciler.addAlarms(alarms)
+	assert.Empty(t, alarms)
+}
+
+func TestReconcileImport(t *testing.T) {
+	a := newAppmgr(t, deployedXapps)
+	p, m := newReconcileNbi(t, PolicyImport)
+
+	drifts, err := p.Reconciler().Reconcile()
+	assert.Nil(t, err)
+	assert.Equal(t, "updated", drifts[0].Action)
+	assert.Equal(t, "imported", drifts[1].Action)
+	assert.Equal(t, "removed", drifts[2].Action)
+	assert.Empty(t, a.changes())
+
+	xpath := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp"
+	assert.Equal(t, "2.0.0", m.GetConfig(xpath+"[name='anr']/version").Find(xpath+"[name='anr']/version").Value)
+	assert.True(t, m.HasItem(xpath+"[name='hw']"))
+	assert.False(t, m.HasItem(xpath+"[name='kpimon']"))
+
+	drifts, err = p.Reconciler().Reconcile()
+	assert.Nil(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestReconcileAppmgrUnreachable(t *testing.T) {
+	p, _ := newReconcileNbi(t, PolicyReport)
+
+	_, err := p.Reconciler().Reconcile()
+	assert.True(t, sbi.IsUnreachable(err))
+
+	tree := mapTree{}
+	assert.Nil(t, p.Reconciler().GetOperData(driftXpath, tree))
+	assert.NotEmpty(t, tree[driftXpath+"/last-error"])
+}
+
+func TestReconcilerRunAndStop(t *testing.T) {
+	p, _ := newReconcileNbi(t, PolicyReport)
+	r := NewReconciler(p, "unknown", 10*time.Millisecond)
+	assert.Equal(t, PolicyReport, r.policy)
+
+	done := make(chan struct{})
+	go func() {
+		r.Run()
+		close(done)
+	}()
+	r.Stop()
+	r.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("reconciler did not stop")
+	}
+}
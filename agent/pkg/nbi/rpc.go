@@ -0,0 +1,218 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const xappDescModule = "o-ran-sc-ric-xapp-desc-v1"
+
+type rpcEntry struct {
+	xpath   string
+	handler RPCHandler
+}
+
+// RegisterRPC binds a handler to the RPC or action at xpath. Handlers
+// registered before Start are subscribed to sysrepo automatically.
+func (n *Nbi) RegisterRPC(xpath string, h RPCHandler) error {
+	if xpath == "" || h == nil {
+		return fmt.Errorf("invalid RPC registration: xpath='%s'", xpath)
+	}
+
+	for i, e := range n.rpcs {
+		if e.xpath == xpath {
+			n.rpcs[i].handler = h
+			return nil
+		}
+	}
+	n.rpcs = append(n.rpcs, rpcEntry{xpath, h})
+	return nil
+}
+
+func (n *Nbi) SubscribeRPCs() bool {
+	for _, e := range n.rpcs {
+		if err := n.ds.SubscribeRPC(e.xpath, e.handler); err != nil {
+			log.Error("NBI: %v", err)
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Nbi) registerDefaultRPCs() {
+	xpath := func(name string) string {
+		return fmt.Sprintf("/%s:%s", xappDescModule, name)
+	}
+	n.RegisterRPC(xpath("restart-xapp"), n.restartXapp)
+	n.RegisterRPC(xpath("scale-xapp"), n.scaleXapp)
+	n.RegisterRPC(xpath("redeploy-xapp"), n.redeployXapp)
+	n.RegisterRPC(xpath("get-xapp-logs"), n.getXappLogs)
+	n.RegisterRPC(xpath("check-xapp-health"), n.checkXappHealth)
+	n.RegisterRPC(xpath("list-config-versions"), n.listConfigVersions)
+	n.RegisterRPC(xpath("diff-config-versions"), n.diffConfigVersions)
+	n.RegisterRPC(xpath("rollback-xapp-config"), n.rollbackXappConfig)
+	n.RegisterRPC(xpath("export-config"), n.exportConfig)
+	n.RegisterRPC(xpath("import-config"), n.importConfig)
+}
+
+// xappRef returns the name and namespace of the xApp an RPC operates on
+func (n *Nbi) xappRef(input map[string]string) (string, string, error) {
+	name := input["name"]
+	if name == "" {
+		return "", "", fmt.Errorf("missing xApp name")
+	}
+	namespace, err := n.namespaces.Resolve(input["namespace"])
+	if err != nil {
+		return "", "", err
+	}
+	return name, namespace, nil
+}
+
+func (n *Nbi) restartXapp(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.authorize(o, AccessLifecycle, namespace, name, xpath); err != nil {
+		return nil, err
+	}
+	return nil, sbiClient.RestartXapp(name, namespace)
+}
+
+func (n *Nbi) scaleXapp(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := strconv.ParseUint(input["replicas"], 10, 31)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replicas '%s'", input["replicas"])
+	}
+	if err := n.authorize(o, AccessLifecycle, namespace, name, xpath); err != nil {
+		return nil, err
+	}
+	return nil, sbiClient.ScaleXapp(name, namespace, int(replicas))
+}
+
+// redeployOriginator makes the commits recording the release name and
+// version an xApp was redeployed with. The RPC has made the SBI calls.
+const redeployOriginator = "redeploy-xapp"
+
+// redeployXapp takes the release name and version missing from the input
+// from the xApp descriptor in the running configuration. A new release name
+// or version is committed to the descriptor once the xApp is redeployed, so
+// the running configuration keeps describing what is deployed. A dry run
+// only returns the SBI call it would make.
+func (n *Nbi) redeployXapp(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+
+	release, version := input["release-name"], input["version"]
+	store, _ := n.ds.(Store)
+	path := fmt.Sprintf("/%s:ric/xapps/xapp[name='%s']", xappDescModule, name)
+	var configured *Node
+	if store != nil {
+		defer lockEdits(store)()
+		configured = store.GetConfig(path).Find(path)
+	}
+	if configured != nil {
+		if release == "" {
+			release = leafValue(configured, "release-name")
+		}
+		if version == "" {
+			version = leafValue(configured, "version")
+		}
+		if leaf := configured.Child("namespace"); leaf != nil && input["namespace"] == "" {
+			namespace = leaf.Value
+		}
+	}
+	if err := n.namespaces.Check(namespace); err != nil {
+		return nil, err
+	}
+
+	if err := n.authorize(o, AccessLifecycle, namespace, name, xpath); err != nil {
+		return nil, err
+	}
+
+	if input["dry-run"] == "true" {
+		call := fmt.Sprintf("redeploy xApp '%s' with release name '%s' and version '%s'", name, release, version)
+		return map[string]string{"sbi-calls": call}, nil
+	}
+
+	desc := sbiClient.BuildXappDescriptor(name, namespace, release, version)
+	if err := sbiClient.RedeployXapp(desc); err != nil {
+		return nil, err
+	}
+	if configured == nil || release == leafValue(configured, "release-name") && version == leafValue(configured, "version") {
+		return nil, nil
+	}
+
+	store.DiscardChanges()
+	err = store.SetItem(path+"/release-name", release)
+	if err == nil {
+		err = store.SetItem(path+"/version", version)
+	}
+	if err == nil {
+		err = store.ApplyChangesAs(Originator{Name: redeployOriginator, User: o.User, Session: o.Session})
+	} else {
+		store.DiscardChanges()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("xApp '%s' redeployed, but its descriptor was not updated: %v", name, err)
+	}
+	return nil, nil
+}
+
+func (n *Nbi) getXappLogs(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := 100
+	if value, ok := input["lines"]; ok {
+		if lines, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid lines '%s'", value)
+		}
+	}
+
+	logs, err := sbiClient.GetXappLogs(name, namespace, lines)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"logs": logs}, nil
+}
+
+func (n *Nbi) checkXappHealth(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	name, namespace, err := n.xappRef(input)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := sbiClient.CheckXappHealth(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"health": pod.Health, "status": pod.Status}, nil
+}
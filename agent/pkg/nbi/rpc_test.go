@@ -0,0 +1,184 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterRPC(t *testing.T) {
+	p := &Nbi{}
+	handler := func(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+		return nil, nil
+	}
+	assert.Nil(t, p.RegisterRPC("/mod-a:op", handler))
+	assert.Nil(t, p.RegisterRPC("/mod-a:op", handler))
+	assert.Equal(t, 1, len(p.rpcs))
+	assert.NotNil(t, p.RegisterRPC("", handler))
+	assert.NotNil(t, p.RegisterRPC("/mod-a:op", nil))
+}
+
+func TestSubscribeRPCs(t *testing.T) {
+	m := NewMemDatastore()
+	p := &Nbi{ds: m}
+	p.registerDefaultRPCs()
+	assert.True(t, p.SubscribeRPCs())
+
+	commands := mockCommands(t, "")
+	_, err := m.CallRPC("/o-ran-sc-ric-xapp-desc-v1:restart-xapp", map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(*commands))
+
+	_, err = m.CallRPC("/o-ran-sc-ric-xapp-desc-v1:unknown", nil)
+	assert.NotNil(t, err)
+}
+
+func TestRestartXappRPC(t *testing.T) {
+	commands := mockCommands(t, "")
+
+	_, err := callRPC("restart-xapp", map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/usr/local/bin/kubectl rollout restart deployment/ricxapp-ueec -n ricxapp"}, *commands)
+
+	_, err = callRPC("restart-xapp", map[string]string{})
+	assert.NotNil(t, err)
+}
+
+func TestScaleXappRPC(t *testing.T) {
+	commands := mockCommands(t, "")
+
+	input := map[string]string{"name": "ueec", "namespace": "test", "replicas": "2"}
+	_, err := callRPC("scale-xapp", input)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/usr/local/bin/kubectl scale deployment/test-ueec --replicas=2 -n test"}, *commands)
+
+	input["replicas"] = "-2"
+	_, err = callRPC("scale-xapp", input)
+	assert.NotNil(t, err)
+}
+
+func TestGetXappLogsRPC(t *testing.T) {
+	commands := mockCommands(t, "started\n")
+
+	output, err := callRPC("get-xapp-logs", map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"logs": "started\n"}, output)
+	assert.Contains(t, (*commands)[0], "--tail=100")
+
+	output, err = callRPC("get-xapp-logs", map[string]string{"name": "ueec", "lines": "5"})
+	assert.Nil(t, err)
+	assert.Contains(t, (*commands)[1], "--tail=5")
+}
+
+func TestCheckXappHealthRPC(t *testing.T) {
+	mockCommands(t, "ricxapp-ueec-7bfdd587db-2jl9j      0/1     CrashLoopBackOff   53         29d\n")
+
+	output, err := callRPC("check-xapp-health", map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"health": "unhealthy", "status": "CrashLoopBackOff"}, output)
+}
+
+func TestRedeployXappRPC(t *testing.T) {
+	a := newAppmgr(t, nil)
+
+	xpath := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']"
+	ds.SetItem(xpath+"/release-name", "ueec-xapp")
+	ds.SetItem(xpath+"/version", "0.0.1")
+	assert.Nil(t, ds.ApplyChanges())
+	defer func() {
+		ds.DeleteItem(xpath)
+		ds.ApplyChanges()
+	}()
+	changes := len(a.changes())
+
+	_, err := callRPC("redeploy-xapp", map[string]string{"name": "ueec"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"DELETE /ric/v1/xapps/ueec-xapp", "POST /ric/v1/xapps"}, a.changes()[changes:])
+}
+
+func TestRedeployXappNewVersion(t *testing.T) {
+	a := newAppmgr(t, nil)
+	p, _ := newCandidateNbi(t)
+	store := p.Datastore().(Store)
+
+	xpath := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']"
+	store.SetItem(xpath+"/release-name", "ueec-xapp")
+	store.SetItem(xpath+"/version", "0.0.1")
+	assert.Nil(t, store.ApplyChanges())
+
+	o := Originator{Name: "netconf", User: "admin"}
+	output, err := p.redeployXapp(o, "", map[string]string{"name": "ueec", "version": "0.0.2", "dry-run": "true"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"sbi-calls": "redeploy xApp 'ueec' with release name 'ueec-xapp' and version '0.0.2'"}, output)
+	assert.Equal(t, []string{"POST /ric/v1/xapps"}, a.changes())
+
+	// the new version is committed without deploying the xApp once more
+	_, err = p.redeployXapp(o, "", map[string]string{"name": "ueec", "version": "0.0.2"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"POST /ric/v1/xapps", "DELETE /ric/v1/xapps/ueec-xapp", "POST /ric/v1/xapps"}, a.changes())
+	assert.Equal(t, "0.0.2", store.GetConfig(xpath).Find(xpath+"/version").Value)
+	if records := p.Audit().Records(); assert.NotEmpty(t, records) {
+		rec := records[len(records)-1]
+		assert.Equal(t, redeployOriginator, rec.Originator)
+		assert.Equal(t, "admin", rec.User)
+		assert.Empty(t, rec.SBICalls)
+	}
+}
+
+func TestLifecycleRPCFailure(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (string, error) {
+		return "", errors.New("kubectl failed")
+	}
+
+	_, err := callRPC("restart-xapp", map[string]string{"name": "ueec"})
+	assert.NotNil(t, err)
+	_, err = callRPC("check-xapp-health", map[string]string{"name": "ueec"})
+	assert.NotNil(t, err)
+}
+
+// callRPC runs the handler n registered for an xapp-desc RPC
+func callRPC(name string, input map[string]string) (map[string]string, error) {
+	for _, e := range n.rpcs {
+		if e.xpath == "/o-ran-sc-ric-xapp-desc-v1:"+name {
+			return e.handler(Originator{}, e.xpath, input)
+		}
+	}
+	return nil, fmt.Errorf("RPC '%s' not registered", name)
+}
+
+// mockCommands replaces kubectl with a fake returning output and records
+// the commands run until the test ends.
+func mockCommands(t *testing.T, output string) *[]string {
+	var commands []string
+	oldCmdExec := sbi.CommandExec
+	t.Cleanup(func() { sbi.CommandExec = oldCmdExec })
+	sbi.CommandExec = func(args string) (string, error) {
+		commands = append(commands, args)
+		return output, nil
+	}
+	return &commands
+}
@@ -0,0 +1,155 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/errors"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+	"github.com/spf13/viper"
+)
+
+// schemaError is one violation of an xApp config schema, path being the
+// dotted path of the offending member
+type schemaError struct {
+	path    string
+	message string
+}
+
+// checkXappConfig validates the configuration of an xApp against the config
+// schema the appmgr has for it. xpath is the node holding the configuration,
+// nodes tells if its members are the YANG nodes below it, see
+// validateXappConfig.
+func (n *Nbi) checkXappConfig(xpath, name, namespace string, config interface{}, nodes bool) error {
+	schema, err := n.xappConfigSchema(xpath, name, namespace)
+	if schema == nil {
+		return err
+	}
+	return validateXappConfig(xpath, name, schema, config, nodes)
+}
+
+// xappConfigSchema returns the config schema the appmgr has for an xApp.
+// Without one it returns nil, and an error if xapps.requireConfigSchema is
+// set.
+func (n *Nbi) xappConfigSchema(xpath, name, namespace string) (*spec.Schema, error) {
+	schema, err := sbiClient.GetXappConfigSchema(name, namespace)
+	if err != nil || schema == "" {
+		if viper.GetBool("xapps.requireConfigSchema") {
+			msg := fmt.Sprintf("no config schema for xApp '%s' in namespace '%s'", name, namespace)
+			return nil, &ChangeError{Xpath: xpath, Message: msg, Err: err}
+		}
+		log.Warn("NBI: config of xApp '%s' not validated, no schema: %v", name, err)
+		return nil, nil
+	}
+	return parseConfigSchema(xpath, name, schema)
+}
+
+func parseConfigSchema(xpath, name, schema string) (*spec.Schema, error) {
+	var s spec.Schema
+	if err := json.Unmarshal([]byte(schema), &s); err != nil {
+		return nil, &ChangeError{Xpath: xpath, Message: fmt.Sprintf("invalid config schema of xApp '%s': %v", name, err), Err: err}
+	}
+	return &s, nil
+}
+
+// validateXappConfig rejects config unless it matches schema. The first
+// violation, by path, is reported on the node of its member if the members
+// of config are the YANG nodes below xpath, as with the control container.
+// Otherwise xpath is a leaf holding config as a JSON string, on which the
+// violation is reported with the JSON pointer of its member.
+func validateXappConfig(xpath, name string, schema *spec.Schema, config interface{}, nodes bool) error {
+	result := validate.NewSchemaValidator(schema, nil, "", strfmt.Default).Validate(config)
+	if result.IsValid() {
+		return nil
+	}
+
+	var violations []schemaError
+	for _, err := range result.Errors {
+		violations = append(violations, schemaErrors(err)...)
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].path < violations[j].path })
+
+	first := violations[0]
+	msg := fmt.Sprintf("invalid config of xApp '%s': %s", name, first.message)
+	switch {
+	case first.path == "":
+	case nodes:
+		xpath += "/" + strings.ReplaceAll(first.path, ".", "/")
+	default:
+		msg = fmt.Sprintf("invalid config of xApp '%s' at %s: %s", name, jsonPointer(first.path), first.message)
+	}
+	if len(violations) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(violations)-1)
+	}
+	return &ChangeError{Xpath: xpath, Tag: "invalid-value", Message: msg, Err: result.AsError()}
+}
+
+// jsonPointer turns the dotted path of a member into its JSON pointer
+func jsonPointer(path string) string {
+	escape := strings.NewReplacer("~", "~0", "/", "~1")
+	var b strings.Builder
+	for _, name := range strings.Split(path, ".") {
+		b.WriteString("/" + escape.Replace(name))
+	}
+	return b.String()
+}
+
+// schemaProperty returns the schema of the member at path of a document
+// described by schema, nil if it is not described
+func schemaProperty(schema *spec.Schema, path []string) *spec.Schema {
+	for _, name := range path {
+		if schema == nil {
+			return nil
+		}
+		prop, ok := schema.Properties[name]
+		if !ok {
+			return nil
+		}
+		schema = &prop
+	}
+	return schema
+}
+
+// schemaErrors flattens a validation error of go-openapi
+func schemaErrors(err error) []schemaError {
+	switch e := err.(type) {
+	case *errors.CompositeError:
+		var result []schemaError
+		for _, err := range e.Errors {
+			result = append(result, schemaErrors(err)...)
+		}
+		return result
+	case *errors.Validation:
+		path := strings.Trim(e.Name, ".")
+		if key, ok := e.Value.(string); ok && e.Code() == errors.UnallowedPropertyCode {
+			path = strings.TrimPrefix(path+"."+key, ".")
+		}
+		msg := strings.TrimPrefix(strings.Replace(e.Error(), " in body", "", 1), ".")
+		return []schemaError{{path: path, message: msg}}
+	default:
+		return []schemaError{{message: err.Error()}}
+	}
+}
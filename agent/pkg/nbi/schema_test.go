@@ -0,0 +1,125 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	apimodel "gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/appmgrmodel"
+	"github.com/stretchr/testify/assert"
+)
+
+var ueecSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["active"],
+	"properties": {
+		"active": {"type": "boolean"},
+		"interfaceId": {
+			"type": "object",
+			"properties": {
+				"globalENBId": {
+					"type": "object",
+					"properties": {
+						"plmnId": {"type": "string", "pattern": "^[0-9]{5,6}$"},
+						"eNBId": {"type": "string"}
+					},
+					"additionalProperties": false
+				}
+			}
+		}
+	}
+}`
+
+func TestValidateXappConfig(t *testing.T) {
+	xpath := "/o-ran-sc-ric-ueec-config-v1:ric/config/control"
+	config := func(data string) interface{} {
+		var result interface{}
+		assert.Nil(t, json.Unmarshal([]byte(data), &result))
+		return result
+	}
+
+	validateConfig := func(schema string, config interface{}) error {
+		s, err := parseConfigSchema(xpath, "ueec", schema)
+		if err != nil {
+			return err
+		}
+		return validateXappConfig(xpath, "ueec", s, config, true)
+	}
+
+	assert.Nil(t, validateConfig(ueecSchema, config(`{"active": true, "interfaceId": {"globalENBId": {"plmnId": "12345"}}}`)))
+
+	var cerr *ChangeError
+	err := validateConfig(ueecSchema, config(`{"active": "yes"}`))
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, xpath+"/active", cerr.Xpath)
+		assert.Equal(t, "invalid-value", cerr.Tag)
+		assert.Equal(t, `invalid config of xApp 'ueec': active must be of type boolean: "string"`, cerr.Message)
+	}
+
+	err = validateConfig(ueecSchema, config(`{"active": true, "interfaceId": {"globalENBId": {"plmnId": "1", "nbId": "2"}}}`))
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, xpath+"/interfaceId/globalENBId/nbId", cerr.Xpath)
+		assert.Contains(t, cerr.Message, "(and 1 more)")
+	}
+
+	err = validateConfig(ueecSchema, config(`{}`))
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, xpath+"/active", cerr.Xpath)
+		assert.Equal(t, "invalid config of xApp 'ueec': active is required", cerr.Message)
+	}
+
+	// the members of JSON in a string leaf are no YANG nodes
+	s, err := parseConfigSchema(xpath, "ueec", ueecSchema)
+	assert.Nil(t, err)
+	err = validateXappConfig(xpath, "ueec", s, config(`{"active": true, "interfaceId": {"globalENBId": {"plmnId": "1"}}}`), false)
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, xpath, cerr.Xpath)
+		assert.Equal(t, "invalid config of xApp 'ueec' at /interfaceId/globalENBId/plmnId: interfaceId.globalENBId.plmnId should match '^[0-9]{5,6}$'", cerr.Message)
+	}
+	assert.Equal(t, "/a~1b/c~0d", jsonPointer("a/b.c~d"))
+
+	err = validateConfig("{", config(`{}`))
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, xpath, cerr.Xpath)
+	}
+}
+
+func TestConfigRejectedBySchema(t *testing.T) {
+	ts := CreateHTTPServer(t, "PUT", "/ric/v1/config", 8080, http.StatusOK, apimodel.ConfigValidationErrors{})
+	defer ts.Close()
+	commands := mockCommands(t, ueecSchema)
+
+	var cerr *ChangeError
+	err := n.ManageConfigmaps("o-ran-sc-ric-ueec-config-v1", XappConfig, OpModified)
+	if assert.True(t, errors.As(err, &cerr)) {
+		assert.Equal(t, "/o-ran-sc-ric-ueec-config-v1:ric/config/control/interfaceId/globalENBId/plmnId", cerr.Xpath)
+		assert.Equal(t, "invalid-value", cerr.Tag)
+		assert.Equal(t, "invalid config of xApp 'ueec': interfaceId.globalENBId.plmnId should match '^[0-9]{5,6}$'", cerr.Message)
+	}
+	assert.Equal(t, []string{`/usr/local/bin/kubectl get configmap configmap-ricxapp-ueec-appconfig -n ricxapp -o jsonpath='{.data.schema\.json}'`}, *commands)
+
+	valid := strings.Replace(XappConfig, `"1234"`, `"12345"`, 1)
+	assert.Nil(t, n.ManageConfigmaps("o-ran-sc-ric-ueec-config-v1", valid, OpModified))
+}
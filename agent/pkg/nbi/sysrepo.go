@@ -0,0 +1,598 @@
+//go:build cgo && !nosysrepo
+
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+/*
+#cgo LDFLAGS: -lsysrepo -lyang
+
+#include <stdio.h>
+#include <stdlib.h>
+#include <limits.h>
+#include <sysrepo.h>
+#include <sysrepo/values.h>
+#include "helper.h"
+*/
+import "C"
+
+var srDatastore *SysrepoDatastore
+
+// SysrepoDatastore is the libsysrepo backed Datastore
+type SysrepoDatastore struct {
+	mu           sync.Mutex
+	editMu       sync.Mutex
+	connection   *C.sr_conn_ctx_t
+	session      *C.sr_session_ctx_t
+	operSession  *C.sr_session_ctx_t
+	subscription *C.sr_subscription_ctx_t
+
+	// RPCs are sent on a session and served by a subscription of their own,
+	// whose thread is not the one running the module change callbacks, so
+	// an RPC handler can commit, e.g. an import-config
+	rpcSession      *C.sr_session_ctx_t
+	rpcSubscription *C.sr_subscription_ctx_t
+
+	changeHandlers map[string]ModuleChangeHandler
+	operHandlers   map[string]OperDataHandler
+	rpcHandlers    map[string]RPCHandler
+
+	// applying and calling are the originators of the commit in progress on
+	// session and of the RPC in progress on rpcSession, whose callbacks
+	// sysrepo runs with our own user and no NETCONF session
+	origMu   sync.Mutex
+	applying Originator
+	calling  Originator
+	rpcMu    sync.Mutex
+}
+
+var _ Store = (*SysrepoDatastore)(nil)
+
+func NewSysrepoDatastore() *SysrepoDatastore {
+	srDatastore = &SysrepoDatastore{
+		changeHandlers: make(map[string]ModuleChangeHandler),
+		operHandlers:   make(map[string]OperDataHandler),
+		rpcHandlers:    make(map[string]RPCHandler),
+	}
+	return srDatastore
+}
+
+func newDefaultDatastore() Datastore {
+	return NewSysrepoDatastore()
+}
+
+func (s *SysrepoDatastore) Connect() error {
+	rc := C.sr_connect(0, &s.connection)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_connect failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+
+	rc = C.sr_session_start(s.connection, C.SR_DS_RUNNING, &s.session)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_session_start failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+
+	rc = C.sr_session_start(s.connection, C.SR_DS_OPERATIONAL, &s.operSession)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_session_start failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+
+	rc = C.sr_session_start(s.connection, C.SR_DS_RUNNING, &s.rpcSession)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_session_start failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+func (s *SysrepoDatastore) Disconnect() {
+	C.sr_unsubscribe(s.rpcSubscription)
+	C.sr_unsubscribe(s.subscription)
+	C.sr_session_stop(s.rpcSession)
+	C.sr_session_stop(s.operSession)
+	C.sr_session_stop(s.session)
+	C.sr_disconnect(s.connection)
+}
+
+func (s *SysrepoDatastore) SubscribeModuleChange(module string, handler ModuleChangeHandler) error {
+	modName := C.CString(module)
+	defer C.free(unsafe.Pointer(modName))
+
+	s.changeHandlers[module] = handler
+	rc := C.sr_module_change_subscribe(s.session, modName, nil, C.sr_module_change_cb(C.module_change_cb), nil, 0, 0, &s.subscription)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_module_change_subscribe failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+func (s *SysrepoDatastore) SubscribeOperData(module, xpath string, handler OperDataHandler) error {
+	mod := C.CString(module)
+	path := C.CString(xpath)
+	defer C.free(unsafe.Pointer(mod))
+	defer C.free(unsafe.Pointer(path))
+
+	s.operHandlers[module+xpath] = handler
+	rc := C.sr_oper_get_items_subscribe(s.session, mod, path, C.sr_oper_get_items_cb(C.gnb_status_cb), nil, 0, &s.subscription)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_oper_get_items_subscribe failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+func (s *SysrepoDatastore) SubscribeRPC(xpath string, handler RPCHandler) error {
+	path := C.CString(xpath)
+	defer C.free(unsafe.Pointer(path))
+
+	s.rpcHandlers[xpath] = handler
+	rc := C.sr_rpc_subscribe(s.rpcSession, path, C.sr_rpc_cb(C.rpc_cb), nil, 0, 0, &s.rpcSubscription)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_rpc_subscribe failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+func (s *SysrepoDatastore) CallRPC(xpath string, input map[string]string) (map[string]string, error) {
+	return s.CallRPCAs(Originator{}, xpath, input)
+}
+
+// CallRPCAs invokes the RPC at xpath on behalf of o
+func (s *SysrepoDatastore) CallRPCAs(o Originator, xpath string, input map[string]string) (map[string]string, error) {
+	s.rpcMu.Lock()
+	defer s.rpcMu.Unlock()
+
+	s.origMu.Lock()
+	s.calling = o
+	s.origMu.Unlock()
+	defer func() {
+		s.origMu.Lock()
+		s.calling = Originator{}
+		s.origMu.Unlock()
+	}()
+
+	path := C.CString(xpath)
+	defer C.free(unsafe.Pointer(path))
+
+	values, count, err := srNewValues(xpath, input)
+	if err != nil {
+		return nil, err
+	}
+	defer C.sr_free_values(values, count)
+
+	var output *C.sr_val_t
+	var outputCount C.size_t
+	rc := C.sr_rpc_send(s.rpcSession, path, values, count, 0, &output, &outputCount)
+	if C.SR_ERR_OK != rc {
+		return nil, fmt.Errorf("sr_rpc_send failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	defer C.sr_free_values(output, outputCount)
+	return srLeafValues(xpath, output, outputCount), nil
+}
+
+// Notify is called from the job goroutines, so it builds and sends the
+// notification on session under mu like the edits
+func (s *SysrepoDatastore) Notify(xpath string, leaves map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(leaves))
+	for name := range leaves {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parent *C.char
+	tree := &srOperDataTree{session: s.session, parent: &parent}
+	for _, name := range names {
+		tree.CreateNewElement(xpath, name, leaves[name])
+	}
+	if len(names) == 0 {
+		path := C.CString(xpath)
+		defer C.free(unsafe.Pointer(path))
+		C.create_new_path(s.session, &parent, path, nil)
+	}
+	if parent == nil {
+		return fmt.Errorf("failed to build notification '%s'", xpath)
+	}
+
+	rc := C.send_notification(s.session, &parent)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_event_notif_send_tree failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+func (s *SysrepoDatastore) GetItems(xpath string) (*Node, error) {
+	return s.getItems(s.operSession, xpath)
+}
+
+func (s *SysrepoDatastore) GetConfig(xpath string) *Node {
+	tree, err := s.getItems(s.session, xpath)
+	if err != nil {
+		log.Error("NBI: %v", err)
+		return NewTree()
+	}
+	return tree
+}
+
+func (s *SysrepoDatastore) HasItem(xpath string) bool {
+	tree, err := s.getItems(s.session, xpath)
+	return err == nil && tree.Find(xpath) != nil
+}
+
+// getItems reads every node below xpath, or of every module if xpath is
+// "/", into a data tree
+func (s *SysrepoDatastore) getItems(session *C.sr_session_ctx_t, xpath string) (*Node, error) {
+	query := xpath + "//."
+	if xpath == "" || xpath == "/" {
+		query = "/*//."
+	}
+	path := C.CString(query)
+	defer C.free(unsafe.Pointer(path))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var values *C.sr_val_t
+	var count C.size_t
+	rc := C.sr_get_items(session, path, 0, 0, &values, &count)
+	if C.SR_ERR_NOT_FOUND == rc {
+		return NewTree(), nil
+	}
+	if C.SR_ERR_OK != rc {
+		return nil, fmt.Errorf("sr_get_items failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	defer C.sr_free_values(values, count)
+
+	tree := NewTree()
+	for i := 0; i < int(count); i++ {
+		val := C.get_val(values, C.size_t(i))
+		p := C.GoString(val.xpath)
+
+		var err error
+		if val._type > C.SR_CONTAINER_PRESENCE_T && val._type != C.SR_NOTIFICATION_T {
+			err = tree.Set(p, srValueString(val))
+		} else {
+			_, err = tree.Create(p)
+		}
+		if err != nil {
+			log.Error("NBI: skipping '%s': %v", p, err)
+		}
+	}
+	return tree, nil
+}
+
+func (s *SysrepoDatastore) SetItem(xpath, value string) error {
+	path := C.CString(xpath)
+	defer C.free(unsafe.Pointer(path))
+
+	var cValue *C.char
+	if value != "" {
+		cValue = C.CString(value)
+		defer C.free(unsafe.Pointer(cValue))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rc := C.sr_set_item_str(s.session, path, cValue, nil, C.SR_EDIT_DEFAULT)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_set_item_str failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+func (s *SysrepoDatastore) DeleteItem(xpath string) error {
+	path := C.CString(xpath)
+	defer C.free(unsafe.Pointer(path))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rc := C.sr_delete_item(s.session, path, C.SR_EDIT_DEFAULT)
+	if C.SR_ERR_OK != rc {
+		return fmt.Errorf("sr_delete_item failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+func (s *SysrepoDatastore) setApplying(o Originator) {
+	s.origMu.Lock()
+	defer s.origMu.Unlock()
+
+	s.applying = o
+}
+
+func (s *SysrepoDatastore) DiscardChanges() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	C.sr_discard_changes(s.session)
+}
+
+func (s *SysrepoDatastore) EditLock() sync.Locker {
+	return &s.editMu
+}
+
+func (s *SysrepoDatastore) ApplyChanges() error {
+	return s.ApplyChangesAs(Originator{})
+}
+
+// ApplyChangesAs commits the staged edits on behalf of o
+func (s *SysrepoDatastore) ApplyChangesAs(o Originator) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setApplying(o)
+	defer s.setApplying(Originator{})
+
+	rc := C.sr_apply_changes(s.session, 0, 1)
+	if C.SR_ERR_OK != rc {
+		C.sr_discard_changes(s.session)
+		return fmt.Errorf("sr_apply_changes failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	return nil
+}
+
+//export nbiModuleChangeCB
+func nbiModuleChangeCB(session *C.sr_session_ctx_t, module *C.char, xpath *C.char, event C.sr_event_t, reqId C.int) C.int {
+	changedModule := C.GoString(module)
+
+	handler, ok := srDatastore.changeHandlers[changedModule]
+	if !ok {
+		return C.SR_ERR_OK
+	}
+
+	err := handler(&srChangeSession{session}, changedModule, C.GoString(xpath), Event(event), int(reqId))
+	if err != nil {
+		srSetError(session, err)
+		return C.SR_ERR_OPERATION_FAILED
+	}
+	return C.SR_ERR_OK
+}
+
+// srSetError passes the reason of a rejected change, and the offending node
+// of a ChangeError, to the client of the session
+func srSetError(session *C.sr_session_ctx_t, err error) {
+	var path *C.char
+	var cerr *ChangeError
+	if errors.As(err, &cerr) && cerr.Xpath != "" {
+		path = C.CString(cerr.Xpath)
+		defer C.free(unsafe.Pointer(path))
+	}
+
+	msg := C.CString(err.Error())
+	defer C.free(unsafe.Pointer(msg))
+
+	if rc := C.set_error(session, path, msg); rc != C.SR_ERR_OK {
+		log.Error("sr_set_error failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+}
+
+//export nbiGnbStateCB
+func nbiGnbStateCB(session *C.sr_session_ctx_t, module *C.char, xpath *C.char, rpath *C.char, reqid C.uint32_t, parent **C.char) C.int {
+	mod := C.GoString(module)
+	path := C.GoString(xpath)
+	log.Info("nbiGnbStateCB: module='%s' xpath='%s' rpath='%s' [id=%d]", mod, path, C.GoString(rpath), reqid)
+
+	handler, ok := srDatastore.operHandlers[mod+path]
+	if !ok {
+		log.Error("nbiGnbStateCB: no handler for module='%s' xpath='%s'", mod, path)
+		return C.SR_ERR_OK
+	}
+
+	if err := handler(mod, path, &srOperDataTree{session: session, parent: parent}); err != nil {
+		log.Error("nbiGnbStateCB: %v", err)
+	}
+	return C.SR_ERR_OK
+}
+
+//export nbiRPCCB
+func nbiRPCCB(session *C.sr_session_ctx_t, opPath *C.char, input *C.sr_val_t, inputCount C.size_t, output **C.sr_val_t, outputCount *C.size_t) C.int {
+	path := C.GoString(opPath)
+	log.Info("nbiRPCCB: xpath='%s'", path)
+
+	handler, ok := srDatastore.rpcHandlers[path]
+	if !ok {
+		log.Error("nbiRPCCB: no handler for xpath='%s'", path)
+		return C.SR_ERR_UNSUPPORTED
+	}
+
+	srDatastore.origMu.Lock()
+	calling := srDatastore.calling
+	srDatastore.origMu.Unlock()
+
+	result, err := handler(srOriginator(session, calling), path, srLeafValues(path, input, inputCount))
+	if err != nil {
+		log.Error("nbiRPCCB: %v", err)
+		return C.SR_ERR_OPERATION_FAILED
+	}
+
+	values, count, err := srNewValues(path, result)
+	if err != nil {
+		log.Error("nbiRPCCB: %v", err)
+		return C.SR_ERR_OPERATION_FAILED
+	}
+	*output = values
+	*outputCount = count
+	return C.SR_ERR_OK
+}
+
+// srLeafValues maps the leaves of an RPC input or output by relative path
+func srLeafValues(xpath string, values *C.sr_val_t, count C.size_t) map[string]string {
+	result := make(map[string]string)
+	for i := 0; i < int(count); i++ {
+		val := C.get_val(values, C.size_t(i))
+		if val._type <= C.SR_CONTAINER_PRESENCE_T || val._type == C.SR_NOTIFICATION_T {
+			continue
+		}
+		name := strings.TrimPrefix(C.GoString(val.xpath), xpath+"/")
+		result[name] = srValueString(val)
+	}
+	return result
+}
+
+// srNewValues allocates the sysrepo values of RPC leaves given by relative path
+func srNewValues(xpath string, leaves map[string]string) (*C.sr_val_t, C.size_t, error) {
+	if len(leaves) == 0 {
+		return nil, 0, nil
+	}
+
+	names := make([]string, 0, len(leaves))
+	for name := range leaves {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var values *C.sr_val_t
+	count := C.size_t(len(names))
+	if rc := C.sr_new_values(count, &values); C.SR_ERR_OK != rc {
+		return nil, 0, fmt.Errorf("sr_new_values failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+
+	for i, name := range names {
+		path := C.CString(xpath + "/" + name)
+		value := C.CString(leaves[name])
+		rc := C.set_str_val(values, C.size_t(i), path, value)
+		C.free(unsafe.Pointer(path))
+		C.free(unsafe.Pointer(value))
+		if C.SR_ERR_OK != rc {
+			C.sr_free_values(values, count)
+			return nil, 0, fmt.Errorf("failed to set RPC value '%s': %s", name, C.GoString(C.sr_strerror(rc)))
+		}
+	}
+	return values, count, nil
+}
+
+// srChangeSession wraps the sysrepo session of a module change callback
+type srChangeSession struct {
+	session *C.sr_session_ctx_t
+}
+
+func (s *srChangeSession) GetChanges(xpath string) ([]Change, error) {
+	path := C.CString(xpath)
+	defer C.free(unsafe.Pointer(path))
+
+	var it *C.sr_change_iter_t
+	rc := C.sr_get_changes_iter(s.session, path, &it)
+	if C.SR_ERR_OK != rc {
+		return nil, fmt.Errorf("sr_get_changes_iter failed: %s", C.GoString(C.sr_strerror(rc)))
+	}
+	defer C.sr_free_change_iter(it)
+
+	var changes []Change
+	var oper C.sr_change_oper_t
+	var oldValue, newValue *C.sr_val_t
+	for C.sr_get_change_next(s.session, it, &oper, &oldValue, &newValue) == C.SR_ERR_OK {
+		c := Change{Oper: Operation(oper)}
+		val := newValue
+		if oper == C.SR_OP_DELETED {
+			val = oldValue
+		}
+		if val != nil {
+			c.Xpath = C.GoString(val.xpath)
+			c.Leaf = val._type > C.SR_CONTAINER_PRESENCE_T && val._type != C.SR_NOTIFICATION_T
+		}
+		c.OldValue = srValueString(oldValue)
+		c.NewValue = srValueString(newValue)
+		changes = append(changes, c)
+
+		C.sr_free_val(oldValue)
+		C.sr_free_val(newValue)
+	}
+	return changes, nil
+}
+
+func (s *srChangeSession) Originator() Originator {
+	srDatastore.origMu.Lock()
+	applying := srDatastore.applying
+	srDatastore.origMu.Unlock()
+
+	return srOriginator(s.session, applying)
+}
+
+// srOriginator returns the NETCONF user and session-id sysrepo passes on
+// with an event, e.g. from netopeer2. Events of the agent's own sessions
+// carry no session-id and are attributed to local, the originator given to
+// ApplyChangesAs or CallRPCAs.
+func srOriginator(session *C.sr_session_ctx_t, local Originator) Originator {
+	o := Originator{
+		User:    C.GoString(C.sr_session_get_user(session)),
+		Session: uint32(C.sr_session_get_nc_id(session)),
+	}
+	if o.Session != 0 {
+		o.Name = "netopeer2"
+		return o
+	}
+	if local != (Originator{}) {
+		return local
+	}
+	return o
+}
+
+func (s *srChangeSession) GetData(xpath string) (string, error) {
+	path := C.CString(xpath)
+	defer C.free(unsafe.Pointer(path))
+
+	data := C.get_data_json(s.session, path)
+	if data == nil {
+		return "", nil
+	}
+	defer C.free(unsafe.Pointer(data))
+	return C.GoString(data), nil
+}
+
+func srValueString(val *C.sr_val_t) string {
+	if val == nil {
+		return ""
+	}
+	str := C.sr_val_to_str(val)
+	if str == nil {
+		return ""
+	}
+	defer C.free(unsafe.Pointer(str))
+	return C.GoString(str)
+}
+
+// srOperDataTree adds provider leaves to the libyang tree of an oper callback
+type srOperDataTree struct {
+	session *C.sr_session_ctx_t
+	parent  **C.char
+}
+
+func (t *srOperDataTree) CreateNewElement(key, name, value string) {
+	basePath := fmt.Sprintf("%s/%s", key, name)
+	log.Info("%s -> %s", basePath, value)
+
+	cPath := C.CString(basePath)
+	defer C.free(unsafe.Pointer(cPath))
+	cValue := C.CString(value)
+	defer C.free(unsafe.Pointer(cValue))
+
+	C.create_new_path(t.session, t.parent, cPath, cValue)
+}
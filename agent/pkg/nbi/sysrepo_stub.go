@@ -0,0 +1,29 @@
+//go:build !cgo || nosysrepo
+
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+// Without libsysrepo (nosysrepo tag or CGO_ENABLED=0) the NBI runs on the
+// in-memory datastore only.
+func newDefaultDatastore() Datastore {
+	log.Warn("NBI: built without sysrepo support, using in-memory datastore")
+	return NewMemDatastore()
+}
@@ -19,19 +19,37 @@
 
 package nbi
 
-/*
-#cgo LDFLAGS: -lsysrepo
+import (
+	"sync"
 
-#include <sysrepo.h>
-#include <sysrepo/values.h>
-*/
-import "C"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/audit"
+)
 
 type Nbi struct {
 	schemas      []string
-	connection   *C.sr_conn_ctx_t
-	session      *C.sr_session_ctx_t
-	subscription *C.sr_subscription_ctx_t
-	oper         C.sr_change_oper_t
+	ds           Datastore
 	cleanupChan  chan bool
+	providers    []providerEntry
+	rpcs         []rpcEntry
+	reconciler   *Reconciler
+	jobs         *JobManager
+	audit        *audit.Logger
+	auditMu      sync.Mutex
+	auditTx      map[string]*audit.Record
+	accessMu     sync.Mutex
+	access       *AccessPolicy
+	accessTx     map[int]*AccessPolicy
+	namespaces   NamespacePolicy
+	nsMu         sync.Mutex
+	deployedNs   map[string]string
+	deployedNsTx map[int]map[string]string
+	history      *ConfigHistory
+	rollbackMu   sync.Mutex
+	rollbacks    map[string]int
+	intent       *IntentStore
+	candidateMu  sync.Mutex
+	candidate    *Candidate
+	dryRunMu     sync.Mutex
+	dryRun       bool
+	dryRunTx     map[int]bool
 }
@@ -0,0 +1,571 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KeyValue is one list key predicate, e.g. [name='ueec']
+type KeyValue struct {
+	Name  string
+	Value string
+}
+
+// PathSegment is one node step of a YANG data path
+type PathSegment struct {
+	Module string
+	Name   string
+	Keys   []KeyValue
+}
+
+func (s PathSegment) String(withModule bool) string {
+	var b strings.Builder
+	if withModule && s.Module != "" {
+		b.WriteString(s.Module + ":")
+	}
+	b.WriteString(s.Name)
+	for _, k := range s.Keys {
+		quote := "'"
+		if strings.Contains(k.Value, "'") {
+			quote = "\""
+		}
+		b.WriteString("[" + k.Name + "=" + quote + k.Value + quote + "]")
+	}
+	return b.String()
+}
+
+// ParsePath splits an absolute YANG data path such as
+// /o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']/version into segments.
+func ParsePath(xpath string) ([]PathSegment, error) {
+	if !strings.HasPrefix(xpath, "/") {
+		return nil, fmt.Errorf("invalid path '%s': not absolute", xpath)
+	}
+
+	var parts []string
+	var quote rune
+	depth, start := 0, 1
+	for i, c := range xpath {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0 && i > 0:
+			parts = append(parts, xpath[start:i])
+			start = i + 1
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, fmt.Errorf("invalid path '%s': unbalanced predicate", xpath)
+	}
+	parts = append(parts, xpath[start:])
+
+	segs := make([]PathSegment, 0, len(parts))
+	for _, p := range parts {
+		seg, err := parseSegment(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path '%s': %v", xpath, err)
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+func parseSegment(s string) (PathSegment, error) {
+	seg := PathSegment{}
+	name := s
+	if i := strings.Index(s, "["); i >= 0 {
+		name = s[:i]
+		preds := s[i:]
+		for len(preds) > 0 {
+			if preds[0] != '[' {
+				return seg, fmt.Errorf("bad predicate '%s'", preds)
+			}
+			end := predicateEnd(preds)
+			if end < 0 {
+				return seg, fmt.Errorf("bad predicate '%s'", preds)
+			}
+			kv := strings.SplitN(preds[1:end], "=", 2)
+			if len(kv) != 2 {
+				return seg, fmt.Errorf("bad predicate '%s'", preds[:end+1])
+			}
+			val := strings.TrimSpace(kv[1])
+			if len(val) < 2 || (val[0] != '\'' && val[0] != '"') || val[len(val)-1] != val[0] {
+				return seg, fmt.Errorf("unquoted predicate value '%s'", val)
+			}
+			seg.Keys = append(seg.Keys, KeyValue{strings.TrimSpace(kv[0]), val[1 : len(val)-1]})
+			preds = preds[end+1:]
+		}
+	}
+
+	if i := strings.Index(name, ":"); i >= 0 {
+		seg.Module, name = name[:i], name[i+1:]
+	}
+	if name == "" {
+		return seg, fmt.Errorf("empty node name")
+	}
+	seg.Name = name
+	return seg, nil
+}
+
+func predicateEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// Node is a schema-less YANG data tree node. List entries carry their key
+// predicates in Keys, leaves carry their canonical string value.
+type Node struct {
+	Module   string
+	Name     string
+	Keys     []KeyValue
+	Value    string
+	Leaf     bool
+	Children []*Node
+}
+
+// NewTree returns an empty data tree root
+func NewTree() *Node {
+	return &Node{}
+}
+
+func (n *Node) IsListEntry() bool {
+	return len(n.Keys) > 0
+}
+
+func (n *Node) segment() PathSegment {
+	return PathSegment{Module: n.Module, Name: n.Name, Keys: n.Keys}
+}
+
+func (n *Node) matches(seg PathSegment) bool {
+	if n.Name != seg.Name || (seg.Module != "" && n.Module != seg.Module) {
+		return false
+	}
+	if len(seg.Keys) != len(n.Keys) {
+		return false
+	}
+	for _, k := range seg.Keys {
+		if v, ok := n.keyValue(k.Name); !ok || v != k.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *Node) keyValue(name string) (string, bool) {
+	for _, k := range n.Keys {
+		if k.Name == name {
+			return k.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *Node) child(seg PathSegment) *Node {
+	for _, c := range n.Children {
+		if c.matches(seg) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Child returns the direct child with the given name and no key predicates
+func (n *Node) Child(name string) *Node {
+	return n.child(PathSegment{Name: name})
+}
+
+// Create makes sure every node of xpath exists and returns the last one.
+// Key leaves of created list entries are added automatically.
+func (n *Node) Create(xpath string) (*Node, error) {
+	segs, err := ParsePath(xpath)
+	if err != nil {
+		return nil, err
+	}
+
+	cur := n
+	for _, seg := range segs {
+		if seg.Module == "" {
+			if cur.Module == "" {
+				return nil, fmt.Errorf("invalid path '%s': missing module prefix", xpath)
+			}
+			seg.Module = cur.Module
+		}
+		if cur.Leaf {
+			return nil, fmt.Errorf("invalid path '%s': '%s' is a leaf", xpath, cur.Name)
+		}
+
+		next := cur.child(seg)
+		if next == nil {
+			next = &Node{Module: seg.Module, Name: seg.Name, Keys: append([]KeyValue(nil), seg.Keys...)}
+			for _, k := range seg.Keys {
+				next.Children = append(next.Children, &Node{Module: seg.Module, Name: k.Name, Value: k.Value, Leaf: true})
+			}
+			cur.Children = append(cur.Children, next)
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// Set creates xpath as a leaf holding value
+func (n *Node) Set(xpath, value string) error {
+	leaf, err := n.Create(xpath)
+	if err != nil {
+		return err
+	}
+	if leaf.IsListEntry() || len(leaf.Children) > 0 {
+		return fmt.Errorf("invalid path '%s': not a leaf", xpath)
+	}
+	leaf.Leaf = true
+	leaf.Value = value
+	return nil
+}
+
+// Find returns the node addressed by xpath, or nil
+func (n *Node) Find(xpath string) *Node {
+	segs, err := ParsePath(xpath)
+	if err != nil {
+		return nil
+	}
+
+	cur := n
+	for _, seg := range segs {
+		if cur = cur.child(seg); cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+// Delete removes the node addressed by xpath. A list step without predicates
+// removes every entry of that list.
+func (n *Node) Delete(xpath string) bool {
+	segs, err := ParsePath(xpath)
+	if err != nil || len(segs) == 0 {
+		return false
+	}
+
+	parent := n
+	for _, seg := range segs[:len(segs)-1] {
+		if parent = parent.child(seg); parent == nil {
+			return false
+		}
+	}
+
+	last := segs[len(segs)-1]
+	found := false
+	children := parent.Children[:0]
+	for _, c := range parent.Children {
+		if c.matches(last) || (len(last.Keys) == 0 && c.Name == last.Name && c.IsListEntry() &&
+			(last.Module == "" || c.Module == last.Module)) {
+			found = true
+			continue
+		}
+		children = append(children, c)
+	}
+	parent.Children = children
+	return found
+}
+
+// Clone returns a deep copy of the tree
+func (n *Node) Clone() *Node {
+	c := *n
+	c.Keys = append([]KeyValue(nil), n.Keys...)
+	c.Children = make([]*Node, 0, len(n.Children))
+	for _, child := range n.Children {
+		c.Children = append(c.Children, child.Clone())
+	}
+	return &c
+}
+
+// Subtree returns a new tree holding the node addressed by xpath together
+// with its ancestors, or nil if the node does not exist.
+func (n *Node) Subtree(xpath string) *Node {
+	segs, err := ParsePath(xpath)
+	if err != nil {
+		return nil
+	}
+
+	root := NewTree()
+	src, dst := n, root
+	for i, seg := range segs {
+		if src = src.child(seg); src == nil {
+			return nil
+		}
+		if i == len(segs)-1 {
+			dst.Children = append(dst.Children, src.Clone())
+			break
+		}
+		next := &Node{Module: src.Module, Name: src.Name, Keys: append([]KeyValue(nil), src.Keys...)}
+		for _, k := range src.Keys {
+			next.Children = append(next.Children, &Node{Module: src.Module, Name: k.Name, Value: k.Value, Leaf: true})
+		}
+		dst.Children = append(dst.Children, next)
+		dst = next
+	}
+	return root
+}
+
+// Merge copies every node of other into the tree, overwriting leaf values
+func (n *Node) Merge(other *Node) {
+	for _, oc := range other.Children {
+		c := n.child(oc.segment())
+		if c == nil {
+			n.Children = append(n.Children, oc.Clone())
+			continue
+		}
+		if oc.Leaf {
+			c.Leaf, c.Value = true, oc.Value
+			continue
+		}
+		c.Merge(oc)
+	}
+}
+
+func (n *Node) childPath(parentPath, parentModule string) string {
+	return parentPath + "/" + n.segment().String(n.Module != parentModule)
+}
+
+// Walk visits every node below n in document order with its absolute path
+func (n *Node) Walk(fn func(xpath string, node *Node)) {
+	n.walk("", n.Module, fn)
+}
+
+func (n *Node) walk(path, module string, fn func(xpath string, node *Node)) {
+	for _, c := range n.Children {
+		p := c.childPath(path, module)
+		fn(p, c)
+		c.walk(p, c.Module, fn)
+	}
+}
+
+// Leaves returns the leaves of a tree as sorted "xpath=value" lines
+func (n *Node) Leaves() []string {
+	var leaves []string
+	n.Walk(func(xpath string, node *Node) {
+		if node.Leaf {
+			leaves = append(leaves, xpath+"="+node.Value)
+		}
+	})
+	sort.Strings(leaves)
+	return leaves
+}
+
+// Diff reports the changes turning old into new, like sysrepo does for a
+// committed edit: list entries and leaves, but no non-presence containers.
+func Diff(old, new *Node) []Change {
+	changes := []Change{}
+	diffChildren("", "", old, new, &changes)
+	return changes
+}
+
+func diffChildren(path, module string, old, new *Node, changes *[]Change) {
+	for _, nc := range new.Children {
+		p := nc.childPath(path, module)
+		oc := old.child(nc.segment())
+		switch {
+		case oc == nil:
+			appendChanges(OpCreated, p, nc, changes)
+		case nc.Leaf && oc.Value != nc.Value:
+			*changes = append(*changes, Change{Oper: OpModified, Xpath: p, OldValue: oc.Value, NewValue: nc.Value, Leaf: true})
+		case !nc.Leaf:
+			diffChildren(p, nc.Module, oc, nc, changes)
+		}
+	}
+
+	for _, oc := range old.Children {
+		if new.child(oc.segment()) == nil {
+			appendChanges(OpDeleted, oc.childPath(path, module), oc, changes)
+		}
+	}
+}
+
+func appendChanges(oper Operation, path string, n *Node, changes *[]Change) {
+	add := func(p string, node *Node) {
+		if !node.Leaf && !node.IsListEntry() {
+			return
+		}
+		c := Change{Oper: oper, Xpath: p, Leaf: node.Leaf}
+		if oper == OpDeleted {
+			c.OldValue = node.Value
+		} else {
+			c.NewValue = node.Value
+		}
+		*changes = append(*changes, c)
+	}
+
+	add(path, n)
+	n.walk(path, n.Module, add)
+}
+
+// BuildTree rebuilds the data touched by a change set and returns it in the
+// JSON encoding, together with the operation of the last change.
+func BuildTree(changes []Change) (string, Operation) {
+	root := NewTree()
+	oper := OpCreated
+	for _, c := range changes {
+		value := c.NewValue
+		if c.Oper == OpDeleted {
+			value = c.OldValue
+		}
+
+		var err error
+		if c.Leaf {
+			err = root.Set(c.Xpath, value)
+		} else {
+			_, err = root.Create(c.Xpath)
+		}
+		if err != nil {
+			log.Error("BuildTree: %v", err)
+			continue
+		}
+		oper = c.Oper
+	}
+	return root.JSON(), oper
+}
+
+// JSON encodes the tree like libyang does (RFC 7951): top-level and
+// cross-module members are module qualified and lists become arrays.
+// Leaf values are strings, except for the boolean literals.
+func (n *Node) JSON() string {
+	if len(n.Children) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	n.writeJSON(&buf)
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return buf.String()
+	}
+	return out.String()
+}
+
+func (n *Node) writeJSON(buf *bytes.Buffer) {
+	buf.WriteString("{")
+	done := make(map[*Node]bool)
+	first := true
+	for _, c := range n.Children {
+		if done[c] {
+			continue
+		}
+		if !first {
+			buf.WriteString(",")
+		}
+		first = false
+
+		name := c.Name
+		if c.Module != n.Module {
+			name = c.Module + ":" + c.Name
+		}
+		b, _ := json.Marshal(name)
+		buf.Write(b)
+		buf.WriteString(":")
+
+		if !c.IsListEntry() {
+			c.writeValue(buf)
+			continue
+		}
+
+		buf.WriteString("[")
+		for i, e := range n.Children {
+			if e.Name != c.Name || e.Module != c.Module || !e.IsListEntry() {
+				continue
+			}
+			if done[c] {
+				buf.WriteString(",")
+			}
+			done[c], done[e] = true, true
+			n.Children[i].writeJSON(buf)
+		}
+		buf.WriteString("]")
+	}
+	buf.WriteString("}")
+}
+
+func (n *Node) writeValue(buf *bytes.Buffer) {
+	if !n.Leaf {
+		n.writeJSON(buf)
+		return
+	}
+	if n.Value == "true" || n.Value == "false" {
+		buf.WriteString(n.Value)
+		return
+	}
+	b, _ := json.Marshal(n.Value)
+	buf.Write(b)
+}
+
+// XML encodes the children of n as XML elements. An element gets an xmlns
+// attribute, looked up in namespaces by module name, when its module differs
+// from the module of its parent.
+func (n *Node) XML(namespaces map[string]string) string {
+	var buf bytes.Buffer
+	n.writeXML(&buf, namespaces)
+	return buf.String()
+}
+
+func (n *Node) writeXML(buf *bytes.Buffer, namespaces map[string]string) {
+	for _, c := range n.Children {
+		buf.WriteString("<" + c.Name)
+		if c.Module != n.Module {
+			ns, ok := namespaces[c.Module]
+			if !ok {
+				ns = c.Module
+			}
+			buf.WriteString(` xmlns="`)
+			xml.EscapeText(buf, []byte(ns))
+			buf.WriteString(`"`)
+		}
+		buf.WriteString(">")
+
+		if c.Leaf {
+			xml.EscapeText(buf, []byte(c.Value))
+		} else {
+			c.writeXML(buf, namespaces)
+		}
+		buf.WriteString("</" + c.Name + ">")
+	}
+}
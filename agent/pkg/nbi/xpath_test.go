@@ -0,0 +1,127 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package nbi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	segs, err := ParsePath("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec'][ns=\"a/b]c\"]/version")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(segs))
+	assert.Equal(t, PathSegment{Module: "o-ran-sc-ric-xapp-desc-v1", Name: "ric"}, segs[0])
+	assert.Equal(t, []KeyValue{{"name", "ueec"}, {"ns", "a/b]c"}}, segs[2].Keys)
+	assert.Equal(t, "version", segs[3].Name)
+
+	for _, bad := range []string{"ric/xapps", "/ric/xapp[name='x'", "/ric/xapp[name=x]", "/ric//xapps"} {
+		_, err := ParsePath(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestTreeCreateFindDelete(t *testing.T) {
+	tree := NewTree()
+	assert.Nil(t, tree.Set("/m:ric/xapps/xapp[name='a']/version", "1"))
+	assert.Nil(t, tree.Set("/m:ric/xapps/xapp[name='b']/version", "2"))
+	assert.NotNil(t, tree.Set("/ric/xapps", "x"))
+
+	assert.Equal(t, "a", tree.Find("/m:ric/xapps/xapp[name='a']/name").Value)
+	assert.Equal(t, "2", tree.Find("/m:ric/xapps/xapp[name='b']/version").Value)
+	assert.Nil(t, tree.Find("/m:ric/xapps/xapp[name='c']"))
+
+	assert.True(t, tree.Delete("/m:ric/xapps/xapp[name='a']"))
+	assert.Nil(t, tree.Find("/m:ric/xapps/xapp[name='a']"))
+	assert.True(t, tree.Delete("/m:ric/xapps/xapp"))
+	assert.Nil(t, tree.Find("/m:ric/xapps/xapp[name='b']"))
+	assert.False(t, tree.Delete("/m:ric/xapps/xapp"))
+}
+
+func TestTreeJSON(t *testing.T) {
+	tree := NewTree()
+	tree.Set("/m:ric/xapps/xapp[name='a']/version", "1")
+	tree.Set("/m:ric/xapps/xapp[name='b']/version", "2")
+	tree.Set("/m:ric/active", "true")
+
+	var v map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(tree.JSON()), &v))
+	ric := v["m:ric"].(map[string]interface{})
+	assert.Equal(t, true, ric["active"])
+	list := ric["xapps"].(map[string]interface{})["xapp"].([]interface{})
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, "2", list[1].(map[string]interface{})["version"])
+
+	assert.Equal(t, "", NewTree().JSON())
+}
+
+func TestTreeXML(t *testing.T) {
+	tree := NewTree()
+	tree.Set("/m:ric/xapps/xapp[name='a']/version", "1<2")
+	tree.Set("/o:ric/active", "true")
+
+	assert.Equal(t, `<ric xmlns="urn:m"><xapps><xapp><name>a</name><version>1&lt;2</version></xapp></xapps></ric><ric xmlns="o"><active>true</active></ric>`,
+		tree.XML(map[string]string{"m": "urn:m"}))
+	assert.Equal(t, "", NewTree().XML(nil))
+}
+
+func TestDiffAndBuildTree(t *testing.T) {
+	old := NewTree()
+	old.Set("/m:ric/xapps/xapp[name='a']/version", "1")
+	old.Set("/m:ric/xapps/xapp[name='b']/version", "1")
+
+	new := old.Clone()
+	new.Set("/m:ric/xapps/xapp[name='a']/version", "2")
+	new.Delete("/m:ric/xapps/xapp[name='b']")
+	new.Set("/m:ric/xapps/xapp[name='c']/version", "3")
+
+	changes := Diff(old, new)
+	assert.Equal(t, []Change{
+		{Oper: OpModified, Xpath: "/m:ric/xapps/xapp[name='a']/version", OldValue: "1", NewValue: "2", Leaf: true},
+		{Oper: OpCreated, Xpath: "/m:ric/xapps/xapp[name='c']"},
+		{Oper: OpCreated, Xpath: "/m:ric/xapps/xapp[name='c']/name", NewValue: "c", Leaf: true},
+		{Oper: OpCreated, Xpath: "/m:ric/xapps/xapp[name='c']/version", NewValue: "3", Leaf: true},
+		{Oper: OpDeleted, Xpath: "/m:ric/xapps/xapp[name='b']"},
+		{Oper: OpDeleted, Xpath: "/m:ric/xapps/xapp[name='b']/name", OldValue: "b", Leaf: true},
+		{Oper: OpDeleted, Xpath: "/m:ric/xapps/xapp[name='b']/version", OldValue: "1", Leaf: true},
+	}, changes)
+
+	data, oper := BuildTree(changes[4:])
+	assert.Equal(t, OpDeleted, oper)
+	assert.Contains(t, data, `"name": "b"`)
+}
+
+func TestSubtreeAndMerge(t *testing.T) {
+	tree := NewTree()
+	tree.Set("/m:ric/xapps/xapp[name='a']/version", "1")
+	tree.Set("/m:ric/health/status[name='a']/health", "healthy")
+
+	sub := tree.Subtree("/m:ric/xapps/xapp[name='a']/version")
+	assert.Equal(t, []string{"/m:ric/xapps/xapp[name='a']/name=a", "/m:ric/xapps/xapp[name='a']/version=1"}, sub.Leaves())
+	assert.Nil(t, tree.Subtree("/m:ric/none"))
+
+	other := NewTree()
+	other.Set("/m:ric/xapps/xapp[name='a']/version", "2")
+	tree.Merge(other)
+	assert.Equal(t, "2", tree.Find("/m:ric/xapps/xapp[name='a']/version").Value)
+	assert.Equal(t, "healthy", tree.Find("/m:ric/health/status[name='a']/health").Value)
+}
@@ -0,0 +1,145 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+const (
+	endOfMessage   = "]]>]]>"
+	maxChunkSize   = 4294967295
+	maxMessageSize = 64 * 1024 * 1024
+)
+
+// framer reads and writes NETCONF messages, using the end-of-message
+// delimiter of base:1.0 until chunked framing (RFC 6242) is switched on.
+type framer struct {
+	r       *bufio.Reader
+	w       io.Writer
+	mu      sync.Mutex
+	chunked bool
+}
+
+func newFramer(r io.Reader, w io.Writer) *framer {
+	return &framer{r: bufio.NewReader(r), w: w}
+}
+
+func (f *framer) ReadMessage() ([]byte, error) {
+	if f.chunked {
+		return f.readChunked()
+	}
+
+	var msg []byte
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, b)
+		if bytes.HasSuffix(msg, []byte(endOfMessage)) {
+			return msg[:len(msg)-len(endOfMessage)], nil
+		}
+		if len(msg) > maxMessageSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxMessageSize)
+		}
+	}
+}
+
+func (f *framer) readChunked() ([]byte, error) {
+	var msg []byte
+	for {
+		if err := f.expect("\n#"); err != nil {
+			return nil, err
+		}
+
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == '#' {
+			if err := f.expect("\n"); err != nil {
+				return nil, err
+			}
+			return msg, nil
+		}
+
+		digits := []byte{b}
+		for {
+			if b, err = f.r.ReadByte(); err != nil {
+				return nil, err
+			}
+			if b == '\n' {
+				break
+			}
+			digits = append(digits, b)
+		}
+
+		size, err := strconv.ParseUint(string(digits), 10, 32)
+		if err != nil || size == 0 || size > maxChunkSize || digits[0] == '0' {
+			return nil, fmt.Errorf("invalid chunk size '%s'", digits)
+		}
+		if len(msg)+int(size) > maxMessageSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxMessageSize)
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(f.r, chunk); err != nil {
+			return nil, err
+		}
+		msg = append(msg, chunk...)
+	}
+}
+
+func (f *framer) expect(s string) error {
+	for i := 0; i < len(s); i++ {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != s[i] {
+			return fmt.Errorf("invalid chunked framing")
+		}
+	}
+	return nil
+}
+
+func (f *framer) WriteMessage(msg []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var buf bytes.Buffer
+	if f.chunked {
+		fmt.Fprintf(&buf, "\n#%d\n", len(msg))
+		buf.Write(msg)
+		buf.WriteString("\n##\n")
+	} else {
+		buf.Write(msg)
+		buf.WriteString(endOfMessage)
+	}
+
+	_, err := f.w.Write(buf.Bytes())
+	return err
+}
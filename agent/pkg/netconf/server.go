@@ -0,0 +1,385 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package netconf is a native NETCONF-over-SSH server (RFC 6241/6242) that
+// can replace netopeer2 + sysrepo. Edits go through an nbi.MemDatastore, so
+// the same NBI module change and operational data handlers are driven.
+package netconf
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+)
+
+var log = xapp.Logger
+
+// Config holds the listen address, SSH host key and user credentials.
+// Without a host key file the key is generated once and kept in StateDir.
+type Config struct {
+	Addr        string
+	HostKeyFile string
+	StateDir    string
+	Users       map[string]string
+}
+
+// ConfigFromViper reads the "netconf" section of the agent configuration
+func ConfigFromViper() Config {
+	addr := viper.GetString("netconf.addr")
+	if addr == "" {
+		addr = ":830"
+	}
+	return Config{
+		Addr:        addr,
+		HostKeyFile: viper.GetString("netconf.hostKey"),
+		StateDir:    viper.GetString("netconf.stateDir"),
+		Users:       viper.GetStringMapString("netconf.users"),
+	}
+}
+
+type Server struct {
+	config    Config
+	ds        *nbi.MemDatastore
+	candidate *nbi.Candidate
+	dryRun    nbi.CandidateValidator
+	codec     *codec
+	sshConfig *ssh.ServerConfig
+	mu        sync.Mutex
+	listener  net.Listener
+	sessions  map[uint32]*session
+	locks     map[string]uint32
+	nextID    uint32
+}
+
+func NewServer(config Config, ds *nbi.MemDatastore, schema *yang.Schema) (*Server, error) {
+	s := &Server{
+		config:   config,
+		ds:       ds,
+		codec:    &codec{schema: schema},
+		sessions: make(map[uint32]*session),
+		locks:    make(map[string]uint32),
+	}
+
+	s.SetCandidate(nbi.NewCandidate(ds, nil))
+
+	s.sshConfig = &ssh.ServerConfig{PasswordCallback: s.checkPassword}
+	signer, err := loadHostKey(config)
+	if err != nil {
+		return nil, err
+	}
+	s.sshConfig.AddHostKey(signer)
+
+	ds.SubscribeNotifications(s.broadcast)
+	return s, nil
+}
+
+// SetCandidate replaces the unvalidated candidate datastore of the server,
+// e.g. by the one of the NBI
+func (s *Server) SetCandidate(c *nbi.Candidate) {
+	if c != nil {
+		s.candidate = c
+	}
+}
+
+// SetDryRun sets the checks of an edit-config with the test-only test
+// option, e.g. the dry run of the NBI. Without them such an edit is only
+// parsed.
+func (s *Server) SetDryRun(v nbi.CandidateValidator) {
+	s.dryRun = v
+}
+
+// loadHostKey reads the configured host key or, without one, the key
+// generated in the state directory, which is created on the first start so
+// clients see the same key on every start
+func loadHostKey(config Config) (ssh.Signer, error) {
+	path := config.HostKeyFile
+	if path == "" {
+		if config.StateDir == "" {
+			return nil, fmt.Errorf("neither a host key nor a state directory configured")
+		}
+		path = filepath.Join(config.StateDir, hostKeyFile)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := generateHostKey(path); err != nil {
+				return nil, fmt.Errorf("generating host key failed: %v", err)
+			}
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading host key failed: %v", err)
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+const hostKeyFile = "ssh_host_ed25519_key"
+
+// generateHostKey writes a new ed25519 key to path in PKCS #8 PEM
+func generateHostKey(path string) error {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	log.Info("NETCONF: generated host key %s", path)
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+}
+
+func (s *Server) checkPassword(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	expected, ok := s.config.Users[conn.User()]
+	if ok && subtle.ConstantTimeCompare([]byte(expected), password) == 1 {
+		return &ssh.Permissions{}, nil
+	}
+	log.Warn("NETCONF: authentication failed for user '%s' from %s", conn.User(), conn.RemoteAddr())
+	return nil, fmt.Errorf("authentication failed")
+}
+
+// ListenAndServe accepts NETCONF clients on the configured address
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	log.Info("NETCONF: listening on %s", l.Addr())
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting clients and terminates every session
+func (s *Server) Close() {
+	s.mu.Lock()
+	l := s.listener
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, ss := range s.sessions {
+		sessions = append(sessions, ss)
+	}
+	s.mu.Unlock()
+
+	if l != nil {
+		l.Close()
+	}
+	for _, ss := range sessions {
+		ss.close()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.sshConfig)
+	if err != nil {
+		log.Info("NETCONF: SSH handshake with %s failed: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		ch, requests, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleChannel(sconn, ch, requests)
+	}
+}
+
+func (s *Server) handleChannel(conn *ssh.ServerConn, ch ssh.Channel, requests <-chan *ssh.Request) {
+	for req := range requests {
+		if req.Type != "subsystem" || subsystemName(req.Payload) != "netconf" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		ss := s.newSession(conn.User(), ch)
+		go func() {
+			for r := range requests {
+				r.Reply(false, nil)
+			}
+		}()
+		ss.run()
+		return
+	}
+	ch.Close()
+}
+
+func subsystemName(payload []byte) string {
+	if len(payload) < 4 {
+		return ""
+	}
+	n := binary.BigEndian.Uint32(payload)
+	if int(n) > len(payload)-4 {
+		return ""
+	}
+	return string(payload[4 : 4+n])
+}
+
+func (s *Server) newSession(user string, ch ssh.Channel) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	ss := &session{
+		id:     s.nextID,
+		user:   user,
+		server: s,
+		ch:     ch,
+		framer: newFramer(ch, ch),
+	}
+	s.sessions[ss.id] = ss
+	log.Info("NETCONF: session %d started for user '%s'", ss.id, user)
+	return ss
+}
+
+func (s *Server) endSession(ss *session) {
+	edits := s.ds.EditLock()
+	edits.Lock()
+	s.candidate.SessionClosed(ss.originator())
+	edits.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, ss.id)
+	for target, owner := range s.locks {
+		if owner == ss.id {
+			delete(s.locks, target)
+		}
+	}
+	log.Info("NETCONF: session %d closed", ss.id)
+}
+
+func (s *Server) session(id uint32) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[id]
+}
+
+func (s *Server) lock(target string, id uint32) *rpcError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if owner, ok := s.locks[target]; ok {
+		err := newError("protocol", "lock-denied", "lock is already held")
+		err.Info = fmt.Sprintf("<session-id>%d</session-id>", owner)
+		return err
+	}
+	s.locks[target] = id
+	return nil
+}
+
+func (s *Server) unlock(target string, id uint32) *rpcError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if owner, ok := s.locks[target]; !ok || owner != id {
+		return newError("protocol", "operation-failed", "lock is not held by this session")
+	}
+	delete(s.locks, target)
+	return nil
+}
+
+// checkLock fails when target is locked by another session
+func (s *Server) checkLock(target string, id uint32) *rpcError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if owner, ok := s.locks[target]; ok && owner != id {
+		err := newError("protocol", "in-use", fmt.Sprintf("%s datastore is locked", target))
+		err.Info = fmt.Sprintf("<session-id>%d</session-id>", owner)
+		return err
+	}
+	return nil
+}
+
+func (s *Server) broadcast(n nbi.Notification) {
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, ss := range s.sessions {
+		sessions = append(sessions, ss)
+	}
+	s.mu.Unlock()
+
+	for _, ss := range sessions {
+		ss.notify(n)
+	}
+}
+
+// capabilities returns the hello capabilities, including one per module
+func (s *Server) capabilities() []string {
+	caps := []string{
+		"urn:ietf:params:netconf:base:1.0",
+		"urn:ietf:params:netconf:base:1.1",
+		"urn:ietf:params:netconf:capability:xpath:1.0",
+		"urn:ietf:params:netconf:capability:candidate:1.0",
+		"urn:ietf:params:netconf:capability:confirmed-commit:1.1",
+		"urn:ietf:params:netconf:capability:validate:1.1",
+		"urn:ietf:params:netconf:capability:notification:1.0",
+		"urn:ietf:params:netconf:capability:interleave:1.0",
+		monitoringNamespace + "?module=ietf-netconf-monitoring&revision=2010-10-04",
+	}
+	for _, name := range s.codec.schema.ModuleNames() {
+		m := s.codec.schema.Modules[name]
+		cap := fmt.Sprintf("%s?module=%s", m.Namespace, m.Name)
+		if m.Revision != "" {
+			cap += "&revision=" + m.Revision
+		}
+		caps = append(caps, cap)
+	}
+	return caps
+}
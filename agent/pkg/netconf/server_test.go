@@ -0,0 +1,469 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package netconf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	netconf "github.com/Juniper/go-netconf/netconf"
+	"github.com/stretchr/testify/assert"
+)
+
+const xappDesc = "o-ran-sc-ric-xapp-desc-v1"
+
+var ds *nbi.MemDatastore
+var lastOriginator nbi.Originator
+var server *Server
+var serverAddr string
+
+const timeout = 2 * time.Second
+const tick = 50 * time.Millisecond
+
+func TestMain(m *testing.M) {
+	schema, err := yang.LoadDir("../../yang")
+	if err != nil {
+		panic(err)
+	}
+
+	ds = nbi.NewMemDatastore()
+	ds.SubscribeModuleChange(xappDesc, func(s nbi.ChangeSession, module, xpath string, event nbi.Event, reqID int) error {
+		lastOriginator = s.Originator()
+		changes, _ := s.GetChanges("//.")
+		for _, c := range changes {
+			if event == nbi.EventChange && c.NewValue == "rejected" {
+				return errors.New("xApp rejected by appmgr")
+			}
+			if event == nbi.EventChange && c.NewValue == "invalid" {
+				return &nbi.ChangeError{Xpath: c.Xpath, Tag: "invalid-value", Message: "no such helm release"}
+			}
+		}
+		return nil
+	})
+	ds.SubscribeOperData(xappDesc, "/o-ran-sc-ric-xapp-desc-v1:ric/health", func(module, xpath string, tree nbi.OperDataTree) error {
+		tree.CreateNewElement("/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name='ueec']", "health", "healthy")
+		return nil
+	})
+
+	stateDir, err := ioutil.TempDir("", "netconf")
+	if err != nil {
+		panic(err)
+	}
+	server, err = NewServer(Config{StateDir: stateDir, Users: map[string]string{"netconf": "netconf"}}, ds, schema)
+	if err != nil {
+		panic(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	serverAddr = l.Addr().String()
+	go server.Serve(l)
+
+	code := m.Run()
+	server.Close()
+	os.RemoveAll(stateDir)
+	os.Exit(code)
+}
+
+func TestHostKey(t *testing.T) {
+	dir := t.TempDir()
+	first, err := loadHostKey(Config{StateDir: dir})
+	assert.Nil(t, err)
+	second, err := loadHostKey(Config{StateDir: dir})
+	assert.Nil(t, err)
+	assert.Equal(t, first.PublicKey().Marshal(), second.PublicKey().Marshal())
+
+	// the state directory only holds a generated key
+	third, err := loadHostKey(Config{HostKeyFile: filepath.Join(dir, hostKeyFile), StateDir: t.TempDir()})
+	assert.Nil(t, err)
+	assert.Equal(t, first.PublicKey().Marshal(), third.PublicKey().Marshal())
+
+	_, err = loadHostKey(Config{})
+	assert.NotNil(t, err)
+}
+
+func dial(t *testing.T) *netconf.Session {
+	s, err := netconf.DialSSH(serverAddr, netconf.SSHConfigPassword("netconf", "netconf"))
+	assert.Nil(t, err)
+	return s
+}
+
+const editXapp = `<edit-config><target><running/></target><config>
+	<ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps><xapp %s><name>%s</name><release-name>%s</release-name><version>1.0.0</version></xapp></xapps></ric>
+	</config></edit-config>`
+
+func edit(s *netconf.Session, attrs, name, release string) error {
+	_, err := s.Exec(netconf.RawMethod(fmt.Sprintf(editXapp, attrs, name, release)))
+	return err
+}
+
+func TestHelloAndAuthentication(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	assert.NotZero(t, s.SessionID)
+	assert.Contains(t, s.ServerCapabilities, "urn:ietf:params:netconf:base:1.1")
+	assert.Contains(t, s.ServerCapabilities, "urn:o-ran:ric:xapp-desc:1.0?module=o-ran-sc-ric-xapp-desc-v1&revision=2026-10-19")
+
+	_, err := netconf.DialSSH(serverAddr, netconf.SSHConfigPassword("netconf", "wrong"))
+	assert.NotNil(t, err)
+}
+
+func TestEditAndGetConfig(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	assert.Nil(t, edit(s, "", "ueec", "ueec-xapp"))
+	assert.Equal(t, nbi.Originator{Name: "netconf", User: "netconf", Session: uint32(s.SessionID)}, lastOriginator)
+	assert.Equal(t, "ueec-xapp", ds.GetConfig("").Find("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']/release-name").Value)
+
+	reply, err := s.Exec(netconf.MethodGetConfig("running"))
+	assert.Nil(t, err)
+	assert.Contains(t, reply.Data, `<ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps><xapp><name>ueec</name><release-name>ueec-xapp</release-name>`)
+
+	// create on an existing entry fails, merge updates it
+	err = edit(s, `xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="create"`, "ueec", "other")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "data already exists")
+	assert.Nil(t, edit(s, "", "ueec", "ueec-xapp-2"))
+
+	// rejected by the module change handler, nothing is committed
+	err = edit(s, "", "bad", "rejected")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "xApp rejected by appmgr")
+	assert.False(t, ds.HasItem("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='bad']"))
+
+	// the reason and the offending node reach the client
+	err = edit(s, "", "bad", "invalid")
+	var rpcErr *netconf.RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, "invalid-value", rpcErr.Tag)
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='bad']/release-name", rpcErr.Path)
+	assert.Equal(t, "no such helm release", rpcErr.Message)
+
+	reply, err = s.Exec(netconf.RawMethod(`<get-config><source><running/></source><filter type="subtree">
+		<ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps><xapp><name>ueec</name><release-name/></xapp></xapps></ric>
+		</filter></get-config>`))
+	assert.Nil(t, err)
+	assert.Contains(t, reply.Data, "<release-name>ueec-xapp-2</release-name>")
+	assert.NotContains(t, reply.Data, "<version>")
+
+	err = edit(s, `xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="delete"`, "ueec", "")
+	assert.Nil(t, err)
+	assert.False(t, ds.HasItem("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='ueec']"))
+
+	err = edit(s, `xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="delete"`, "ueec", "")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "data does not exist")
+}
+
+func TestGetWithState(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	reply, err := s.Exec(netconf.RawMethod(`<get><filter type="xpath" xmlns:x="urn:o-ran:ric:xapp-desc:1.0" select="/x:ric/health"/></get>`))
+	assert.Nil(t, err)
+	assert.Contains(t, reply.Data, "<status><name>ueec</name><health>healthy</health></status>")
+
+	reply, err = s.Exec(netconf.RawMethod(`<get/>`))
+	assert.Nil(t, err)
+	assert.Contains(t, reply.Data, "<health>healthy</health>")
+
+	_, err = s.Exec(netconf.RawMethod(`<get><filter><ric xmlns="urn:unknown"/></filter></get>`))
+	assert.NotNil(t, err)
+
+	_, err = s.Exec(netconf.RawMethod(`<get-config><source><startup/></source></get-config>`))
+	assert.NotNil(t, err)
+}
+
+func TestLockUnlock(t *testing.T) {
+	s1 := dial(t)
+	defer s1.Close()
+	s2 := dial(t)
+	defer s2.Close()
+
+	_, err := s1.Exec(netconf.MethodLock("running"))
+	assert.Nil(t, err)
+	_, err = s2.Exec(netconf.MethodLock("running"))
+	assert.NotNil(t, err)
+
+	err = edit(s2, "", "locked", "locked")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "locked")
+	assert.Nil(t, edit(s1, "", "locked", "locked"))
+
+	_, err = s2.Exec(netconf.MethodUnlock("running"))
+	assert.NotNil(t, err)
+	_, err = s1.Exec(netconf.MethodUnlock("running"))
+	assert.Nil(t, err)
+	assert.Nil(t, edit(s2, `xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="remove"`, "locked", ""))
+}
+
+func TestLockReleasedOnClose(t *testing.T) {
+	s1 := dial(t)
+	_, err := s1.Exec(netconf.MethodLock("running"))
+	assert.Nil(t, err)
+	_, err = s1.Exec(netconf.RawMethod("<close-session/>"))
+	assert.Nil(t, err)
+	s1.Close()
+
+	s2 := dial(t)
+	defer s2.Close()
+	assert.Eventually(t, func() bool {
+		_, err := s2.Exec(netconf.MethodLock("running"))
+		return err == nil
+	}, timeout, tick)
+	s2.Exec(netconf.MethodUnlock("running"))
+}
+
+func TestNotifications(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	_, err := s.Exec(netconf.RawMethod(`<create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"/>`))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"/>`))
+	assert.NotNil(t, err)
+
+	ds.Notify("/o-ran-sc-ric-alarm-v1:ric/alarms", map[string]string{"alarm-id": "1"})
+	msg, err := s.Transport.Receive()
+	assert.Nil(t, err)
+	assert.Contains(t, string(msg), "<notification xmlns=\"urn:ietf:params:xml:ns:netconf:notification:1.0\"><eventTime>")
+	assert.Contains(t, string(msg), "<alarms><alarm-id>1</alarm-id></alarms>")
+}
+
+func TestGetSchemaAndErrors(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	reply, err := s.Exec(netconf.RawMethod(`<get-schema xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"><identifier>o-ran-sc-ric-alarm-v1</identifier></get-schema>`))
+	assert.Nil(t, err)
+	assert.Contains(t, reply.Data, "module o-ran-sc-ric-alarm-v1")
+
+	_, err = s.Exec(netconf.RawMethod(`<get-schema xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"><identifier>none</identifier></get-schema>`))
+	assert.NotNil(t, err)
+
+	_, err = s.Exec(netconf.RawMethod(`<copy-config/>`))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+}
+
+const editCandidate = `<edit-config><target><candidate/></target><config>
+	<ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps><xapp><name>%s</name><release-name>%s</release-name><version>1.0.0</version></xapp></xapps></ric>
+	</config></edit-config>`
+
+func candidateXapp(name string) string {
+	return "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='" + name + "']"
+}
+
+func TestCandidateCommit(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	assert.Contains(t, s.ServerCapabilities, "urn:ietf:params:netconf:capability:candidate:1.0")
+
+	_, err := s.Exec(netconf.RawMethod(fmt.Sprintf(editCandidate, "cand", "cand")))
+	assert.Nil(t, err)
+	assert.False(t, ds.HasItem(candidateXapp("cand")))
+
+	reply, err := s.Exec(netconf.MethodGetConfig("candidate"))
+	assert.Nil(t, err)
+	assert.Contains(t, reply.Data, "<name>cand</name>")
+
+	_, err = s.Exec(netconf.RawMethod(`<validate><source><candidate/></source></validate>`))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit/>`))
+	assert.Nil(t, err)
+	assert.True(t, ds.HasItem(candidateXapp("cand")))
+	assert.Equal(t, nbi.Originator{Name: "netconf", User: "netconf", Session: uint32(s.SessionID)}, lastOriginator)
+
+	// a rejected commit changes nothing and keeps the candidate
+	_, err = s.Exec(netconf.RawMethod(fmt.Sprintf(editCandidate, "cand-2", "rejected")))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit/>`))
+	assert.NotNil(t, err)
+	assert.False(t, ds.HasItem(candidateXapp("cand-2")))
+
+	_, err = s.Exec(netconf.RawMethod(`<discard-changes/>`))
+	assert.Nil(t, err)
+	reply, err = s.Exec(netconf.MethodGetConfig("candidate"))
+	assert.Nil(t, err)
+	assert.NotContains(t, reply.Data, "cand-2")
+
+	ds.DeleteItem(candidateXapp("cand"))
+	assert.Nil(t, ds.ApplyChanges())
+}
+
+const testEditXapp = `<edit-config><target><%s/></target><test-option>%s</test-option><config>
+	<ric xmlns="urn:o-ran:ric:xapp-desc:1.0"><xapps><xapp><name>tested</name><release-name>%s</release-name></xapp></xapps></ric>
+	</config></edit-config>`
+
+func TestTestOnlyEdit(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	var planned []nbi.Change
+	server.SetDryRun(func(o nbi.Originator, changes []nbi.Change, data *nbi.Node) error {
+		planned = changes
+		if data.Find(candidateXapp("tested")+"/release-name").Value == "rejected" {
+			return errors.New("xApp rejected by the dry run")
+		}
+		return nil
+	})
+	defer server.SetDryRun(nil)
+
+	_, err := s.Exec(netconf.RawMethod(fmt.Sprintf(testEditXapp, "running", "test-only", "tested")))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(planned))
+	assert.False(t, ds.HasItem(candidateXapp("tested")))
+
+	_, err = s.Exec(netconf.RawMethod(fmt.Sprintf(testEditXapp, "running", "test-only", "rejected")))
+	assert.NotNil(t, err)
+
+	_, err = s.Exec(netconf.RawMethod(fmt.Sprintf(testEditXapp, "candidate", "test-only", "tested")))
+	assert.Nil(t, err)
+	reply, err := s.Exec(netconf.MethodGetConfig("candidate"))
+	assert.Nil(t, err)
+	assert.NotContains(t, reply.Data, "tested")
+
+	_, err = s.Exec(netconf.RawMethod(fmt.Sprintf(testEditXapp, "running", "test-twice", "tested")))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid test-option")
+}
+
+func TestConfirmedCommit(t *testing.T) {
+	s := dial(t)
+	defer s.Close()
+
+	// confirmed by a second commit
+	_, err := s.Exec(netconf.RawMethod(fmt.Sprintf(editCandidate, "confirmed", "confirmed")))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit><confirmed/><confirm-timeout>1</confirm-timeout></commit>`))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit/>`))
+	assert.Nil(t, err)
+	time.Sleep(1500 * time.Millisecond)
+	assert.True(t, ds.HasItem(candidateXapp("confirmed")))
+
+	// rolled back when the timeout passes
+	_, err = s.Exec(netconf.RawMethod(fmt.Sprintf(editCandidate, "unconfirmed", "unconfirmed")))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit><confirmed/><confirm-timeout>1</confirm-timeout></commit>`))
+	assert.Nil(t, err)
+	assert.True(t, ds.HasItem(candidateXapp("unconfirmed")))
+	assert.Eventually(t, func() bool { return !ds.HasItem(candidateXapp("unconfirmed")) }, timeout, tick)
+	assert.Equal(t, nbi.Originator{Name: "confirmed-commit"}, lastOriginator)
+	assert.True(t, ds.HasItem(candidateXapp("confirmed")))
+
+	// persistent commits are confirmed or cancelled by their persist-id only
+	_, err = s.Exec(netconf.RawMethod(fmt.Sprintf(editCandidate, "persisted", "persisted")))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit><confirmed/><persist>p1</persist></commit>`))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit/>`))
+	assert.NotNil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<cancel-commit><persist-id>p1</persist-id></cancel-commit>`))
+	assert.Nil(t, err)
+	assert.False(t, ds.HasItem(candidateXapp("persisted")))
+
+	_, err = s.Exec(netconf.RawMethod(`<cancel-commit/>`))
+	assert.NotNil(t, err)
+
+	ds.DeleteItem(candidateXapp("confirmed"))
+	assert.Nil(t, ds.ApplyChanges())
+}
+
+func TestConfirmedCommitRolledBackOnClose(t *testing.T) {
+	s := dial(t)
+	_, err := s.Exec(netconf.RawMethod(fmt.Sprintf(editCandidate, "closed", "closed")))
+	assert.Nil(t, err)
+	_, err = s.Exec(netconf.RawMethod(`<commit><confirmed/></commit>`))
+	assert.Nil(t, err)
+	assert.True(t, ds.HasItem(candidateXapp("closed")))
+
+	s.Close()
+	assert.Eventually(t, func() bool { return !ds.HasItem(candidateXapp("closed")) }, timeout, tick)
+}
+
+func TestModuleRPC(t *testing.T) {
+	var input map[string]string
+	ds.SubscribeRPC("/o-ran-sc-ric-xapp-desc-v1:get-xapp-logs", func(o nbi.Originator, xpath string, in map[string]string) (map[string]string, error) {
+		input = in
+		if in["name"] == "broken" {
+			return nil, errors.New("kubectl failed")
+		}
+		return map[string]string{"logs": "a < b"}, nil
+	})
+
+	s := dial(t)
+	defer s.Close()
+
+	reply, err := s.Exec(netconf.RawMethod(`<get-xapp-logs xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>ueec</name></get-xapp-logs>`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"name": "ueec", "lines": "100"}, input)
+	assert.Contains(t, reply.Data, `<logs xmlns="urn:o-ran:ric:xapp-desc:1.0">a &lt; b</logs>`)
+
+	_, err = s.Exec(netconf.RawMethod(`<get-xapp-logs xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>broken</name></get-xapp-logs>`))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "kubectl failed")
+
+	_, err = s.Exec(netconf.RawMethod(`<get-xapp-logs xmlns="urn:o-ran:ric:xapp-desc:1.0"><lines>5</lines></get-xapp-logs>`))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "missing input 'name'")
+
+	_, err = s.Exec(netconf.RawMethod(`<get-xapp-logs xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>ueec</name><tail>5</tail></get-xapp-logs>`))
+	assert.NotNil(t, err)
+
+	_, err = s.Exec(netconf.RawMethod(`<restart-xapp xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>ueec</name></restart-xapp>`))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no subscriber")
+}
+
+func TestChunkedFraming(t *testing.T) {
+	var out bytes.Buffer
+	in := bytes.NewBufferString("\n#4\n<rpc\n#17\n message-id=\"1\"/>\n##\n\n#x\n")
+	f := newFramer(in, &out)
+	f.chunked = true
+
+	msg, err := f.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, `<rpc message-id="1"/>`, string(msg))
+	_, err = f.ReadMessage()
+	assert.NotNil(t, err)
+
+	assert.Nil(t, f.WriteMessage([]byte("<ok/>")))
+	assert.Equal(t, "\n#5\n<ok/>\n##\n", out.String())
+
+	f = newFramer(bytes.NewBufferString("<hello/>]]>]]>"), &out)
+	msg, err = f.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, "<hello/>", string(msg))
+}
@@ -0,0 +1,736 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	"golang.org/x/crypto/ssh"
+)
+
+// rpcError is a NETCONF <rpc-error>
+type rpcError struct {
+	Type    string
+	Tag     string
+	Path    string
+	Message string
+	Info    string
+}
+
+func newError(errType, tag, message string) *rpcError {
+	return &rpcError{Type: errType, Tag: tag, Message: message}
+}
+
+// changeError reports a rejected commit, with the tag and offending node of
+// an nbi.ChangeError
+func changeError(err error) *rpcError {
+	e := newError("application", "operation-failed", err.Error())
+	var cerr *nbi.ChangeError
+	if errors.As(err, &cerr) {
+		if cerr.Tag != "" {
+			e.Tag = cerr.Tag
+		}
+		e.Path = cerr.Xpath
+	}
+	return e
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Tag, e.Message)
+}
+
+func (e *rpcError) encode(buf *bytes.Buffer) {
+	buf.WriteString("<rpc-error><error-type>" + e.Type + "</error-type>")
+	buf.WriteString("<error-tag>" + e.Tag + "</error-tag><error-severity>error</error-severity>")
+	if e.Path != "" {
+		buf.WriteString("<error-path>")
+		xml.EscapeText(buf, []byte(e.Path))
+		buf.WriteString("</error-path>")
+	}
+	if e.Message != "" {
+		buf.WriteString(`<error-message xml:lang="en">`)
+		xml.EscapeText(buf, []byte(e.Message))
+		buf.WriteString("</error-message>")
+	}
+	if e.Info != "" {
+		buf.WriteString("<error-info>" + e.Info + "</error-info>")
+	}
+	buf.WriteString("</rpc-error>")
+}
+
+type session struct {
+	id         uint32
+	user       string
+	server     *Server
+	ch         ssh.Channel
+	framer     *framer
+	mu         sync.Mutex
+	subscribed bool
+	closed     bool
+}
+
+func (ss *session) run() {
+	defer ss.server.endSession(ss)
+	defer ss.close()
+
+	if err := ss.hello(); err != nil {
+		log.Error("NETCONF: session %d hello failed: %v", ss.id, err)
+		return
+	}
+
+	for {
+		msg, err := ss.framer.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		reply, done := ss.handle(msg)
+		if err := ss.framer.WriteMessage(reply); err != nil {
+			log.Error("NETCONF: session %d write failed: %v", ss.id, err)
+			return
+		}
+		if done {
+			return
+		}
+	}
+}
+
+func (ss *session) close() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if !ss.closed {
+		ss.closed = true
+		ss.ch.Close()
+	}
+}
+
+func (ss *session) hello() error {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<hello xmlns="` + baseNamespace + `"><capabilities>`)
+	for _, c := range ss.server.capabilities() {
+		buf.WriteString("<capability>")
+		xml.EscapeText(&buf, []byte(c))
+		buf.WriteString("</capability>")
+	}
+	buf.WriteString(fmt.Sprintf("</capabilities><session-id>%d</session-id></hello>", ss.id))
+	if err := ss.framer.WriteMessage(buf.Bytes()); err != nil {
+		return err
+	}
+
+	msg, err := ss.framer.ReadMessage()
+	if err != nil {
+		return err
+	}
+	hello, err := parseXML(msg)
+	if err != nil {
+		return err
+	}
+	if hello.Name.Local != "hello" || hello.child("capabilities") == nil {
+		return fmt.Errorf("expected a hello message")
+	}
+
+	base10, base11 := false, false
+	for _, c := range hello.child("capabilities").Children {
+		switch c.value() {
+		case "urn:ietf:params:netconf:base:1.0":
+			base10 = true
+		case "urn:ietf:params:netconf:base:1.1":
+			base11 = true
+		}
+	}
+	if !base10 && !base11 {
+		return fmt.Errorf("no common base capability")
+	}
+	ss.framer.chunked = base11
+	return nil
+}
+
+// handle processes one <rpc> and returns the <rpc-reply> and whether the
+// session should be terminated afterwards.
+func (ss *session) handle(msg []byte) ([]byte, bool) {
+	rpc, err := parseXML(msg)
+	if err != nil || rpc.Name.Local != "rpc" || len(rpc.Children) != 1 {
+		e := newError("rpc", "malformed-message", "malformed rpc")
+		return ss.reply(nil, nil, e), false
+	}
+
+	if _, ok := rpc.attr("", "message-id"); !ok {
+		e := newError("rpc", "missing-attribute", "missing message-id")
+		e.Info = "<bad-attribute>message-id</bad-attribute><bad-element>rpc</bad-element>"
+		return ss.reply(rpc, nil, e), false
+	}
+
+	op := rpc.Children[0]
+	var data []byte
+	var rerr *rpcError
+	done := false
+
+	switch op.Name.Local {
+	case "get":
+		data, rerr = ss.get(op, true)
+	case "get-config":
+		data, rerr = ss.get(op, false)
+	case "edit-config":
+		rerr = ss.editConfig(op)
+	case "commit":
+		rerr = ss.commit(op)
+	case "cancel-commit":
+		rerr = ss.cancelCommit(op)
+	case "discard-changes":
+		ss.server.candidate.Discard()
+	case "validate":
+		rerr = ss.validate(op)
+	case "lock":
+		rerr = ss.lock(op, true)
+	case "unlock":
+		rerr = ss.lock(op, false)
+	case "close-session":
+		done = true
+	case "kill-session":
+		rerr = ss.killSession(op)
+	case "create-subscription":
+		rerr = ss.createSubscription(op)
+	case "get-schema":
+		data, rerr = ss.getSchema(op)
+	default:
+		if m := ss.server.codec.schema.ModuleByNamespace(op.Name.Space); m != nil && m.RPC(op.Name.Local) != nil {
+			data, rerr = ss.callRPC(op, m, m.RPC(op.Name.Local))
+			break
+		}
+		rerr = newError("protocol", "operation-not-supported", fmt.Sprintf("operation '%s' not supported", op.Name.Local))
+	}
+
+	return ss.reply(rpc, data, rerr), done
+}
+
+func (ss *session) reply(rpc *element, data []byte, rerr *rpcError) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<rpc-reply xmlns="` + baseNamespace + `"`)
+	if rpc != nil {
+		for _, a := range rpc.Attrs {
+			if a.Name.Space == "" && a.Name.Local != "xmlns" {
+				buf.WriteString(" " + a.Name.Local + `="`)
+				xml.EscapeText(&buf, []byte(a.Value))
+				buf.WriteString(`"`)
+			}
+		}
+	}
+	buf.WriteString(">")
+
+	switch {
+	case rerr != nil:
+		rerr.encode(&buf)
+	case data != nil:
+		buf.Write(data)
+	default:
+		buf.WriteString("<ok/>")
+	}
+	buf.WriteString("</rpc-reply>")
+	return buf.Bytes()
+}
+
+// datastore returns the name of the datastore in a <source> or <target>,
+// running or candidate
+func datastore(op *element, name string) (string, *rpcError) {
+	el := op.child(name)
+	if el == nil || len(el.Children) != 1 {
+		return "", newError("protocol", "missing-element", fmt.Sprintf("missing %s datastore", name))
+	}
+	if ds := el.Children[0].Name.Local; ds != "running" && ds != "candidate" {
+		return "", newError("protocol", "invalid-value", fmt.Sprintf("datastore '%s' not supported", ds))
+	}
+	return el.Children[0].Name.Local, nil
+}
+
+// editTarget is the datastore an edit-config writes to
+type editTarget interface {
+	HasItem(xpath string) bool
+	SetItem(xpath, value string) error
+	DeleteItem(xpath string) error
+}
+
+func (ss *session) get(op *element, withState bool) ([]byte, *rpcError) {
+	source := "running"
+	if !withState {
+		var err *rpcError
+		if source, err = datastore(op, "source"); err != nil {
+			return nil, err
+		}
+	}
+
+	var paths []string
+	if filter := op.child("filter"); filter != nil {
+		p, err := ss.server.codec.filterPaths(filter)
+		if err != nil {
+			return nil, err
+		}
+		paths = p
+	} else {
+		paths = ss.topLevelPaths(withState)
+	}
+
+	tree := nbi.NewTree()
+	for _, path := range paths {
+		if source == "candidate" {
+			tree.Merge(ss.server.candidate.GetConfig(path))
+			continue
+		}
+		if !withState {
+			tree.Merge(ss.server.ds.GetConfig(path))
+			continue
+		}
+
+		result, err := ss.server.ds.GetItems(path)
+		if err != nil {
+			log.Error("NETCONF: get '%s' failed: %v", path, err)
+			return nil, newError("application", "operation-failed", err.Error())
+		}
+		tree.Merge(result)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<data>")
+	buf.WriteString(tree.XML(ss.server.codec.schema.Namespaces()))
+	buf.WriteString("</data>")
+	return buf.Bytes(), nil
+}
+
+func (ss *session) topLevelPaths(withState bool) []string {
+	var paths []string
+	schema := ss.server.codec.schema
+	for _, name := range schema.ModuleNames() {
+		for _, e := range schema.Modules[name].Nodes {
+			if withState || e.Config {
+				paths = append(paths, e.Path())
+			}
+		}
+	}
+	return paths
+}
+
+func (ss *session) originator() nbi.Originator {
+	return nbi.Originator{Name: "netconf", User: ss.user, Session: ss.id}
+}
+
+// editConfig commits an edit of running right away, one of candidate waits
+// for <commit>. With the test-only test option the edit is only checked.
+func (ss *session) editConfig(op *element) *rpcError {
+	target, err := datastore(op, "target")
+	if err != nil {
+		return err
+	}
+	if err := ss.server.checkLock(target, ss.id); err != nil {
+		return err
+	}
+
+	defaultOp := "merge"
+	if d := op.child("default-operation"); d != nil {
+		defaultOp = d.value()
+		if defaultOp != "merge" && defaultOp != "replace" && defaultOp != "none" {
+			return newError("protocol", "invalid-value", fmt.Sprintf("invalid default-operation '%s'", defaultOp))
+		}
+	}
+
+	testOnly := false
+	if t := op.child("test-option"); t != nil {
+		switch t.value() {
+		case "test-then-set", "set":
+		case "test-only":
+			testOnly = true
+		default:
+			return newError("protocol", "invalid-value", fmt.Sprintf("invalid test-option '%s'", t.value()))
+		}
+	}
+
+	config := op.child("config")
+	if config == nil {
+		return newError("protocol", "missing-element", "missing config")
+	}
+
+	edits := ss.server.ds.EditLock()
+	edits.Lock()
+	defer edits.Unlock()
+
+	if testOnly {
+		return ss.testEdit(target, config, defaultOp)
+	}
+
+	if target == "candidate" {
+		return ss.editAll(ss.server.candidate, config, defaultOp)
+	}
+
+	ds := ss.server.ds
+	ds.DiscardChanges()
+	if err := ss.editAll(ds, config, defaultOp); err != nil {
+		ds.DiscardChanges()
+		return err
+	}
+
+	if err := ds.ApplyChangesAs(ss.originator()); err != nil {
+		log.Error("NETCONF: session %d edit-config failed: %v", ss.id, err)
+		return changeError(err)
+	}
+	return nil
+}
+
+// testEdit stages an edit in a copy of target and checks it there
+func (ss *session) testEdit(target string, config *element, defaultOp string) *rpcError {
+	scratch := nbi.NewCandidate(ss.server.ds, ss.server.dryRun)
+	if target == "candidate" {
+		scratch = ss.server.candidate.Copy(ss.server.dryRun)
+	}
+	if err := ss.editAll(scratch, config, defaultOp); err != nil {
+		return err
+	}
+	if err := scratch.Validate(ss.originator()); err != nil {
+		log.Error("NETCONF: session %d test of edit-config failed: %v", ss.id, err)
+		return changeError(err)
+	}
+	return nil
+}
+
+// editAll stages the edits of every element of config in ds
+func (ss *session) editAll(ds editTarget, config *element, defaultOp string) *rpcError {
+	if defaultOp == "replace" {
+		for _, path := range ss.topLevelPaths(false) {
+			ds.DeleteItem(path)
+		}
+		defaultOp = "merge"
+	}
+
+	for _, el := range config.Children {
+		if err := ss.edit(ds, el, "", "", nil, defaultOp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// edit stages the edit of one data element and its descendants
+func (ss *session) edit(ds editTarget, el *element, parentPath, parentModule string, parent *yang.Entry, op string) *rpcError {
+	codec := ss.server.codec
+	module, entry, err := codec.resolve(el, parentModule, parent)
+	if err != nil {
+		return err
+	}
+
+	seg, err := codec.segment(el, module, parentModule, entry)
+	if err != nil {
+		return err
+	}
+	path := parentPath + "/" + seg
+
+	if o, ok := el.attr(baseNamespace, "operation"); ok {
+		op = o
+	}
+	if !entry.Config && op != "none" {
+		err := newError("application", "invalid-value", fmt.Sprintf("'%s' is not configuration data", el.Name.Local))
+		err.Path = path
+		return err
+	}
+
+	exists := ds.HasItem(path)
+	switch op {
+	case "create":
+		if exists {
+			err := newError("application", "data-exists", "data already exists")
+			err.Path = path
+			return err
+		}
+	case "delete":
+		if !exists {
+			err := newError("application", "data-missing", "data does not exist")
+			err.Path = path
+			return err
+		}
+		ds.DeleteItem(path)
+		return nil
+	case "remove":
+		if exists {
+			ds.DeleteItem(path)
+		}
+		return nil
+	case "replace":
+		if exists {
+			ds.DeleteItem(path)
+		}
+	case "merge", "none":
+	default:
+		return newError("protocol", "bad-attribute", fmt.Sprintf("invalid operation '%s'", op))
+	}
+
+	if entry.IsLeaf() {
+		if op == "none" {
+			return nil
+		}
+		if e := ds.SetItem(path, el.value()); e != nil {
+			return newError("application", "invalid-value", e.Error())
+		}
+		return nil
+	}
+
+	childOp := op
+	if op != "none" {
+		if e := ds.SetItem(path, ""); e != nil {
+			return newError("application", "invalid-value", e.Error())
+		}
+		childOp = "merge"
+	}
+
+	for _, child := range el.Children {
+		if entry.Kind == yang.List && entry.IsKey(child.Name.Local) {
+			continue
+		}
+		if err := ss.edit(ds, child, path, module, entry, childOp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit applies the candidate to running, see RFC 6241 section 8.4 for
+// the confirmed commit
+func (ss *session) commit(op *element) *rpcError {
+	if err := ss.server.checkLock("running", ss.id); err != nil {
+		return err
+	}
+
+	opts := nbi.CommitOptions{Confirmed: op.child("confirmed") != nil}
+	if el := op.child("confirm-timeout"); el != nil {
+		seconds, err := strconv.ParseUint(el.value(), 10, 32)
+		if err != nil || seconds == 0 {
+			return newError("protocol", "invalid-value", fmt.Sprintf("invalid confirm-timeout '%s'", el.value()))
+		}
+		opts.Timeout = time.Duration(seconds) * time.Second
+	}
+	if el := op.child("persist"); el != nil {
+		opts.Persist = el.value()
+	}
+	if el := op.child("persist-id"); el != nil {
+		opts.PersistID = el.value()
+	}
+
+	edits := ss.server.ds.EditLock()
+	edits.Lock()
+	defer edits.Unlock()
+
+	if err := ss.server.candidate.Commit(ss.originator(), opts); err != nil {
+		log.Error("NETCONF: session %d commit failed: %v", ss.id, err)
+		return changeError(err)
+	}
+	return nil
+}
+
+func (ss *session) cancelCommit(op *element) *rpcError {
+	var persistID string
+	if el := op.child("persist-id"); el != nil {
+		persistID = el.value()
+	}
+
+	edits := ss.server.ds.EditLock()
+	edits.Lock()
+	defer edits.Unlock()
+
+	if err := ss.server.candidate.CancelCommit(ss.originator(), persistID); err != nil {
+		return changeError(err)
+	}
+	return nil
+}
+
+// validate checks the candidate the way a commit would. The running
+// configuration has been validated by its commits already.
+func (ss *session) validate(op *element) *rpcError {
+	source, err := datastore(op, "source")
+	if err != nil {
+		return err
+	}
+	if source == "candidate" {
+		if err := ss.server.candidate.Validate(ss.originator()); err != nil {
+			return changeError(err)
+		}
+	}
+	return nil
+}
+
+func (ss *session) lock(op *element, lock bool) *rpcError {
+	target, err := datastore(op, "target")
+	if err != nil {
+		return err
+	}
+	if lock {
+		return ss.server.lock(target, ss.id)
+	}
+	return ss.server.unlock(target, ss.id)
+}
+
+func (ss *session) killSession(op *element) *rpcError {
+	el := op.child("session-id")
+	if el == nil {
+		return newError("protocol", "missing-element", "missing session-id")
+	}
+
+	id, err := strconv.ParseUint(el.value(), 10, 32)
+	if err != nil || uint32(id) == ss.id {
+		return newError("protocol", "invalid-value", fmt.Sprintf("invalid session-id '%s'", el.value()))
+	}
+
+	other := ss.server.session(uint32(id))
+	if other == nil {
+		return newError("protocol", "invalid-value", fmt.Sprintf("session %d not found", id))
+	}
+	other.close()
+	return nil
+}
+
+func (ss *session) createSubscription(op *element) *rpcError {
+	if stream := op.child("stream"); stream != nil && stream.value() != "NETCONF" {
+		return newError("application", "invalid-value", fmt.Sprintf("stream '%s' not supported", stream.value()))
+	}
+	if op.child("startTime") != nil {
+		return newError("protocol", "operation-not-supported", "replay is not supported")
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.subscribed {
+		return newError("protocol", "in-use", "subscription already active")
+	}
+	ss.subscribed = true
+	return nil
+}
+
+func (ss *session) notify(n nbi.Notification) {
+	ss.mu.Lock()
+	subscribed := ss.subscribed && !ss.closed
+	ss.mu.Unlock()
+	if !subscribed {
+		return
+	}
+
+	tree, err := notificationTree(n)
+	if err != nil {
+		log.Error("NETCONF: invalid notification '%s': %v", n.Xpath, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<notification xmlns="` + notifNamespace + `"><eventTime>`)
+	buf.WriteString(n.Time.UTC().Format(time.RFC3339))
+	buf.WriteString("</eventTime>")
+	buf.WriteString(tree.XML(ss.server.codec.schema.Namespaces()))
+	buf.WriteString("</notification>")
+
+	if err := ss.framer.WriteMessage(buf.Bytes()); err != nil {
+		log.Error("NETCONF: session %d notification failed: %v", ss.id, err)
+	}
+}
+
+// callRPC invokes a YANG RPC of a loaded module. Input and output are
+// flat leaves, defaults of missing input leaves are filled in.
+func (ss *session) callRPC(op *element, m *yang.Module, rpc *yang.Entry) ([]byte, *rpcError) {
+	in := rpc.Child("input")
+	if in == nil {
+		in = &yang.Entry{}
+	}
+
+	input := make(map[string]string)
+	for _, c := range op.Children {
+		if in.Child(c.Name.Local) == nil || c.Name.Space != m.Namespace || len(c.Children) != 0 {
+			err := newError("protocol", "unknown-element", fmt.Sprintf("unexpected element '%s'", c.Name.Local))
+			err.Info = "<bad-element>" + c.Name.Local + "</bad-element>"
+			return nil, err
+		}
+		input[c.Name.Local] = c.value()
+	}
+	for _, e := range in.Children {
+		if _, ok := input[e.Name]; ok {
+			continue
+		}
+		if e.Mandatory {
+			err := newError("protocol", "missing-element", fmt.Sprintf("missing input '%s'", e.Name))
+			err.Info = "<bad-element>" + e.Name + "</bad-element>"
+			return nil, err
+		}
+		if e.Default != "" {
+			input[e.Name] = e.Default
+		}
+	}
+
+	output, err := ss.server.ds.CallRPCAs(ss.originator(), "/"+m.Name+":"+rpc.Name, input)
+	if err != nil {
+		return nil, changeError(err)
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(output))
+	for name := range output {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString("<" + name + ` xmlns="` + m.Namespace + `">`)
+		xml.EscapeText(&buf, []byte(output[name]))
+		buf.WriteString("</" + name + ">")
+	}
+	return buf.Bytes(), nil
+}
+
+func (ss *session) getSchema(op *element) ([]byte, *rpcError) {
+	id := op.child("identifier")
+	if id == nil {
+		return nil, newError("protocol", "missing-element", "missing identifier")
+	}
+	if f := op.child("format"); f != nil && !strings.HasSuffix(f.value(), "yang") {
+		return nil, newError("application", "invalid-value", fmt.Sprintf("format '%s' not supported", f.value()))
+	}
+
+	m, ok := ss.server.codec.schema.Modules[id.value()]
+	if !ok {
+		return nil, newError("application", "invalid-value", fmt.Sprintf("schema '%s' not found", id.value()))
+	}
+	if v := op.child("version"); v != nil && v.value() != "" && v.value() != m.Revision {
+		return nil, newError("application", "invalid-value", fmt.Sprintf("revision '%s' of '%s' not found", v.value(), m.Name))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<data xmlns="` + monitoringNamespace + `">`)
+	xml.EscapeText(&buf, []byte(m.Source))
+	buf.WriteString("</data>")
+	return buf.Bytes(), nil
+}
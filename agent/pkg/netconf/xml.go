@@ -0,0 +1,287 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+)
+
+const (
+	baseNamespace       = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	notifNamespace      = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+	monitoringNamespace = "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"
+)
+
+// element is a generic XML element with resolved namespaces
+type element struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Text     string
+	Children []*element
+}
+
+func parseXML(data []byte) (*element, error) {
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimSpace(data)))
+
+	var stack []*element
+	var root *element
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if root != nil && len(stack) == 0 {
+				return root, nil
+			}
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			el := &element{Name: t.Name, Attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, el)
+			} else if root == nil {
+				root = el
+			} else {
+				return nil, fmt.Errorf("multiple root elements")
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += string(t)
+			}
+		}
+	}
+}
+
+func (e *element) attr(space, local string) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Name.Local == local && (a.Name.Space == space || space == "") {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (e *element) child(local string) *element {
+	for _, c := range e.Children {
+		if c.Name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+func (e *element) value() string {
+	return strings.TrimSpace(e.Text)
+}
+
+func (e *element) isLeafValue() bool {
+	return len(e.Children) == 0 && e.value() != ""
+}
+
+// prefixes returns the namespace prefixes declared on e
+func (e *element) prefixes() map[string]string {
+	result := make(map[string]string)
+	for _, a := range e.Attrs {
+		if a.Name.Space == "xmlns" {
+			result[a.Name.Local] = a.Value
+		}
+	}
+	return result
+}
+
+// codec converts between NETCONF XML content and nbi data trees
+type codec struct {
+	schema *yang.Schema
+}
+
+// resolve finds the module and schema node of a data element below parent
+func (c *codec) resolve(el *element, parentModule string, parent *yang.Entry) (string, *yang.Entry, *rpcError) {
+	module := parentModule
+	if el.Name.Space != "" {
+		m := c.schema.ModuleByNamespace(el.Name.Space)
+		if m == nil {
+			return "", nil, newError("application", "unknown-namespace", fmt.Sprintf("unknown namespace '%s'", el.Name.Space))
+		}
+		module = m.Name
+	}
+	if module == "" {
+		return "", nil, newError("application", "unknown-namespace", fmt.Sprintf("missing namespace of '%s'", el.Name.Local))
+	}
+
+	var entry *yang.Entry
+	if parent == nil {
+		entry = c.schema.Find(module, []string{el.Name.Local})
+	} else {
+		entry = parent.Child(el.Name.Local)
+	}
+	if entry == nil {
+		return "", nil, newError("application", "unknown-element", fmt.Sprintf("unknown element '%s'", el.Name.Local))
+	}
+	return module, entry, nil
+}
+
+// segment returns the path step of a data element, with the key predicates
+// of a list entry taken from its key leaves.
+func (c *codec) segment(el *element, module, parentModule string, entry *yang.Entry) (string, *rpcError) {
+	seg := nbi.PathSegment{Module: module, Name: el.Name.Local}
+	if entry.Kind == yang.List {
+		for _, key := range entry.Keys {
+			k := el.child(key)
+			if k == nil {
+				return "", newError("protocol", "missing-element", fmt.Sprintf("missing key '%s' of list '%s'", key, entry.Name))
+			}
+			seg.Keys = append(seg.Keys, nbi.KeyValue{Name: key, Value: k.value()})
+		}
+	}
+	return seg.String(module != parentModule), nil
+}
+
+// filterPaths converts a subtree filter into the xpaths it selects. Key
+// content-match nodes become predicates, other content-match nodes select
+// the leaf itself.
+func (c *codec) filterPaths(filter *element) ([]string, *rpcError) {
+	if t, ok := filter.attr("", "type"); ok && t == "xpath" {
+		sel, _ := filter.attr("", "select")
+		path, err := c.xpathFilter(sel, filter.prefixes())
+		if err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	var paths []string
+	for _, el := range filter.Children {
+		p, err := c.subtreePaths(el, "", "", nil)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p...)
+	}
+	return paths, nil
+}
+
+func (c *codec) subtreePaths(el *element, parentPath, parentModule string, parent *yang.Entry) ([]string, *rpcError) {
+	module, entry, err := c.resolve(el, parentModule, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := nbi.PathSegment{Module: module, Name: el.Name.Local}
+	var selections []*element
+	for _, child := range el.Children {
+		if entry.Kind == yang.List && entry.IsKey(child.Name.Local) && child.isLeafValue() {
+			seg.Keys = append(seg.Keys, nbi.KeyValue{Name: child.Name.Local, Value: child.value()})
+			continue
+		}
+		selections = append(selections, child)
+	}
+
+	path := parentPath + "/" + seg.String(module != parentModule)
+	if len(selections) == 0 {
+		return []string{path}, nil
+	}
+
+	var paths []string
+	for _, child := range selections {
+		p, err := c.subtreePaths(child, path, module, entry)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p...)
+	}
+	return paths, nil
+}
+
+// xpathFilter rewrites the namespace prefixes of a simple location path into
+// module names.
+func (c *codec) xpathFilter(sel string, prefixes map[string]string) (string, *rpcError) {
+	segs, err := nbi.ParsePath(sel)
+	if err != nil {
+		return "", newError("protocol", "invalid-value", err.Error())
+	}
+
+	var b strings.Builder
+	module := ""
+	for _, seg := range segs {
+		if seg.Module != "" {
+			m := c.moduleByPrefix(seg.Module, prefixes)
+			if m == nil {
+				return "", newError("protocol", "invalid-value", fmt.Sprintf("unknown prefix '%s' in '%s'", seg.Module, sel))
+			}
+			seg.Module = m.Name
+		} else if module == "" {
+			return "", newError("protocol", "invalid-value", fmt.Sprintf("missing prefix in '%s'", sel))
+		} else {
+			seg.Module = module
+		}
+		b.WriteString("/" + seg.String(seg.Module != module))
+		module = seg.Module
+	}
+	return b.String(), nil
+}
+
+func (c *codec) moduleByPrefix(prefix string, prefixes map[string]string) *yang.Module {
+	if ns, ok := prefixes[prefix]; ok {
+		if m := c.schema.ModuleByNamespace(ns); m != nil {
+			return m
+		}
+	}
+	if m, ok := c.schema.Modules[prefix]; ok {
+		return m
+	}
+	for _, m := range c.schema.Modules {
+		if m.Prefix == prefix {
+			return m
+		}
+	}
+	return nil
+}
+
+// notificationTree builds the data tree of a notification
+func notificationTree(n nbi.Notification) (*nbi.Node, error) {
+	tree := nbi.NewTree()
+	if _, err := tree.Create(n.Xpath); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(n.Leaves))
+	for name := range n.Leaves {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := tree.Set(n.Xpath+"/"+name, n.Leaves[name]); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,400 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+)
+
+// restconfError is an entry of an RFC 8040 "errors" response
+type restconfError struct {
+	status  int
+	Type    string
+	Tag     string
+	Path    string
+	Message string
+}
+
+func newError(status int, tag, message string) *restconfError {
+	errType := "application"
+	if status == http.StatusBadRequest || status == http.StatusMethodNotAllowed {
+		errType = "protocol"
+	}
+	return &restconfError{status: status, Type: errType, Tag: tag, Message: message}
+}
+
+// changeError reports a rejected commit, with the tag and offending node of
+// an nbi.ChangeError
+func changeError(err error) *restconfError {
+	var cerr *nbi.ChangeError
+	if !errors.As(err, &cerr) {
+		return newError(http.StatusInternalServerError, "operation-failed", err.Error())
+	}
+
+	var e *restconfError
+	switch cerr.Tag {
+	case "invalid-value":
+		e = newError(http.StatusBadRequest, cerr.Tag, cerr.Message)
+	case "operation-not-supported":
+		e = newError(http.StatusNotImplemented, cerr.Tag, cerr.Message)
+	case "access-denied":
+		e = newError(http.StatusForbidden, cerr.Tag, cerr.Message)
+	default:
+		e = newError(http.StatusInternalServerError, "operation-failed", cerr.Message)
+	}
+	e.Type, e.Path = "application", cerr.Xpath
+	return e
+}
+
+func (e *restconfError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Tag, e.Message)
+}
+
+// target is the data resource addressed by a request URL
+type target struct {
+	xpath   string
+	url     string
+	module  string
+	entry   *yang.Entry
+	segment nbi.PathSegment
+}
+
+func (t *target) isDatastore() bool {
+	return t.entry == nil
+}
+
+// codec maps RESTCONF URLs and message bodies onto nbi data trees
+type codec struct {
+	schema *yang.Schema
+}
+
+// parseTarget converts the escaped path below /restconf/data into an xpath.
+// List instances are written as name=key1,key2 with percent-encoded keys.
+func (c *codec) parseTarget(path string) (*target, *restconfError) {
+	t := &target{}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return t, nil
+	}
+
+	var parent *yang.Entry
+	for _, step := range strings.Split(path, "/") {
+		name, keys := step, ""
+		if i := strings.Index(step, "="); i >= 0 {
+			name, keys = step[:i], step[i+1:]
+		}
+		name, err := url.PathUnescape(name)
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalid-value", err.Error())
+		}
+
+		module := t.module
+		if i := strings.Index(name, ":"); i >= 0 {
+			module, name = name[:i], name[i+1:]
+		}
+		if module == "" {
+			return nil, newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("missing module name of '%s'", name))
+		}
+
+		entry := c.lookup(parent, module, name)
+		if entry == nil {
+			return nil, newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("unknown resource '%s:%s'", module, name))
+		}
+
+		seg := nbi.PathSegment{Module: module, Name: name}
+		if entry.Kind == yang.List {
+			values := strings.Split(keys, ",")
+			if keys == "" || len(values) != len(entry.Keys) {
+				return nil, newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("list '%s' needs the keys %v", name, entry.Keys))
+			}
+			for i, k := range entry.Keys {
+				v, err := url.PathUnescape(values[i])
+				if err != nil {
+					return nil, newError(http.StatusBadRequest, "invalid-value", err.Error())
+				}
+				seg.Keys = append(seg.Keys, nbi.KeyValue{Name: k, Value: v})
+			}
+		} else if keys != "" {
+			return nil, newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("'%s' is not a list", name))
+		}
+
+		t.xpath += "/" + seg.String(module != t.module)
+		t.url += "/" + urlSegment(seg, module != t.module)
+		t.module, t.entry, t.segment = module, entry, seg
+		parent = entry
+	}
+	return t, nil
+}
+
+func (c *codec) lookup(parent *yang.Entry, module, name string) *yang.Entry {
+	if parent == nil {
+		return c.schema.Find(module, []string{name})
+	}
+	e := parent.Child(name)
+	if e == nil || e.Module.Name != module {
+		return nil
+	}
+	return e
+}
+
+func urlSegment(seg nbi.PathSegment, withModule bool) string {
+	s := url.PathEscape(seg.Name)
+	if withModule {
+		s = url.PathEscape(seg.Module) + ":" + s
+	}
+	for i, k := range seg.Keys {
+		if i == 0 {
+			s += "="
+		} else {
+			s += ","
+		}
+		s += url.PathEscape(k.Value)
+	}
+	return s
+}
+
+// decode parses a message body holding children of the parent schema node
+// into a detached data tree.
+func (c *codec) decode(body io.Reader, contentType string, parent *yang.Entry, parentModule string) (*nbi.Node, *restconfError) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "malformed-message", err.Error())
+	}
+
+	root := &nbi.Node{Module: parentModule}
+	if strings.Contains(contentType, "xml") {
+		err = c.decodeXML(data, root, parent)
+	} else {
+		err = c.decodeJSON(data, root, parent)
+	}
+	if err != nil {
+		if rerr, ok := err.(*restconfError); ok {
+			return nil, rerr
+		}
+		return nil, newError(http.StatusBadRequest, "malformed-message", err.Error())
+	}
+	return root, nil
+}
+
+func (c *codec) decodeJSON(data []byte, root *nbi.Node, parent *yang.Entry) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var obj map[string]interface{}
+	if err := d.Decode(&obj); err != nil {
+		return err
+	}
+	return c.jsonMembers(obj, root, parent)
+}
+
+func (c *codec) jsonMembers(obj map[string]interface{}, node *nbi.Node, parent *yang.Entry) error {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, member := range names {
+		module, name := node.Module, member
+		if i := strings.Index(member, ":"); i >= 0 {
+			module, name = member[:i], member[i+1:]
+		}
+		entry := c.lookup(parent, module, name)
+		if entry == nil {
+			return newError(http.StatusBadRequest, "unknown-element", fmt.Sprintf("unknown element '%s'", member))
+		}
+
+		value := obj[member]
+		switch entry.Kind {
+		case yang.Leaf:
+			v, err := jsonLeafValue(value)
+			if err != nil {
+				return newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("%s: %v", member, err))
+			}
+			node.Children = append(node.Children, &nbi.Node{Module: module, Name: name, Value: v, Leaf: true})
+		case yang.List:
+			entries, ok := value.([]interface{})
+			if !ok {
+				return newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("list '%s' must be an array", member))
+			}
+			for _, e := range entries {
+				m, ok := e.(map[string]interface{})
+				if !ok {
+					return newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("entry of '%s' must be an object", member))
+				}
+				child := &nbi.Node{Module: module, Name: name}
+				for _, k := range entry.Keys {
+					v, err := jsonLeafValue(m[k])
+					if m[k] == nil || err != nil {
+						return newError(http.StatusBadRequest, "missing-element", fmt.Sprintf("missing key '%s' of list '%s'", k, name))
+					}
+					child.Keys = append(child.Keys, nbi.KeyValue{Name: k, Value: v})
+					child.Children = append(child.Children, &nbi.Node{Module: module, Name: k, Value: v, Leaf: true})
+					delete(m, k)
+				}
+				if err := c.jsonMembers(m, child, entry); err != nil {
+					return err
+				}
+				node.Children = append(node.Children, child)
+			}
+		case yang.Container:
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("container '%s' must be an object", member))
+			}
+			child := &nbi.Node{Module: module, Name: name}
+			if err := c.jsonMembers(m, child, entry); err != nil {
+				return err
+			}
+			node.Children = append(node.Children, child)
+		default:
+			return newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("'%s' is not supported in data", member))
+		}
+	}
+	return nil
+}
+
+func jsonLeafValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case []interface{}:
+		if len(val) == 1 && val[0] == nil {
+			return "", nil
+		}
+	}
+	return "", fmt.Errorf("invalid leaf value")
+}
+
+func (c *codec) decodeXML(data []byte, root *nbi.Node, parent *yang.Entry) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if err := c.xmlElement(d, start, root, parent); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *codec) xmlElement(d *xml.Decoder, start xml.StartElement, parentNode *nbi.Node, parent *yang.Entry) error {
+	module := parentNode.Module
+	if start.Name.Space != "" {
+		m := c.schema.ModuleByNamespace(start.Name.Space)
+		if m == nil {
+			return newError(http.StatusBadRequest, "unknown-namespace", fmt.Sprintf("unknown namespace '%s'", start.Name.Space))
+		}
+		module = m.Name
+	}
+
+	entry := c.lookup(parent, module, start.Name.Local)
+	if entry == nil {
+		return newError(http.StatusBadRequest, "unknown-element", fmt.Sprintf("unknown element '%s'", start.Name.Local))
+	}
+
+	node := &nbi.Node{Module: module, Name: start.Name.Local}
+	var text strings.Builder
+	for done := false; !done; {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := c.xmlElement(d, t, node, entry); err != nil {
+				return err
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			done = true
+		}
+	}
+
+	switch entry.Kind {
+	case yang.Leaf:
+		node.Leaf, node.Value = true, strings.TrimSpace(text.String())
+	case yang.List:
+		for _, k := range entry.Keys {
+			key := node.Child(k)
+			if key == nil {
+				return newError(http.StatusBadRequest, "missing-element", fmt.Sprintf("missing key '%s' of list '%s'", k, node.Name))
+			}
+			node.Keys = append(node.Keys, nbi.KeyValue{Name: k, Value: key.Value})
+		}
+	case yang.Container:
+	default:
+		return newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("'%s' is not supported in data", node.Name))
+	}
+	parentNode.Children = append(parentNode.Children, node)
+	return nil
+}
+
+// pruneConfig removes the configuration leaves of a tree, keeping list keys
+// and state data. It returns false when nothing is left of n.
+func (c *codec) pruneConfig(n *nbi.Node, entry *yang.Entry) bool {
+	if n.Leaf {
+		return entry == nil || !entry.Config
+	}
+
+	children := n.Children[:0]
+	for _, child := range n.Children {
+		var e *yang.Entry
+		if entry == nil {
+			e = c.schema.Find(child.Module, []string{child.Name})
+		} else {
+			e = entry.Child(child.Name)
+		}
+		if entry != nil && entry.IsKey(child.Name) {
+			children = append(children, child)
+			continue
+		}
+		if c.pruneConfig(child, e) {
+			children = append(children, child)
+		}
+	}
+	n.Children = children
+
+	return len(n.Children) > len(n.Keys)
+}
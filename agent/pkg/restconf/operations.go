@@ -0,0 +1,195 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+)
+
+// operations lists the RPCs of every loaded module
+func (s *Server) operations(w http.ResponseWriter, r *http.Request) {
+	var jsonOps []string
+	var xmlOps bytes.Buffer
+	for _, name := range s.codec.schema.ModuleNames() {
+		m := s.codec.schema.Modules[name]
+		for _, rpc := range m.RPCs {
+			jsonOps = append(jsonOps, fmt.Sprintf(`"%s:%s":[null]`, m.Name, rpc.Name))
+			fmt.Fprintf(&xmlOps, `<%s xmlns="%s"/>`, rpc.Name, m.Namespace)
+		}
+	}
+	s.writeRaw(w, r, "ietf-restconf:operations", "{"+strings.Join(jsonOps, ",")+"}", xmlOps.String())
+}
+
+// invoke runs the operation resource /restconf/operations/<module>:<rpc>.
+// Input and output are flat leaves, defaults of missing input leaves are
+// filled in.
+func (s *Server) invoke(w http.ResponseWriter, r *http.Request, path string) {
+	if r.Method != http.MethodPost {
+		s.writeError(w, r, newError(http.StatusMethodNotAllowed, "operation-not-supported", "operations must be invoked with POST"))
+		return
+	}
+
+	name, err := url.PathUnescape(path)
+	if err != nil {
+		s.writeError(w, r, newError(http.StatusBadRequest, "invalid-value", err.Error()))
+		return
+	}
+	parts := strings.SplitN(name, ":", 2)
+	m, ok := s.codec.schema.Modules[parts[0]]
+	if len(parts) != 2 || !ok || m.RPC(parts[1]) == nil {
+		s.writeError(w, r, newError(http.StatusNotFound, "invalid-value", fmt.Sprintf("unknown operation '%s'", name)))
+		return
+	}
+	rpc := m.RPC(parts[1])
+
+	input, rerr := s.codec.decodeInput(r.Body, r.Header.Get("Content-Type"), m)
+	if rerr != nil {
+		s.writeError(w, r, rerr)
+		return
+	}
+	if rerr := checkInput(rpc, input); rerr != nil {
+		s.writeError(w, r, rerr)
+		return
+	}
+
+	output, err := s.store.CallRPCAs(originator(r), "/"+m.Name+":"+rpc.Name, input)
+	if err != nil {
+		s.writeError(w, r, changeError(err))
+		return
+	}
+	if len(output) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	names := make([]string, 0, len(output))
+	for name := range output {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if wantsXML(r) {
+		var buf bytes.Buffer
+		buf.WriteString(`<output xmlns="` + m.Namespace + `">`)
+		for _, name := range names {
+			buf.WriteString("<" + name + ">")
+			xml.EscapeText(&buf, []byte(output[name]))
+			buf.WriteString("</" + name + ">")
+		}
+		buf.WriteString("</output>")
+		w.Header().Set("Content-Type", mediaXML)
+		w.Write(buf.Bytes())
+		return
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{m.Name + ":output": output})
+	w.Header().Set("Content-Type", mediaJSON)
+	w.Write(body)
+}
+
+// decodeInput reads the leaves of an operation input, given as
+// {"<module>:input": {...}} or <input xmlns="...">...</input>.
+func (c *codec) decodeInput(body io.Reader, contentType string, m *yang.Module) (map[string]string, *restconfError) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, newError(http.StatusBadRequest, "malformed-message", err.Error())
+	}
+
+	input := make(map[string]string)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return input, nil
+	}
+
+	if strings.Contains(contentType, "xml") {
+		var doc struct {
+			XMLName xml.Name
+			Leaves  []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		}
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, newError(http.StatusBadRequest, "malformed-message", err.Error())
+		}
+		if doc.XMLName.Local != "input" || doc.XMLName.Space != m.Namespace {
+			return nil, newError(http.StatusBadRequest, "unknown-element", fmt.Sprintf("unexpected element '%s'", doc.XMLName.Local))
+		}
+		for _, leaf := range doc.Leaves {
+			input[leaf.XMLName.Local] = strings.TrimSpace(leaf.Value)
+		}
+		return input, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc map[string]map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, newError(http.StatusBadRequest, "malformed-message", err.Error())
+	}
+	for member, leaves := range doc {
+		if member != m.Name+":input" {
+			return nil, newError(http.StatusBadRequest, "unknown-element", fmt.Sprintf("unexpected member '%s'", member))
+		}
+		for name, value := range leaves {
+			v, err := jsonLeafValue(value)
+			if err != nil {
+				return nil, newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("%s: %v", name, err))
+			}
+			input[name] = v
+		}
+	}
+	return input, nil
+}
+
+// checkInput rejects unknown and missing mandatory leaves and fills in defaults
+func checkInput(rpc *yang.Entry, input map[string]string) *restconfError {
+	in := rpc.Child("input")
+	if in == nil {
+		in = &yang.Entry{}
+	}
+
+	for name := range input {
+		if e := in.Child(name); e == nil || e.Kind != yang.Leaf {
+			return newError(http.StatusBadRequest, "unknown-element", fmt.Sprintf("unknown input '%s'", name))
+		}
+	}
+	for _, e := range in.Children {
+		if _, ok := input[e.Name]; ok {
+			continue
+		}
+		if e.Mandatory {
+			return newError(http.StatusBadRequest, "missing-element", fmt.Sprintf("missing input '%s'", e.Name))
+		}
+		if e.Default != "" {
+			input[e.Name] = e.Default
+		}
+	}
+	return nil
+}
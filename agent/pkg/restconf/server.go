@@ -0,0 +1,574 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package restconf serves the agent's YANG modules over RESTCONF (RFC 8040).
+// Reads and edits go through an nbi.Store, so committed changes run the same
+// NBI handlers as NETCONF edits do.
+package restconf
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gerrit.o-ran-sc.org/r/ric-plt/xapp-frame/pkg/xapp"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	"github.com/spf13/viper"
+)
+
+const (
+	restconfNamespace  = "urn:ietf:params:xml:ns:yang:ietf-restconf"
+	yangLibraryVersion = "2016-06-21"
+	mediaJSON          = "application/yang-data+json"
+	mediaXML           = "application/yang-data+xml"
+)
+
+var log = xapp.Logger
+
+// Config holds the credentials of the users RESTCONF clients authenticate
+// as with HTTP Basic authentication
+type Config struct {
+	Users map[string]string
+}
+
+// ConfigFromViper reads the "restconf" section of the agent configuration.
+// Without users of its own RESTCONF has the ones of NETCONF.
+func ConfigFromViper() Config {
+	users := viper.GetStringMapString("restconf.users")
+	if len(users) == 0 {
+		users = viper.GetStringMapString("netconf.users")
+	}
+	return Config{Users: users}
+}
+
+type Server struct {
+	config  Config
+	store   nbi.Store
+	codec   *codec
+	alarms  *alarmStream
+	builtin map[string]func() *nbi.Node
+}
+
+// NewServer fails without users, as every request is authenticated
+func NewServer(config Config, store nbi.Store, schema *yang.Schema) (*Server, error) {
+	if len(config.Users) == 0 {
+		return nil, fmt.Errorf("no users to authenticate RESTCONF clients")
+	}
+
+	s := &Server{
+		config: config,
+		store:  store,
+		codec:  &codec{schema: schema},
+	}
+	s.alarms = newAlarmStream(store)
+	s.builtin = map[string]func() *nbi.Node{
+		"ietf-yang-library:modules-state":         s.modulesState,
+		"ietf-restconf-monitoring:restconf-state": s.restconfState,
+	}
+	return s, nil
+}
+
+// InjectRoutes registers the RESTCONF resources on the xapp-frame HTTP server
+func (s *Server) InjectRoutes() {
+	for _, method := range []string{"GET", "PUT", "PATCH", "POST", "DELETE"} {
+		xapp.Resource.InjectRoute("/restconf/data", s.ServeHTTP, method)
+		xapp.Resource.InjectRoute("/restconf/data/{path:.*}", s.ServeHTTP, method)
+	}
+	for _, url := range []string{"/.well-known/host-meta", "/restconf", "/restconf/yang-library-version",
+		"/restconf/operations", "/restconf/yang/{module}", "/restconf/streams/{path:.*}"} {
+		xapp.Resource.InjectRoute(url, s.ServeHTTP, "GET")
+	}
+	xapp.Resource.InjectRoute("/restconf/operations/{operation}", s.ServeHTTP, "POST")
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticate(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="restconf"`)
+		s.writeError(w, r, newError(http.StatusUnauthorized, "access-denied", "authentication failed"))
+		return
+	}
+	path := r.URL.EscapedPath()
+
+	switch {
+	case path == "/restconf/data" || strings.HasPrefix(path, "/restconf/data/"):
+		s.serveData(w, r, strings.TrimPrefix(path, "/restconf/data"))
+	case strings.HasPrefix(path, "/restconf/operations/"):
+		s.invoke(w, r, strings.TrimPrefix(path, "/restconf/operations/"))
+	case r.Method != http.MethodGet:
+		s.writeError(w, r, newError(http.StatusMethodNotAllowed, "operation-not-supported", "method not allowed"))
+	case path == "/.well-known/host-meta":
+		w.Header().Set("Content-Type", "application/xrd+xml")
+		fmt.Fprint(w, `<XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0"><Link rel="restconf" href="/restconf"/></XRD>`)
+	case path == "/restconf" || path == "/restconf/":
+		s.writeRaw(w, r, "ietf-restconf:restconf",
+			`{"data":{},"operations":{},"yang-library-version":"`+yangLibraryVersion+`"}`,
+			`<data/><operations/><yang-library-version>`+yangLibraryVersion+`</yang-library-version>`)
+	case path == "/restconf/yang-library-version":
+		s.writeRaw(w, r, "ietf-restconf:yang-library-version", `"`+yangLibraryVersion+`"`, yangLibraryVersion)
+	case path == "/restconf/operations":
+		s.operations(w, r)
+	case strings.HasPrefix(path, "/restconf/yang/"):
+		s.serveSchema(w, r, strings.TrimPrefix(path, "/restconf/yang/"))
+	case strings.HasPrefix(path, "/restconf/streams/"):
+		s.alarms.serve(w, r, strings.TrimPrefix(path, "/restconf/streams/"), s.codec.schema.Namespaces())
+	default:
+		s.writeError(w, r, newError(http.StatusNotFound, "invalid-value", "resource not found"))
+	}
+}
+
+// authenticate checks the HTTP Basic credentials of r against the users
+func (s *Server) authenticate(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	expected, known := s.config.Users[user]
+	if ok && known && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1 {
+		return true
+	}
+	log.Warn("RESTCONF: authentication failed for user '%s' from %s", user, r.RemoteAddr)
+	return false
+}
+
+func (s *Server) serveData(w http.ResponseWriter, r *http.Request, path string) {
+	if fn, rest, ok := s.builtinResource(path); ok {
+		if r.Method != http.MethodGet {
+			s.writeError(w, r, newError(http.StatusMethodNotAllowed, "operation-not-supported", "resource is read-only"))
+			return
+		}
+		s.serveBuiltin(w, r, fn(), rest)
+		return
+	}
+
+	t, rerr := s.codec.parseTarget(path)
+	if rerr != nil {
+		s.writeError(w, r, rerr)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.get(w, r, t)
+	case http.MethodPut, http.MethodPatch, http.MethodPost:
+		s.edit(w, r, t)
+	case http.MethodDelete:
+		s.delete(w, r, t)
+	default:
+		s.writeError(w, r, newError(http.StatusMethodNotAllowed, "operation-not-supported", "method not allowed"))
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, t *target) {
+	content := "all"
+	for name, values := range r.URL.Query() {
+		if name != "content" || len(values) != 1 {
+			s.writeError(w, r, newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("unsupported query parameter '%s'", name)))
+			return
+		}
+		content = values[0]
+	}
+	if content != "all" && content != "config" && content != "nonconfig" {
+		s.writeError(w, r, newError(http.StatusBadRequest, "invalid-value", fmt.Sprintf("invalid content '%s'", content)))
+		return
+	}
+
+	paths := []string{t.xpath}
+	if t.isDatastore() {
+		paths = s.topLevelPaths(content == "config")
+	}
+
+	tree := nbi.NewTree()
+	for _, p := range paths {
+		if content == "config" {
+			tree.Merge(s.store.GetConfig(p))
+			continue
+		}
+
+		result, err := s.store.GetItems(p)
+		if err != nil {
+			log.Error("RESTCONF: get '%s' failed: %v", p, err)
+			s.writeError(w, r, newError(http.StatusInternalServerError, "operation-failed", err.Error()))
+			return
+		}
+		tree.Merge(result)
+	}
+	if content == "nonconfig" {
+		s.codec.pruneConfig(tree, nil)
+	}
+
+	if t.isDatastore() {
+		s.writeData(w, r, http.StatusOK, tree, true)
+		return
+	}
+
+	node := tree.Find(t.xpath)
+	if node == nil {
+		s.writeError(w, r, newError(http.StatusNotFound, "invalid-value", "data resource not found"))
+		return
+	}
+	s.writeData(w, r, http.StatusOK, &nbi.Node{Children: []*nbi.Node{node}}, false)
+}
+
+func (s *Server) topLevelPaths(configOnly bool) []string {
+	var paths []string
+	for _, name := range s.codec.schema.ModuleNames() {
+		for _, e := range s.codec.schema.Modules[name].Nodes {
+			if !configOnly || e.Config {
+				paths = append(paths, e.Path())
+			}
+		}
+	}
+	return paths
+}
+
+// edit handles PUT (replace), PATCH (merge) and POST (create child)
+func (s *Server) edit(w http.ResponseWriter, r *http.Request, t *target) {
+	if t.entry != nil && !t.entry.Config {
+		s.writeError(w, r, newError(http.StatusBadRequest, "invalid-value", "resource is not configuration data"))
+		return
+	}
+
+	parent, parentModule := t.entry, t.module
+	if r.Method != http.MethodPost && !t.isDatastore() {
+		parent, parentModule = t.entry.Parent, ""
+		if parent != nil {
+			parentModule = parent.Module.Name
+		}
+	}
+
+	body, rerr := s.codec.decode(r.Body, r.Header.Get("Content-Type"), parent, parentModule)
+	if rerr != nil {
+		s.writeError(w, r, rerr)
+		return
+	}
+
+	edits := s.store.EditLock()
+	edits.Lock()
+	defer edits.Unlock()
+	s.store.DiscardChanges()
+
+	var status int
+	var location string
+	switch {
+	case r.Method == http.MethodPost:
+		status, location, rerr = s.post(t, body)
+	case t.isDatastore():
+		status, rerr = s.putDatastore(r.Method == http.MethodPut, body)
+	default:
+		status, rerr = s.putResource(r.Method == http.MethodPut, t, body)
+	}
+	if rerr != nil {
+		s.store.DiscardChanges()
+		s.writeError(w, r, rerr)
+		return
+	}
+
+	if err := s.store.ApplyChangesAs(originator(r)); err != nil {
+		log.Error("RESTCONF: %s '%s' failed: %v", r.Method, t.xpath, err)
+		s.writeError(w, r, changeError(err))
+		return
+	}
+
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	w.WriteHeader(status)
+}
+
+// originator names the client of an edit by the user it authenticated as
+func originator(r *http.Request) nbi.Originator {
+	user, _, _ := r.BasicAuth()
+	return nbi.Originator{Name: "restconf", User: user}
+}
+
+func (s *Server) post(t *target, body *nbi.Node) (int, string, *restconfError) {
+	if len(body.Children) != 1 {
+		return 0, "", newError(http.StatusBadRequest, "invalid-value", "exactly one child resource must be posted")
+	}
+	if t.entry != nil && t.entry.Kind == yang.List && !s.store.HasItem(t.xpath) {
+		return 0, "", newError(http.StatusNotFound, "invalid-value", "parent resource not found")
+	}
+
+	child := body.Children[0]
+	seg := nbi.PathSegment{Module: child.Module, Name: child.Name, Keys: child.Keys}
+	xpath := t.xpath + "/" + seg.String(child.Module != t.module)
+	if s.store.HasItem(xpath) {
+		err := newError(http.StatusConflict, "data-exists", "resource already exists")
+		err.Path = xpath
+		return 0, "", err
+	}
+
+	if err := s.apply(xpath, child); err != nil {
+		return 0, "", err
+	}
+	return http.StatusCreated, "/restconf/data" + t.url + "/" + urlSegment(seg, child.Module != t.module), nil
+}
+
+func (s *Server) putDatastore(replace bool, body *nbi.Node) (int, *restconfError) {
+	if replace {
+		for _, p := range s.topLevelPaths(true) {
+			if s.store.HasItem(p) {
+				s.store.DeleteItem(p)
+			}
+		}
+	}
+	for _, child := range body.Children {
+		seg := nbi.PathSegment{Module: child.Module, Name: child.Name, Keys: child.Keys}
+		if err := s.apply("/"+seg.String(true), child); err != nil {
+			return 0, err
+		}
+	}
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) putResource(replace bool, t *target, body *nbi.Node) (int, *restconfError) {
+	if len(body.Children) != 1 {
+		return 0, newError(http.StatusBadRequest, "invalid-value", "the body must hold exactly the target resource")
+	}
+	node := body.Children[0]
+	seg := nbi.PathSegment{Module: node.Module, Name: node.Name, Keys: node.Keys}
+	if seg.String(true) != t.segment.String(true) {
+		return 0, newError(http.StatusBadRequest, "invalid-value", "the body does not match the target resource")
+	}
+
+	exists := s.store.HasItem(t.xpath)
+	if !replace && !exists {
+		return 0, newError(http.StatusNotFound, "invalid-value", "data resource not found")
+	}
+	if replace && exists {
+		s.store.DeleteItem(t.xpath)
+	}
+	if err := s.apply(t.xpath, node); err != nil {
+		return 0, err
+	}
+
+	if replace && !exists {
+		return http.StatusCreated, nil
+	}
+	return http.StatusNoContent, nil
+}
+
+// apply stages node and its descendants at xpath
+func (s *Server) apply(xpath string, node *nbi.Node) *restconfError {
+	var err error
+	if node.Leaf {
+		err = s.store.SetItem(xpath, node.Value)
+	} else {
+		err = s.store.SetItem(xpath, "")
+	}
+	if err != nil {
+		return newError(http.StatusBadRequest, "invalid-value", err.Error())
+	}
+
+	for _, child := range node.Children {
+		if _, isKey := keyValue(node, child.Name); isKey && child.Leaf {
+			continue
+		}
+		seg := nbi.PathSegment{Module: child.Module, Name: child.Name, Keys: child.Keys}
+		if err := s.apply(xpath+"/"+seg.String(child.Module != node.Module), child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func keyValue(n *nbi.Node, name string) (string, bool) {
+	for _, k := range n.Keys {
+		if k.Name == name {
+			return k.Value, true
+		}
+	}
+	return "", false
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, t *target) {
+	if t.isDatastore() {
+		s.writeError(w, r, newError(http.StatusMethodNotAllowed, "operation-not-supported", "the datastore cannot be deleted"))
+		return
+	}
+
+	edits := s.store.EditLock()
+	edits.Lock()
+	defer edits.Unlock()
+
+	if !s.store.HasItem(t.xpath) {
+		s.writeError(w, r, newError(http.StatusNotFound, "data-missing", "data resource not found"))
+		return
+	}
+
+	s.store.DiscardChanges()
+	if err := s.store.DeleteItem(t.xpath); err != nil {
+		s.writeError(w, r, newError(http.StatusBadRequest, "invalid-value", err.Error()))
+		return
+	}
+	if err := s.store.ApplyChangesAs(originator(r)); err != nil {
+		log.Error("RESTCONF: DELETE '%s' failed: %v", t.xpath, err)
+		s.writeError(w, r, changeError(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) serveSchema(w http.ResponseWriter, r *http.Request, name string) {
+	name = strings.SplitN(name, "@", 2)[0]
+	m, ok := s.codec.schema.Modules[name]
+	if !ok {
+		s.writeError(w, r, newError(http.StatusNotFound, "invalid-value", fmt.Sprintf("module '%s' not found", name)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/yang")
+	fmt.Fprint(w, m.Source)
+}
+
+func (s *Server) builtinResource(path string) (func() *nbi.Node, string, bool) {
+	path = strings.Trim(path, "/")
+	for name, fn := range s.builtin {
+		if path == name || strings.HasPrefix(path, name+"/") {
+			return fn, path[len(name):], true
+		}
+	}
+	return nil, "", false
+}
+
+func (s *Server) serveBuiltin(w http.ResponseWriter, r *http.Request, tree *nbi.Node, rest string) {
+	node := tree.Children[0]
+	for _, step := range strings.Split(strings.Trim(rest, "/"), "/") {
+		if step == "" {
+			continue
+		}
+		if node = node.Child(step); node == nil {
+			s.writeError(w, r, newError(http.StatusNotFound, "invalid-value", "data resource not found"))
+			return
+		}
+	}
+	s.writeData(w, r, http.StatusOK, &nbi.Node{Children: []*nbi.Node{node}}, false)
+}
+
+// modulesState is the ietf-yang-library module list (RFC 7895)
+func (s *Server) modulesState() *nbi.Node {
+	const prefix = "/ietf-yang-library:modules-state"
+	tree := nbi.NewTree()
+	tree.Set(prefix+"/module-set-id", fmt.Sprintf("%d", len(s.codec.schema.Modules)))
+	for _, name := range s.codec.schema.ModuleNames() {
+		m := s.codec.schema.Modules[name]
+		entry := fmt.Sprintf("%s/module[name='%s'][revision='%s']", prefix, m.Name, m.Revision)
+		tree.Set(entry+"/namespace", m.Namespace)
+		tree.Set(entry+"/schema", "/restconf/yang/"+m.Name)
+		tree.Set(entry+"/conformance-type", "implement")
+	}
+	return tree
+}
+
+// restconfState lists the event streams (RFC 8040, section 9.2)
+func (s *Server) restconfState() *nbi.Node {
+	const prefix = "/ietf-restconf-monitoring:restconf-state"
+	tree := nbi.NewTree()
+	tree.Create(prefix + "/capabilities")
+	stream := fmt.Sprintf("%s/streams/stream[name='%s']", prefix, alarmStreamName)
+	tree.Set(stream+"/description", "RIC alarm raise and clear events")
+	for _, enc := range []string{"json", "xml"} {
+		tree.Set(fmt.Sprintf("%s/access[encoding='%s']/location", stream, enc), "/restconf/streams/"+alarmStreamName+"/"+enc)
+	}
+	return tree
+}
+
+func wantsXML(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "xml") && !strings.Contains(accept, "json")
+}
+
+func (s *Server) namespaces() map[string]string {
+	ns := s.codec.schema.Namespaces()
+	ns["ietf-yang-library"] = "urn:ietf:params:xml:ns:yang:ietf-yang-library"
+	ns["ietf-restconf-monitoring"] = "urn:ietf:params:xml:ns:yang:ietf-restconf-monitoring"
+	return ns
+}
+
+// writeData sends a data tree, wrapped into ietf-restconf:data for the
+// datastore resource.
+func (s *Server) writeData(w http.ResponseWriter, r *http.Request, status int, tree *nbi.Node, datastore bool) {
+	if wantsXML(r) {
+		w.Header().Set("Content-Type", mediaXML)
+		w.WriteHeader(status)
+		data := tree.XML(s.namespaces())
+		if datastore {
+			data = `<data xmlns="` + restconfNamespace + `">` + data + `</data>`
+		}
+		fmt.Fprint(w, data)
+		return
+	}
+
+	data := tree.JSON()
+	if data == "" {
+		data = "{}"
+	}
+	if datastore {
+		data = `{"ietf-restconf:data":` + data + `}`
+	}
+	w.Header().Set("Content-Type", mediaJSON)
+	w.WriteHeader(status)
+	fmt.Fprint(w, data)
+}
+
+// writeRaw sends an ietf-restconf resource given in both encodings
+func (s *Server) writeRaw(w http.ResponseWriter, r *http.Request, name, jsonValue, xmlContent string) {
+	if wantsXML(r) {
+		local := strings.SplitN(name, ":", 2)[1]
+		w.Header().Set("Content-Type", mediaXML)
+		fmt.Fprintf(w, `<%s xmlns="%s">%s</%s>`, local, restconfNamespace, xmlContent, local)
+		return
+	}
+	w.Header().Set("Content-Type", mediaJSON)
+	fmt.Fprintf(w, `{"%s":%s}`, name, jsonValue)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, e *restconfError) {
+	if wantsXML(r) {
+		var buf bytes.Buffer
+		buf.WriteString(`<errors xmlns="` + restconfNamespace + `"><error>`)
+		buf.WriteString("<error-type>" + e.Type + "</error-type><error-tag>" + e.Tag + "</error-tag>")
+		if e.Path != "" {
+			buf.WriteString("<error-path>")
+			xml.EscapeText(&buf, []byte(e.Path))
+			buf.WriteString("</error-path>")
+		}
+		buf.WriteString("<error-message>")
+		xml.EscapeText(&buf, []byte(e.Message))
+		buf.WriteString("</error-message></error></errors>")
+
+		w.Header().Set("Content-Type", mediaXML)
+		w.WriteHeader(e.status)
+		w.Write(buf.Bytes())
+		return
+	}
+
+	entry := map[string]string{"error-type": e.Type, "error-tag": e.Tag, "error-message": e.Message}
+	if e.Path != "" {
+		entry["error-path"] = e.Path
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"ietf-restconf:errors": map[string]interface{}{"error": []interface{}{entry}},
+	})
+
+	w.Header().Set("Content-Type", mediaJSON)
+	w.WriteHeader(e.status)
+	w.Write(body)
+}
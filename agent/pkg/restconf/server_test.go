@@ -0,0 +1,414 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restconf
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/yang"
+	"github.com/stretchr/testify/assert"
+)
+
+const xappDesc = "o-ran-sc-ric-xapp-desc-v1"
+
+var ds *nbi.MemDatastore
+var server *Server
+var ts *httptest.Server
+
+var alarmMu sync.Mutex
+var activeAlarms = map[string]string{}
+
+func TestMain(m *testing.M) {
+	schema, err := yang.LoadDir("../../yang")
+	if err != nil {
+		panic(err)
+	}
+
+	ds = nbi.NewMemDatastore()
+	ds.SubscribeModuleChange(xappDesc, func(s nbi.ChangeSession, module, xpath string, event nbi.Event, reqID int) error {
+		changes, _ := s.GetChanges("//.")
+		for _, c := range changes {
+			if event == nbi.EventChange && c.NewValue == "rejected" {
+				return errors.New("xApp rejected by appmgr")
+			}
+			if event == nbi.EventChange && c.NewValue == "invalid" {
+				return &nbi.ChangeError{Xpath: c.Xpath, Tag: "invalid-value", Message: "no such helm release"}
+			}
+			if event == nbi.EventChange && c.NewValue == "forbidden" {
+				o := s.Originator()
+				return &nbi.ChangeError{Xpath: c.Xpath, Tag: "access-denied", Message: o.Name + " user '" + o.User + "' may not deploy"}
+			}
+		}
+		return nil
+	})
+	ds.SubscribeOperData(xappDesc, "/o-ran-sc-ric-xapp-desc-v1:ric/health", func(module, xpath string, tree nbi.OperDataTree) error {
+		tree.CreateNewElement("/o-ran-sc-ric-xapp-desc-v1:ric/health/status[name='ueec']", "health", "healthy")
+		return nil
+	})
+	ds.SubscribeOperData("o-ran-sc-ric-alarm-v1", alarmsXpath, func(module, xpath string, tree nbi.OperDataTree) error {
+		alarmMu.Lock()
+		defer alarmMu.Unlock()
+		for id, text := range activeAlarms {
+			path := alarmsXpath + "/alarm[alarm-id='" + id + "']"
+			tree.CreateNewElement(path, "alarm-id", id)
+			tree.CreateNewElement(path, "alarm-text", text)
+			tree.CreateNewElement(path, "status", "active")
+		}
+		return nil
+	})
+
+	server, err = NewServer(Config{Users: map[string]string{"admin": "secret"}}, ds, schema)
+	if err != nil {
+		panic(err)
+	}
+	server.alarms.interval = 20 * time.Millisecond
+	ts = httptest.NewServer(server)
+
+	code := m.Run()
+	ts.Close()
+	os.Exit(code)
+}
+
+func request(t *testing.T, method, path, contentType, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	assert.Nil(t, err)
+	req.SetBasicAuth("admin", "secret")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+const xappPath = "/restconf/data/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp="
+
+func TestRootDiscovery(t *testing.T) {
+	resp, body := request(t, "GET", "/.well-known/host-meta", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `<Link rel="restconf" href="/restconf"/>`)
+
+	_, body = request(t, "GET", "/restconf", "", "")
+	assert.Equal(t, `{"ietf-restconf:restconf":{"data":{},"operations":{},"yang-library-version":"2016-06-21"}}`, body)
+
+	_, body = request(t, "GET", "/restconf/yang-library-version", mediaXML, "")
+	assert.Equal(t, `<yang-library-version xmlns="urn:ietf:params:xml:ns:yang:ietf-restconf">2016-06-21</yang-library-version>`, body)
+}
+
+func TestAuthentication(t *testing.T) {
+	resp, err := http.Get(ts.URL + "/restconf/data")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, `Basic realm="restconf"`, resp.Header.Get("WWW-Authenticate"))
+
+	req, _ := http.NewRequest("PUT", ts.URL+xappPath+"auth-xapp", strings.NewReader(`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"auth-xapp"}]}`))
+	req.Header.Set("Content-Type", mediaJSON)
+	req.SetBasicAuth("admin", "wrong")
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, string(body), `"error-tag":"access-denied"`)
+	assert.False(t, ds.HasItem("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='auth-xapp']"))
+
+	_, err = NewServer(Config{}, ds, server.codec.schema)
+	assert.NotNil(t, err)
+}
+
+func TestYangLibrary(t *testing.T) {
+	resp, body := request(t, "GET", "/restconf/data/ietf-yang-library:modules-state", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"name": "o-ran-sc-ric-xapp-desc-v1"`)
+	assert.Contains(t, body, `"schema": "/restconf/yang/o-ran-sc-ric-xapp-desc-v1"`)
+
+	resp, body = request(t, "GET", "/restconf/yang/o-ran-sc-ric-xapp-desc-v1", "", "")
+	assert.Equal(t, "application/yang", resp.Header.Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(body, "module o-ran-sc-ric-xapp-desc-v1"))
+
+	resp, _ = request(t, "GET", "/restconf/yang/unknown", "", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = request(t, "DELETE", "/restconf/data/ietf-yang-library:modules-state", "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestPutGetDelete(t *testing.T) {
+	resp, _ := request(t, "PUT", xappPath+"put-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"put-xapp","release-name":"put-release","version":"1.0.0"}]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "put-release", ds.GetConfig("").Find("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='put-xapp']/release-name").Value)
+
+	resp, body := request(t, "GET", xappPath+"put-xapp", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, mediaJSON, resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, `"o-ran-sc-ric-xapp-desc-v1:xapp": [`)
+	assert.Contains(t, body, `"release-name": "put-release"`)
+
+	resp, _ = request(t, "PUT", xappPath+"put-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"put-xapp","release-name":"new-release"}]}`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Nil(t, ds.GetConfig("").Find("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='put-xapp']/version"))
+
+	resp, _ = request(t, "PUT", xappPath+"put-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"other-xapp"}]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = request(t, "DELETE", xappPath+"put-xapp", "", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.False(t, ds.HasItem("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='put-xapp']"))
+
+	resp, body = request(t, "DELETE", xappPath+"put-xapp", "", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Contains(t, body, `"error-tag":"data-missing"`)
+}
+
+func TestEditLock(t *testing.T) {
+	// another northbound holds the edit lock while it stages its edits
+	other := "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='other-xapp']"
+	edits := ds.EditLock()
+	edits.Lock()
+	ds.SetItem(other+"/release-name", "other-release")
+
+	done := make(chan int)
+	go func() {
+		resp, _ := request(t, "PUT", xappPath+"locked-xapp", mediaJSON, `{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"locked-xapp"}]}`)
+		done <- resp.StatusCode
+	}()
+	select {
+	case <-done:
+		t.Error("the edit did not wait for the edit lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Nil(t, ds.ApplyChangesAs(nbi.Originator{Name: "netopeer2"}))
+	edits.Unlock()
+
+	assert.Equal(t, http.StatusCreated, <-done)
+	assert.True(t, ds.HasItem(other))
+	ds.DeleteItem(other)
+	ds.DeleteItem("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='locked-xapp']")
+	assert.Nil(t, ds.ApplyChanges())
+}
+
+func TestPostAndPatch(t *testing.T) {
+	post := `<xapp xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>post-xapp</name><release-name>post-release</release-name></xapp>`
+	resp, _ := request(t, "POST", "/restconf/data/o-ran-sc-ric-xapp-desc-v1:ric/xapps", mediaXML, post)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/restconf/data/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp=post-xapp", resp.Header.Get("Location"))
+
+	resp, body := request(t, "POST", "/restconf/data/o-ran-sc-ric-xapp-desc-v1:ric/xapps", mediaXML, post)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Contains(t, body, "<error-tag>data-exists</error-tag>")
+
+	resp, _ = request(t, "PATCH", xappPath+"post-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"post-xapp","version":"2.0.0"}]}`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	config := ds.GetConfig("")
+	assert.Equal(t, "post-release", config.Find("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='post-xapp']/release-name").Value)
+	assert.Equal(t, "2.0.0", config.Find("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='post-xapp']/version").Value)
+
+	resp, _ = request(t, "PATCH", xappPath+"missing", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"missing","version":"2.0.0"}]}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body = request(t, "GET", xappPath+"post-xapp", mediaXML, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `<xapp xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>post-xapp</name>`)
+
+	resp, _ = request(t, "DELETE", xappPath+"post-xapp", "", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestRejectedEdit(t *testing.T) {
+	resp, body := request(t, "PUT", xappPath+"bad-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"bad-xapp","release-name":"rejected"}]}`)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, body, `"error-tag":"operation-failed"`)
+	assert.Contains(t, body, "xApp rejected by appmgr")
+	assert.False(t, ds.HasItem("/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='bad-xapp']"))
+
+	resp, body = request(t, "PUT", xappPath+"bad-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"bad-xapp","release-name":"invalid"}]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, `"error-type":"application"`)
+	assert.Contains(t, body, `"error-tag":"invalid-value"`)
+	assert.Contains(t, body, `"error-path":"/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp[name='bad-xapp']/release-name"`)
+	assert.Contains(t, body, `"error-message":"no such helm release"`)
+
+	resp, body = request(t, "PUT", xappPath+"bad-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"bad-xapp","release-name":"forbidden"}]}`)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, `"error-tag":"access-denied"`)
+	assert.Contains(t, body, `"error-message":"restconf user 'admin' may not deploy"`)
+
+	resp, body = request(t, "PUT", xappPath+"bad-xapp", mediaJSON, `{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"bad-xapp","unknown":"x"}]}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, `"error-tag":"unknown-element"`)
+
+	resp, _ = request(t, "GET", "/restconf/data/o-ran-sc-ric-xapp-desc-v1:ric/unknown", "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = request(t, "PUT", "/restconf/data/o-ran-sc-ric-xapp-desc-v1:ric/health", mediaJSON, `{}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestGetContent(t *testing.T) {
+	resp, _ := request(t, "PUT", xappPath+"content-xapp", mediaJSON,
+		`{"o-ran-sc-ric-xapp-desc-v1:xapp":[{"name":"content-xapp","release-name":"content-release"}]}`)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	_, body := request(t, "GET", "/restconf/data", "", "")
+	assert.True(t, strings.HasPrefix(body, `{"ietf-restconf:data":`))
+	assert.Contains(t, body, "content-release")
+	assert.Contains(t, body, `"health": "healthy"`)
+
+	_, body = request(t, "GET", "/restconf/data?content=config", "", "")
+	assert.Contains(t, body, "content-release")
+	assert.NotContains(t, body, "healthy")
+
+	_, body = request(t, "GET", "/restconf/data/o-ran-sc-ric-xapp-desc-v1:ric?content=nonconfig", "", "")
+	assert.NotContains(t, body, "content-release")
+	assert.Contains(t, body, `"health": "healthy"`)
+
+	resp, _ = request(t, "GET", "/restconf/data?depth=1", "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = request(t, "DELETE", xappPath+"content-xapp", "", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestAlarmStream(t *testing.T) {
+	_, body := request(t, "GET", "/restconf/data/ietf-restconf-monitoring:restconf-state/streams", "", "")
+	assert.Contains(t, body, `"location": "/restconf/streams/alarms/json"`)
+
+	assert.Eventually(t, func() bool {
+		server.alarms.mu.Lock()
+		defer server.alarms.mu.Unlock()
+		return len(server.alarms.subs) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	alarmMu.Lock()
+	activeAlarms = map[string]string{"8004": "RIC ROUTING TABLE DISTRIBUTION FAILED"}
+	alarmMu.Unlock()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/restconf/streams/alarms/json", nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	alarmMu.Lock()
+	delete(activeAlarms, "8004")
+	activeAlarms["8005"] = "TCP CONNECTIVITY LOST TO DBAAS"
+	alarmMu.Unlock()
+
+	events := make(chan string, 2)
+	go func() {
+		r := bufio.NewReader(resp.Body)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "data: ") {
+				events <- line
+			}
+		}
+	}()
+
+	var received []string
+	for len(received) < 2 {
+		select {
+		case ev := <-events:
+			received = append(received, ev)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("alarm events not received: %v", received)
+		}
+	}
+	all := strings.Join(received, "")
+	assert.Contains(t, all, `{"ietf-restconf:notification":{"eventTime":`)
+	assert.Contains(t, all, `"alarm-id":"8005","alarm-text":"TCP CONNECTIVITY LOST TO DBAAS","status":"active"`)
+	assert.Contains(t, all, `"alarm-id":"8004","alarm-text":"RIC ROUTING TABLE DISTRIBUTION FAILED","status":"cleared"`)
+}
+
+func TestOperations(t *testing.T) {
+	var input map[string]string
+	ds.SubscribeRPC("/o-ran-sc-ric-xapp-desc-v1:check-xapp-health", func(o nbi.Originator, xpath string, in map[string]string) (map[string]string, error) {
+		input = in
+		return map[string]string{"health": "healthy", "status": "Running"}, nil
+	})
+	ds.SubscribeRPC("/o-ran-sc-ric-xapp-desc-v1:restart-xapp", func(o nbi.Originator, xpath string, in map[string]string) (map[string]string, error) {
+		if in["name"] == "broken" {
+			return nil, errors.New("kubectl failed")
+		}
+		return nil, nil
+	})
+
+	_, body := request(t, "GET", "/restconf/operations", "", "")
+	assert.Contains(t, body, `"o-ran-sc-ric-xapp-desc-v1:restart-xapp":[null]`)
+
+	const op = "/restconf/operations/o-ran-sc-ric-xapp-desc-v1:"
+	resp, body := request(t, "POST", op+"check-xapp-health", mediaJSON, `{"o-ran-sc-ric-xapp-desc-v1:input":{"name":"ueec"}}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"o-ran-sc-ric-xapp-desc-v1:output":{"health":"healthy","status":"Running"}}`, body)
+	assert.Equal(t, map[string]string{"name": "ueec"}, input)
+
+	resp, body = request(t, "POST", op+"check-xapp-health", mediaXML, `<input xmlns="urn:o-ran:ric:xapp-desc:1.0"><name>ueec</name><namespace>test</namespace></input>`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `<output xmlns="urn:o-ran:ric:xapp-desc:1.0"><health>healthy</health><status>Running</status></output>`, body)
+	assert.Equal(t, "test", input["namespace"])
+
+	resp, _ = request(t, "POST", op+"restart-xapp", mediaJSON, `{"o-ran-sc-ric-xapp-desc-v1:input":{"name":"ueec"}}`)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, body = request(t, "POST", op+"restart-xapp", mediaJSON, `{"o-ran-sc-ric-xapp-desc-v1:input":{"name":"broken"}}`)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, body, "kubectl failed")
+
+	resp, body = request(t, "POST", op+"restart-xapp", mediaJSON, `{}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "missing input 'name'")
+
+	resp, _ = request(t, "POST", op+"restart-xapp", mediaJSON, `{"o-ran-sc-ric-xapp-desc-v1:input":{"name":"ueec","replicas":2}}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = request(t, "POST", op+"unknown", mediaJSON, "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = request(t, "GET", op+"restart-xapp", "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
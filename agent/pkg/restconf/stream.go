@@ -0,0 +1,209 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package restconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/nbi"
+)
+
+const (
+	alarmStreamName = "alarms"
+	alarmsXpath     = "/o-ran-sc-ric-alarm-v1:ric/alarms"
+)
+
+// alarmEvent is a raised or cleared alarm entry
+type alarmEvent struct {
+	time  time.Time
+	alarm *nbi.Node
+}
+
+// alarmStream polls the active alarms while clients are subscribed and
+// turns additions and removals into events.
+type alarmStream struct {
+	store    nbi.Store
+	interval time.Duration
+
+	mu     sync.Mutex
+	subs   map[chan alarmEvent]bool
+	stop   chan struct{}
+	active map[string]*nbi.Node
+}
+
+func newAlarmStream(store nbi.Store) *alarmStream {
+	return &alarmStream{
+		store:    store,
+		interval: 5 * time.Second,
+		subs:     make(map[chan alarmEvent]bool),
+	}
+}
+
+func (a *alarmStream) subscribe() chan alarmEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ch := make(chan alarmEvent, 64)
+	a.subs[ch] = true
+	if a.stop == nil {
+		a.active = a.poll()
+		a.stop = make(chan struct{})
+		go a.run(a.stop)
+	}
+	return ch
+}
+
+func (a *alarmStream) unsubscribe(ch chan alarmEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.subs, ch)
+	if len(a.subs) == 0 && a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+}
+
+func (a *alarmStream) run(stop chan struct{}) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			a.update(a.poll())
+		}
+	}
+}
+
+// poll returns the active alarms keyed by alarm-id
+func (a *alarmStream) poll() map[string]*nbi.Node {
+	result := make(map[string]*nbi.Node)
+	tree, err := a.store.GetItems(alarmsXpath)
+	if err != nil {
+		log.Error("RESTCONF: reading alarms failed: %v", err)
+		return result
+	}
+	if alarms := tree.Find(alarmsXpath); alarms != nil {
+		for _, alarm := range alarms.Children {
+			if id := alarm.Child("alarm-id"); id != nil {
+				result[id.Value] = alarm
+			}
+		}
+	}
+	return result
+}
+
+func (a *alarmStream) update(current map[string]*nbi.Node) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.stop == nil {
+		return
+	}
+
+	now := time.Now()
+	var events []alarmEvent
+	for id, alarm := range current {
+		if _, ok := a.active[id]; !ok {
+			events = append(events, alarmEvent{now, alarm})
+		}
+	}
+	for id, alarm := range a.active {
+		if _, ok := current[id]; !ok {
+			cleared := alarm.Clone()
+			if status := cleared.Child("status"); status != nil {
+				status.Value = "cleared"
+			} else {
+				cleared.Children = append(cleared.Children, &nbi.Node{Module: alarm.Module, Name: "status", Value: "cleared", Leaf: true})
+			}
+			events = append(events, alarmEvent{now, cleared})
+		}
+	}
+	a.active = current
+
+	for ch := range a.subs {
+		for _, ev := range events {
+			select {
+			case ch <- ev:
+			default:
+				log.Warn("RESTCONF: alarm stream client is too slow, dropping event")
+			}
+		}
+	}
+}
+
+// serve sends the stream to a client as server-sent events
+func (a *alarmStream) serve(w http.ResponseWriter, r *http.Request, path string, namespaces map[string]string) {
+	encoding := strings.TrimPrefix(path, alarmStreamName+"/")
+	if !strings.HasPrefix(path, alarmStreamName+"/") || (encoding != "json" && encoding != "xml") {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := a.subscribe()
+	defer a.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			fmt.Fprintf(w, "data: %s\n\n", ev.encode(encoding, namespaces))
+			flusher.Flush()
+		}
+	}
+}
+
+// encode formats an event as an RFC 8040 notification message
+func (ev alarmEvent) encode(encoding string, namespaces map[string]string) string {
+	tree := nbi.NewTree()
+	alarms, _ := tree.Create(alarmsXpath)
+	alarms.Children = append(alarms.Children, ev.alarm)
+
+	eventTime := ev.time.UTC().Format(time.RFC3339)
+	if encoding == "xml" {
+		return `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>` +
+			eventTime + `</eventTime>` + tree.XML(namespaces) + `</notification>`
+	}
+
+	var data bytes.Buffer
+	json.Compact(&data, []byte(tree.JSON()))
+	content := data.String()
+	return `{"ietf-restconf:notification":{"eventTime":"` + eventTime + `",` + content[1:len(content)-1] + `}}`
+}
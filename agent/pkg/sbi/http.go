@@ -0,0 +1,410 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package sbi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	"github.com/spf13/viper"
+)
+
+// Endpoints named in the errors of SBI calls
+const (
+	EndpointAppmgr   = "appmgr"
+	EndpointAlertmgr = "alertmanager"
+)
+
+// ErrCircuitOpen is returned without contacting an endpoint that failed
+// too often in a row, until its cooldown has passed
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// UnreachableError is returned when an endpoint could not be reached or
+// answered that it is unavailable (502, 503, 504)
+type UnreachableError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("%s unreachable: %v", e.Endpoint, e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// RejectedError is returned when an endpoint answered a request with an
+// error status. Body holds the start of the answer.
+type RejectedError struct {
+	Endpoint string
+	Status   int
+	Body     string
+	Err      error
+}
+
+func (e *RejectedError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("%s rejected the request (status %d): %s", e.Endpoint, e.Status, e.Body)
+	}
+	return fmt.Sprintf("%s rejected the request (status %d): %v", e.Endpoint, e.Status, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnreachable tells if err is, or wraps, an UnreachableError
+func IsUnreachable(err error) bool {
+	var e *UnreachableError
+	return errors.As(err, &e)
+}
+
+// IsRejected tells if err is, or wraps, a RejectedError
+func IsRejected(err error) bool {
+	var e *RejectedError
+	return errors.As(err, &e)
+}
+
+// HTTPOptions tune the HTTP clients of the SBI endpoints. Retries and
+// BreakerThreshold of zero disable retrying and circuit breaking, a negative
+// MaxIdleConns closes every connection after its request. Endpoints are
+// keyed by EndpointAppmgr and EndpointAlertmgr; missing ones use plain http.
+type HTTPOptions struct {
+	Retries          int
+	Backoff          time.Duration
+	MaxBackoff       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	MaxIdleConns     int
+	Endpoints        map[string]EndpointConfig
+}
+
+// DefaultHTTPOptions returns the options used when nothing is configured
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		Retries:          3,
+		Backoff:          200 * time.Millisecond,
+		MaxBackoff:       5 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+		MaxIdleConns:     16,
+	}
+}
+
+// HTTPOptionsFromViper reads the options from the sbi section of the
+// configuration, falling back to DefaultHTTPOptions
+func HTTPOptionsFromViper() HTTPOptions {
+	opts := DefaultHTTPOptions()
+	if viper.IsSet("sbi.retries") {
+		opts.Retries = viper.GetInt("sbi.retries")
+	}
+	if viper.IsSet("sbi.retryBackoff") {
+		opts.Backoff = time.Duration(viper.GetInt("sbi.retryBackoff")) * time.Millisecond
+	}
+	if viper.IsSet("sbi.maxRetryBackoff") {
+		opts.MaxBackoff = time.Duration(viper.GetInt("sbi.maxRetryBackoff")) * time.Millisecond
+	}
+	if viper.IsSet("sbi.breakerThreshold") {
+		opts.BreakerThreshold = viper.GetInt("sbi.breakerThreshold")
+	}
+	if viper.IsSet("sbi.breakerCooldown") {
+		opts.BreakerCooldown = time.Duration(viper.GetInt("sbi.breakerCooldown")) * time.Second
+	}
+	if viper.IsSet("sbi.maxIdleConns") {
+		opts.MaxIdleConns = viper.GetInt("sbi.maxIdleConns")
+	}
+	opts.Endpoints = map[string]EndpointConfig{
+		EndpointAppmgr:   EndpointConfigFromViper(EndpointAppmgr),
+		EndpointAlertmgr: EndpointConfigFromViper(EndpointAlertmgr),
+	}
+	return opts
+}
+
+// newHTTPClient returns a client keeping connections to an endpoint open,
+// authenticating to it, retrying failed calls and breaking the circuit when
+// it keeps failing
+func newHTTPClient(opts HTTPOptions, cfg EndpointConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.MaxIdleConns > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConns
+	} else if opts.MaxIdleConns < 0 {
+		transport.DisableKeepAlives = true
+	}
+
+	var creds *credentials
+	transport.TLSClientConfig, creds = tlsConfig(cfg)
+
+	return &http.Client{
+		Transport: &retryTransport{
+			next: &authTransport{
+				next:  transport,
+				cfg:   cfg,
+				creds: creds,
+				token: newWatchedFile(cfg.BearerTokenFile),
+			},
+			opts:     opts,
+			breakers: make(map[string]*breaker),
+		},
+	}
+}
+
+// retryTransport retries requests that are safe to repeat with a jittered
+// exponential backoff, and keeps a circuit breaker per host
+type retryTransport struct {
+	next     http.RoundTripper
+	opts     HTTPOptions
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := t.breaker(req.URL.Host)
+	for attempt := 0; ; attempt++ {
+		if !b.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		failed := err != nil || unavailable(resp.StatusCode)
+		b.record(!failed)
+		if !failed || attempt >= t.opts.Retries || !t.retryable(req, err) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		log.Info("SBI: %s %s failed (attempt %d), retrying: %v", req.Method, req.URL, attempt+1, t.cause(resp, err))
+		if err := t.wait(req, attempt); err != nil {
+			return nil, err
+		}
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) breaker(host string) *breaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{threshold: t.opts.BreakerThreshold, cooldown: t.opts.BreakerCooldown}
+		t.breakers[host] = b
+	}
+	return b
+}
+
+// retryable tells if a failed request may be sent again. Requests that never
+// left because the connection was refused are always safe to repeat, others
+// only when their method is idempotent and their body can be replayed.
+func (t *retryTransport) retryable(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// wait sleeps a random time up to the exponential backoff of the attempt
+func (t *retryTransport) wait(req *http.Request, attempt int) error {
+	backoff := t.opts.Backoff << uint(attempt)
+	if t.opts.MaxBackoff > 0 && (backoff > t.opts.MaxBackoff || backoff <= 0) {
+		backoff = t.opts.MaxBackoff
+	}
+	if backoff <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff))))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+func (t *retryTransport) cause(resp *http.Response, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return resp.Status
+}
+
+// rewind returns a copy of req with a fresh body to send it again
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+func unavailable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// breaker opens after threshold consecutive failures. Once the cooldown has
+// passed a single request is let through; its outcome closes the breaker or
+// opens it for another cooldown.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	probing  bool
+	openedAt time.Time
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) record(ok bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if ok {
+		b.failures, b.open = 0, false
+		return
+	}
+	b.failures++
+	if b.open || b.failures >= b.threshold {
+		if !b.open {
+			log.Warn("SBI: circuit breaker opened after %d failures", b.failures)
+		}
+		b.open, b.openedAt = true, time.Now()
+	}
+}
+
+// classifier is a client transport turning the errors of the operations it
+// submits into UnreachableError and RejectedError
+type classifier struct {
+	endpoint  string
+	transport runtime.ClientTransport
+}
+
+func (c *classifier) Submit(op *runtime.ClientOperation) (interface{}, error) {
+	reader := &statusReader{next: op.Reader}
+	op.Reader = reader
+
+	result, err := c.transport.Submit(op)
+	if err != nil {
+		return result, classify(c.endpoint, reader.status, reader.body, err)
+	}
+	return result, nil
+}
+
+// maxErrorBody limits how much of an error answer ends up in a RejectedError
+const maxErrorBody = 512
+
+// statusReader remembers the status of the response it reads, and the body
+// of error responses
+type statusReader struct {
+	next   runtime.ClientResponseReader
+	status int
+	body   string
+}
+
+func (r *statusReader) ReadResponse(resp runtime.ClientResponse, consumer runtime.Consumer) (interface{}, error) {
+	r.status = resp.Code()
+	if r.status >= http.StatusBadRequest {
+		body, _ := io.ReadAll(resp.Body())
+		r.body = strings.TrimSpace(string(body))
+		if len(r.body) > maxErrorBody {
+			r.body = r.body[:maxErrorBody] + "..."
+		}
+		resp = &bufferedResponse{ClientResponse: resp, body: body}
+	}
+	return r.next.ReadResponse(resp, consumer)
+}
+
+// bufferedResponse serves a body that was already read
+type bufferedResponse struct {
+	runtime.ClientResponse
+	body []byte
+}
+
+func (r *bufferedResponse) Body() io.ReadCloser {
+	return io.NopCloser(bytes.NewReader(r.body))
+}
+
+func classify(endpoint string, status int, body string, err error) error {
+	switch {
+	case status == 0 || unavailable(status):
+		return &UnreachableError{Endpoint: endpoint, Err: err}
+	case status >= http.StatusBadRequest:
+		return &RejectedError{Endpoint: endpoint, Status: status, Body: body, Err: err}
+	}
+	return err
+}
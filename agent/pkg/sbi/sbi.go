@@ -25,12 +25,13 @@ import (
 	"fmt"
 	httptransport "github.com/go-openapi/runtime/client"
 	"github.com/go-openapi/strfmt"
+	"net/http"
 	"os/exec"
 	"regexp"
 	"strings"
 	"time"
 
-	clientruntime "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/runtime"
 	"github.com/prometheus/alertmanager/api/v2/client"
 	"github.com/prometheus/alertmanager/api/v2/client/alert"
 
@@ -50,11 +51,44 @@ type PodStatus struct {
 var log = xapp.Logger
 
 func NewSBIClient(appmgrAddr, alertmgrAddr string, timo int) *SBIClient {
-	return &SBIClient{appmgrAddr, alertmgrAddr, time.Duration(timo) * time.Second}
+	return NewSBIClientWithOptions(appmgrAddr, alertmgrAddr, timo, HTTPOptionsFromViper())
+}
+
+// NewSBIClientWithOptions returns a client whose calls to each endpoint share
+// one pool of connections and are secured, retried and circuit broken as
+// opts tell
+func NewSBIClientWithOptions(appmgrAddr, alertmgrAddr string, timo int, opts HTTPOptions) *SBIClient {
+	s := &SBIClient{
+		appmgrAddr:   appmgrAddr,
+		alertmgrAddr: alertmgrAddr,
+		timeout:      time.Duration(timo) * time.Second,
+		endpoints:    make(map[string]*endpoint),
+	}
+	for _, name := range []string{EndpointAppmgr, EndpointAlertmgr} {
+		cfg := opts.Endpoints[name]
+		if err := cfg.validate(); err != nil {
+			log.Error("SBI: invalid %s configuration, using plain http: %v", name, err)
+			cfg = EndpointConfig{}
+		}
+		s.endpoints[name] = &endpoint{scheme: cfg.scheme(), client: newHTTPClient(opts, cfg)}
+	}
+	return s
 }
 
 func (s *SBIClient) CreateTransport(host string) *apiclient.RICAppmgr {
-	return apiclient.New(httptransport.New(host, "/ric/v1/", []string{"http"}), strfmt.Default)
+	return apiclient.New(s.transport(EndpointAppmgr, host, "/ric/v1/"), strfmt.Default)
+}
+
+// endpoint is how an SBI endpoint is reached
+type endpoint struct {
+	scheme string
+	client *http.Client
+}
+
+func (s *SBIClient) transport(endpoint, host, basePath string) runtime.ClientTransport {
+	e := s.endpoints[endpoint]
+	rt := httptransport.NewWithClient(host, basePath, []string{e.scheme}, e.client)
+	return &classifier{endpoint: endpoint, transport: rt}
 }
 
 func (s *SBIClient) BuildXappDescriptor(name, namespace, release, version string) *apimodel.XappDescriptor {
@@ -108,6 +142,17 @@ func (s *SBIClient) GetDeployedXapps() error {
 	return err
 }
 
+// ListDeployedXapps returns the xApps the appmgr has deployed
+func (s *SBIClient) ListDeployedXapps() (apimodel.AllDeployedXapps, error) {
+	params := apixapp.NewGetAllXappsParamsWithTimeout(s.timeout)
+	result, err := s.CreateTransport(s.appmgrAddr).Xapp.GetAllXapps(params)
+	if err != nil {
+		log.Error("SBI: ListDeployedXapps unsuccessful: %v", err)
+		return nil, err
+	}
+	return result.Payload, nil
+}
+
 func (s *SBIClient) BuildXappConfig(name, namespace string, configData interface{}) *apimodel.XAppConfig {
 	metadata := &apimodel.ConfigMetadata{
 		XappName:  &name,
@@ -131,6 +176,28 @@ func (s *SBIClient) ModifyXappConfig(xappConfig *apimodel.XAppConfig) error {
 	return err
 }
 
+// GetXappConfig returns the configuration the appmgr has for an xApp, nil if
+// it has none
+func (s *SBIClient) GetXappConfig(name, namespace string) (interface{}, error) {
+	params := apixapp.NewGetAllXappConfigParamsWithTimeout(s.timeout)
+	result, err := s.CreateTransport(s.appmgrAddr).Xapp.GetAllXappConfig(params)
+	if err != nil {
+		log.Error("SBI: GetXappConfig unsuccessful: %v", err)
+		return nil, err
+	}
+
+	for _, cfg := range result.Payload {
+		if cfg == nil || cfg.Metadata == nil || cfg.Metadata.XappName == nil || *cfg.Metadata.XappName != name {
+			continue
+		}
+		if ns := cfg.Metadata.Namespace; ns != nil && *ns != "" && *ns != namespace {
+			continue
+		}
+		return cfg.Config, nil
+	}
+	return nil, nil
+}
+
 func (s *SBIClient) GetAllPodStatus(namespace string) ([]PodStatus, error) {
 	output, err := s.RunCommand(fmt.Sprintf("/usr/local/bin/kubectl get pod -n %s", namespace))
 	if err != nil {
@@ -154,6 +221,120 @@ func (s *SBIClient) GetAllPodStatus(namespace string) ([]PodStatus, error) {
 	return podStatusList, nil
 }
 
+// RestartXapp does a rolling restart of the pods of an xApp
+func (s *SBIClient) RestartXapp(name, namespace string) error {
+	deployment, err := xappDeployment(name, namespace)
+	if err != nil {
+		return err
+	}
+	log.Info("SBI: RestartXapp name=%s namespace=%s", name, namespace)
+
+	_, err = s.RunCommand(fmt.Sprintf("/usr/local/bin/kubectl rollout restart %s -n %s", deployment, namespace))
+	if err != nil {
+		log.Error("SBI: RestartXapp unsuccessful: %v", err)
+	}
+	return err
+}
+
+// ScaleXapp sets the number of instances an xApp runs
+func (s *SBIClient) ScaleXapp(name, namespace string, replicas int) error {
+	deployment, err := xappDeployment(name, namespace)
+	if err != nil {
+		return err
+	}
+	if replicas < 0 {
+		return fmt.Errorf("invalid replica count %d", replicas)
+	}
+	log.Info("SBI: ScaleXapp name=%s namespace=%s replicas=%d", name, namespace, replicas)
+
+	_, err = s.RunCommand(fmt.Sprintf("/usr/local/bin/kubectl scale %s --replicas=%d -n %s", deployment, replicas, namespace))
+	if err != nil {
+		log.Error("SBI: ScaleXapp unsuccessful: %v", err)
+	}
+	return err
+}
+
+// RedeployXapp undeploys an xApp and deploys it again with the same descriptor
+func (s *SBIClient) RedeployXapp(xappDesc *apimodel.XappDescriptor) error {
+	log.Info("SBI: RedeployXapp name=%s", *xappDesc.XappName)
+
+	if err := s.UndeployXapp(xappDesc); err != nil {
+		return err
+	}
+	return s.DeployXapp(xappDesc)
+}
+
+// GetXappLogs returns the last lines logged by all containers of an xApp
+func (s *SBIClient) GetXappLogs(name, namespace string, lines int) (string, error) {
+	deployment, err := xappDeployment(name, namespace)
+	if err != nil {
+		return "", err
+	}
+	if lines <= 0 {
+		return "", fmt.Errorf("invalid line count %d", lines)
+	}
+
+	output, err := s.RunCommand(fmt.Sprintf("/usr/local/bin/kubectl logs %s -n %s --all-containers=true --tail=%d", deployment, namespace, lines))
+	if err != nil {
+		log.Error("SBI: GetXappLogs unsuccessful: %v", err)
+		return "", err
+	}
+	return output, nil
+}
+
+// CheckXappHealth reports the pod status of one xApp. Its pods are named
+// <namespace>-<name>-<replica set>-<pod> after its deployment, which tells
+// them apart from the pods of xApps with a hyphenated name starting the
+// same. Terminating pods of an old replica set are left out and the least
+// healthy of the others is reported. An xApp without pods is unavailable.
+func (s *SBIClient) CheckXappHealth(name, namespace string) (PodStatus, error) {
+	if _, err := xappDeployment(name, namespace); err != nil {
+		return PodStatus{}, err
+	}
+
+	output, err := s.RunCommand(fmt.Sprintf("/usr/local/bin/kubectl get pod -n %s", namespace))
+	if err != nil {
+		return PodStatus{}, err
+	}
+
+	status := PodStatus{Name: name, Health: "unavailable", Status: "NotFound"}
+	re := regexp.MustCompile(fmt.Sprintf(`(?m)^%s-%s-[a-z0-9]+-[a-z0-9]+\s.*$`, namespace, name))
+	for _, line := range re.FindAllString(output, -1) {
+		var podName, readyStr, podStatus string
+		fmt.Sscanf(line, "%s %s %s", &podName, &readyStr, &podStatus)
+		if podStatus == "Terminating" {
+			continue
+		}
+
+		health := s.GetHealthState(readyStr)
+		if status.Status == "NotFound" || (status.Health == "healthy" && health != "healthy") {
+			status.Health, status.Status = health, podStatus
+		}
+	}
+	return status, nil
+}
+
+// GetXappConfigSchema returns the JSON schema of the xApp configuration. It
+// cannot come from the appmgr config API GetXappConfig uses, whose records
+// only hold the metadata and the configuration of an xApp. The schema is
+// part of the helm chart of the xApp, whose config files end up in the
+// config map configmap-<namespace>-<name>-appconfig, the one the appmgr
+// reads and writes the configuration in too. It is empty if the xApp comes
+// without a schema.
+func (s *SBIClient) GetXappConfigSchema(name, namespace string) (string, error) {
+	if _, err := xappDeployment(name, namespace); err != nil {
+		return "", err
+	}
+
+	configMap := fmt.Sprintf("configmap-%s-%s-appconfig", namespace, name)
+	output, err := s.RunCommand(fmt.Sprintf(`/usr/local/bin/kubectl get configmap %s -n %s -o jsonpath='{.data.schema\.json}'`, configMap, namespace))
+	if err != nil {
+		log.Error("SBI: GetXappConfigSchema unsuccessful: %v", err)
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
 func (s *SBIClient) GetHealthState(ready string) (state string) {
 	result := strings.Split(ready, "/")
 	if len(result) < 2 {
@@ -168,6 +349,20 @@ func (s *SBIClient) GetHealthState(ready string) (state string) {
 	return
 }
 
+var resourceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// xappDeployment returns the Kubernetes deployment of an xApp. Both names
+// end up in a shell command, so anything but a DNS label is rejected.
+func xappDeployment(name, namespace string) (string, error) {
+	if !resourceName.MatchString(name) {
+		return "", fmt.Errorf("invalid xApp name '%s'", name)
+	}
+	if !resourceName.MatchString(namespace) {
+		return "", fmt.Errorf("invalid namespace '%s'", namespace)
+	}
+	return fmt.Sprintf("deployment/%s-%s", namespace, name), nil
+}
+
 func (s *SBIClient) RunCommand(args string) (string, error) {
 	return CommandExec(args)
 }
@@ -191,7 +386,7 @@ var CommandExec = func(args string) (string, error) {
 func (s *SBIClient) GetAlerts() (*alert.GetAlertsOK, error) {
 	xapp.Logger.Info("Fetching alerts ...")
 
-	cr := clientruntime.New(s.alertmgrAddr, "/api/v2", []string{"http"})
+	cr := s.transport(EndpointAlertmgr, s.alertmgrAddr, "/api/v2")
 	resp, err := client.New(cr, strfmt.Default).Alert.GetAlerts(nil)
 	if err != nil {
 		xapp.Logger.Error("Fetching alerts failed with error: %v", err)
@@ -234,4 +429,3 @@ func (s *SBIClient) GetAllDeployedXappsConfig() ([]string, []string) {
 
 	return xappNameList, xappCfgList
 }
-
@@ -28,6 +28,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -117,6 +119,27 @@ func TestGetDeployedXappsReturnsErrorIfHttpErrorResponse(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestListDeployedXapps(t *testing.T) {
+	name := "ueec-xapp"
+	deployed := apimodel.AllDeployedXapps{&apimodel.Xapp{Name: &name, Version: "0.0.1", Status: "deployed"}}
+	ts := createHTTPServer(t, "GET", "/ric/v1/xapps", 8080, http.StatusOK, deployed)
+	defer ts.Close()
+
+	xapps, err := s.ListDeployedXapps()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(xapps))
+	assert.Equal(t, "ueec-xapp", *xapps[0].Name)
+	assert.Equal(t, "0.0.1", xapps[0].Version)
+}
+
+func TestListDeployedXappsReturnsErrorIfHttpErrorResponse(t *testing.T) {
+	ts := createHTTPServer(t, "GET", "/ric/v1/xapps", 8080, http.StatusInternalServerError, nil)
+	defer ts.Close()
+
+	_, err := s.ListDeployedXapps()
+	assert.NotNil(t, err)
+}
+
 func TestBuildXappConfig(t *testing.T) {
 	expResp := &apimodel.XAppConfig{
 		Metadata: &apimodel.ConfigMetadata{
@@ -145,6 +168,25 @@ func TestModifyXappConfigReturnsErrorIfHttpErrorResponse(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestGetXappConfig(t *testing.T) {
+	other, otherNs := "kpimon", "ricxapp-test"
+	cfg := apimodel.AllXappConfig{
+		&apimodel.XAppConfig{Metadata: &apimodel.ConfigMetadata{XappName: &xappName, Namespace: &otherNs}, Config: "other namespace"},
+		&apimodel.XAppConfig{Metadata: &apimodel.ConfigMetadata{XappName: &other, Namespace: &ns}, Config: "other xApp"},
+		&apimodel.XAppConfig{Metadata: &apimodel.ConfigMetadata{XappName: &xappName, Namespace: &ns}, Config: map[string]interface{}{"active": true}},
+	}
+	ts := createHTTPServer(t, "GET", "/ric/v1/config", 8080, http.StatusOK, cfg)
+	defer ts.Close()
+
+	config, err := s.GetXappConfig(xappName, ns)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"active": true}, config)
+
+	config, err = s.GetXappConfig("unknown", ns)
+	assert.Nil(t, err)
+	assert.Nil(t, config)
+}
+
 func TestGetAllPodStatus(t *testing.T) {
 	oldCmdExec := sbi.CommandExec
 	defer func() { sbi.CommandExec = oldCmdExec }()
@@ -203,6 +245,133 @@ func TestGetHealthStateReturnsUnhealthyIfReadyStatusNotUp(t *testing.T) {
 	assert.Equal(t, "unhealthy", s.GetHealthState("0/1"))
 }
 
+func TestRestartXapp(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		assert.Equal(t, "/usr/local/bin/kubectl rollout restart deployment/ricxapp-ueec -n ricxapp", args)
+		return "deployment.apps/ricxapp-ueec restarted", nil
+	}
+
+	assert.Nil(t, s.RestartXapp("ueec", "ricxapp"))
+}
+
+func TestScaleXapp(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		assert.Equal(t, "/usr/local/bin/kubectl scale deployment/ricxapp-ueec --replicas=3 -n ricxapp", args)
+		return "deployment.apps/ricxapp-ueec scaled", nil
+	}
+
+	assert.Nil(t, s.ScaleXapp("ueec", "ricxapp", 3))
+	assert.NotNil(t, s.ScaleXapp("ueec", "ricxapp", -1))
+}
+
+func TestLifecycleRejectsInvalidNames(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		t.Errorf("unexpected command: %s", args)
+		return "", nil
+	}
+
+	assert.NotNil(t, s.RestartXapp("ueec; reboot", "ricxapp"))
+	assert.NotNil(t, s.ScaleXapp("ueec", "ric xapp", 1))
+	_, err := s.GetXappLogs("UEEC", "ricxapp", 10)
+	assert.NotNil(t, err)
+	_, err = s.CheckXappHealth("ueec", "")
+	assert.NotNil(t, err)
+}
+
+func TestRedeployXappReturnsErrorIfUndeployFails(t *testing.T) {
+	ts := createHTTPServer(t, "DELETE", "/ric/v1/xapps/ueec-xapp", 8080, http.StatusInternalServerError, nil)
+	defer ts.Close()
+
+	assert.NotNil(t, s.RedeployXapp(getTestXappDescriptor()))
+}
+
+func TestGetXappLogs(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		assert.Equal(t, "/usr/local/bin/kubectl logs deployment/ricxapp-ueec -n ricxapp --all-containers=true --tail=2", args)
+		return "line 1\nline 2\n", nil
+	}
+
+	logs, err := s.GetXappLogs("ueec", "ricxapp", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "line 1\nline 2\n", logs)
+
+	_, err = s.GetXappLogs("ueec", "ricxapp", 0)
+	assert.NotNil(t, err)
+}
+
+func TestGetXappConfigSchema(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		assert.Equal(t, `/usr/local/bin/kubectl get configmap configmap-ricxapp-ueec-appconfig -n ricxapp -o jsonpath='{.data.schema\.json}'`, args)
+		return `{"type": "object"}` + "\n", nil
+	}
+
+	schema, err := s.GetXappConfigSchema("ueec", "ricxapp")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"type": "object"}`, schema)
+
+	_, err = s.GetXappConfigSchema("ueec;reboot", "ricxapp")
+	assert.NotNil(t, err)
+}
+
+func TestCheckXappHealth(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		return kpodOutput, nil
+	}
+
+	pod, err := s.CheckXappHealth("dualco", "ricxapp")
+	assert.Nil(t, err)
+	assert.Equal(t, sbi.PodStatus{Name: "dualco", Health: "unhealthy", Status: "Running"}, pod)
+
+	pod, err = s.CheckXappHealth("hwxapp", "ricxapp")
+	assert.Nil(t, err)
+	assert.Equal(t, "unavailable", pod.Health)
+}
+
+func TestCheckXappHealthOfReplicas(t *testing.T) {
+	oldCmdExec := sbi.CommandExec
+	defer func() { sbi.CommandExec = oldCmdExec }()
+	sbi.CommandExec = func(args string) (out string, err error) {
+		assert.Equal(t, "/usr/local/bin/kubectl get pod -n ric-xapp", args)
+		return `
+NAME                                  READY   STATUS        RESTARTS   AGE
+ric-xapp-hw-6d8f7c9b4d-x2x9q          0/1     Running       0          1m
+ric-xapp-hw-go-5c6b8d7f9-k8s2p        1/1     Running       0          1m
+ric-xapp-kpimon-74d9c8f6b5-abcde      1/1     Terminating   0          3d
+ric-xapp-kpimon-8c7f6d5b4-fghij       1/1     Running       0          1m
+ric-xapp-ueec-7bfdd587db-2jl9j        1/1     Running       0          1m
+ric-xapp-ueec-7bfdd587db-9qw4r        0/1     Pending       0          1m
+`, nil
+	}
+
+	pod, err := s.CheckXappHealth("hw-go", "ric-xapp")
+	assert.Nil(t, err)
+	assert.Equal(t, sbi.PodStatus{Name: "hw-go", Health: "healthy", Status: "Running"}, pod)
+
+	pod, err = s.CheckXappHealth("hw", "ric-xapp")
+	assert.Nil(t, err)
+	assert.Equal(t, sbi.PodStatus{Name: "hw", Health: "unhealthy", Status: "Running"}, pod)
+
+	pod, err = s.CheckXappHealth("kpimon", "ric-xapp")
+	assert.Nil(t, err)
+	assert.Equal(t, sbi.PodStatus{Name: "kpimon", Health: "healthy", Status: "Running"}, pod)
+
+	pod, err = s.CheckXappHealth("ueec", "ric-xapp")
+	assert.Nil(t, err)
+	assert.Equal(t, sbi.PodStatus{Name: "ueec", Health: "unhealthy", Status: "Pending"}, pod)
+}
+
 func TestGetAlerts(t *testing.T) {
 	tim := strfmt.DateTime(time.Now())
 	fingerprint := "34c8f717936f063f"
@@ -259,6 +428,112 @@ func TestGetAlertsReturnErrorIfHttpErrorResponse(t *testing.T) {
 	assert.Nil(t, resp)
 }
 
+func TestRetryOfIdempotentCall(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`[{"name":"ueec","status":"deployed"}]`))
+	}))
+	defer ts.Close()
+
+	c := newRetryingClient(ts, 3, 5)
+	xapps, err := c.ListDeployedXapps()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(xapps))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestNoRetryOfPost(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	err := newRetryingClient(ts, 3, 5).DeployXapp(getTestXappDescriptor())
+	assert.True(t, sbi.IsUnreachable(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRejectedError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"helm chart not found"}` + "\n"))
+	}))
+	defer ts.Close()
+
+	err := newRetryingClient(ts, 3, 5).DeployXapp(getTestXappDescriptor())
+	assert.Equal(t, `appmgr rejected the request (status 400): {"message":"helm chart not found"}`, err.Error())
+	assert.True(t, sbi.IsRejected(err))
+	assert.False(t, sbi.IsUnreachable(err))
+
+	var rejected *sbi.RejectedError
+	assert.True(t, errors.As(err, &rejected))
+	assert.Equal(t, "appmgr", rejected.Endpoint)
+	assert.Equal(t, http.StatusBadRequest, rejected.Status)
+	assert.Equal(t, `{"message":"helm chart not found"}`, rejected.Body)
+}
+
+func TestUnreachableError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	c := newRetryingClient(ts, 1, 5)
+	err := c.DeployXapp(getTestXappDescriptor())
+	assert.True(t, sbi.IsUnreachable(err))
+	assert.False(t, sbi.IsRejected(err))
+
+	_, err = c.GetAlerts()
+	var unreachable *sbi.UnreachableError
+	assert.True(t, errors.As(err, &unreachable))
+	assert.Equal(t, "alertmanager", unreachable.Endpoint)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	var healthy atomic.Value
+	healthy.Store(false)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !healthy.Load().(bool) {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	c := sbi.NewSBIClientWithOptions(strings.TrimPrefix(ts.URL, "http://"), "", 5, sbi.HTTPOptions{
+		BreakerThreshold: 2,
+		BreakerCooldown:  50 * time.Millisecond,
+	})
+	for i := 0; i < 2; i++ {
+		_, err := c.ListDeployedXapps()
+		assert.True(t, sbi.IsUnreachable(err))
+	}
+
+	// Open: the appmgr is not called until the cooldown has passed
+	_, err := c.ListDeployedXapps()
+	assert.True(t, errors.Is(err, sbi.ErrCircuitOpen))
+	assert.True(t, sbi.IsUnreachable(err))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	time.Sleep(60 * time.Millisecond)
+	healthy.Store(true)
+	_, err = c.ListDeployedXapps()
+	assert.Nil(t, err)
+	_, err = c.ListDeployedXapps()
+	assert.Nil(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
 func TestCommandExec(t *testing.T) {
 	resp, err := sbi.CommandExec("date")
 	assert.NotEqual(t, "", resp)
@@ -275,6 +550,11 @@ func getTestXappDescriptor() *apimodel.XappDescriptor {
 	return s.BuildXappDescriptor(xappName, ns, release, helmVer)
 }
 
+func newRetryingClient(ts *httptest.Server, retries int, backoff time.Duration) *sbi.SBIClient {
+	addr := strings.TrimPrefix(ts.URL, "http://")
+	return sbi.NewSBIClientWithOptions(addr, addr, 5, sbi.HTTPOptions{Retries: retries, Backoff: backoff * time.Millisecond})
+}
+
 func createHTTPServer(t *testing.T, method, url string, port, status int, respData interface{}) *httptest.Server {
 	l, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
 	if err != nil {
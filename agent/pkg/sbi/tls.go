@@ -0,0 +1,314 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package sbi
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// EndpointConfig tells how to connect and authenticate to an SBI endpoint.
+// Certificates, keys and the token file are read again when they change.
+type EndpointConfig struct {
+	Scheme             string
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+	BearerToken        string
+	BearerTokenFile    string
+	Username           string
+	Password           string
+}
+
+// EndpointConfigFromViper reads the sbi.<endpoint> section of the
+// configuration. The scheme defaults to https when TLS files are given.
+func EndpointConfigFromViper(endpoint string) EndpointConfig {
+	key := "sbi." + endpoint + "."
+	cfg := EndpointConfig{
+		Scheme:             viper.GetString(key + "scheme"),
+		CAFile:             viper.GetString(key + "caFile"),
+		CertFile:           viper.GetString(key + "certFile"),
+		KeyFile:            viper.GetString(key + "keyFile"),
+		ServerName:         viper.GetString(key + "serverName"),
+		InsecureSkipVerify: viper.GetBool(key + "insecureSkipVerify"),
+		BearerToken:        viper.GetString(key + "bearerToken"),
+		BearerTokenFile:    viper.GetString(key + "bearerTokenFile"),
+		Username:           viper.GetString(key + "username"),
+		Password:           viper.GetString(key + "password"),
+	}
+	if cfg.Scheme == "" && (cfg.CAFile != "" || cfg.CertFile != "" || cfg.InsecureSkipVerify) {
+		cfg.Scheme = "https"
+	}
+	return cfg
+}
+
+func (c EndpointConfig) scheme() string {
+	if c.Scheme == "" {
+		return "http"
+	}
+	return c.Scheme
+}
+
+func (c EndpointConfig) validate() error {
+	if c.Scheme != "" && c.Scheme != "http" && c.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme '%s'", c.Scheme)
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("client certificate and key must be given together")
+	}
+	if c.BearerToken != "" && c.BearerTokenFile != "" {
+		return fmt.Errorf("bearer token and token file are exclusive")
+	}
+	if (c.BearerToken != "" || c.BearerTokenFile != "") && c.Username != "" {
+		return fmt.Errorf("bearer and basic authentication are exclusive")
+	}
+	return nil
+}
+
+// watchedFile caches the content of a file and reads it again once its size
+// or modification time changes, so rotated secrets are picked up
+type watchedFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+func newWatchedFile(path string) *watchedFile {
+	if path == "" {
+		return nil
+	}
+	return &watchedFile{path: path}
+}
+
+// load returns the content of the file and whether it changed since the
+// last call
+func (f *watchedFile) load() ([]byte, bool, error) {
+	st, err := os.Stat(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.data != nil && st.ModTime().Equal(f.modTime) && st.Size() == f.size {
+		return f.data, false, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, false, err
+	}
+	f.data, f.modTime, f.size = data, st.ModTime(), st.Size()
+	return data, true, nil
+}
+
+// credentials holds the CA bundle and client certificate of an endpoint as
+// last read from their files
+type credentials struct {
+	ca, cert, key *watchedFile
+
+	mu          sync.RWMutex
+	roots       *x509.CertPool
+	certificate *tls.Certificate
+}
+
+// refresh reads the files again and tells if any of them changed
+func (c *credentials) refresh() (bool, error) {
+	var roots *x509.CertPool
+	var certificate *tls.Certificate
+
+	caChanged := false
+	if c.ca != nil {
+		pem, changed, err := c.ca.load()
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			roots = x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(pem) {
+				return false, fmt.Errorf("no certificates in '%s'", c.ca.path)
+			}
+		}
+		caChanged = changed
+	}
+
+	certChanged := false
+	if c.cert != nil {
+		certPEM, changed, err := c.cert.load()
+		if err != nil {
+			return false, err
+		}
+		keyPEM, keyChanged, err := c.key.load()
+		if err != nil {
+			return false, err
+		}
+		if changed || keyChanged {
+			pair, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				return false, err
+			}
+			certificate = &pair
+		}
+		certChanged = changed || keyChanged
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if roots != nil {
+		c.roots = roots
+	}
+	if certificate != nil {
+		c.certificate = certificate
+	}
+	return caChanged || certChanged, nil
+}
+
+// loaded tells if credentials were read before, so a failed refresh can
+// carry on with them
+func (c *credentials) loaded() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.roots != nil || c.certificate != nil
+}
+
+func (c *credentials) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.certificate == nil {
+		return &tls.Certificate{}, nil
+	}
+	return c.certificate, nil
+}
+
+// verifyConnection checks the server certificate against the CA bundle last
+// read, which a static tls.Config.RootCAs could not follow
+func (c *credentials) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("no server certificate")
+	}
+
+	c.mu.RLock()
+	roots := c.roots
+	c.mu.RUnlock()
+	if roots == nil {
+		return fmt.Errorf("no CA certificates loaded")
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// tlsConfig returns the client TLS configuration of an endpoint and the
+// credentials backing it, nil when the endpoint has no files
+func tlsConfig(cfg EndpointConfig) (*tls.Config, *credentials) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile == "" && cfg.CertFile == "" {
+		return config, nil
+	}
+
+	creds := &credentials{
+		ca:   newWatchedFile(cfg.CAFile),
+		cert: newWatchedFile(cfg.CertFile),
+		key:  newWatchedFile(cfg.KeyFile),
+	}
+	if cfg.CertFile != "" {
+		config.GetClientCertificate = creds.clientCertificate
+	}
+	if cfg.CAFile != "" && !cfg.InsecureSkipVerify {
+		// The chain is verified by verifyConnection instead
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = creds.verifyConnection
+	}
+	return config, creds
+}
+
+// authTransport adds the credentials of an endpoint to its requests. Before
+// each request the TLS files are checked, and idle connections made with
+// outdated ones are closed.
+type authTransport struct {
+	next  *http.Transport
+	cfg   EndpointConfig
+	creds *credentials
+	token *watchedFile
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.creds != nil {
+		changed, err := t.creds.refresh()
+		if err != nil {
+			log.Error("SBI: loading TLS credentials failed: %v", err)
+			if !t.creds.loaded() {
+				return nil, err
+			}
+		} else if changed {
+			log.Info("SBI: TLS credentials of %s loaded", req.URL.Host)
+			t.next.CloseIdleConnections()
+		}
+	}
+
+	token := t.cfg.BearerToken
+	if t.token != nil {
+		data, _, err := t.token.load()
+		if err != nil {
+			log.Error("SBI: loading bearer token failed: %v", err)
+			return nil, err
+		}
+		token = string(bytes.TrimSpace(data))
+	}
+
+	if token == "" && t.cfg.Username == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	r := req.Clone(req.Context())
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		r.SetBasicAuth(t.cfg.Username, t.cfg.Password)
+	}
+	return t.next.RoundTrip(r)
+}
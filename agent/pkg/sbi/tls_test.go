@@ -0,0 +1,198 @@
+/*
+==================================================================================
+  Copyright (c) 2020 AT&T Intellectual Property.
+  Copyright (c) 2020 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package sbi_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gerrit.oran-osc.org/r/ric-plt/o1mediator/pkg/sbi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// authServer is an appmgr behind mTLS remembering what clients sent
+type authServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	auth    []string
+	clients []string
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	a := &authServer{}
+	a.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		a.auth = append(a.auth, r.Header.Get("Authorization"))
+		if len(r.TLS.PeerCertificates) > 0 {
+			a.clients = append(a.clients, r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+		a.mu.Unlock()
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	a.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	a.StartTLS()
+	t.Cleanup(a.Close)
+	return a
+}
+
+func (a *authServer) requests() ([]string, []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string{}, a.auth...), append([]string{}, a.clients...)
+}
+
+// writeFile writes a file and moves its modification time forward, so the
+// change is seen even within the timestamp granularity
+func writeFile(t *testing.T, path string, data []byte, age int) {
+	assert.Nil(t, os.WriteFile(path, data, 0600))
+	mtime := time.Now().Add(time.Duration(age) * time.Second)
+	assert.Nil(t, os.Chtimes(path, mtime, mtime))
+}
+
+// writeClientCert writes a self-signed client certificate and its key
+func writeClientCert(t *testing.T, dir, name string, age int) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), age)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), age)
+	return certFile, keyFile
+}
+
+func newTLSClient(ts *httptest.Server, cfg sbi.EndpointConfig) *sbi.SBIClient {
+	addr := strings.TrimPrefix(ts.URL, "https://")
+	return sbi.NewSBIClientWithOptions(addr, addr, 5, sbi.HTTPOptions{
+		Endpoints: map[string]sbi.EndpointConfig{sbi.EndpointAppmgr: cfg},
+	})
+}
+
+func TestMutualTLSAndBearerToken(t *testing.T) {
+	ts := newAuthServer(t)
+	dir := t.TempDir()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0)
+	certFile, keyFile := writeClientCert(t, dir, "o1mediator", 0)
+	tokenFile := filepath.Join(dir, "token")
+	writeFile(t, tokenFile, []byte("secret-1\n"), 0)
+
+	c := newTLSClient(ts.Server, sbi.EndpointConfig{
+		Scheme:          "https",
+		CAFile:          caFile,
+		CertFile:        certFile,
+		KeyFile:         keyFile,
+		BearerTokenFile: tokenFile,
+	})
+	_, err := c.ListDeployedXapps()
+	assert.Nil(t, err)
+
+	// Rotated certificate and token are used by the next call
+	writeClientCert(t, dir, "o1mediator-renewed", 10)
+	writeFile(t, tokenFile, []byte("secret-2"), 10)
+	_, err = c.ListDeployedXapps()
+	assert.Nil(t, err)
+
+	auth, clients := ts.requests()
+	assert.Equal(t, []string{"Bearer secret-1", "Bearer secret-2"}, auth)
+	assert.Equal(t, []string{"o1mediator", "o1mediator-renewed"}, clients)
+}
+
+func TestBasicAuth(t *testing.T) {
+	ts := newAuthServer(t)
+	certFile, keyFile := writeClientCert(t, t.TempDir(), "o1mediator", 0)
+
+	c := newTLSClient(ts.Server, sbi.EndpointConfig{
+		Scheme:             "https",
+		InsecureSkipVerify: true,
+		CertFile:           certFile,
+		KeyFile:            keyFile,
+		Username:           "o1",
+		Password:           "pass",
+	})
+	_, err := c.ListDeployedXapps()
+	assert.Nil(t, err)
+
+	auth, _ := ts.requests()
+	assert.Equal(t, []string{"Basic bzE6cGFzcw=="}, auth)
+}
+
+func TestUnknownServerCertificate(t *testing.T) {
+	ts := newAuthServer(t)
+	dir := t.TempDir()
+
+	// A CA that did not sign the server certificate
+	certFile, keyFile := writeClientCert(t, dir, "other-ca", 0)
+	c := newTLSClient(ts.Server, sbi.EndpointConfig{
+		Scheme:   "https",
+		CAFile:   certFile,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	})
+	_, err := c.ListDeployedXapps()
+	assert.True(t, sbi.IsUnreachable(err))
+
+	auth, _ := ts.requests()
+	assert.Empty(t, auth)
+}
+
+func TestEndpointConfigFromViper(t *testing.T) {
+	defer viper.Set("sbi.alertmanager", nil)
+	viper.Set("sbi.alertmanager", map[string]interface{}{
+		"caFile":      "/etc/o1agent/ca.crt",
+		"bearerToken": "token",
+	})
+
+	cfg := sbi.EndpointConfigFromViper(sbi.EndpointAlertmgr)
+	assert.Equal(t, "https", cfg.Scheme)
+	assert.Equal(t, "/etc/o1agent/ca.crt", cfg.CAFile)
+	assert.Equal(t, "token", cfg.BearerToken)
+
+	cfg = sbi.EndpointConfigFromViper(sbi.EndpointAppmgr)
+	assert.Equal(t, sbi.EndpointConfig{}, cfg)
+}
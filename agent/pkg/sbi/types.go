@@ -30,6 +30,7 @@ type SBIClient struct {
 	appmgrAddr   string
 	alertmgrAddr string
 	timeout      time.Duration
+	endpoints    map[string]*endpoint
 }
 
 type SBIClientInterface interface {
@@ -37,13 +38,22 @@ type SBIClientInterface interface {
 	DeployXapp(xappDesc *apimodel.XappDescriptor) error
 	UndeployXapp(xappDesc *apimodel.XappDescriptor) error
 	GetDeployedXapps() error
+	ListDeployedXapps() (apimodel.AllDeployedXapps, error)
 
 	BuildXappConfig(name, namespace string, configData interface{}) *apimodel.XAppConfig
 	ModifyXappConfig(xappConfig *apimodel.XAppConfig) error
+	GetXappConfig(name, namespace string) (interface{}, error)
 
 	GetAllPodStatus(namespace string) ([]PodStatus, error)
 
+	RestartXapp(name, namespace string) error
+	ScaleXapp(name, namespace string, replicas int) error
+	RedeployXapp(xappDesc *apimodel.XappDescriptor) error
+	GetXappLogs(name, namespace string, lines int) (string, error)
+	CheckXappHealth(name, namespace string) (PodStatus, error)
+
 	GetAlerts() (*alert.GetAlertsOK, error)
 
 	GetAllDeployedXappsConfig() ([]string, []string)
+	GetXappConfigSchema(name, namespace string) (string, error)
 }
@@ -0,0 +1,476 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+// Package yang is a small YANG parser giving the agent the schema facts it
+// needs without libyang: namespaces, list keys, config/state and leaf types.
+package yang
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Container Kind = iota
+	List
+	Leaf
+	LeafList
+	RPC
+	Action
+	Notification
+	Input
+	Output
+)
+
+// Entry is a schema node of a module
+type Entry struct {
+	Name        string
+	Kind        Kind
+	Module      *Module
+	Parent      *Entry
+	Keys        []string
+	Config      bool
+	Mandatory   bool
+	Type        string
+	Enums       []string
+	Default     string
+	Description string
+	Children    []*Entry
+}
+
+// Module is a parsed YANG module with its data, RPC and notification nodes
+type Module struct {
+	Name          string
+	Namespace     string
+	Prefix        string
+	Revision      string
+	Source        string
+	Nodes         []*Entry
+	RPCs          []*Entry
+	Notifications []*Entry
+}
+
+// Schema is a set of modules, usually loaded from a directory
+type Schema struct {
+	Modules map[string]*Module
+}
+
+func (e *Entry) IsLeaf() bool {
+	return e.Kind == Leaf || e.Kind == LeafList
+}
+
+// Child returns the child schema node called name
+func (e *Entry) Child(name string) *Entry {
+	for _, c := range e.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// IsKey tells if the leaf name is a key of the list entry e
+func (e *Entry) IsKey(name string) bool {
+	for _, k := range e.Keys {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the schema path of the entry, e.g. /module:ric/xapps/xapp
+func (e *Entry) Path() string {
+	if e.Parent == nil {
+		return "/" + e.Module.Name + ":" + e.Name
+	}
+	return e.Parent.Path() + "/" + e.Name
+}
+
+// Node returns the top-level data node called name
+func (m *Module) Node(name string) *Entry {
+	for _, n := range m.Nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// RPC returns the RPC called name
+func (m *Module) RPC(name string) *Entry {
+	for _, r := range m.RPCs {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func NewSchema() *Schema {
+	return &Schema{Modules: make(map[string]*Module)}
+}
+
+// LoadDir parses every *.yang file of dir
+func LoadDir(dir string) (*Schema, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yang"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewSchema()
+	for _, f := range files {
+		m, err := ParseFile(f)
+		if err != nil {
+			return nil, err
+		}
+		s.Modules[m.Name] = m
+	}
+	return s, nil
+}
+
+// Add registers a parsed module
+func (s *Schema) Add(m *Module) {
+	s.Modules[m.Name] = m
+}
+
+// ModuleNames returns the loaded module names in sorted order
+func (s *Schema) ModuleNames() []string {
+	names := make([]string, 0, len(s.Modules))
+	for name := range s.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Namespaces maps every module name to its XML namespace
+func (s *Schema) Namespaces() map[string]string {
+	result := make(map[string]string, len(s.Modules))
+	for name, m := range s.Modules {
+		result[name] = m.Namespace
+	}
+	return result
+}
+
+// ModuleByNamespace returns the module using the XML namespace ns
+func (s *Schema) ModuleByNamespace(ns string) *Module {
+	for _, m := range s.Modules {
+		if m.Namespace == ns {
+			return m
+		}
+	}
+	return nil
+}
+
+// Find returns the schema node reached from the top-level node of module
+// through the given node names, or nil.
+func (s *Schema) Find(module string, names []string) *Entry {
+	m, ok := s.Modules[module]
+	if !ok || len(names) == 0 {
+		return nil
+	}
+
+	e := m.Node(names[0])
+	if e == nil {
+		e = m.RPC(names[0])
+	}
+	for _, name := range names[1:] {
+		if e == nil {
+			return nil
+		}
+		e = e.Child(name)
+	}
+	return e
+}
+
+func ParseFile(path string) (*Module, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// Parse builds a module from YANG source text. Groupings of the module are
+// expanded in place, choice and case statements are flattened.
+func Parse(data string) (*Module, error) {
+	toks, err := tokenize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	stmts, err := p.statements()
+	if err != nil {
+		return nil, err
+	}
+	if len(stmts) != 1 || stmts[0].keyword != "module" {
+		return nil, fmt.Errorf("expected a single module statement")
+	}
+
+	root := stmts[0]
+	m := &Module{Name: root.arg, Source: data}
+	groupings := make(map[string]*statement)
+	for _, st := range root.subs {
+		switch st.keyword {
+		case "namespace":
+			m.Namespace = st.arg
+		case "prefix":
+			m.Prefix = st.arg
+		case "revision":
+			if st.arg > m.Revision {
+				m.Revision = st.arg
+			}
+		case "grouping":
+			groupings[st.arg] = st
+		}
+	}
+
+	b := &builder{module: m, groupings: groupings}
+	for _, st := range root.subs {
+		switch st.keyword {
+		case "rpc":
+			m.RPCs = append(m.RPCs, b.entries(st, nil, true)...)
+		case "notification":
+			m.Notifications = append(m.Notifications, b.entries(st, nil, false)...)
+		default:
+			m.Nodes = append(m.Nodes, b.entries(st, nil, true)...)
+		}
+	}
+	return m, nil
+}
+
+type statement struct {
+	keyword string
+	arg     string
+	subs    []*statement
+}
+
+func (st *statement) sub(keyword string) *statement {
+	for _, s := range st.subs {
+		if s.keyword == keyword {
+			return s
+		}
+	}
+	return nil
+}
+
+type builder struct {
+	module    *Module
+	groupings map[string]*statement
+	depth     int
+}
+
+var entryKinds = map[string]Kind{
+	"container":    Container,
+	"list":         List,
+	"leaf":         Leaf,
+	"leaf-list":    LeafList,
+	"rpc":          RPC,
+	"action":       Action,
+	"notification": Notification,
+	"input":        Input,
+	"output":       Output,
+}
+
+func (b *builder) entries(st *statement, parent *Entry, config bool) []*Entry {
+	switch st.keyword {
+	case "uses":
+		name := st.arg
+		if i := strings.Index(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		g, ok := b.groupings[name]
+		if !ok || b.depth > 32 {
+			return nil
+		}
+		b.depth++
+		defer func() { b.depth-- }()
+
+		var result []*Entry
+		for _, s := range g.subs {
+			result = append(result, b.entries(s, parent, config)...)
+		}
+		return result
+	case "choice", "case":
+		var result []*Entry
+		for _, s := range st.subs {
+			result = append(result, b.entries(s, parent, config)...)
+		}
+		return result
+	}
+
+	kind, ok := entryKinds[st.keyword]
+	if !ok {
+		return nil
+	}
+
+	e := &Entry{Name: st.arg, Kind: kind, Module: b.module, Parent: parent, Config: config}
+	if kind == Input || kind == Output {
+		e.Name = st.keyword
+	}
+	if s := st.sub("config"); s != nil {
+		e.Config = config && s.arg != "false"
+	}
+	if s := st.sub("key"); s != nil {
+		e.Keys = strings.Fields(s.arg)
+	}
+	if s := st.sub("mandatory"); s != nil {
+		e.Mandatory = s.arg == "true"
+	}
+	if s := st.sub("default"); s != nil {
+		e.Default = s.arg
+	}
+	if s := st.sub("description"); s != nil {
+		e.Description = s.arg
+	}
+	if s := st.sub("type"); s != nil {
+		e.Type = s.arg
+		for _, enum := range s.subs {
+			if enum.keyword == "enum" {
+				e.Enums = append(e.Enums, enum.arg)
+			}
+		}
+	}
+
+	for _, s := range st.subs {
+		e.Children = append(e.Children, b.entries(s, e, e.Config)...)
+	}
+	return []*Entry{e}
+}
+
+type parser struct {
+	toks []string
+	pos  int
+}
+
+func (p *parser) statements() ([]*statement, error) {
+	var stmts []*statement
+	for p.pos < len(p.toks) && p.toks[p.pos] != "}" {
+		st := &statement{keyword: p.toks[p.pos]}
+		p.pos++
+
+		if p.pos < len(p.toks) && p.toks[p.pos] != ";" && p.toks[p.pos] != "{" {
+			st.arg = p.toks[p.pos]
+			p.pos++
+		}
+		if p.pos >= len(p.toks) {
+			return nil, fmt.Errorf("unexpected end of input after '%s'", st.keyword)
+		}
+
+		switch p.toks[p.pos] {
+		case ";":
+			p.pos++
+		case "{":
+			p.pos++
+			subs, err := p.statements()
+			if err != nil {
+				return nil, err
+			}
+			if p.pos >= len(p.toks) || p.toks[p.pos] != "}" {
+				return nil, fmt.Errorf("missing '}' for '%s %s'", st.keyword, st.arg)
+			}
+			p.pos++
+			st.subs = subs
+		default:
+			return nil, fmt.Errorf("unexpected token '%s' in '%s'", p.toks[p.pos], st.keyword)
+		}
+		stmts = append(stmts, st)
+	}
+	return stmts, nil
+}
+
+// tokenize splits YANG text into keywords, arguments and ; { } separators.
+// Quoted strings are unquoted and joined when concatenated with '+'.
+func tokenize(data string) ([]string, error) {
+	var toks []string
+	concat := false
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(data[i:], "//"):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(data[i:], "/*"):
+			end := strings.Index(data[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == ';' || c == '{' || c == '}':
+			toks = append(toks, string(c))
+			i++
+		case c == '+' && len(toks) > 0:
+			concat = true
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for j < len(data) && data[j] != c {
+				if c == '"' && data[j] == '\\' && j+1 < len(data) {
+					j++
+					switch data[j] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(data[j])
+					}
+				} else {
+					b.WriteByte(data[j])
+				}
+				j++
+			}
+			if j >= len(data) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			if concat {
+				toks[len(toks)-1] += b.String()
+				concat = false
+			} else {
+				toks = append(toks, b.String())
+			}
+			i = j + 1
+		default:
+			j := i
+			for j < len(data) && !strings.ContainsRune(" \t\r\n;{}", rune(data[j])) {
+				j++
+			}
+			toks = append(toks, data[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
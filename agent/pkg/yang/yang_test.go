@@ -0,0 +1,89 @@
+/*
+==================================================================================
+  Copyright (c) 2019 AT&T Intellectual Property.
+  Copyright (c) 2019 Nokia
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+==================================================================================
+*/
+
+package yang
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDir(t *testing.T) {
+	s, err := LoadDir("../../yang")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(s.Modules))
+
+	m := s.Modules["o-ran-sc-ric-xapp-desc-v1"]
+	assert.Equal(t, "urn:o-ran:ric:xapp-desc:1.0", m.Namespace)
+	assert.Equal(t, m, s.ModuleByNamespace("urn:o-ran:ric:xapp-desc:1.0"))
+	assert.Equal(t, "urn:o-ran:ric:xapp-desc:1.0", s.Namespaces()["o-ran-sc-ric-xapp-desc-v1"])
+
+	xapp := s.Find("o-ran-sc-ric-xapp-desc-v1", []string{"ric", "xapps", "xapp"})
+	assert.NotNil(t, xapp)
+	assert.Equal(t, List, xapp.Kind)
+	assert.Equal(t, []string{"name"}, xapp.Keys)
+	assert.True(t, xapp.Config)
+	assert.True(t, xapp.Child("name").Mandatory)
+	assert.NotNil(t, xapp.Child("release-name"))
+	assert.Equal(t, "/o-ran-sc-ric-xapp-desc-v1:ric/xapps/xapp", xapp.Path())
+
+	health := s.Find("o-ran-sc-ric-xapp-desc-v1", []string{"ric", "health"})
+	assert.NotNil(t, health)
+	assert.False(t, health.Config)
+	assert.Nil(t, s.Find("o-ran-sc-ric-xapp-desc-v1", []string{"ric", "none"}))
+
+	rule := s.Find("o-ran-sc-ric-xapp-access-v1", []string{"access", "rule"})
+	assert.NotNil(t, rule)
+	assert.Equal(t, []string{"name"}, rule.Keys)
+	assert.True(t, rule.Child("action").Mandatory)
+	assert.Equal(t, "*", rule.Child("namespace").Default)
+}
+
+func TestParse(t *testing.T) {
+	m, err := Parse(`module m {
+		namespace "urn:" + 'm';
+		prefix m; // comment
+		/* block
+		   comment */
+		grouping g { leaf a { type enumeration { enum x; enum y; } default "x"; } }
+		container c {
+			choice ch { case one { uses m:g; } }
+			leaf-list l { type string; config false; }
+		}
+		rpc r { input { leaf in { type string; } } }
+		notification n { leaf e { type string; } }
+	}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "urn:m", m.Namespace)
+
+	a := m.Node("c").Child("a")
+	assert.Equal(t, Leaf, a.Kind)
+	assert.Equal(t, []string{"x", "y"}, a.Enums)
+	assert.Equal(t, "x", a.Default)
+	assert.False(t, m.Node("c").Child("l").Config)
+	assert.Equal(t, Input, m.RPC("r").Children[0].Kind)
+	assert.NotNil(t, m.RPC("r").Child("input").Child("in"))
+	assert.Equal(t, 1, len(m.Notifications))
+
+	for _, bad := range []string{"module m { leaf x; ", "module m { leaf \"x; }", "submodule s { }", "module m { a b c; }"} {
+		_, err := Parse(bad)
+		assert.NotNil(t, err, bad)
+	}
+}